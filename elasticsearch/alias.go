@@ -0,0 +1,330 @@
+// Copyright 2016, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elasticsearch
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/venicegeo/pz-gocommon/gocommon"
+)
+
+// Elasticsearch won't change a field's mapping in place. Instead, clients
+// read and write through an alias, and a mapping change is made by creating
+// the next version of the backing index, copying the documents into it and
+// swapping the alias over:
+//
+//	name, err := NextBackingIndexName(current, "events")
+//	next, err := NewIndex2(url, user, pass, name, newSettings)
+//	n, err := Reindex("events", current, next)
+//
+// The old backing index is left in place, to be deleted once the new one
+// has been checked. Reindex catches up with writes made to the old index
+// while it copies, but one made as it swaps the alias is lost, so writers
+// should pause if they can.
+
+// reindexBatchSize is the number of documents copied per bulk request.
+const reindexBatchSize = 500
+
+// reindexPasses is the number of passes Reindex makes, after the first, to
+// catch up with writes to the index it copies.
+const reindexPasses = 3
+
+// BackingIndexName returns the name of a version of the index behind an
+// alias: alias_v1, alias_v2, and so on.
+func BackingIndexName(alias string, version int) string {
+	return fmt.Sprintf("%s_v%d", alias, version)
+}
+
+// ParseBackingIndexName splits a name made by BackingIndexName into the
+// alias and the version. ok is false if the name isn't of that form.
+func ParseBackingIndexName(name string) (alias string, version int, ok bool) {
+	i := strings.LastIndex(name, "_v")
+	if i <= 0 {
+		return "", 0, false
+	}
+	version, err := strconv.Atoi(name[i+2:])
+	if err != nil || version <= 0 {
+		return "", 0, false
+	}
+	return name[:i], version, true
+}
+
+// NextBackingIndexName returns the name of the version of the index behind
+// alias that follows the newest one the alias points to. If the alias
+// doesn't exist yet, that's version 1.
+func NextBackingIndexName(esi IIndex, alias string) (string, error) {
+	indices, err := esi.AliasIndices(alias)
+	if err != nil {
+		return "", err
+	}
+	latest := 0
+	for _, index := range indices {
+		a, version, ok := ParseBackingIndexName(index)
+		if ok && a == alias && version > latest {
+			latest = version
+		}
+	}
+	return BackingIndexName(alias, latest+1), nil
+}
+
+// CopyDocuments copies every document of src into dst, keeping its type
+// and id, and returns the number copied. A type dst doesn't have is given
+// src's mapping first. Percolation queries are copied last, so the fields
+// they refer to are mapped by then. A document written to src while the
+// copy runs may be missed; Reindex catches up with such writes.
+func CopyDocuments(src IIndex, dst IIndex) (int, error) {
+	c := newCopier(src, dst)
+	_, err := c.sync()
+	return c.count(), err
+}
+
+// A copier copies the documents of one index into another, remembering the
+// version of each it copied, so that a later pass need only copy those
+// written, and delete those deleted, since.
+type copier struct {
+	src, dst IIndex
+	versions map[string]map[string]int // by type, then id
+}
+
+func newCopier(src IIndex, dst IIndex) *copier {
+	return &copier{src: src, dst: dst, versions: map[string]map[string]int{}}
+}
+
+// count returns the number of documents dst holds from src.
+func (c *copier) count() int {
+	n := 0
+	for _, ids := range c.versions {
+		n += len(ids)
+	}
+	return n
+}
+
+// sync makes a pass over src, copying what has changed since the last, and
+// returns the number of documents copied or deleted.
+func (c *copier) sync() (int, error) {
+	// a scan only sees what has been refreshed
+	if err := c.src.Refresh(); err != nil {
+		return 0, err
+	}
+	types, err := c.src.GetTypes()
+	if err != nil {
+		return 0, err
+	}
+	found := map[string]bool{}
+	for _, typ := range types {
+		found[typ] = true
+	}
+	for typ := range c.versions {
+		if !found[typ] {
+			types = append(types, typ)
+		}
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if (types[i] == percolateTypeName) != (types[j] == percolateTypeName) {
+			return types[j] == percolateTypeName
+		}
+		return types[i] < types[j]
+	})
+
+	changed := 0
+	for _, typ := range types {
+		if _, ok := c.versions[typ]; !ok {
+			if err = c.copyMapping(typ); err != nil {
+				return changed, err
+			}
+			c.versions[typ] = map[string]int{}
+		}
+		n, err := c.syncType(typ)
+		changed += n
+		if err != nil {
+			return changed, err
+		}
+	}
+	return changed, nil
+}
+
+// copyMapping gives dst src's mapping of the type, if it has none.
+func (c *copier) copyMapping(typ string) error {
+	ok, err := c.dst.TypeExists(typ)
+	if err != nil || ok {
+		return err
+	}
+	mapping, err := c.src.GetMapping(typ)
+	if err != nil {
+		return err
+	}
+	jsn, err := json.Marshal(mapping)
+	if err != nil {
+		return err
+	}
+	return c.dst.SetMapping(typ, piazza.JsonString(jsn))
+}
+
+func (c *copier) syncType(typ string) (int, error) {
+	versions := c.versions[typ]
+	seen := map[string]bool{}
+	changed := 0
+
+	items := make([]*BulkItem, 0, reindexBatchSize)
+	itemVersions := make([]int, 0, reindexBatchSize)
+	flush := func() error {
+		if len(items) == 0 {
+			return nil
+		}
+		resp, err := c.dst.BulkPostData(typ, items)
+		if err != nil {
+			return err
+		}
+		if failed := resp.Failed(); len(failed) > 0 {
+			return fmt.Errorf("copying %s/%s: %d of %d documents failed, the first with: %s",
+				typ, failed[0].ID, len(failed), len(items), failed[0].Error)
+		}
+		for i, item := range items {
+			versions[item.ID] = itemVersions[i]
+		}
+		changed += len(items)
+		items = items[:0]
+		itemVersions = itemVersions[:0]
+		return nil
+	}
+
+	// of a type deleted from src, every document is gone
+	ok, err := c.src.TypeExists(typ)
+	if err != nil {
+		return changed, err
+	}
+	var it *ScanIterator
+	if ok {
+		if it, err = c.src.Scan(typ, nil); err != nil {
+			return changed, err
+		}
+		it.PageSize(reindexBatchSize)
+	}
+	for it != nil {
+		hit, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return changed, err
+		}
+		seen[hit.ID] = true
+		if version, ok := versions[hit.ID]; ok && version == hit.Version {
+			continue
+		}
+		items = append(items, &BulkItem{ID: hit.ID, Obj: hit.Source})
+		itemVersions = append(itemVersions, hit.Version)
+		if len(items) == reindexBatchSize {
+			if err = flush(); err != nil {
+				return changed, err
+			}
+		}
+	}
+	if err = flush(); err != nil {
+		return changed, err
+	}
+
+	gone := []string{}
+	for id := range versions {
+		if !seen[id] {
+			gone = append(gone, id)
+		}
+	}
+	for len(gone) > 0 {
+		batch := gone
+		if len(batch) > reindexBatchSize {
+			batch = batch[:reindexBatchSize]
+		}
+		gone = gone[len(batch):]
+		resp, err := c.dst.BulkDeleteByID(typ, batch)
+		if err != nil {
+			return changed, err
+		}
+		for _, item := range resp.Items {
+			if !item.Succeeded() && item.Status != http.StatusNotFound {
+				return changed, fmt.Errorf("deleting %s/%s: %s", typ, item.ID, item.Error)
+			}
+			delete(versions, item.ID)
+			changed++
+		}
+	}
+	return changed, nil
+}
+
+// verify checks that src and dst hold as many documents of each type as
+// were copied.
+func (c *copier) verify() error {
+	if err := c.src.Refresh(); err != nil {
+		return err
+	}
+	if err := c.dst.Refresh(); err != nil {
+		return err
+	}
+	for typ, versions := range c.versions {
+		for _, esi := range []IIndex{c.src, c.dst} {
+			ok, err := esi.TypeExists(typ)
+			if err != nil {
+				return err
+			}
+			if !ok && len(versions) == 0 {
+				continue
+			}
+			result, err := esi.SearchByJSON(typ, `{"size": 0}`)
+			if err != nil {
+				return err
+			}
+			if n := result.TotalHits(); n != int64(len(versions)) {
+				return fmt.Errorf("%s holds %d documents of type %s, not the %d copied",
+					esi.IndexName(), n, typ, len(versions))
+			}
+		}
+	}
+	return nil
+}
+
+// Reindex copies the documents of src into dst and then atomically points
+// alias at dst alone, returning the number of documents copied. Writes made
+// to src while it copies are caught up with, by passes re-copying what has
+// changed, until one finds nothing has; it fails if src is still changing
+// after reindexPasses passes, or if the indices then hold different numbers
+// of documents. A write made between the last pass and the swap is lost. If
+// Reindex fails, the alias is left as it was.
+func Reindex(alias string, src IIndex, dst IIndex) (int, error) {
+	c := newCopier(src, dst)
+	for pass := 0; ; pass++ {
+		changed, err := c.sync()
+		if err != nil {
+			return c.count(), err
+		}
+		if pass > 0 && changed == 0 {
+			break
+		}
+		if pass == reindexPasses {
+			return c.count(), fmt.Errorf("Reindex: %s is still being written to after %d passes",
+				src.IndexName(), reindexPasses)
+		}
+	}
+	if err := c.verify(); err != nil {
+		return c.count(), errors.Wrap(err, "Reindex")
+	}
+	return c.count(), dst.SwapAlias(alias)
+}
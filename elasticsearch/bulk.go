@@ -0,0 +1,243 @@
+// Copyright 2016, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elasticsearch
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/venicegeo/pz-gocommon/elasticsearch/elastic-5-api"
+)
+
+// BulkItem is a single document to be submitted as part of a bulk request.
+// If ID is empty, one is generated. If Create is set, the document is only
+// stored if none exists with the same id, as with CreateData; otherwise the
+// item fails with a 409.
+type BulkItem struct {
+	ID     string
+	Obj    interface{}
+	Create bool
+}
+
+// BulkSettings is the flush policy for an IBulkIndexer. Pending operations
+// are submitted when any one of the thresholds is reached.
+type BulkSettings struct {
+	// Actions is the number of pending operations that triggers a flush; -1 disables.
+	Actions int
+	// Size is the estimated number of bytes that triggers a flush; -1 disables.
+	Size int
+	// FlushInterval, if non-zero, flushes pending operations periodically.
+	FlushInterval time.Duration
+	// Before, if set, is called as each flush begins, and After, if set,
+	// with the results of every flush, once it is over. An indexer makes one
+	// flush at a time. Before must not use the indexer, but After may.
+	Before func()
+	After  func(*BulkResponse, error)
+}
+
+// NewBulkSettings returns the default flush policy: 1000 operations or 5 MB,
+// whichever comes first, and no periodic flushing.
+func NewBulkSettings() *BulkSettings {
+	return &BulkSettings{
+		Actions: 1000,
+		Size:    5 << 20,
+	}
+}
+
+// IBulkIndexer accumulates document operations and submits them in batches,
+// according to its BulkSettings. Close flushes anything still pending.
+// Flush and Close return the error, if any, of the request they submit.
+type IBulkIndexer interface {
+	PostData(typ string, id string, obj interface{}) error
+	DeleteByID(typ string, id string) error
+	Flush() error
+	Close() error
+}
+
+var errBulkIndexerClosed = errors.New("bulk indexer is closed")
+
+//---------------------------------------------------------------------------
+
+type bulkOp struct {
+	action string
+	typ    string
+	id     string
+	obj    interface{}
+}
+
+// bulkIndexer is the IBulkIndexer for Index and MockIndex. It applies the
+// same flush policy as elastic.BulkProcessor, but commits in the goroutine
+// whose operation triggers the flush, and calls After with the lock
+// released, so that After may use the indexer.
+type bulkIndexer struct {
+	commitOps func([]*bulkOp) (*BulkResponse, error)
+	settings  *BulkSettings
+	ops       []*bulkOp
+	size      int
+	stopC     chan struct{}
+
+	mu     sync.Mutex
+	closed bool
+}
+
+func newBulkIndexer(commit func([]*bulkOp) (*BulkResponse, error), settings *BulkSettings) *bulkIndexer {
+	if settings == nil {
+		settings = NewBulkSettings()
+	}
+
+	b := &bulkIndexer{commitOps: commit, settings: settings}
+
+	if settings.FlushInterval > 0 {
+		b.stopC = make(chan struct{})
+		go b.flusher(settings.FlushInterval)
+	}
+
+	return b
+}
+
+// indexBulkCommit submits operations to the index in a single bulk request.
+func indexBulkCommit(esi *Index) func([]*bulkOp) (*BulkResponse, error) {
+	return func(ops []*bulkOp) (*BulkResponse, error) {
+		ctx, cancel := esi.writeContext()
+		defer cancel()
+
+		for _, op := range ops {
+			if op.action != "delete" {
+				if err := esi.requireType(op.typ); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		bulk := esi.lib.Bulk().Index(esi.index)
+		for _, op := range ops {
+			switch op.action {
+			case "delete":
+				bulk.Add(elastic.NewBulkDeleteRequest().Type(op.typ).Id(op.id))
+			default:
+				bulk.Add(elastic.NewBulkIndexRequest().Type(op.typ).Id(op.id).Doc(op.obj))
+			}
+		}
+
+		bulkResponse, err := bulk.Do(ctx)
+		if err != nil {
+			return nil, esi.typedError(err, nil)
+		}
+		return NewBulkResponse(bulkResponse), nil
+	}
+}
+
+func (b *bulkIndexer) flusher(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			_ = b.Flush()
+		case <-b.stopC:
+			return
+		}
+	}
+}
+
+func (b *bulkIndexer) add(op *bulkOp) error {
+	size := len(op.typ) + len(op.id)
+	if op.obj != nil {
+		byts, err := json.Marshal(op.obj)
+		if err != nil {
+			return err
+		}
+		size += len(byts)
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return errBulkIndexerClosed
+	}
+	b.ops = append(b.ops, op)
+	b.size += size
+	report := func() {}
+	if (b.settings.Actions >= 0 && len(b.ops) >= b.settings.Actions) ||
+		(b.settings.Size >= 0 && b.size >= b.settings.Size) {
+		report, _ = b.commit()
+	}
+	b.mu.Unlock()
+
+	report()
+	return nil
+}
+
+// commit submits the pending operations, and must be called with the lock
+// held. It returns the request's error, and a func passing the outcome to
+// After, to be called once the lock is released, so that After may use the
+// indexer.
+func (b *bulkIndexer) commit() (func(), error) {
+	if len(b.ops) == 0 {
+		return func() {}, nil
+	}
+	if b.settings.Before != nil {
+		b.settings.Before()
+	}
+	resp, err := b.commitOps(b.ops)
+	b.ops = nil
+	b.size = 0
+	after := b.settings.After
+	if after == nil {
+		return func() {}, err
+	}
+	return func() { after(resp, err) }, err
+}
+
+func (b *bulkIndexer) PostData(typ string, id string, obj interface{}) error {
+	return b.add(&bulkOp{action: "index", typ: typ, id: id, obj: obj})
+}
+
+func (b *bulkIndexer) DeleteByID(typ string, id string) error {
+	return b.add(&bulkOp{action: "delete", typ: typ, id: id})
+}
+
+func (b *bulkIndexer) Flush() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return errBulkIndexerClosed
+	}
+	report, err := b.commit()
+	b.mu.Unlock()
+
+	report()
+	return err
+}
+
+func (b *bulkIndexer) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	if b.stopC != nil {
+		close(b.stopC)
+	}
+	report, err := b.commit()
+	b.mu.Unlock()
+
+	report()
+	return err
+}
@@ -0,0 +1,110 @@
+// Copyright 2016, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elasticsearch
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/venicegeo/pz-gocommon/elasticsearch/elastic-5-api"
+	"golang.org/x/net/context"
+)
+
+// ByQueryPollInterval is how often ByQueryTask.Wait asks Elasticsearch how a
+// task is getting on.
+const ByQueryPollInterval = time.Second
+
+// ByQueryStatus reports the progress of a DeleteByQuery or UpdateByQuery.
+type ByQueryStatus struct {
+	// Completed is set once the task has finished, successfully or not.
+	Completed bool
+
+	// Total is the number of documents the query matched.
+	Total   int64
+	Deleted int64
+	Updated int64
+	// Batches is the number of scroll pages processed so far.
+	Batches int64
+	// VersionConflicts counts the documents changed by someone else while
+	// the task ran, which it left alone.
+	VersionConflicts int64
+	// Noops counts the documents a script chose not to change.
+	Noops int64
+
+	// Failures describe the documents that couldn't be changed.
+	Failures []string
+}
+
+func newByQueryStatus(resp *elastic.BulkIndexByScrollResponse, completed bool) *ByQueryStatus {
+	status := &ByQueryStatus{
+		Completed:        completed,
+		Total:            resp.Total,
+		Deleted:          resp.Deleted,
+		Updated:          resp.Updated,
+		Batches:          resp.Batches,
+		VersionConflicts: resp.VersionConflicts,
+		Noops:            resp.Noops,
+		Failures:         []string{},
+	}
+	for _, f := range resp.Failures {
+		reason := ""
+		switch {
+		case f.Cause != nil:
+			reason = f.Cause.Reason
+		case f.Reason != nil:
+			reason = f.Reason.Reason
+		}
+		status.Failures = append(status.Failures, fmt.Sprintf("%s/%s/%s: %s", f.Index, f.Type, f.Id, reason))
+	}
+	return status
+}
+
+// Done returns the number of documents dealt with so far, one way or
+// another.
+func (s *ByQueryStatus) Done() int64 {
+	return s.Deleted + s.Updated + s.VersionConflicts + s.Noops
+}
+
+// ByQueryTask follows a DeleteByQuery or UpdateByQuery, which Elasticsearch
+// runs in the background.
+type ByQueryTask struct {
+	// ID is the Elasticsearch task id. Under mocking it is empty, and the
+	// task has completed by the time it is returned.
+	ID string
+
+	ctx    context.Context
+	status func() (*ByQueryStatus, error)
+}
+
+// Status returns the progress of the task.
+func (t *ByQueryTask) Status() (*ByQueryStatus, error) {
+	return t.status()
+}
+
+// Wait blocks until the task has completed, or the index's context is done,
+// and returns its final status.
+func (t *ByQueryTask) Wait() (*ByQueryStatus, error) {
+	for {
+		status, err := t.status()
+		if err != nil || status.Completed {
+			return status, err
+		}
+		select {
+		case <-t.ctx.Done():
+			return status, typedError(t.ctx.Err())
+		case <-time.After(ByQueryPollInterval):
+		}
+	}
+}
@@ -16,11 +16,13 @@ package elasticsearch
 
 import (
 	"errors"
-	"fmt"
-	"strings"
+	"os"
 	"time"
 
 	"github.com/venicegeo/pz-gocommon/gocommon"
+
+	"github.com/venicegeo/pz-gocommon/elasticsearch/elastic-5-api"
+	"golang.org/x/net/context"
 )
 
 // MappingElementTypeName is just an alias for a string.
@@ -68,10 +70,21 @@ const (
 	MappingElementTypeCompletionA MappingElementTypeName = "[completion]"
 )
 
+// Percolation queries are stored as documents of a dedicated type, in a field
+// of ES5's "percolator" type. (ES5 does not allow type names beginning with '.'.)
+const (
+	percolateTypeName  = "percolate"
+	percolateFieldName = "query"
+)
+
 // IIndex is an interface to Elasticsearch Index methods
 type IIndex interface {
 	GetVersion() string
 
+	// WithContext returns a view of the index, sharing its data, whose
+	// operations are abandoned when ctx is done.
+	WithContext(ctx context.Context) IIndex
+
 	IndexName() string
 	IndexExists() (bool, error)
 	TypeExists(typ string) (bool, error)
@@ -81,22 +94,57 @@ type IIndex interface {
 	Delete() error
 	PostData(typ string, id string, obj interface{}) (*IndexResponse, error)
 	PutData(typ string, id string, obj interface{}) (*IndexResponse, error)
+	CreateData(typ string, id string, obj interface{}) (*IndexResponse, error)
+	PutDataIfVersion(typ string, id string, obj interface{}, version int) (*IndexResponse, error)
+	UpdateData(typ string, id string, update *Update) (*IndexResponse, error)
 	GetByID(typ string, id string) (*GetResult, error)
+	GetByIDs(typ string, ids []string) ([]*GetResult, error)
 	DeleteByID(typ string, id string) (*DeleteResponse, error)
 	DeleteByIDWait(typ string, id string) (*DeleteResponse, error)
+	Refresh() error
+	BulkPostData(typ string, items []*BulkItem) (*BulkResponse, error)
+	BulkDeleteByID(typ string, ids []string) (*BulkResponse, error)
+	DeleteByQuery(typ string, query elastic.Query) (*ByQueryTask, error)
+	// UpdateByQuery's script may do anything painless can, but a MockIndex,
+	// or FileIndex, only runs scripts which set fields, to parameters or
+	// literals, and remove them; see MockIndex.UpdateByQuery.
+	UpdateByQuery(typ string, query elastic.Query, script *elastic.Script) (*ByQueryTask, error)
+	NewBulkIndexer(settings *BulkSettings) (IBulkIndexer, error)
 	FilterByMatchAll(typ string, format *piazza.JsonPagination) (*SearchResult, error)
 	GetAllElements(typ string) (*SearchResult, error)
+	Scan(typ string, query elastic.Query) (*ScanIterator, error)
 	FilterByTermQuery(typ string, name string, value interface{}, format *piazza.JsonPagination) (*SearchResult, error)
 	FilterByMatchQuery(typ string, name string, value interface{}, format *piazza.JsonPagination) (*SearchResult, error)
+	FilterByQuery(typ string, query elastic.Query, format *piazza.JsonPagination) (*SearchResult, error)
+	FilterByBoundingBox(typ string, field string, topLeft *elastic.GeoPoint, bottomRight *elastic.GeoPoint, format *piazza.JsonPagination) (*SearchResult, error)
+	FilterByDistance(typ string, field string, center *elastic.GeoPoint, distance string, format *piazza.JsonPagination) (*SearchResult, error)
 	SearchByJSON(typ string, jsn string) (*SearchResult, error)
+	MultiSearch(requests []*SearchRequest) ([]*MultiSearchItem, error)
+	Aggregate(typ string, query elastic.Query, aggs map[string]elastic.Aggregation) (*SearchResult, error)
+	SearchWithHighlight(typ string, query elastic.Query, highlight *elastic.Highlight, format *piazza.JsonPagination) (*SearchResult, error)
+	Suggest(typ string, suggester elastic.Suggester) ([]*Suggestion, error)
 	SetMapping(typename string, jsn piazza.JsonString) error
 	GetTypes() ([]string, error)
 	GetMapping(typ string) (interface{}, error)
+	AddAlias(alias string) error
+	RemoveAlias(alias string) error
+	SwapAlias(alias string) error
+	GetAliases() ([]string, error)
+	AliasIndices(alias string) ([]string, error)
+	GetSettings() (*IndexSettings, error)
+	UpdateSettings(settings *IndexSettings) error
+	PutTemplate(name string, template *IndexTemplate) error
+	DeleteTemplate(name string) error
+	AddPercolationQuery(id string, query piazza.JsonString) (*IndexResponse, error)
+	DeletePercolationQuery(id string) (*DeleteResponse, error)
+	AddPercolationDocument(typ string, doc interface{}) (*PercolateResponse, error)
 
 	DirectAccess(verb string, endpoint string, input interface{}, output interface{}) error
 }
 
-// NewIndexInterface constructs an IIndex
+// NewIndexInterface constructs an IIndex: a MockIndex if mocking, a FileIndex
+// if the environment variable named by IndexDirEnv is set, and otherwise an
+// Index. Unless mocking, the index is created with the settings if need be.
 func NewIndexInterface(sys *piazza.SystemConfig, index string, settings string, mocking bool) (IIndex, error) {
 	var esi IIndex
 	var err error
@@ -106,6 +154,10 @@ func NewIndexInterface(sys *piazza.SystemConfig, index string, settings string,
 		return esi, nil
 	}
 
+	if dir := os.Getenv(IndexDirEnv); dir != "" {
+		return newFileIndexInterface(dir, index, settings)
+	}
+
 	esi, err = NewIndex(sys, index, settings)
 	if err != nil {
 		return nil, err
@@ -118,28 +170,32 @@ func NewIndexInterface(sys *piazza.SystemConfig, index string, settings string,
 	return esi, nil
 }
 
-// ConstructMappingSchema takes a map of parameter names to datatypes and
-// returns the corresponding ES DSL for it.
-func ConstructMappingSchema(name string, items map[string]MappingElementTypeName) (piazza.JsonString, error) {
-
-	const template string = `{
-		"%s":{
-			"properties":{
-				%s
-			}
+func newFileIndexInterface(dir string, index string, settings string) (IIndex, error) {
+	esi, err := NewFileIndex(dir, index)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := esi.IndexExists()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if err = esi.Create(settings); err != nil {
+			return nil, err
 		}
-	}`
+	}
+	return esi, nil
+}
 
-	stuff := make([]string, len(items))
-	i := 0
+// ConstructMappingSchema takes a map of parameter names to datatypes and
+// returns the corresponding ES DSL for it. It is shorthand for a Mapping of
+// simple fields; see NewMapping for anything more.
+func ConstructMappingSchema(name string, items map[string]MappingElementTypeName) (piazza.JsonString, error) {
+	mapping := NewMapping(name)
 	for k, v := range items {
-		stuff[i] = fmt.Sprintf(`"%s": {"type":"%s"}`, k, v)
-		i++
+		mapping.Property(k, NewProperty(v))
 	}
-
-	json := fmt.Sprintf(template, name, strings.Join(stuff, ","))
-
-	return piazza.JsonString(json), nil
+	return mapping.JSON()
 }
 
 // NewQueryFormat constructs a QueryFormat
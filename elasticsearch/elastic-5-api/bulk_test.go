@@ -0,0 +1,162 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+type bulkTestDoc struct {
+	User    string `json:"user"`
+	Message string `json:"message"`
+}
+
+func TestBulkIndexRequestSerialization(t *testing.T) {
+	tests := []struct {
+		Request  BulkableRequest
+		Expected []string
+	}{
+		// #0
+		{
+			Request: NewBulkIndexRequest().Index("index1").Type("tweet").Id("1").
+				Doc(bulkTestDoc{User: "olivere", Message: "Welcome to Golang and Elasticsearch."}),
+			Expected: []string{
+				`{"index":{"_id":"1","_index":"index1","_type":"tweet"}}`,
+				`{"user":"olivere","message":"Welcome to Golang and Elasticsearch."}`,
+			},
+		},
+		// #1
+		{
+			Request: NewBulkIndexRequest().OpType("create").Index("index1").Type("tweet").Id("1").
+				Doc(bulkTestDoc{User: "olivere", Message: "Welcome to Golang and Elasticsearch."}),
+			Expected: []string{
+				`{"create":{"_id":"1","_index":"index1","_type":"tweet"}}`,
+				`{"user":"olivere","message":"Welcome to Golang and Elasticsearch."}`,
+			},
+		},
+		// #2
+		{
+			Request: NewBulkIndexRequest().Index("index1").Type("tweet").Id("1").Version(3).
+				Doc(json.RawMessage(`{"a":1}`)),
+			Expected: []string{
+				`{"index":{"_id":"1","_index":"index1","_type":"tweet","_version":3}}`,
+				`{"a":1}`,
+			},
+		},
+		// #3
+		{
+			Request: NewBulkDeleteRequest().Index("index1").Type("tweet").Id("1"),
+			Expected: []string{
+				`{"delete":{"_id":"1","_index":"index1","_type":"tweet"}}`,
+			},
+		},
+	}
+
+	for i, test := range tests {
+		lines, err := test.Request.Source()
+		if err != nil {
+			t.Fatalf("case #%d: expected no error, got: %v", i, err)
+		}
+		if lines == nil {
+			t.Fatalf("case #%d: expected lines, got nil", i)
+		}
+		if len(lines) != len(test.Expected) {
+			t.Fatalf("case #%d: expected %d lines, got %d", i, len(test.Expected), len(lines))
+		}
+		for j, line := range lines {
+			if line != test.Expected[j] {
+				t.Errorf("case #%d: expected line #%d to be %s, got: %s", i, j, test.Expected[j], line)
+			}
+		}
+	}
+}
+
+func TestBulkRequestsSerialization(t *testing.T) {
+	tweet1 := bulkTestDoc{User: "olivere", Message: "Welcome to Golang and Elasticsearch."}
+	tweet2 := bulkTestDoc{User: "sandrae", Message: "Dancing all night long. Yeah."}
+
+	index1Req := NewBulkIndexRequest().Index(testIndexName).Type("tweet").Id("1").Doc(tweet1)
+	index2Req := NewBulkIndexRequest().OpType("create").Index(testIndexName).Type("tweet").Id("2").Doc(tweet2)
+	delete1Req := NewBulkDeleteRequest().Index(testIndexName).Type("tweet").Id("1")
+
+	bulkRequest := NewBulkService(nil)
+	bulkRequest = bulkRequest.Add(index1Req)
+	bulkRequest = bulkRequest.Add(index2Req)
+	bulkRequest = bulkRequest.Add(delete1Req)
+
+	if bulkRequest.NumberOfActions() != 3 {
+		t.Errorf("expected bulkRequest.NumberOfActions %d; got %d", 3, bulkRequest.NumberOfActions())
+	}
+
+	expected := `{"index":{"_id":"1","_index":"` + testIndexName + `","_type":"tweet"}}
+{"user":"olivere","message":"Welcome to Golang and Elasticsearch."}
+{"create":{"_id":"2","_index":"` + testIndexName + `","_type":"tweet"}}
+{"user":"sandrae","message":"Dancing all night long. Yeah."}
+{"delete":{"_id":"1","_index":"` + testIndexName + `","_type":"tweet"}}
+`
+	got, err := bulkRequest.bodyAsString()
+	if err != nil {
+		t.Fatalf("expected no error, got: %v", err)
+	}
+	if got != expected {
+		t.Errorf("expected\n%s\ngot:\n%s", expected, got)
+	}
+
+	if size := bulkRequest.EstimatedSizeInBytes(); size != int64(len(expected)) {
+		t.Errorf("expected estimated size %d; got %d", len(expected), size)
+	}
+
+	bulkRequest.reset()
+	if bulkRequest.NumberOfActions() != 0 {
+		t.Errorf("expected bulkRequest.NumberOfActions %d; got %d", 0, bulkRequest.NumberOfActions())
+	}
+	if bulkRequest.EstimatedSizeInBytes() != 0 {
+		t.Errorf("expected bulkRequest.EstimatedSizeInBytes %d; got %d", 0, bulkRequest.EstimatedSizeInBytes())
+	}
+}
+
+func TestBulkResponseItems(t *testing.T) {
+	body := `{
+		"took":3,
+		"errors":true,
+		"items":[
+			{"index":{"_index":"index1","_type":"tweet","_id":"1","_version":1,"status":201}},
+			{"create":{"_index":"index1","_type":"tweet","_id":"2","status":409,
+				"error":{"type":"version_conflict_engine_exception","reason":"document already exists"}}},
+			{"delete":{"_index":"index1","_type":"tweet","_id":"1","_version":2,"status":200,"found":true}}
+		]
+	}`
+
+	var res BulkResponse
+	if err := json.Unmarshal([]byte(body), &res); err != nil {
+		t.Fatal(err)
+	}
+	if !res.Errors {
+		t.Errorf("expected errors to be true")
+	}
+	if got := len(res.Indexed()); got != 1 {
+		t.Errorf("expected 1 indexed item; got %d", got)
+	}
+	if got := len(res.Created()); got != 1 {
+		t.Errorf("expected 1 created item; got %d", got)
+	}
+	if got := len(res.Deleted()); got != 1 {
+		t.Errorf("expected 1 deleted item; got %d", got)
+	}
+	if got := len(res.ById("1")); got != 2 {
+		t.Errorf("expected 2 items with id 1; got %d", got)
+	}
+	failed := res.Failed()
+	if len(failed) != 1 {
+		t.Fatalf("expected 1 failed item; got %d", len(failed))
+	}
+	if failed[0].Error == nil || failed[0].Error.Type != "version_conflict_engine_exception" {
+		t.Errorf("expected version conflict error; got %v", failed[0].Error)
+	}
+	if got := len(res.Succeeded()); got != 2 {
+		t.Errorf("expected 2 succeeded items; got %d", got)
+	}
+}
@@ -1182,9 +1182,10 @@ func (c *Client) PerformRequest(ctx context.Context, method, path string, params
 
 		// Get response
 		res, err := c.c.Do((*http.Request)(req).WithContext(ctx))
-		if err == context.Canceled || err == context.DeadlineExceeded {
-			// Proceed, but don't mark the node as dead
-			return nil, err
+		if err != nil && ctx.Err() != nil {
+			// Proceed, but don't mark the node as dead. The HTTP client
+			// wraps the context's error in a *url.Error.
+			return nil, ctx.Err()
 		}
 		if err != nil {
 			n++
@@ -1261,6 +1262,31 @@ func (c *Client) Update() *UpdateService {
 	return NewUpdateService(c)
 }
 
+// DeleteByQuery deletes documents that match a query.
+func (c *Client) DeleteByQuery(indices ...string) *DeleteByQueryService {
+	return NewDeleteByQueryService(c).Index(indices...)
+}
+
+// UpdateByQuery updates documents that match a query.
+func (c *Client) UpdateByQuery(indices ...string) *UpdateByQueryService {
+	return NewUpdateByQueryService(c).Index(indices...)
+}
+
+// Mget retrieves multiple documents in one roundtrip.
+func (c *Client) Mget() *MgetService {
+	return NewMgetService(c)
+}
+
+// Bulk is the entry point to mass insert/update/delete documents.
+func (c *Client) Bulk() *BulkService {
+	return NewBulkService(c)
+}
+
+// BulkProcessor allows setting up a concurrent processor of bulk requests.
+func (c *Client) BulkProcessor() *BulkProcessorService {
+	return NewBulkProcessorService(c)
+}
+
 // -- Search APIs --
 
 // Search is the entry point for searches.
@@ -1268,6 +1294,11 @@ func (c *Client) Search(indices ...string) *SearchService {
 	return NewSearchService(c).Index(indices...)
 }
 
+// MultiSearch is the entry point for multi searches.
+func (c *Client) MultiSearch() *MultiSearchService {
+	return NewMultiSearchService(c)
+}
+
 // Count documents.
 func (c *Client) Count(indices ...string) *CountService {
 	return NewCountService(c).Index(indices...)
@@ -1326,6 +1357,21 @@ func (c *Client) IndexGetSettings(indices ...string) *IndicesGetSettingsService
 	return NewIndicesGetSettingsService(c).Index(indices...)
 }
 
+// IndexPutSettings sets settings for all, one or more indices.
+func (c *Client) IndexPutSettings(indices ...string) *IndicesPutSettingsService {
+	return NewIndicesPutSettingsService(c).Index(indices...)
+}
+
+// IndexPutTemplate creates or updates an index template.
+func (c *Client) IndexPutTemplate(name string) *IndicesPutTemplateService {
+	return NewIndicesPutTemplateService(c).Name(name)
+}
+
+// IndexDeleteTemplate deletes an index template.
+func (c *Client) IndexDeleteTemplate(name string) *IndicesDeleteTemplateService {
+	return NewIndicesDeleteTemplateService(c).Name(name)
+}
+
 // Refresh asks Elasticsearch to refresh one or more indices.
 func (c *Client) Refresh(indices ...string) *RefreshService {
 	return NewRefreshService(c).Index(indices...)
@@ -1342,6 +1388,11 @@ func (c *Client) Alias() *AliasService {
 	return NewAliasService(c)
 }
 
+// Aliases returns aliases by index name(s).
+func (c *Client) Aliases() *AliasesService {
+	return NewAliasesService(c)
+}
+
 // GetMapping gets a mapping.
 func (c *Client) GetMapping() *IndicesGetMappingService {
 	return NewIndicesGetMappingService(c)
@@ -1381,6 +1432,11 @@ func (c *Client) NodesInfo() *NodesInfoService {
 	return NewNodesInfoService(c)
 }
 
+// TasksGetTask retrieves a task running in the cluster.
+func (c *Client) TasksGetTask() *TasksGetTaskService {
+	return NewTasksGetTaskService(c)
+}
+
 // TODO Pending cluster tasks
 // TODO Cluster Reroute
 // TODO Cluster Update Settings
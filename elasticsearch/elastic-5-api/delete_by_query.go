@@ -0,0 +1,289 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/venicegeo/pz-gocommon/elasticsearch/elastic-5-api/uritemplates"
+)
+
+// DeleteByQueryService deletes documents that match a query.
+// See https://www.elastic.co/guide/en/elasticsearch/reference/5.2/docs-delete-by-query.html.
+type DeleteByQueryService struct {
+	client            *Client
+	pretty            bool
+	index             []string
+	typ               []string
+	query             Query
+	conflicts         string
+	refresh           string
+	requestsPerSecond *int
+	scrollSize        *int
+	timeout           string
+	waitForCompletion *bool
+}
+
+// NewDeleteByQueryService creates a new DeleteByQueryService.
+// You typically use the client's DeleteByQuery to get a reference to
+// the service.
+func NewDeleteByQueryService(client *Client) *DeleteByQueryService {
+	return &DeleteByQueryService{
+		client: client,
+	}
+}
+
+// Index sets the indices on which to perform the delete operation.
+func (s *DeleteByQueryService) Index(index ...string) *DeleteByQueryService {
+	s.index = append(s.index, index...)
+	return s
+}
+
+// Type limits the delete operation to the given types.
+func (s *DeleteByQueryService) Type(typ ...string) *DeleteByQueryService {
+	s.typ = append(s.typ, typ...)
+	return s
+}
+
+// Query sets the query that selects the documents to delete.
+func (s *DeleteByQueryService) Query(query Query) *DeleteByQueryService {
+	s.query = query
+	return s
+}
+
+// Conflicts indicates what to do when the process detects version conflicts.
+// Possible values are "proceed" and "abort".
+func (s *DeleteByQueryService) Conflicts(conflicts string) *DeleteByQueryService {
+	s.conflicts = conflicts
+	return s
+}
+
+// AbortOnVersionConflict aborts the request on version conflicts.
+// It is an alias to setting Conflicts("abort").
+func (s *DeleteByQueryService) AbortOnVersionConflict() *DeleteByQueryService {
+	s.conflicts = "abort"
+	return s
+}
+
+// ProceedOnVersionConflict proceeds with the request on version conflicts,
+// counting them instead. It is an alias to setting Conflicts("proceed").
+func (s *DeleteByQueryService) ProceedOnVersionConflict() *DeleteByQueryService {
+	s.conflicts = "proceed"
+	return s
+}
+
+// Refresh indicates whether the affected indices should be refreshed.
+func (s *DeleteByQueryService) Refresh(refresh string) *DeleteByQueryService {
+	s.refresh = refresh
+	return s
+}
+
+// RequestsPerSecond sets the throttle on this request in sub-requests per
+// second. -1 means no throttle.
+func (s *DeleteByQueryService) RequestsPerSecond(requestsPerSecond int) *DeleteByQueryService {
+	s.requestsPerSecond = &requestsPerSecond
+	return s
+}
+
+// ScrollSize is the size on the scroll request powering the operation.
+func (s *DeleteByQueryService) ScrollSize(scrollSize int) *DeleteByQueryService {
+	s.scrollSize = &scrollSize
+	return s
+}
+
+// Timeout is the time each individual bulk request should wait for shards
+// that are unavailable.
+func (s *DeleteByQueryService) Timeout(timeout string) *DeleteByQueryService {
+	s.timeout = timeout
+	return s
+}
+
+// WaitForCompletion indicates whether the request should block until the
+// operation is complete. See DoAsync for the alternative.
+func (s *DeleteByQueryService) WaitForCompletion(waitForCompletion bool) *DeleteByQueryService {
+	s.waitForCompletion = &waitForCompletion
+	return s
+}
+
+// Pretty indicates that the JSON response be indented and human readable.
+func (s *DeleteByQueryService) Pretty(pretty bool) *DeleteByQueryService {
+	s.pretty = pretty
+	return s
+}
+
+// buildURL builds the URL for the operation.
+func (s *DeleteByQueryService) buildURL() (string, url.Values, error) {
+	// Build URL
+	var err error
+	var path string
+
+	if len(s.typ) > 0 {
+		path, err = uritemplates.Expand("/{index}/{type}/_delete_by_query", map[string]string{
+			"index": strings.Join(s.index, ","),
+			"type":  strings.Join(s.typ, ","),
+		})
+	} else {
+		path, err = uritemplates.Expand("/{index}/_delete_by_query", map[string]string{
+			"index": strings.Join(s.index, ","),
+		})
+	}
+	if err != nil {
+		return "", url.Values{}, err
+	}
+
+	// Add query string parameters
+	params := url.Values{}
+	if s.pretty {
+		params.Set("pretty", "1")
+	}
+	if s.conflicts != "" {
+		params.Set("conflicts", s.conflicts)
+	}
+	if s.refresh != "" {
+		params.Set("refresh", s.refresh)
+	}
+	if s.requestsPerSecond != nil {
+		params.Set("requests_per_second", fmt.Sprintf("%v", *s.requestsPerSecond))
+	}
+	if s.scrollSize != nil {
+		params.Set("scroll_size", fmt.Sprintf("%d", *s.scrollSize))
+	}
+	if s.timeout != "" {
+		params.Set("timeout", s.timeout)
+	}
+	if s.waitForCompletion != nil {
+		params.Set("wait_for_completion", fmt.Sprintf("%v", *s.waitForCompletion))
+	}
+	return path, params, nil
+}
+
+// Validate checks if the operation is valid.
+func (s *DeleteByQueryService) Validate() error {
+	var invalid []string
+	if len(s.index) == 0 {
+		invalid = append(invalid, "Index")
+	}
+	if len(invalid) > 0 {
+		return fmt.Errorf("missing required fields: %v", invalid)
+	}
+	return nil
+}
+
+// body returns the request body, which selects all documents if no query
+// is given.
+func (s *DeleteByQueryService) body() (interface{}, error) {
+	query := s.query
+	if query == nil {
+		query = NewMatchAllQuery()
+	}
+	src, err := query.Source()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"query": src}, nil
+}
+
+// Do executes the delete-by-query operation, waiting for it to complete
+// unless WaitForCompletion(false) is set.
+func (s *DeleteByQueryService) Do(ctx context.Context) (*BulkIndexByScrollResponse, error) {
+	res, err := s.perform(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return operation response
+	ret := new(BulkIndexByScrollResponse)
+	if err := s.client.decoder.Decode(res.Body, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// DoAsync starts the delete-by-query operation as a task and returns its
+// id, to be followed with the tasks API.
+func (s *DeleteByQueryService) DoAsync(ctx context.Context) (*StartTaskResult, error) {
+	s.WaitForCompletion(false)
+	res, err := s.perform(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return operation response
+	ret := new(StartTaskResult)
+	if err := s.client.decoder.Decode(res.Body, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (s *DeleteByQueryService) perform(ctx context.Context) (*Response, error) {
+	// Check pre-conditions
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Get URL for request
+	path, params, err := s.buildURL()
+	if err != nil {
+		return nil, err
+	}
+
+	// Setup HTTP request body
+	body, err := s.body()
+	if err != nil {
+		return nil, err
+	}
+
+	// Get HTTP response
+	return s.client.PerformRequest(ctx, "POST", path, params, body)
+}
+
+// BulkIndexByScrollResponse is the outcome of executing Do with
+// DeleteByQueryService and UpdateByQueryService. Tasks running either
+// report their progress in the same form.
+type BulkIndexByScrollResponse struct {
+	Took             int64 `json:"took"`
+	TimedOut         bool  `json:"timed_out"`
+	Total            int64 `json:"total"`
+	Updated          int64 `json:"updated"`
+	Created          int64 `json:"created"`
+	Deleted          int64 `json:"deleted"`
+	Batches          int64 `json:"batches"`
+	VersionConflicts int64 `json:"version_conflicts"`
+	Noops            int64 `json:"noops"`
+	Retries          struct {
+		Bulk   int64 `json:"bulk"`
+		Search int64 `json:"search"`
+	} `json:"retries"`
+	Throttled            string                      `json:"throttled"`
+	ThrottledMillis      int64                       `json:"throttled_millis"`
+	RequestsPerSecond    float64                     `json:"requests_per_second"`
+	Canceled             string                      `json:"canceled"`
+	ThrottledUntil       string                      `json:"throttled_until"`
+	ThrottledUntilMillis int64                       `json:"throttled_until_millis"`
+	Failures             []*BulkIndexByScrollFailure `json:"failures"`
+}
+
+// BulkIndexByScrollFailure describes a document that could not be deleted
+// or updated, or a search that failed.
+type BulkIndexByScrollFailure struct {
+	Index  string        `json:"index,omitempty"`
+	Type   string        `json:"type,omitempty"`
+	Id     string        `json:"id,omitempty"`
+	Status int           `json:"status,omitempty"`
+	Shard  *int          `json:"shard,omitempty"`
+	Node   string        `json:"node,omitempty"`
+	Cause  *ErrorDetails `json:"cause,omitempty"`
+	Reason *ErrorDetails `json:"reason,omitempty"`
+}
+
+// StartTaskResult is the response of a request started with
+// wait_for_completion=false.
+type StartTaskResult struct {
+	TaskId string `json:"task"`
+}
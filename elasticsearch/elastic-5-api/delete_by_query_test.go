@@ -0,0 +1,120 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDeleteByQueryBuildURL(t *testing.T) {
+	tests := []struct {
+		Indices  []string
+		Types    []string
+		Expected string
+	}{
+		{
+			[]string{"index1"},
+			nil,
+			"/index1/_delete_by_query",
+		},
+		{
+			[]string{"index1", "index2"},
+			nil,
+			"/index1%2Cindex2/_delete_by_query",
+		},
+		{
+			[]string{"index1"},
+			[]string{"type1", "type2"},
+			"/index1/type1%2Ctype2/_delete_by_query",
+		},
+	}
+
+	for i, test := range tests {
+		path, _, err := NewDeleteByQueryService(nil).Index(test.Indices...).Type(test.Types...).buildURL()
+		if err != nil {
+			t.Errorf("case #%d: %v", i+1, err)
+			continue
+		}
+		if path != test.Expected {
+			t.Errorf("case #%d: expected %q; got: %q", i+1, test.Expected, path)
+		}
+	}
+}
+
+func TestDeleteByQueryParams(t *testing.T) {
+	_, params, err := NewDeleteByQueryService(nil).Index("index1").
+		ProceedOnVersionConflict().
+		ScrollSize(500).
+		WaitForCompletion(false).
+		buildURL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := params.Encode()
+	expected := "conflicts=proceed&scroll_size=500&wait_for_completion=false"
+	if got != expected {
+		t.Errorf("expected %q; got: %q", expected, got)
+	}
+}
+
+func TestDeleteByQueryBody(t *testing.T) {
+	tests := []struct {
+		Query    Query
+		Expected string
+	}{
+		{
+			nil,
+			`{"query":{"match_all":{}}}`,
+		},
+		{
+			NewTermQuery("user", "olivere"),
+			`{"query":{"term":{"user":"olivere"}}}`,
+		},
+	}
+
+	for i, test := range tests {
+		body, err := NewDeleteByQueryService(nil).Index("index1").Query(test.Query).body()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("marshaling to JSON failed: %v", err)
+		}
+		if got := string(data); got != test.Expected {
+			t.Errorf("case #%d: expected\n%s\ngot:\n%s", i+1, test.Expected, got)
+		}
+	}
+}
+
+func TestDeleteByQueryValidate(t *testing.T) {
+	if err := NewDeleteByQueryService(nil).Validate(); err == nil {
+		t.Errorf("expected an error without an index")
+	}
+}
+
+func TestBulkIndexByScrollResponse(t *testing.T) {
+	body := `{
+		"took": 147, "timed_out": false, "total": 3, "deleted": 2, "batches": 1,
+		"version_conflicts": 1, "noops": 0, "retries": {"bulk": 0, "search": 0},
+		"throttled_millis": 0, "requests_per_second": -1.0, "throttled_until_millis": 0,
+		"failures": [{"index": "index1", "type": "type1", "id": "1", "status": 409,
+			"cause": {"type": "version_conflict_engine_exception", "reason": "[type1][1]: version conflict"}}]
+	}`
+	var res BulkIndexByScrollResponse
+	if err := json.Unmarshal([]byte(body), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 3 || res.Deleted != 2 || res.VersionConflicts != 1 {
+		t.Errorf("expected 3 total, 2 deleted, 1 conflict; got: %d, %d, %d", res.Total, res.Deleted, res.VersionConflicts)
+	}
+	if len(res.Failures) != 1 || res.Failures[0].Id != "1" || res.Failures[0].Cause == nil {
+		t.Fatalf("expected a failure for document 1; got: %v", res.Failures)
+	}
+	if res.Failures[0].Cause.Type != "version_conflict_engine_exception" {
+		t.Errorf("expected a version conflict; got: %q", res.Failures[0].Cause.Type)
+	}
+}
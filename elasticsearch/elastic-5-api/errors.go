@@ -123,6 +123,56 @@ func IsTimeout(err interface{}) bool {
 	return false
 }
 
+// IsConflict returns true if the given error indicates that the Elasticsearch
+// operation resulted in a version conflict. This can occur in operations like
+// `update` or `index` with `op_type=create`. The err parameter can be of
+// type *elastic.Error, elastic.Error, *http.Response or int (indicating the
+// HTTP status code).
+func IsConflict(err interface{}) bool {
+	switch e := err.(type) {
+	case *http.Response:
+		return e.StatusCode == http.StatusConflict
+	case *Error:
+		return e.Status == http.StatusConflict
+	case Error:
+		return e.Status == http.StatusConflict
+	case int:
+		return e == http.StatusConflict
+	}
+	return false
+}
+
+// IsIndexNotFound returns true if the given error indicates that the index
+// the operation refers to does not exist. The err parameter can be of type
+// *elastic.Error or elastic.Error.
+func IsIndexNotFound(err interface{}) bool {
+	return errorType(err) == "index_not_found_exception"
+}
+
+// IsIndexAlreadyExists returns true if the given error indicates that an
+// index could not be created because it exists already. The err parameter
+// can be of type *elastic.Error or elastic.Error.
+func IsIndexAlreadyExists(err interface{}) bool {
+	typ := errorType(err)
+	return typ == "index_already_exists_exception" || typ == "resource_already_exists_exception"
+}
+
+// errorType returns the type of the error Elasticsearch reported, or ""
+// if err carries no details.
+func errorType(err interface{}) string {
+	var details *ErrorDetails
+	switch e := err.(type) {
+	case *Error:
+		details = e.Details
+	case Error:
+		details = e.Details
+	}
+	if details == nil {
+		return ""
+	}
+	return details.Type
+}
+
 // -- General errors --
 
 // shardsInfo represents information from a shard.
@@ -200,3 +200,71 @@ func TestIsTimeout(t *testing.T) {
 		t.Errorf("expected %v; got: %v", want, got)
 	}
 }
+
+func TestIsConflict(t *testing.T) {
+	if got, want := IsConflict(nil), false; got != want {
+		t.Errorf("expected %v; got: %v", want, got)
+	}
+	if got, want := IsConflict(""), false; got != want {
+		t.Errorf("expected %v; got: %v", want, got)
+	}
+	if got, want := IsConflict(200), false; got != want {
+		t.Errorf("expected %v; got: %v", want, got)
+	}
+	if got, want := IsConflict(409), true; got != want {
+		t.Errorf("expected %v; got: %v", want, got)
+	}
+
+	if got, want := IsConflict(&Error{Status: 409}), true; got != want {
+		t.Errorf("expected %v; got: %v", want, got)
+	}
+	if got, want := IsConflict(&Error{Status: 200}), false; got != want {
+		t.Errorf("expected %v; got: %v", want, got)
+	}
+
+	if got, want := IsConflict(Error{Status: 409}), true; got != want {
+		t.Errorf("expected %v; got: %v", want, got)
+	}
+	if got, want := IsConflict(Error{Status: 200}), false; got != want {
+		t.Errorf("expected %v; got: %v", want, got)
+	}
+
+	if got, want := IsConflict(&http.Response{StatusCode: 409}), true; got != want {
+		t.Errorf("expected %v; got: %v", want, got)
+	}
+	if got, want := IsConflict(&http.Response{StatusCode: 200}), false; got != want {
+		t.Errorf("expected %v; got: %v", want, got)
+	}
+}
+
+func TestIsIndexNotFound(t *testing.T) {
+	notFound := &Error{Status: 404, Details: &ErrorDetails{Type: "index_not_found_exception", Reason: "no such index"}}
+
+	if got, want := IsIndexNotFound(nil), false; got != want {
+		t.Errorf("expected %v; got: %v", want, got)
+	}
+	if got, want := IsIndexNotFound(404), false; got != want {
+		t.Errorf("expected %v; got: %v", want, got)
+	}
+	if got, want := IsIndexNotFound(&Error{Status: 404}), false; got != want {
+		t.Errorf("expected %v; got: %v", want, got)
+	}
+	if got, want := IsIndexNotFound(notFound), true; got != want {
+		t.Errorf("expected %v; got: %v", want, got)
+	}
+	if got, want := IsIndexNotFound(*notFound), true; got != want {
+		t.Errorf("expected %v; got: %v", want, got)
+	}
+}
+
+func TestIsIndexAlreadyExists(t *testing.T) {
+	if got, want := IsIndexAlreadyExists(&Error{Status: 400}), false; got != want {
+		t.Errorf("expected %v; got: %v", want, got)
+	}
+	if got, want := IsIndexAlreadyExists(&Error{Status: 400, Details: &ErrorDetails{Type: "index_already_exists_exception"}}), true; got != want {
+		t.Errorf("expected %v; got: %v", want, got)
+	}
+	if got, want := IsIndexAlreadyExists(Error{Status: 400, Details: &ErrorDetails{Type: "resource_already_exists_exception"}}), true; got != want {
+		t.Errorf("expected %v; got: %v", want, got)
+	}
+}
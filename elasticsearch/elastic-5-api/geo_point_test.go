@@ -0,0 +1,39 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestGeoPointSource(t *testing.T) {
+	pt := GeoPoint{Lat: 40, Lon: -70}
+
+	data, err := json.Marshal(pt.Source())
+	if err != nil {
+		t.Fatalf("marshaling to JSON failed: %v", err)
+	}
+	got := string(data)
+	expected := `{"lat":40,"lon":-70}`
+	if got != expected {
+		t.Errorf("expected\n%s\n,got:\n%s", expected, got)
+	}
+}
+
+func TestGeoPointFromString(t *testing.T) {
+	pt, err := GeoPointFromString("40.10210, -70.12091")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pt.Lat != 40.10210 || pt.Lon != -70.12091 {
+		t.Errorf("expected 40.10210,-70.12091; got: %v,%v", pt.Lat, pt.Lon)
+	}
+
+	_, err = GeoPointFromString("40.10210")
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"encoding/json"
+	"sort"
+	"testing"
+)
+
+func TestAliasesBuildURL(t *testing.T) {
+	tests := []struct {
+		Indices  []string
+		Expected string
+	}{
+		{
+			[]string{},
+			"/_aliases",
+		},
+		{
+			[]string{"index1"},
+			"/index1/_aliases",
+		},
+		{
+			[]string{"index1", "index2"},
+			"/index1%2Cindex2/_aliases",
+		},
+	}
+
+	for i, test := range tests {
+		path, _, err := NewAliasesService(nil).Index(test.Indices...).buildURL()
+		if err != nil {
+			t.Errorf("case #%d: %v", i+1, err)
+			continue
+		}
+		if path != test.Expected {
+			t.Errorf("case #%d: expected %q; got: %q", i+1, test.Expected, path)
+		}
+	}
+}
+
+func TestAliasesResult(t *testing.T) {
+	body := `{
+		"index1": {"aliases": {"alias1": {}, "alias2": {}}},
+		"index2": {"aliases": {"alias1": {}}},
+		"index3": {"aliases": {}}
+	}`
+	var indexMap map[string]interface{}
+	if err := json.Unmarshal([]byte(body), &indexMap); err != nil {
+		t.Fatal(err)
+	}
+	res := newAliasesResult(indexMap)
+
+	if len(res.Indices) != 3 {
+		t.Fatalf("expected 3 indices; got: %d", len(res.Indices))
+	}
+	indices := res.IndicesByAlias("alias1")
+	sort.Strings(indices)
+	if len(indices) != 2 || indices[0] != "index1" || indices[1] != "index2" {
+		t.Errorf("expected [index1 index2]; got: %v", indices)
+	}
+	if got := res.IndicesByAlias("alias3"); len(got) != 0 {
+		t.Errorf("expected no indices; got: %v", got)
+	}
+	if !res.Indices["index1"].HasAlias("alias2") {
+		t.Errorf("expected index1 to have alias2")
+	}
+	if res.Indices["index3"].HasAlias("alias1") {
+		t.Errorf("expected index3 not to have alias1")
+	}
+}
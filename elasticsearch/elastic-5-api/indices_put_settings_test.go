@@ -0,0 +1,37 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import "testing"
+
+func TestIndicesPutSettingsBuildURL(t *testing.T) {
+	tests := []struct {
+		Indices  []string
+		Expected string
+	}{
+		{
+			[]string{},
+			"/_settings",
+		},
+		{
+			[]string{"*"},
+			"/%2A/_settings",
+		},
+		{
+			[]string{"store-1", "store-2"},
+			"/store-1%2Cstore-2/_settings",
+		},
+	}
+
+	for i, test := range tests {
+		path, _, err := NewIndicesPutSettingsService(nil).Index(test.Indices...).buildURL()
+		if err != nil {
+			t.Fatalf("case #%d: %v", i+1, err)
+		}
+		if path != test.Expected {
+			t.Errorf("case #%d: expected %q; got: %q", i+1, test.Expected, path)
+		}
+	}
+}
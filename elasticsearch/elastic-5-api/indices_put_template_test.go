@@ -0,0 +1,40 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import "testing"
+
+func TestIndicesTemplateBuildURL(t *testing.T) {
+	path, params, err := NewIndicesPutTemplateService(nil).Name("logs").Order(2).Create(true).buildURL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != "/_template/logs" {
+		t.Errorf("expected %q; got: %q", "/_template/logs", path)
+	}
+	if params.Get("order") != "2" || params.Get("create") != "true" {
+		t.Errorf("expected order=2 and create=true; got: %v", params)
+	}
+
+	path, _, err = NewIndicesDeleteTemplateService(nil).Name("logs").buildURL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != "/_template/logs" {
+		t.Errorf("expected %q; got: %q", "/_template/logs", path)
+	}
+}
+
+func TestIndicesTemplateValidate(t *testing.T) {
+	if err := NewIndicesPutTemplateService(nil).Name("logs").Validate(); err == nil {
+		t.Errorf("expected a template without a body to be invalid")
+	}
+	if err := NewIndicesPutTemplateService(nil).BodyString("{}").Validate(); err == nil {
+		t.Errorf("expected a template without a name to be invalid")
+	}
+	if err := NewIndicesDeleteTemplateService(nil).Validate(); err == nil {
+		t.Errorf("expected a template without a name to be invalid")
+	}
+}
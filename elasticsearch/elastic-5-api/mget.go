@@ -0,0 +1,315 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/venicegeo/pz-gocommon/elasticsearch/elastic-5-api/uritemplates"
+)
+
+// MgetService allows to get multiple documents based on an index,
+// type (optional) and id (possibly routing). The response includes
+// a docs array with all the fetched documents, each element similar
+// in structure to a document provided by the Get API.
+//
+// See https://www.elastic.co/guide/en/elasticsearch/reference/5.2/docs-multi-get.html
+// for details.
+type MgetService struct {
+	client       *Client
+	pretty       bool
+	index        string
+	typ          string
+	preference   string
+	realtime     *bool
+	refresh      string
+	routing      string
+	storedFields []string
+	items        []*MultiGetItem
+}
+
+// NewMgetService initializes a new Multi GET API request call.
+func NewMgetService(client *Client) *MgetService {
+	builder := &MgetService{
+		client: client,
+	}
+	return builder
+}
+
+// Index is the default index for items that don't name one.
+func (s *MgetService) Index(index string) *MgetService {
+	s.index = index
+	return s
+}
+
+// Type is the default type for items that don't name one.
+func (s *MgetService) Type(typ string) *MgetService {
+	s.typ = typ
+	return s
+}
+
+// Preference specifies the node or shard the operation should be performed
+// on (default: random).
+func (s *MgetService) Preference(preference string) *MgetService {
+	s.preference = preference
+	return s
+}
+
+// Refresh the shard containing the document before performing the operation.
+func (s *MgetService) Refresh(refresh string) *MgetService {
+	s.refresh = refresh
+	return s
+}
+
+// Realtime specifies whether to perform the operation in realtime or search mode.
+func (s *MgetService) Realtime(realtime bool) *MgetService {
+	s.realtime = &realtime
+	return s
+}
+
+// Routing is the specific routing value.
+func (s *MgetService) Routing(routing string) *MgetService {
+	s.routing = routing
+	return s
+}
+
+// StoredFields is a list of fields to return in the response.
+func (s *MgetService) StoredFields(storedFields ...string) *MgetService {
+	s.storedFields = append(s.storedFields, storedFields...)
+	return s
+}
+
+// Pretty indicates that the JSON response be indented and human readable.
+func (s *MgetService) Pretty(pretty bool) *MgetService {
+	s.pretty = pretty
+	return s
+}
+
+// Add an item to the request.
+func (s *MgetService) Add(items ...*MultiGetItem) *MgetService {
+	s.items = append(s.items, items...)
+	return s
+}
+
+// Source returns the request body, which will be serialized into JSON.
+func (s *MgetService) Source() (interface{}, error) {
+	source := make(map[string]interface{})
+	items := make([]interface{}, len(s.items))
+	for i, item := range s.items {
+		src, err := item.Source()
+		if err != nil {
+			return nil, err
+		}
+		items[i] = src
+	}
+	source["docs"] = items
+	return source, nil
+}
+
+// buildURL builds the URL for the operation.
+func (s *MgetService) buildURL() (string, url.Values, error) {
+	// Build URL
+	var err error
+	var path string
+
+	switch {
+	case s.index != "" && s.typ != "":
+		path, err = uritemplates.Expand("/{index}/{type}/_mget", map[string]string{
+			"index": s.index,
+			"type":  s.typ,
+		})
+	case s.index != "":
+		path, err = uritemplates.Expand("/{index}/_mget", map[string]string{
+			"index": s.index,
+		})
+	default:
+		path = "/_mget"
+	}
+	if err != nil {
+		return "", url.Values{}, err
+	}
+
+	// Add query string parameters
+	params := url.Values{}
+	if s.pretty {
+		params.Set("pretty", "1")
+	}
+	if s.realtime != nil {
+		params.Set("realtime", fmt.Sprintf("%v", *s.realtime))
+	}
+	if s.preference != "" {
+		params.Set("preference", s.preference)
+	}
+	if s.refresh != "" {
+		params.Set("refresh", s.refresh)
+	}
+	if s.routing != "" {
+		params.Set("routing", s.routing)
+	}
+	if len(s.storedFields) > 0 {
+		params.Set("stored_fields", strings.Join(s.storedFields, ","))
+	}
+	return path, params, nil
+}
+
+// Validate checks if the operation is valid.
+func (s *MgetService) Validate() error {
+	if s.typ != "" && s.index == "" {
+		return fmt.Errorf("missing required fields: [Index]")
+	}
+	return nil
+}
+
+// Do executes the request.
+func (s *MgetService) Do(ctx context.Context) (*MgetResponse, error) {
+	// Check pre-conditions
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Get URL for request
+	path, params, err := s.buildURL()
+	if err != nil {
+		return nil, err
+	}
+
+	// Set body
+	body, err := s.Source()
+	if err != nil {
+		return nil, err
+	}
+
+	// Get response
+	res, err := s.client.PerformRequest(ctx, "GET", path, params, body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return result
+	ret := new(MgetResponse)
+	if err := s.client.decoder.Decode(res.Body, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// -- Multi Get Item --
+
+// MultiGetItem is a single document to retrieve via the MgetService.
+type MultiGetItem struct {
+	index        string
+	typ          string
+	id           string
+	routing      string
+	storedFields []string
+	version      *int64 // see org.elasticsearch.common.lucene.uid.Versions
+	versionType  string // see org.elasticsearch.index.VersionType
+	fsc          *FetchSourceContext
+}
+
+// NewMultiGetItem initializes a new, single item for a Multi GET request.
+func NewMultiGetItem() *MultiGetItem {
+	return &MultiGetItem{}
+}
+
+// Index specifies the index name.
+func (item *MultiGetItem) Index(index string) *MultiGetItem {
+	item.index = index
+	return item
+}
+
+// Type specifies the type name.
+func (item *MultiGetItem) Type(typ string) *MultiGetItem {
+	item.typ = typ
+	return item
+}
+
+// Id specifies the identifier of the document.
+func (item *MultiGetItem) Id(id string) *MultiGetItem {
+	item.id = id
+	return item
+}
+
+// Routing is the specific routing value.
+func (item *MultiGetItem) Routing(routing string) *MultiGetItem {
+	item.routing = routing
+	return item
+}
+
+// StoredFields is a list of fields to return in the response.
+func (item *MultiGetItem) StoredFields(storedFields ...string) *MultiGetItem {
+	item.storedFields = append(item.storedFields, storedFields...)
+	return item
+}
+
+// Version can be MatchAny (-3), MatchAnyPre120 (0), NotFound (-1),
+// or NotSet (-2). These are specified in org.elasticsearch.common.lucene.uid.Versions.
+// The default in Elasticsearch is MatchAny (-3).
+func (item *MultiGetItem) Version(version int64) *MultiGetItem {
+	item.version = &version
+	return item
+}
+
+// VersionType can be "internal", "external", "external_gt", "external_gte",
+// or "force". See org.elasticsearch.index.VersionType in Elasticsearch source.
+// It is "internal" by default.
+func (item *MultiGetItem) VersionType(versionType string) *MultiGetItem {
+	item.versionType = versionType
+	return item
+}
+
+// FetchSource allows to specify source filtering.
+func (item *MultiGetItem) FetchSource(fetchSourceContext *FetchSourceContext) *MultiGetItem {
+	item.fsc = fetchSourceContext
+	return item
+}
+
+// Source returns the serialized JSON to be sent to Elasticsearch as
+// part of a MultiGet search.
+func (item *MultiGetItem) Source() (interface{}, error) {
+	source := make(map[string]interface{})
+
+	source["_id"] = item.id
+
+	if item.index != "" {
+		source["_index"] = item.index
+	}
+	if item.typ != "" {
+		source["_type"] = item.typ
+	}
+	if item.fsc != nil {
+		src, err := item.fsc.Source()
+		if err != nil {
+			return nil, err
+		}
+		source["_source"] = src
+	}
+	if item.routing != "" {
+		source["_routing"] = item.routing
+	}
+	if len(item.storedFields) > 0 {
+		source["stored_fields"] = strings.Join(item.storedFields, ",")
+	}
+	if item.version != nil {
+		source["version"] = fmt.Sprintf("%d", *item.version)
+	}
+	if item.versionType != "" {
+		source["version_type"] = item.versionType
+	}
+
+	return source, nil
+}
+
+// -- Result of a Multi Get request.
+
+// MgetResponse is the outcome of a Multi GET API request. Docs are in
+// the order of the items requested; a document that couldn't be
+// retrieved has Error set.
+type MgetResponse struct {
+	Docs []*GetResult `json:"docs,omitempty"`
+}
@@ -0,0 +1,92 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMgetBuildURL(t *testing.T) {
+	tests := []struct {
+		Index    string
+		Type     string
+		Expected string
+	}{
+		{
+			"",
+			"",
+			"/_mget",
+		},
+		{
+			"index1",
+			"",
+			"/index1/_mget",
+		},
+		{
+			"index1",
+			"type1",
+			"/index1/type1/_mget",
+		},
+	}
+
+	for i, test := range tests {
+		path, _, err := NewMgetService(nil).Index(test.Index).Type(test.Type).buildURL()
+		if err != nil {
+			t.Errorf("case #%d: %v", i+1, err)
+			continue
+		}
+		if path != test.Expected {
+			t.Errorf("case #%d: expected %q; got: %q", i+1, test.Expected, path)
+		}
+	}
+	if err := NewMgetService(nil).Type("type1").Validate(); err == nil {
+		t.Errorf("expected an error for a type without an index")
+	}
+}
+
+func TestMgetSource(t *testing.T) {
+	svc := NewMgetService(nil).Add(
+		NewMultiGetItem().Id("1"),
+		NewMultiGetItem().Index("index2").Type("type2").Id("2").FetchSource(NewFetchSourceContext(true).Include("user")),
+	)
+	src, err := svc.Source()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(src)
+	if err != nil {
+		t.Fatalf("marshaling to JSON failed: %v", err)
+	}
+	got := string(data)
+	expected := `{"docs":[{"_id":"1"},{"_id":"2","_index":"index2","_source":{"excludes":[],"includes":["user"]},"_type":"type2"}]}`
+	if got != expected {
+		t.Errorf("expected\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+func TestMgetResponse(t *testing.T) {
+	body := `{"docs": [
+		{"_index": "index1", "_type": "type1", "_id": "1", "_version": 2, "found": true, "_source": {"user": "olivere"}},
+		{"_index": "index1", "_type": "type1", "_id": "2", "found": false},
+		{"_index": "index1", "_type": "type1", "_id": "3", "error": {"type": "index_not_found_exception", "reason": "no such index"}}
+	]}`
+	var res MgetResponse
+	if err := json.Unmarshal([]byte(body), &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Docs) != 3 {
+		t.Fatalf("expected 3 docs; got: %d", len(res.Docs))
+	}
+	if !res.Docs[0].Found || res.Docs[0].Source == nil || *res.Docs[0].Version != 2 {
+		t.Errorf("expected document 1 to be found at version 2; got: %+v", res.Docs[0])
+	}
+	if res.Docs[1].Found || res.Docs[1].Error != nil {
+		t.Errorf("expected document 2 not to be found; got: %+v", res.Docs[1])
+	}
+	if res.Docs[2].Error == nil || res.Docs[2].Error.Type != "index_not_found_exception" {
+		t.Errorf("expected an error for document 3; got: %+v", res.Docs[2])
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/venicegeo/pz-gocommon/elasticsearch/elastic-5-api/uritemplates"
+)
+
+// MultiSearchService executes one or more searches in one roundtrip.
+//
+// See https://www.elastic.co/guide/en/elasticsearch/reference/5.2/search-multi-search.html
+// for details.
+type MultiSearchService struct {
+	client     *Client
+	requests   []*SearchRequest
+	indices    []string
+	pretty     bool
+	routing    string
+	preference string
+}
+
+// NewMultiSearchService creates a new MultiSearchService.
+func NewMultiSearchService(client *Client) *MultiSearchService {
+	builder := &MultiSearchService{
+		client:   client,
+		requests: make([]*SearchRequest, 0),
+		indices:  make([]string, 0),
+	}
+	return builder
+}
+
+// Add adds one or more search requests.
+func (s *MultiSearchService) Add(requests ...*SearchRequest) *MultiSearchService {
+	s.requests = append(s.requests, requests...)
+	return s
+}
+
+// Index sets the default indices, for requests that don't name any.
+func (s *MultiSearchService) Index(indices ...string) *MultiSearchService {
+	s.indices = append(s.indices, indices...)
+	return s
+}
+
+// Pretty indicates that the JSON response be indented and human readable.
+func (s *MultiSearchService) Pretty(pretty bool) *MultiSearchService {
+	s.pretty = pretty
+	return s
+}
+
+// buildURL builds the URL for the operation.
+func (s *MultiSearchService) buildURL() (string, url.Values, error) {
+	// Build URL
+	var err error
+	path := "/_msearch"
+	if len(s.indices) > 0 {
+		path, err = uritemplates.Expand("/{index}/_msearch", map[string]string{
+			"index": strings.Join(s.indices, ","),
+		})
+	}
+	if err != nil {
+		return "", url.Values{}, err
+	}
+
+	// Add query string parameters
+	params := url.Values{}
+	if s.pretty {
+		params.Set("pretty", fmt.Sprintf("%v", s.pretty))
+	}
+	return path, params, nil
+}
+
+// body returns the newline-delimited header and body of each request.
+func (s *MultiSearchService) body() (string, error) {
+	var lines []string
+	for _, sr := range s.requests {
+		// Set default indices if not specified in the request
+		if !sr.HasIndices() && len(s.indices) > 0 {
+			sr = sr.Index(s.indices...)
+		}
+
+		header, err := json.Marshal(sr.header())
+		if err != nil {
+			return "", err
+		}
+		body, err := json.Marshal(sr.body())
+		if err != nil {
+			return "", err
+		}
+		lines = append(lines, string(header))
+		lines = append(lines, string(body))
+	}
+	return strings.Join(lines, "\n") + "\n", nil // add trailing \n
+}
+
+// Validate checks if the operation is valid.
+func (s *MultiSearchService) Validate() error {
+	if len(s.requests) == 0 {
+		return fmt.Errorf("missing required fields: [Requests]")
+	}
+	return nil
+}
+
+// Do executes the searches. A search that failed has Error set in its
+// response, which doesn't fail the others.
+func (s *MultiSearchService) Do(ctx context.Context) (*MultiSearchResult, error) {
+	// Check pre-conditions
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Get URL for request
+	path, params, err := s.buildURL()
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := s.body()
+	if err != nil {
+		return nil, err
+	}
+
+	// Get response
+	res, err := s.client.PerformRequest(ctx, "GET", path, params, body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return result
+	ret := new(MultiSearchResult)
+	if err := s.client.decoder.Decode(res.Body, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// MultiSearchResult is the outcome of running a multi-search operation.
+// Responses are in the order of the requests.
+type MultiSearchResult struct {
+	Responses []*SearchResult `json:"responses,omitempty"`
+}
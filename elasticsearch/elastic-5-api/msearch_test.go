@@ -0,0 +1,49 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"testing"
+)
+
+func TestMultiSearchBuildURL(t *testing.T) {
+	path, _, err := NewMultiSearchService(nil).buildURL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "/_msearch"; path != expected {
+		t.Errorf("expected %q; got: %q", expected, path)
+	}
+	path, _, err = NewMultiSearchService(nil).Index("index1", "index2").buildURL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "/index1%2Cindex2/_msearch"; path != expected {
+		t.Errorf("expected %q; got: %q", expected, path)
+	}
+	if err = NewMultiSearchService(nil).Validate(); err == nil {
+		t.Errorf("expected an error without requests")
+	}
+}
+
+func TestMultiSearchBody(t *testing.T) {
+	sreq1 := NewSearchRequest().Type("type1").
+		Source(NewSearchSource().Query(NewTermQuery("user", "olivere")).Size(10))
+	sreq2 := NewSearchRequest().Index("index2").
+		Source(NewSearchSource().Query(NewMatchAllQuery()))
+
+	body, err := NewMultiSearchService(nil).Index("index1").Add(sreq1, sreq2).body()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"index":"index1","type":"type1"}
+{"query":{"term":{"user":"olivere"}},"size":10}
+{"index":"index2"}
+{"query":{"match_all":{}}}
+`
+	if body != expected {
+		t.Errorf("expected\n%s\ngot:\n%s", expected, body)
+	}
+}
@@ -178,6 +178,12 @@ func (s *SearchService) GlobalSuggestText(globalText string) *SearchService {
 	return s
 }
 
+// Suggester adds a suggester to the search.
+func (s *SearchService) Suggester(suggester Suggester) *SearchService {
+	s.searchSource = s.searchSource.Suggester(suggester)
+	return s
+}
+
 // Aggregation adds an aggreation to perform as part of the search.
 func (s *SearchService) Aggregation(name string, aggregation Aggregation) *SearchService {
 	s.searchSource = s.searchSource.Aggregation(name, aggregation)
@@ -486,12 +492,32 @@ type SearchSuggestion struct {
 // SearchSuggestionOption is an option of a SearchSuggestion.
 // See https://www.elastic.co/guide/en/elasticsearch/reference/5.2/search-suggesters.html.
 type SearchSuggestionOption struct {
-	Text   string           `json:"text"`
-	Index  string           `json:"_index"`
-	Type   string           `json:"_type"`
-	Id     string           `json:"_id"`
-	Score  float64          `json:"_score"`
-	Source *json.RawMessage `json:"_source"`
+	Text         string           `json:"text"`
+	Index        string           `json:"_index"`
+	Type         string           `json:"_type"`
+	Id           string           `json:"_id"`
+	Score        float64          `json:"_score"`
+	Highlighted  string           `json:"highlighted"`   // phrase suggestions only
+	CollateMatch bool             `json:"collate_match"` // phrase suggestions only
+	Freq         int              `json:"freq"`          // term suggestions only
+	Source       *json.RawMessage `json:"_source"`
+}
+
+// UnmarshalJSON decodes an option. Completion suggestions report their
+// score as "_score", term and phrase suggestions as "score".
+func (o *SearchSuggestionOption) UnmarshalJSON(data []byte) error {
+	type option SearchSuggestionOption
+	aux := struct {
+		*option
+		TermScore *float64 `json:"score"`
+	}{option: (*option)(o)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if aux.TermScore != nil {
+		o.Score = *aux.TermScore
+	}
+	return nil
 }
 
 // SearchProfile is a list of shard profiling data collected during
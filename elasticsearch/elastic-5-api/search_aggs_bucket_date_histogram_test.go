@@ -0,0 +1,54 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDateHistogramAggregation(t *testing.T) {
+	agg := NewDateHistogramAggregation().
+		Field("date").
+		Interval("month").
+		Format("YYYY-MM").
+		TimeZone("UTC").
+		Offset("+6h")
+	src, err := agg.Source()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(src)
+	if err != nil {
+		t.Fatalf("marshaling to JSON failed: %v", err)
+	}
+	got := string(data)
+	expected := `{"date_histogram":{"field":"date","format":"YYYY-MM","interval":"month","offset":"+6h","time_zone":"UTC"}}`
+	if got != expected {
+		t.Errorf("expected\n%s\n,got:\n%s", expected, got)
+	}
+}
+func TestDateHistogramAggregationWithMissingAndBounds(t *testing.T) {
+	agg := NewDateHistogramAggregation().
+		Field("date").
+		Interval("day").
+		Missing("1900").
+		MinDocCount(0).
+		ExtendedBounds("2016-01-01", "2016-12-31").
+		OrderByKey(false)
+	src, err := agg.Source()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(src)
+	if err != nil {
+		t.Fatalf("marshaling to JSON failed: %v", err)
+	}
+	got := string(data)
+	expected := `{"date_histogram":{"extended_bounds":{"max":"2016-12-31","min":"2016-01-01"},"field":"date","interval":"day","min_doc_count":0,"missing":"1900","order":{"_key":"desc"}}}`
+	if got != expected {
+		t.Errorf("expected\n%s\n,got:\n%s", expected, got)
+	}
+}
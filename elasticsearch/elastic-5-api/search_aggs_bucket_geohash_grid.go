@@ -0,0 +1,117 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+// GeoHashGridAggregation is a multi-bucket aggregation that works on
+// geo_point fields and groups points into buckets that represent cells
+// in a grid.
+// See: https://www.elastic.co/guide/en/elasticsearch/reference/5.2/search-aggregations-bucket-geohashgrid-aggregation.html
+type GeoHashGridAggregation struct {
+	field           string
+	precision       int
+	size            int
+	shardSize       int
+	subAggregations map[string]Aggregation
+	meta            map[string]interface{}
+}
+
+// NewGeoHashGridAggregation creates and initializes a new GeoHashGridAggregation.
+func NewGeoHashGridAggregation() *GeoHashGridAggregation {
+	return &GeoHashGridAggregation{
+		subAggregations: make(map[string]Aggregation),
+		precision:       -1,
+		size:            -1,
+		shardSize:       -1,
+	}
+}
+
+// Field sets the geo_point field to aggregate on.
+func (a *GeoHashGridAggregation) Field(field string) *GeoHashGridAggregation {
+	a.field = field
+	return a
+}
+
+// Precision sets the length of the geohashes used for the cells, from 1 to 12.
+func (a *GeoHashGridAggregation) Precision(precision int) *GeoHashGridAggregation {
+	a.precision = precision
+	return a
+}
+
+// Size sets the maximum number of buckets returned.
+func (a *GeoHashGridAggregation) Size(size int) *GeoHashGridAggregation {
+	a.size = size
+	return a
+}
+
+// ShardSize sets the number of buckets each shard returns.
+func (a *GeoHashGridAggregation) ShardSize(shardSize int) *GeoHashGridAggregation {
+	a.shardSize = shardSize
+	return a
+}
+
+// SubAggregation adds a sub-aggregation to this aggregation.
+func (a *GeoHashGridAggregation) SubAggregation(name string, subAggregation Aggregation) *GeoHashGridAggregation {
+	a.subAggregations[name] = subAggregation
+	return a
+}
+
+// Meta sets the meta data to be included in the aggregation response.
+func (a *GeoHashGridAggregation) Meta(metaData map[string]interface{}) *GeoHashGridAggregation {
+	a.meta = metaData
+	return a
+}
+
+// Source returns JSON for the aggregation.
+func (a *GeoHashGridAggregation) Source() (interface{}, error) {
+	// Example:
+	// {
+	//     "aggs": {
+	//         "new_york": {
+	//             "geohash_grid": {
+	//                 "field": "location",
+	//                 "precision": 5
+	//             }
+	//         }
+	//     }
+	// }
+	// This method returns only the { "geohash_grid" : { ... } } part.
+
+	source := make(map[string]interface{})
+	opts := make(map[string]interface{})
+	source["geohash_grid"] = opts
+
+	if a.field != "" {
+		opts["field"] = a.field
+	}
+	if a.precision != -1 {
+		opts["precision"] = a.precision
+	}
+	if a.size != -1 {
+		opts["size"] = a.size
+	}
+	if a.shardSize != -1 {
+		opts["shard_size"] = a.shardSize
+	}
+
+	// AggregationBuilder (SubAggregations)
+	if len(a.subAggregations) > 0 {
+		aggsMap := make(map[string]interface{})
+		source["aggregations"] = aggsMap
+		for name, aggregate := range a.subAggregations {
+			src, err := aggregate.Source()
+			if err != nil {
+				return nil, err
+			}
+			aggsMap[name] = src
+		}
+	}
+
+	// Add Meta data if available
+	if len(a.meta) > 0 {
+		source["meta"] = a.meta
+	}
+
+	return source, nil
+}
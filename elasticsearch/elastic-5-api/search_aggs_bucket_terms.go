@@ -0,0 +1,191 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+// TermsAggregation is a multi-bucket value source based aggregation
+// where buckets are dynamically built - one per unique value.
+// See: http://www.elastic.co/guide/en/elasticsearch/reference/5.2/search-aggregations-bucket-terms-aggregation.html
+type TermsAggregation struct {
+	field           string
+	missing         interface{}
+	subAggregations map[string]Aggregation
+	meta            map[string]interface{}
+
+	size        *int
+	shardSize   *int
+	minDocCount *int64
+	order       []TermsOrder
+	include     string
+	exclude     string
+}
+
+// NewTermsAggregation creates and initializes a new TermsAggregation.
+func NewTermsAggregation() *TermsAggregation {
+	return &TermsAggregation{
+		subAggregations: make(map[string]Aggregation, 0),
+	}
+}
+
+// Field sets the field to aggregate on.
+func (a *TermsAggregation) Field(field string) *TermsAggregation {
+	a.field = field
+	return a
+}
+
+// Missing configures the value to use when documents miss a value.
+func (a *TermsAggregation) Missing(missing interface{}) *TermsAggregation {
+	a.missing = missing
+	return a
+}
+
+// SubAggregation adds a sub-aggregation to this aggregation.
+func (a *TermsAggregation) SubAggregation(name string, subAggregation Aggregation) *TermsAggregation {
+	a.subAggregations[name] = subAggregation
+	return a
+}
+
+// Meta sets the meta data to be included in the aggregation response.
+func (a *TermsAggregation) Meta(metaData map[string]interface{}) *TermsAggregation {
+	a.meta = metaData
+	return a
+}
+
+// Size sets the number of buckets returned.
+func (a *TermsAggregation) Size(size int) *TermsAggregation {
+	a.size = &size
+	return a
+}
+
+// ShardSize sets the number of buckets each shard returns.
+func (a *TermsAggregation) ShardSize(shardSize int) *TermsAggregation {
+	a.shardSize = &shardSize
+	return a
+}
+
+// MinDocCount sets the minimum number of documents a bucket must hold.
+func (a *TermsAggregation) MinDocCount(minDocCount int64) *TermsAggregation {
+	a.minDocCount = &minDocCount
+	return a
+}
+
+// Include filters the terms by a regular expression.
+func (a *TermsAggregation) Include(regexp string) *TermsAggregation {
+	a.include = regexp
+	return a
+}
+
+// Exclude filters out the terms matching a regular expression.
+func (a *TermsAggregation) Exclude(regexp string) *TermsAggregation {
+	a.exclude = regexp
+	return a
+}
+
+// Order adds an ordering criterion, e.g. "_count", "_term" or the name
+// of a single-valued sub-aggregation.
+func (a *TermsAggregation) Order(order string, asc bool) *TermsAggregation {
+	a.order = append(a.order, TermsOrder{Field: order, Ascending: asc})
+	return a
+}
+
+// OrderByCount orders the buckets by their document count.
+func (a *TermsAggregation) OrderByCount(asc bool) *TermsAggregation {
+	return a.Order("_count", asc)
+}
+
+// OrderByTerm orders the buckets by their term.
+func (a *TermsAggregation) OrderByTerm(asc bool) *TermsAggregation {
+	return a.Order("_term", asc)
+}
+
+// OrderByAggregation orders the buckets by a single-valued sub-aggregation.
+func (a *TermsAggregation) OrderByAggregation(aggName string, asc bool) *TermsAggregation {
+	return a.Order(aggName, asc)
+}
+
+// Source returns JSON for the aggregation.
+func (a *TermsAggregation) Source() (interface{}, error) {
+	// Example:
+	//	{
+	//    "aggs" : {
+	//      "genders" : {
+	//        "terms" : { "field" : "gender" }
+	//      }
+	//    }
+	//	}
+	// This method returns only the { "terms" : { "field" : "gender" } } part.
+
+	source := make(map[string]interface{})
+	opts := make(map[string]interface{})
+	source["terms"] = opts
+
+	// ValuesSourceAggregationBuilder
+	if a.field != "" {
+		opts["field"] = a.field
+	}
+	if a.missing != nil {
+		opts["missing"] = a.missing
+	}
+
+	// TermsBuilder
+	if a.size != nil && *a.size >= 0 {
+		opts["size"] = *a.size
+	}
+	if a.shardSize != nil && *a.shardSize >= 0 {
+		opts["shard_size"] = *a.shardSize
+	}
+	if a.minDocCount != nil && *a.minDocCount >= 0 {
+		opts["min_doc_count"] = *a.minDocCount
+	}
+	if len(a.order) > 0 {
+		var orderSlice []interface{}
+		for _, order := range a.order {
+			orderSlice = append(orderSlice, order.Source())
+		}
+		opts["order"] = orderSlice
+	}
+	if a.include != "" {
+		opts["include"] = a.include
+	}
+	if a.exclude != "" {
+		opts["exclude"] = a.exclude
+	}
+
+	// AggregationBuilder (SubAggregations)
+	if len(a.subAggregations) > 0 {
+		aggsMap := make(map[string]interface{})
+		source["aggregations"] = aggsMap
+		for name, aggregate := range a.subAggregations {
+			src, err := aggregate.Source()
+			if err != nil {
+				return nil, err
+			}
+			aggsMap[name] = src
+		}
+	}
+
+	// Add Meta data if available
+	if len(a.meta) > 0 {
+		source["meta"] = a.meta
+	}
+
+	return source, nil
+}
+
+// TermsOrder specifies a single order field for a terms aggregation.
+type TermsOrder struct {
+	Field     string
+	Ascending bool
+}
+
+// Source returns serializable JSON of the TermsOrder.
+func (order *TermsOrder) Source() interface{} {
+	source := make(map[string]string)
+	if order.Ascending {
+		source[order.Field] = "asc"
+	} else {
+		source[order.Field] = "desc"
+	}
+	return source
+}
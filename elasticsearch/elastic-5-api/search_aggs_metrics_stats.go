@@ -0,0 +1,100 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+// StatsAggregation is a multi-value metrics aggregation that computes stats
+// over numeric values extracted from the aggregated documents.
+// These values are extracted from a specific numeric field in the documents.
+// See: https://www.elastic.co/guide/en/elasticsearch/reference/5.2/search-aggregations-metrics-stats-aggregation.html
+type StatsAggregation struct {
+	field           string
+	format          string
+	missing         interface{}
+	subAggregations map[string]Aggregation
+	meta            map[string]interface{}
+}
+
+// NewStatsAggregation creates and initializes a new StatsAggregation.
+func NewStatsAggregation() *StatsAggregation {
+	return &StatsAggregation{
+		subAggregations: make(map[string]Aggregation),
+	}
+}
+
+// Field sets the field to compute the stats over.
+func (a *StatsAggregation) Field(field string) *StatsAggregation {
+	a.field = field
+	return a
+}
+
+// Format sets the format of the stats values returned as strings.
+func (a *StatsAggregation) Format(format string) *StatsAggregation {
+	a.format = format
+	return a
+}
+
+// Missing configures the value to use when documents miss a value.
+func (a *StatsAggregation) Missing(missing interface{}) *StatsAggregation {
+	a.missing = missing
+	return a
+}
+
+// SubAggregation adds a sub-aggregation to this aggregation.
+func (a *StatsAggregation) SubAggregation(name string, subAggregation Aggregation) *StatsAggregation {
+	a.subAggregations[name] = subAggregation
+	return a
+}
+
+// Meta sets the meta data to be included in the aggregation response.
+func (a *StatsAggregation) Meta(metaData map[string]interface{}) *StatsAggregation {
+	a.meta = metaData
+	return a
+}
+
+// Source returns JSON for the aggregation.
+func (a *StatsAggregation) Source() (interface{}, error) {
+	// Example:
+	//	{
+	//    "aggs" : {
+	//      "grades_stats" : { "stats" : { "field" : "grade" } }
+	//    }
+	//	}
+	// This method returns only the { "stats" : { "field" : "grade" } } part.
+
+	source := make(map[string]interface{})
+	opts := make(map[string]interface{})
+	source["stats"] = opts
+
+	// ValuesSourceAggregationBuilder
+	if a.field != "" {
+		opts["field"] = a.field
+	}
+	if a.format != "" {
+		opts["format"] = a.format
+	}
+	if a.missing != nil {
+		opts["missing"] = a.missing
+	}
+
+	// AggregationBuilder (SubAggregations)
+	if len(a.subAggregations) > 0 {
+		aggsMap := make(map[string]interface{})
+		source["aggregations"] = aggsMap
+		for name, aggregate := range a.subAggregations {
+			src, err := aggregate.Source()
+			if err != nil {
+				return nil, err
+			}
+			aggsMap[name] = src
+		}
+	}
+
+	// Add Meta data if available
+	if len(a.meta) > 0 {
+		source["meta"] = a.meta
+	}
+
+	return source, nil
+}
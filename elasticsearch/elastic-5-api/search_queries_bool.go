@@ -0,0 +1,180 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import "fmt"
+
+// A bool query matches documents matching boolean
+// combinations of other queries.
+//
+// For more details, see:
+// https://www.elastic.co/guide/en/elasticsearch/reference/5.2/query-dsl-bool-query.html
+type BoolQuery struct {
+	Query
+	mustClauses        []Query
+	mustNotClauses     []Query
+	filterClauses      []Query
+	shouldClauses      []Query
+	boost              *float64
+	disableCoord       *bool
+	minimumShouldMatch string
+	adjustPureNegative *bool
+	queryName          string
+}
+
+// Creates a new bool query.
+func NewBoolQuery() *BoolQuery {
+	return &BoolQuery{
+		mustClauses:    make([]Query, 0),
+		mustNotClauses: make([]Query, 0),
+		filterClauses:  make([]Query, 0),
+		shouldClauses:  make([]Query, 0),
+	}
+}
+
+// Must adds queries which must appear in matching documents and
+// contribute to the score.
+func (q *BoolQuery) Must(queries ...Query) *BoolQuery {
+	q.mustClauses = append(q.mustClauses, queries...)
+	return q
+}
+
+// MustNot adds queries which must not appear in matching documents.
+func (q *BoolQuery) MustNot(queries ...Query) *BoolQuery {
+	q.mustNotClauses = append(q.mustNotClauses, queries...)
+	return q
+}
+
+// Filter adds queries which must appear in matching documents, but
+// which do not contribute to the score.
+func (q *BoolQuery) Filter(filters ...Query) *BoolQuery {
+	q.filterClauses = append(q.filterClauses, filters...)
+	return q
+}
+
+// Should adds queries of which some should appear in matching documents.
+func (q *BoolQuery) Should(queries ...Query) *BoolQuery {
+	q.shouldClauses = append(q.shouldClauses, queries...)
+	return q
+}
+
+// Boost sets the boost for this query.
+func (q *BoolQuery) Boost(boost float64) *BoolQuery {
+	q.boost = &boost
+	return q
+}
+
+// DisableCoord disables the coord factor in scoring.
+func (q *BoolQuery) DisableCoord(disableCoord bool) *BoolQuery {
+	q.disableCoord = &disableCoord
+	return q
+}
+
+// MinimumShouldMatch specifies how many of the should clauses must match,
+// e.g. "2" or "75%".
+func (q *BoolQuery) MinimumShouldMatch(minimumShouldMatch string) *BoolQuery {
+	q.minimumShouldMatch = minimumShouldMatch
+	return q
+}
+
+// MinimumNumberShouldMatch is MinimumShouldMatch for an absolute number.
+func (q *BoolQuery) MinimumNumberShouldMatch(minimumNumberShouldMatch int) *BoolQuery {
+	q.minimumShouldMatch = fmt.Sprintf("%d", minimumNumberShouldMatch)
+	return q
+}
+
+// AdjustPureNegative specifies whether a query consisting only of
+// must_not clauses matches all other documents.
+func (q *BoolQuery) AdjustPureNegative(adjustPureNegative bool) *BoolQuery {
+	q.adjustPureNegative = &adjustPureNegative
+	return q
+}
+
+// QueryName sets the query name for the filter that can be used when
+// searching for matched_filters per hit.
+func (q *BoolQuery) QueryName(queryName string) *BoolQuery {
+	q.queryName = queryName
+	return q
+}
+
+// Source returns JSON for the bool query.
+func (q *BoolQuery) Source() (interface{}, error) {
+	// {
+	//	"bool" : {
+	//		"must" : {
+	//			"term" : { "user" : "kimchy" }
+	//		},
+	//		"must_not" : {
+	//			"range" : {
+	//				"age" : { "from" : 10, "to" : 20 }
+	//			}
+	//		},
+	//    "filter" : [
+	//      ...
+	//    ]
+	//		"should" : [
+	//			{
+	//				"term" : { "tag" : "sometag" }
+	//			},
+	//			{
+	//				"term" : { "tag" : "sometagtag" }
+	//			}
+	//		],
+	//		"minimum_should_match" : 1,
+	//		"boost" : 1.0
+	//	}
+	// }
+
+	query := make(map[string]interface{})
+
+	boolClause := make(map[string]interface{})
+	query["bool"] = boolClause
+
+	for _, occur := range []struct {
+		name    string
+		clauses []Query
+	}{
+		{"must", q.mustClauses},
+		{"must_not", q.mustNotClauses},
+		{"filter", q.filterClauses},
+		{"should", q.shouldClauses},
+	} {
+		if len(occur.clauses) == 1 {
+			src, err := occur.clauses[0].Source()
+			if err != nil {
+				return nil, err
+			}
+			boolClause[occur.name] = src
+		} else if len(occur.clauses) > 1 {
+			var clauses []interface{}
+			for _, subQuery := range occur.clauses {
+				src, err := subQuery.Source()
+				if err != nil {
+					return nil, err
+				}
+				clauses = append(clauses, src)
+			}
+			boolClause[occur.name] = clauses
+		}
+	}
+
+	if q.boost != nil {
+		boolClause["boost"] = *q.boost
+	}
+	if q.disableCoord != nil {
+		boolClause["disable_coord"] = *q.disableCoord
+	}
+	if q.minimumShouldMatch != "" {
+		boolClause["minimum_should_match"] = q.minimumShouldMatch
+	}
+	if q.adjustPureNegative != nil {
+		boolClause["adjust_pure_negative"] = *q.adjustPureNegative
+	}
+	if q.queryName != "" {
+		boolClause["_name"] = q.queryName
+	}
+
+	return query, nil
+}
@@ -0,0 +1,162 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import "errors"
+
+// GeoShapeQuery filters documents whose geo_shape field has a given spatial
+// relation (intersects, disjoint, within or contains) to a shape. The shape
+// is either given inline, in GeoJSON-like form, or refers to a shape indexed
+// in another document.
+//
+// For more details, see:
+// https://www.elastic.co/guide/en/elasticsearch/reference/5.2/query-dsl-geo-shape-query.html
+type GeoShapeQuery struct {
+	name              string
+	shape             map[string]interface{}
+	indexedShapeId    string
+	indexedShapeType  string
+	indexedShapeIndex string
+	indexedShapePath  string
+	relation          string
+	boost             *float64
+	queryName         string
+	ignoreUnmapped    *bool
+}
+
+// NewGeoShapeQuery creates and initializes a new GeoShapeQuery.
+func NewGeoShapeQuery(name string) *GeoShapeQuery {
+	return &GeoShapeQuery{name: name}
+}
+
+// Shape sets the shape, e.g. "polygon" with coordinates
+// [][][]float64{{{lon, lat}, ...}}. Coordinates are in GeoJSON order,
+// i.e. longitude first.
+func (q *GeoShapeQuery) Shape(typ string, coordinates interface{}) *GeoShapeQuery {
+	q.shape = map[string]interface{}{
+		"type":        typ,
+		"coordinates": coordinates,
+	}
+	return q
+}
+
+// Point sets the shape to a single point.
+func (q *GeoShapeQuery) Point(lat, lon float64) *GeoShapeQuery {
+	return q.Shape("point", []float64{lon, lat})
+}
+
+// Envelope sets the shape to the rectangle given by its top left and
+// bottom right corners.
+func (q *GeoShapeQuery) Envelope(top, left, bottom, right float64) *GeoShapeQuery {
+	return q.Shape("envelope", [][]float64{{left, top}, {right, bottom}})
+}
+
+// Polygon sets the shape to a polygon without holes. The ring is closed
+// automatically if the last point differs from the first.
+func (q *GeoShapeQuery) Polygon(points ...*GeoPoint) *GeoShapeQuery {
+	ring := make([][]float64, 0, len(points)+1)
+	for _, pt := range points {
+		ring = append(ring, []float64{pt.Lon, pt.Lat})
+	}
+	if n := len(points); n > 0 && (points[0].Lat != points[n-1].Lat || points[0].Lon != points[n-1].Lon) {
+		ring = append(ring, []float64{points[0].Lon, points[0].Lat})
+	}
+	return q.Shape("polygon", [][][]float64{ring})
+}
+
+// IndexedShape uses the shape stored in field path of an indexed document.
+// Path defaults to "shape".
+func (q *GeoShapeQuery) IndexedShape(index, typ, id, path string) *GeoShapeQuery {
+	q.indexedShapeIndex = index
+	q.indexedShapeType = typ
+	q.indexedShapeId = id
+	q.indexedShapePath = path
+	return q
+}
+
+// Relation sets the spatial relation: "intersects" (default), "disjoint",
+// "within" or "contains".
+func (q *GeoShapeQuery) Relation(relation string) *GeoShapeQuery {
+	q.relation = relation
+	return q
+}
+
+// Boost sets the boost for this query.
+func (q *GeoShapeQuery) Boost(boost float64) *GeoShapeQuery {
+	q.boost = &boost
+	return q
+}
+
+// QueryName sets the query name for the filter that can be used when
+// searching for matched_filters per hit.
+func (q *GeoShapeQuery) QueryName(queryName string) *GeoShapeQuery {
+	q.queryName = queryName
+	return q
+}
+
+// IgnoreUnmapped sets the ignore_unmapped option for the query.
+// If set to true, the query will not fail when the field is unmapped.
+func (q *GeoShapeQuery) IgnoreUnmapped(value bool) *GeoShapeQuery {
+	q.ignoreUnmapped = &value
+	return q
+}
+
+// Source returns JSON for the query.
+func (q *GeoShapeQuery) Source() (interface{}, error) {
+	// {
+	//   "geo_shape" : {
+	//     "location" : {
+	//       "shape" : {
+	//         "type" : "envelope",
+	//         "coordinates" : [[13.0, 53.0], [14.0, 52.0]]
+	//       },
+	//       "relation" : "within"
+	//     }
+	//   }
+	// }
+
+	if q.shape == nil && q.indexedShapeId == "" {
+		return nil, errors.New("elastic: Shape or IndexedShape is required in GeoShapeQuery")
+	}
+
+	source := make(map[string]interface{})
+	params := make(map[string]interface{})
+	source["geo_shape"] = params
+
+	field := make(map[string]interface{})
+	params[q.name] = field
+
+	if q.shape != nil {
+		field["shape"] = q.shape
+	} else {
+		indexed := make(map[string]interface{})
+		indexed["id"] = q.indexedShapeId
+		if q.indexedShapeType != "" {
+			indexed["type"] = q.indexedShapeType
+		}
+		if q.indexedShapeIndex != "" {
+			indexed["index"] = q.indexedShapeIndex
+		}
+		if q.indexedShapePath != "" {
+			indexed["path"] = q.indexedShapePath
+		}
+		field["indexed_shape"] = indexed
+	}
+	if q.relation != "" {
+		field["relation"] = q.relation
+	}
+
+	if q.boost != nil {
+		params["boost"] = *q.boost
+	}
+	if q.queryName != "" {
+		params["_name"] = q.queryName
+	}
+	if q.ignoreUnmapped != nil {
+		params["ignore_unmapped"] = *q.ignoreUnmapped
+	}
+
+	return source, nil
+}
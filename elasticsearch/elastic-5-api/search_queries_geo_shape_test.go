@@ -0,0 +1,71 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestGeoShapeQueryWithEnvelope(t *testing.T) {
+	q := NewGeoShapeQuery("location").
+		Envelope(53, 13, 52, 14).
+		Relation("within")
+	src, err := q.Source()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(src)
+	if err != nil {
+		t.Fatalf("marshaling to JSON failed: %v", err)
+	}
+	got := string(data)
+	expected := `{"geo_shape":{"location":{"relation":"within","shape":{"coordinates":[[13,53],[14,52]],"type":"envelope"}}}}`
+	if got != expected {
+		t.Errorf("expected\n%s\n,got:\n%s", expected, got)
+	}
+}
+func TestGeoShapeQueryWithPolygon(t *testing.T) {
+	q := NewGeoShapeQuery("location").
+		Polygon(GeoPointFromLatLon(0, 0), GeoPointFromLatLon(0, 10), GeoPointFromLatLon(10, 10)).
+		QueryName("q")
+	src, err := q.Source()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(src)
+	if err != nil {
+		t.Fatalf("marshaling to JSON failed: %v", err)
+	}
+	got := string(data)
+	expected := `{"geo_shape":{"_name":"q","location":{"shape":{"coordinates":[[[0,0],[10,0],[10,10],[0,0]]],"type":"polygon"}}}}`
+	if got != expected {
+		t.Errorf("expected\n%s\n,got:\n%s", expected, got)
+	}
+}
+func TestGeoShapeQueryWithIndexedShape(t *testing.T) {
+	q := NewGeoShapeQuery("location").
+		IndexedShape("shapes", "doc", "deu", "location")
+	src, err := q.Source()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(src)
+	if err != nil {
+		t.Fatalf("marshaling to JSON failed: %v", err)
+	}
+	got := string(data)
+	expected := `{"geo_shape":{"location":{"indexed_shape":{"id":"deu","index":"shapes","path":"location","type":"doc"}}}}`
+	if got != expected {
+		t.Errorf("expected\n%s\n,got:\n%s", expected, got)
+	}
+}
+func TestGeoShapeQueryWithoutShape(t *testing.T) {
+	q := NewGeoShapeQuery("location")
+	_, err := q.Source()
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
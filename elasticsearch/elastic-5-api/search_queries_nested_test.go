@@ -0,0 +1,47 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNestedQuery(t *testing.T) {
+	q := NewNestedQuery("obj1",
+		NewBoolQuery().Must(NewTermQuery("obj1.name", "blue"), NewRangeQuery("obj1.count").Gt(5)))
+	q = q.QueryName("qname")
+	src, err := q.Source()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(src)
+	if err != nil {
+		t.Fatalf("marshaling to JSON failed: %v", err)
+	}
+	got := string(data)
+	expected := `{"nested":{"_name":"qname","path":"obj1","query":{"bool":{"must":[{"term":{"obj1.name":"blue"}},{"range":{"obj1.count":{"from":5,"include_lower":false,"include_upper":true,"to":null}}}]}}}}`
+	if got != expected {
+		t.Errorf("expected\n%s\n,got:\n%s", expected, got)
+	}
+}
+func TestNestedQueryWithInnerHit(t *testing.T) {
+	q := NewNestedQuery("obj1", NewTermQuery("obj1.name", "blue")).
+		ScoreMode("avg").
+		InnerHit(NewInnerHit().Name("comments").Query(NewTermQuery("user", "olivere")))
+	src, err := q.Source()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(src)
+	if err != nil {
+		t.Fatalf("marshaling to JSON failed: %v", err)
+	}
+	got := string(data)
+	expected := `{"nested":{"inner_hits":{"name":"comments","query":{"term":{"user":"olivere"}}},"path":"obj1","query":{"term":{"obj1.name":"blue"}},"score_mode":"avg"}}`
+	if got != expected {
+		t.Errorf("expected\n%s\n,got:\n%s", expected, got)
+	}
+}
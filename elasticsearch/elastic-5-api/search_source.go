@@ -30,6 +30,7 @@ type SearchSource struct {
 	aggregations             map[string]Aggregation
 	highlight                *Highlight
 	globalSuggestText        string
+	suggesters               []Suggester
 	rescores                 []*Rescore
 	defaultRescoreWindowSize *int
 	indexBoosts              map[string]float64
@@ -205,6 +206,12 @@ func (s *SearchSource) GlobalSuggestText(text string) *SearchSource {
 	return s
 }
 
+// Suggester adds a suggester to the search.
+func (s *SearchSource) Suggester(suggester Suggester) *SearchSource {
+	s.suggesters = append(s.suggesters, suggester)
+	return s
+}
+
 // Rescorer adds a rescorer to the search.
 func (s *SearchSource) Rescorer(rescore *Rescore) *SearchSource {
 	s.rescores = append(s.rescores, rescore)
@@ -403,6 +410,21 @@ func (s *SearchSource) Source() (interface{}, error) {
 		source["highlight"] = src
 	}
 
+	if len(s.suggesters) > 0 {
+		suggesters := make(map[string]interface{})
+		for _, suggester := range s.suggesters {
+			src, err := suggester.Source(false)
+			if err != nil {
+				return nil, err
+			}
+			suggesters[suggester.Name()] = src
+		}
+		if s.globalSuggestText != "" {
+			suggesters["text"] = s.globalSuggestText
+		}
+		source["suggest"] = suggesters
+	}
+
 	if len(s.rescores) > 0 {
 		// Strip empty rescores from request
 		var rescores []*Rescore
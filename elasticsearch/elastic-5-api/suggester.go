@@ -0,0 +1,34 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+// Suggester is the generic interface of the term, phrase and completion
+// suggesters. A suggester's only purpose is to return the source of its
+// request as a JSON-serializable object; with includeName, the source is
+// wrapped in an object keyed by the suggester's name.
+//
+// For more details, see
+// https://www.elastic.co/guide/en/elasticsearch/reference/5.2/search-suggesters.html
+type Suggester interface {
+	Name() string
+	Source(includeName bool) (interface{}, error)
+}
+
+// suggesterSource builds the source of a suggester of the given kind:
+// {"text": text, kind: options}, wrapped in {name: ...} with includeName.
+func suggesterSource(name, kind, text string, options map[string]interface{}, includeName bool) interface{} {
+	suggester := make(map[string]interface{})
+	if text != "" {
+		suggester["text"] = text
+	}
+	suggester[kind] = options
+
+	if !includeName {
+		return suggester
+	}
+	source := make(map[string]interface{})
+	source[name] = suggester
+	return source
+}
@@ -0,0 +1,119 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+// CompletionSuggester suggests the documents whose completion field has an
+// input starting with the text typed so far, for autocompletion. Its
+// suggestions carry the documents found.
+//
+// For more details, see
+// https://www.elastic.co/guide/en/elasticsearch/reference/5.2/search-suggesters-completion.html
+type CompletionSuggester struct {
+	name     string
+	prefix   string
+	regex    string
+	field    string
+	analyzer string
+	size     *int
+	fuzzy    map[string]interface{}
+	contexts map[string]interface{}
+}
+
+// NewCompletionSuggester creates a new CompletionSuggester.
+func NewCompletionSuggester(name string) *CompletionSuggester {
+	return &CompletionSuggester{name: name}
+}
+
+// Name returns the name of the suggester, which keys its results.
+func (q *CompletionSuggester) Name() string {
+	return q.name
+}
+
+// Prefix sets the text typed so far.
+func (q *CompletionSuggester) Prefix(prefix string) *CompletionSuggester {
+	q.prefix = prefix
+	return q
+}
+
+// Text is the same as Prefix.
+func (q *CompletionSuggester) Text(text string) *CompletionSuggester {
+	return q.Prefix(text)
+}
+
+// Regex sets a regular expression that inputs must match, instead of a
+// prefix.
+func (q *CompletionSuggester) Regex(regex string) *CompletionSuggester {
+	q.regex = regex
+	return q
+}
+
+// Field sets the completion field to look up.
+func (q *CompletionSuggester) Field(field string) *CompletionSuggester {
+	q.field = field
+	return q
+}
+
+// Analyzer sets the analyzer to analyze the prefix with.
+func (q *CompletionSuggester) Analyzer(analyzer string) *CompletionSuggester {
+	q.analyzer = analyzer
+	return q
+}
+
+// Size sets the maximum number of suggestions.
+func (q *CompletionSuggester) Size(size int) *CompletionSuggester {
+	q.size = &size
+	return q
+}
+
+// Fuzzy allows inputs within the given edit distance of the prefix.
+func (q *CompletionSuggester) Fuzzy(fuzziness int) *CompletionSuggester {
+	q.fuzzy = map[string]interface{}{"fuzziness": fuzziness}
+	return q
+}
+
+// Context restricts suggestions to those with the given values in the
+// named context of the field's mapping.
+func (q *CompletionSuggester) Context(name string, values ...interface{}) *CompletionSuggester {
+	if q.contexts == nil {
+		q.contexts = make(map[string]interface{})
+	}
+	q.contexts[name] = values
+	return q
+}
+
+// Source returns JSON for the suggester.
+func (q *CompletionSuggester) Source(includeName bool) (interface{}, error) {
+	options := make(map[string]interface{})
+	if q.field != "" {
+		options["field"] = q.field
+	}
+	if q.analyzer != "" {
+		options["analyzer"] = q.analyzer
+	}
+	if q.size != nil {
+		options["size"] = *q.size
+	}
+	if q.fuzzy != nil {
+		options["fuzzy"] = q.fuzzy
+	}
+	if q.contexts != nil {
+		options["contexts"] = q.contexts
+	}
+
+	suggester := make(map[string]interface{})
+	if q.regex != "" {
+		suggester["regex"] = q.regex
+	} else {
+		suggester["prefix"] = q.prefix
+	}
+	suggester["completion"] = options
+
+	if !includeName {
+		return suggester, nil
+	}
+	source := make(map[string]interface{})
+	source[q.name] = suggester
+	return source, nil
+}
@@ -0,0 +1,75 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCompletionSuggesterSource(t *testing.T) {
+	s := NewCompletionSuggester("song-suggest").
+		Prefix("nir").
+		Field("suggest").
+		Size(5).
+		Fuzzy(1)
+	src, err := s.Source(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(src)
+	if err != nil {
+		t.Fatalf("marshaling to JSON failed: %v", err)
+	}
+	got := string(data)
+	expected := `{"song-suggest":{"completion":{"field":"suggest","fuzzy":{"fuzziness":1},"size":5},"prefix":"nir"}}`
+	if got != expected {
+		t.Errorf("expected\n%s\n,got:\n%s", expected, got)
+	}
+}
+
+func TestSearchSourceSuggesters(t *testing.T) {
+	builder := NewSearchSource().
+		Size(0).
+		GlobalSuggestText("nir").
+		Suggester(NewTermSuggester("spelling").Field("title")).
+		Suggester(NewCompletionSuggester("complete").Field("suggest"))
+	src, err := builder.Source()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(src)
+	if err != nil {
+		t.Fatalf("marshaling to JSON failed: %v", err)
+	}
+	got := string(data)
+	expected := `{"size":0,"suggest":{"complete":{"completion":{"field":"suggest"},"prefix":""},"spelling":{"term":{"field":"title"}},"text":"nir"}}`
+	if got != expected {
+		t.Errorf("expected\n%s\n,got:\n%s", expected, got)
+	}
+}
+
+func TestSearchSuggestionOptionScores(t *testing.T) {
+	var suggest SearchSuggest
+	err := json.Unmarshal([]byte(`{
+		"complete": [{"text": "nir", "offset": 0, "length": 3, "options": [
+			{"text": "Nirvana", "_index": "music", "_type": "song", "_id": "1", "_score": 34.0, "_source": {"title": "Nevermind"}}
+		]}],
+		"spelling": [{"text": "nirvanna", "offset": 0, "length": 8, "options": [
+			{"text": "nirvana", "score": 0.875, "freq": 2}
+		]}]
+	}`), &suggest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	complete := suggest["complete"][0].Options[0]
+	if complete.Score != 34 || complete.Id != "1" || complete.Source == nil {
+		t.Errorf("unexpected completion option: %+v", complete)
+	}
+	spelling := suggest["spelling"][0].Options[0]
+	if spelling.Score != 0.875 || spelling.Freq != 2 {
+		t.Errorf("unexpected term option: %+v", spelling)
+	}
+}
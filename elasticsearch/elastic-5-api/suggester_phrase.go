@@ -0,0 +1,157 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+// PhraseSuggester suggests corrections of the text as a whole, weighing
+// candidate terms by how they occur together in the field.
+//
+// For more details, see
+// https://www.elastic.co/guide/en/elasticsearch/reference/5.2/search-suggesters-phrase.html
+type PhraseSuggester struct {
+	name                    string
+	text                    string
+	field                   string
+	analyzer                string
+	size                    *int
+	shardSize               *int
+	gramSize                *int
+	confidence              *float64
+	maxErrors               *float64
+	separator               *string
+	realWordErrorLikelihood *float64
+	preTag                  *string
+	postTag                 *string
+	directGenerators        []map[string]interface{}
+}
+
+// NewPhraseSuggester creates a new PhraseSuggester.
+func NewPhraseSuggester(name string) *PhraseSuggester {
+	return &PhraseSuggester{name: name}
+}
+
+// Name returns the name of the suggester, which keys its results.
+func (q *PhraseSuggester) Name() string {
+	return q.name
+}
+
+// Text sets the text to make suggestions for.
+func (q *PhraseSuggester) Text(text string) *PhraseSuggester {
+	q.text = text
+	return q
+}
+
+// Field sets the field to look up n-grams in.
+func (q *PhraseSuggester) Field(field string) *PhraseSuggester {
+	q.field = field
+	return q
+}
+
+// Analyzer sets the analyzer to analyze the text with.
+func (q *PhraseSuggester) Analyzer(analyzer string) *PhraseSuggester {
+	q.analyzer = analyzer
+	return q
+}
+
+// Size sets the number of phrases returned.
+func (q *PhraseSuggester) Size(size int) *PhraseSuggester {
+	q.size = &size
+	return q
+}
+
+// ShardSize sets the maximum number of phrases taken from each shard.
+func (q *PhraseSuggester) ShardSize(shardSize int) *PhraseSuggester {
+	q.shardSize = &shardSize
+	return q
+}
+
+// GramSize sets the maximum size of the n-grams in the field.
+func (q *PhraseSuggester) GramSize(gramSize int) *PhraseSuggester {
+	q.gramSize = &gramSize
+	return q
+}
+
+// Confidence sets the factor applied to the input phrase's score that
+// suggestions must beat.
+func (q *PhraseSuggester) Confidence(confidence float64) *PhraseSuggester {
+	q.confidence = &confidence
+	return q
+}
+
+// MaxErrors sets the maximum number, or fraction, of terms considered
+// misspellings.
+func (q *PhraseSuggester) MaxErrors(maxErrors float64) *PhraseSuggester {
+	q.maxErrors = &maxErrors
+	return q
+}
+
+// Separator sets the separator of the terms of the bigram field.
+func (q *PhraseSuggester) Separator(separator string) *PhraseSuggester {
+	q.separator = &separator
+	return q
+}
+
+// RealWordErrorLikelihood sets the likelihood of a term being misspelled
+// even if it is in the dictionary.
+func (q *PhraseSuggester) RealWordErrorLikelihood(likelihood float64) *PhraseSuggester {
+	q.realWordErrorLikelihood = &likelihood
+	return q
+}
+
+// Highlight marks the changed terms of the suggestions with the tags.
+func (q *PhraseSuggester) Highlight(preTag, postTag string) *PhraseSuggester {
+	q.preTag = &preTag
+	q.postTag = &postTag
+	return q
+}
+
+// DirectGenerator adds a generator of candidate terms for a field, given
+// in the form of the term suggester's options, e.g.
+// {"field": "title.trigram", "suggest_mode": "always"}.
+func (q *PhraseSuggester) DirectGenerator(generator map[string]interface{}) *PhraseSuggester {
+	q.directGenerators = append(q.directGenerators, generator)
+	return q
+}
+
+// Source returns JSON for the suggester.
+func (q *PhraseSuggester) Source(includeName bool) (interface{}, error) {
+	options := make(map[string]interface{})
+	if q.field != "" {
+		options["field"] = q.field
+	}
+	if q.analyzer != "" {
+		options["analyzer"] = q.analyzer
+	}
+	if q.size != nil {
+		options["size"] = *q.size
+	}
+	if q.shardSize != nil {
+		options["shard_size"] = *q.shardSize
+	}
+	if q.gramSize != nil {
+		options["gram_size"] = *q.gramSize
+	}
+	if q.confidence != nil {
+		options["confidence"] = *q.confidence
+	}
+	if q.maxErrors != nil {
+		options["max_errors"] = *q.maxErrors
+	}
+	if q.separator != nil {
+		options["separator"] = *q.separator
+	}
+	if q.realWordErrorLikelihood != nil {
+		options["real_word_error_likelihood"] = *q.realWordErrorLikelihood
+	}
+	if q.preTag != nil {
+		options["highlight"] = map[string]interface{}{
+			"pre_tag":  *q.preTag,
+			"post_tag": *q.postTag,
+		}
+	}
+	if len(q.directGenerators) > 0 {
+		options["direct_generator"] = q.directGenerators
+	}
+	return suggesterSource(q.name, "phrase", q.text, options, includeName), nil
+}
@@ -0,0 +1,34 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestPhraseSuggesterSource(t *testing.T) {
+	s := NewPhraseSuggester("name").
+		Text("Xor the Got-Jewel").
+		Field("bigram").
+		Size(1).
+		GramSize(2).
+		MaxErrors(0.5).
+		Highlight("[", "]").
+		DirectGenerator(map[string]interface{}{"field": "body", "suggest_mode": "always"})
+	src, err := s.Source(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(src)
+	if err != nil {
+		t.Fatalf("marshaling to JSON failed: %v", err)
+	}
+	got := string(data)
+	expected := `{"name":{"phrase":{"direct_generator":[{"field":"body","suggest_mode":"always"}],"field":"bigram","gram_size":2,"highlight":{"post_tag":"]","pre_tag":"["},"max_errors":0.5,"size":1},"text":"Xor the Got-Jewel"}}`
+	if got != expected {
+		t.Errorf("expected\n%s\n,got:\n%s", expected, got)
+	}
+}
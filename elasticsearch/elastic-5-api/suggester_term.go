@@ -0,0 +1,162 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+// TermSuggester suggests terms, for each term of the text given, based on
+// edit distance.
+//
+// For more details, see
+// https://www.elastic.co/guide/en/elasticsearch/reference/5.2/search-suggesters-term.html
+type TermSuggester struct {
+	name           string
+	text           string
+	field          string
+	analyzer       string
+	size           *int
+	shardSize      *int
+	suggestMode    string
+	sort           string
+	maxEdits       *int
+	prefixLength   *int
+	minWordLength  *int
+	minDocFreq     *float64
+	maxTermFreq    *float64
+	stringDistance string
+}
+
+// NewTermSuggester creates a new TermSuggester.
+func NewTermSuggester(name string) *TermSuggester {
+	return &TermSuggester{name: name}
+}
+
+// Name returns the name of the suggester, which keys its results.
+func (q *TermSuggester) Name() string {
+	return q.name
+}
+
+// Text sets the text to make suggestions for.
+func (q *TermSuggester) Text(text string) *TermSuggester {
+	q.text = text
+	return q
+}
+
+// Field sets the field to fetch the candidate suggestions from.
+func (q *TermSuggester) Field(field string) *TermSuggester {
+	q.field = field
+	return q
+}
+
+// Analyzer sets the analyzer to analyze the text with.
+func (q *TermSuggester) Analyzer(analyzer string) *TermSuggester {
+	q.analyzer = analyzer
+	return q
+}
+
+// Size sets the maximum number of suggestions per term.
+func (q *TermSuggester) Size(size int) *TermSuggester {
+	q.size = &size
+	return q
+}
+
+// ShardSize sets the maximum number of suggestions taken from each shard.
+func (q *TermSuggester) ShardSize(shardSize int) *TermSuggester {
+	q.shardSize = &shardSize
+	return q
+}
+
+// SuggestMode controls which terms suggestions are made for: "missing"
+// (the default) only for terms not in the index, "popular" only suggests
+// terms more frequent than the original, and "always" for every term.
+func (q *TermSuggester) SuggestMode(suggestMode string) *TermSuggester {
+	q.suggestMode = suggestMode
+	return q
+}
+
+// Sort orders the suggestions by "score" (the default) or "frequency".
+func (q *TermSuggester) Sort(sort string) *TermSuggester {
+	q.sort = sort
+	return q
+}
+
+// MaxEdits sets the maximum edit distance of suggestions, 1 or 2.
+func (q *TermSuggester) MaxEdits(maxEdits int) *TermSuggester {
+	q.maxEdits = &maxEdits
+	return q
+}
+
+// PrefixLength sets the number of leading characters that must match.
+func (q *TermSuggester) PrefixLength(prefixLength int) *TermSuggester {
+	q.prefixLength = &prefixLength
+	return q
+}
+
+// MinWordLength sets the minimum length of a term to make suggestions for.
+func (q *TermSuggester) MinWordLength(minWordLength int) *TermSuggester {
+	q.minWordLength = &minWordLength
+	return q
+}
+
+// MinDocFreq sets the minimal number, or fraction, of documents a
+// suggestion must appear in.
+func (q *TermSuggester) MinDocFreq(minDocFreq float64) *TermSuggester {
+	q.minDocFreq = &minDocFreq
+	return q
+}
+
+// MaxTermFreq sets the maximal number, or fraction, of documents a term
+// may appear in to have suggestions made for it.
+func (q *TermSuggester) MaxTermFreq(maxTermFreq float64) *TermSuggester {
+	q.maxTermFreq = &maxTermFreq
+	return q
+}
+
+// StringDistance sets the algorithm comparing terms, e.g. "internal" or
+// "levenstein".
+func (q *TermSuggester) StringDistance(stringDistance string) *TermSuggester {
+	q.stringDistance = stringDistance
+	return q
+}
+
+// Source returns JSON for the suggester.
+func (q *TermSuggester) Source(includeName bool) (interface{}, error) {
+	options := make(map[string]interface{})
+	if q.field != "" {
+		options["field"] = q.field
+	}
+	if q.analyzer != "" {
+		options["analyzer"] = q.analyzer
+	}
+	if q.size != nil {
+		options["size"] = *q.size
+	}
+	if q.shardSize != nil {
+		options["shard_size"] = *q.shardSize
+	}
+	if q.suggestMode != "" {
+		options["suggest_mode"] = q.suggestMode
+	}
+	if q.sort != "" {
+		options["sort"] = q.sort
+	}
+	if q.maxEdits != nil {
+		options["max_edits"] = *q.maxEdits
+	}
+	if q.prefixLength != nil {
+		options["prefix_length"] = *q.prefixLength
+	}
+	if q.minWordLength != nil {
+		options["min_word_length"] = *q.minWordLength
+	}
+	if q.minDocFreq != nil {
+		options["min_doc_freq"] = *q.minDocFreq
+	}
+	if q.maxTermFreq != nil {
+		options["max_term_freq"] = *q.maxTermFreq
+	}
+	if q.stringDistance != "" {
+		options["string_distance"] = q.stringDistance
+	}
+	return suggesterSource(q.name, "term", q.text, options, includeName), nil
+}
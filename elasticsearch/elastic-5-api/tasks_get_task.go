@@ -0,0 +1,147 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/venicegeo/pz-gocommon/elasticsearch/elastic-5-api/uritemplates"
+)
+
+// TasksGetTaskService retrieves the state of a task in the cluster.
+// A task started with wait_for_completion=false can still be looked up
+// once it has completed, with its result.
+//
+// See https://www.elastic.co/guide/en/elasticsearch/reference/5.2/tasks.html.
+type TasksGetTaskService struct {
+	client            *Client
+	pretty            bool
+	taskId            string
+	waitForCompletion *bool
+	timeout           string
+}
+
+// NewTasksGetTaskService creates a new TasksGetTaskService.
+func NewTasksGetTaskService(client *Client) *TasksGetTaskService {
+	return &TasksGetTaskService{
+		client: client,
+	}
+}
+
+// TaskId indicates to return the task with specified id, in the form
+// node_id:task_number.
+func (s *TasksGetTaskService) TaskId(taskId string) *TasksGetTaskService {
+	s.taskId = taskId
+	return s
+}
+
+// WaitForCompletion indicates whether to wait for the matching task to
+// complete (default: false).
+func (s *TasksGetTaskService) WaitForCompletion(waitForCompletion bool) *TasksGetTaskService {
+	s.waitForCompletion = &waitForCompletion
+	return s
+}
+
+// Timeout bounds how long WaitForCompletion waits.
+func (s *TasksGetTaskService) Timeout(timeout string) *TasksGetTaskService {
+	s.timeout = timeout
+	return s
+}
+
+// Pretty indicates that the JSON response be indented and human readable.
+func (s *TasksGetTaskService) Pretty(pretty bool) *TasksGetTaskService {
+	s.pretty = pretty
+	return s
+}
+
+// buildURL builds the URL for the operation.
+func (s *TasksGetTaskService) buildURL() (string, url.Values, error) {
+	// Build URL
+	path, err := uritemplates.Expand("/_tasks/{task_id}", map[string]string{
+		"task_id": s.taskId,
+	})
+	if err != nil {
+		return "", url.Values{}, err
+	}
+
+	// Add query string parameters
+	params := url.Values{}
+	if s.pretty {
+		params.Set("pretty", "1")
+	}
+	if s.waitForCompletion != nil {
+		params.Set("wait_for_completion", fmt.Sprintf("%v", *s.waitForCompletion))
+	}
+	if s.timeout != "" {
+		params.Set("timeout", s.timeout)
+	}
+	return path, params, nil
+}
+
+// Validate checks if the operation is valid.
+func (s *TasksGetTaskService) Validate() error {
+	var invalid []string
+	if s.taskId == "" {
+		invalid = append(invalid, "TaskId")
+	}
+	if len(invalid) > 0 {
+		return fmt.Errorf("missing required fields: %v", invalid)
+	}
+	return nil
+}
+
+// Do executes the operation.
+func (s *TasksGetTaskService) Do(ctx context.Context) (*TasksGetTaskResponse, error) {
+	// Check pre-conditions
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Get URL for request
+	path, params, err := s.buildURL()
+	if err != nil {
+		return nil, err
+	}
+
+	// Get HTTP response
+	res, err := s.client.PerformRequest(ctx, "GET", path, params, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return operation response
+	ret := new(TasksGetTaskResponse)
+	if err := s.client.decoder.Decode(res.Body, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// TasksGetTaskResponse is the response of TasksGetTaskService.Do. Once the
+// task has completed, either Response or Error is set.
+type TasksGetTaskResponse struct {
+	Completed bool             `json:"completed"`
+	Task      *TaskInfo        `json:"task,omitempty"`
+	Response  *json.RawMessage `json:"response,omitempty"`
+	Error     *ErrorDetails    `json:"error,omitempty"`
+}
+
+// TaskInfo describes a task. Its Status depends on the action; tasks
+// deleting or updating by query report a BulkIndexByScrollResponse.
+type TaskInfo struct {
+	Node               string           `json:"node"`
+	Id                 int64            `json:"id"`
+	Type               string           `json:"type"`
+	Action             string           `json:"action"`
+	Status             *json.RawMessage `json:"status,omitempty"`
+	Description        string           `json:"description"`
+	StartTimeInMillis  int64            `json:"start_time_in_millis"`
+	RunningTimeInNanos int64            `json:"running_time_in_nanos"`
+	Cancellable        bool             `json:"cancellable"`
+	ParentTaskId       string           `json:"parent_task_id,omitempty"`
+}
@@ -0,0 +1,51 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestTasksGetTaskBuildURL(t *testing.T) {
+	path, params, err := NewTasksGetTaskService(nil).TaskId("node1:123").WaitForCompletion(true).buildURL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "/_tasks/node1%3A123"; path != expected {
+		t.Errorf("expected %q; got: %q", expected, path)
+	}
+	if got := params.Get("wait_for_completion"); got != "true" {
+		t.Errorf("expected wait_for_completion=true; got: %q", got)
+	}
+	if err = NewTasksGetTaskService(nil).Validate(); err == nil {
+		t.Errorf("expected an error without a task id")
+	}
+}
+
+func TestTasksGetTaskResponse(t *testing.T) {
+	body := `{
+		"completed": false,
+		"task": {
+			"node": "node1", "id": 123, "type": "transport", "action": "indices:data/write/delete/byquery",
+			"status": {"total": 6154, "deleted": 3500, "batches": 4},
+			"start_time_in_millis": 1483000000000, "running_time_in_nanos": 1000, "cancellable": true
+		}
+	}`
+	var res TasksGetTaskResponse
+	if err := json.Unmarshal([]byte(body), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Completed || res.Task == nil || res.Task.Status == nil {
+		t.Fatalf("expected a running task with a status; got: %+v", res)
+	}
+	var status BulkIndexByScrollResponse
+	if err := json.Unmarshal(*res.Task.Status, &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.Total != 6154 || status.Deleted != 3500 {
+		t.Errorf("expected 3500 of 6154 deleted; got: %d of %d", status.Deleted, status.Total)
+	}
+}
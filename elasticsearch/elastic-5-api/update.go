@@ -23,6 +23,7 @@ type UpdateService struct {
 	id                  string
 	routing             string
 	parent              string
+	script              *Script
 	fields              []string
 	version             *int64
 	versionType         string
@@ -77,6 +78,12 @@ func (b *UpdateService) Parent(parent string) *UpdateService {
 	return b
 }
 
+// Script is the script definition.
+func (b *UpdateService) Script(script *Script) *UpdateService {
+	b.script = script
+	return b
+}
+
 // RetryOnConflict specifies how many times the operation should be retried
 // when a conflict occurs (default: 0).
 func (b *UpdateService) RetryOnConflict(retryOnConflict int) *UpdateService {
@@ -218,6 +225,14 @@ func (b *UpdateService) url() (string, url.Values, error) {
 func (b *UpdateService) body() (interface{}, error) {
 	source := make(map[string]interface{})
 
+	if b.script != nil {
+		src, err := b.script.Source()
+		if err != nil {
+			return nil, err
+		}
+		source["script"] = src
+	}
+
 	if b.scriptedUpsert != nil {
 		source["scripted_upsert"] = *b.scriptedUpsert
 	}
@@ -0,0 +1,261 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/venicegeo/pz-gocommon/elasticsearch/elastic-5-api/uritemplates"
+)
+
+// UpdateByQueryService updates documents that match a query, running a
+// script on each or, without one, reindexing each in place so that it
+// picks up mapping changes.
+// See https://www.elastic.co/guide/en/elasticsearch/reference/5.2/docs-update-by-query.html.
+type UpdateByQueryService struct {
+	client            *Client
+	pretty            bool
+	index             []string
+	typ               []string
+	query             Query
+	script            *Script
+	conflicts         string
+	refresh           string
+	requestsPerSecond *int
+	scrollSize        *int
+	timeout           string
+	waitForCompletion *bool
+}
+
+// NewUpdateByQueryService creates a new UpdateByQueryService.
+// You typically use the client's UpdateByQuery to get a reference to
+// the service.
+func NewUpdateByQueryService(client *Client) *UpdateByQueryService {
+	return &UpdateByQueryService{
+		client: client,
+	}
+}
+
+// Index sets the indices on which to perform the update operation.
+func (s *UpdateByQueryService) Index(index ...string) *UpdateByQueryService {
+	s.index = append(s.index, index...)
+	return s
+}
+
+// Type limits the update operation to the given types.
+func (s *UpdateByQueryService) Type(typ ...string) *UpdateByQueryService {
+	s.typ = append(s.typ, typ...)
+	return s
+}
+
+// Query sets the query that selects the documents to update.
+func (s *UpdateByQueryService) Query(query Query) *UpdateByQueryService {
+	s.query = query
+	return s
+}
+
+// Script sets the script run on each document, which it sees as
+// ctx._source.
+func (s *UpdateByQueryService) Script(script *Script) *UpdateByQueryService {
+	s.script = script
+	return s
+}
+
+// Conflicts indicates what to do when the process detects version conflicts.
+// Possible values are "proceed" and "abort".
+func (s *UpdateByQueryService) Conflicts(conflicts string) *UpdateByQueryService {
+	s.conflicts = conflicts
+	return s
+}
+
+// AbortOnVersionConflict aborts the request on version conflicts.
+// It is an alias to setting Conflicts("abort").
+func (s *UpdateByQueryService) AbortOnVersionConflict() *UpdateByQueryService {
+	s.conflicts = "abort"
+	return s
+}
+
+// ProceedOnVersionConflict proceeds with the request on version conflicts,
+// counting them instead. It is an alias to setting Conflicts("proceed").
+func (s *UpdateByQueryService) ProceedOnVersionConflict() *UpdateByQueryService {
+	s.conflicts = "proceed"
+	return s
+}
+
+// Refresh indicates whether the affected indices should be refreshed.
+func (s *UpdateByQueryService) Refresh(refresh string) *UpdateByQueryService {
+	s.refresh = refresh
+	return s
+}
+
+// RequestsPerSecond sets the throttle on this request in sub-requests per
+// second. -1 means no throttle.
+func (s *UpdateByQueryService) RequestsPerSecond(requestsPerSecond int) *UpdateByQueryService {
+	s.requestsPerSecond = &requestsPerSecond
+	return s
+}
+
+// ScrollSize is the size on the scroll request powering the operation.
+func (s *UpdateByQueryService) ScrollSize(scrollSize int) *UpdateByQueryService {
+	s.scrollSize = &scrollSize
+	return s
+}
+
+// Timeout is the time each individual bulk request should wait for shards
+// that are unavailable.
+func (s *UpdateByQueryService) Timeout(timeout string) *UpdateByQueryService {
+	s.timeout = timeout
+	return s
+}
+
+// WaitForCompletion indicates whether the request should block until the
+// operation is complete. See DoAsync for the alternative.
+func (s *UpdateByQueryService) WaitForCompletion(waitForCompletion bool) *UpdateByQueryService {
+	s.waitForCompletion = &waitForCompletion
+	return s
+}
+
+// Pretty indicates that the JSON response be indented and human readable.
+func (s *UpdateByQueryService) Pretty(pretty bool) *UpdateByQueryService {
+	s.pretty = pretty
+	return s
+}
+
+// buildURL builds the URL for the operation.
+func (s *UpdateByQueryService) buildURL() (string, url.Values, error) {
+	// Build URL
+	var err error
+	var path string
+
+	if len(s.typ) > 0 {
+		path, err = uritemplates.Expand("/{index}/{type}/_update_by_query", map[string]string{
+			"index": strings.Join(s.index, ","),
+			"type":  strings.Join(s.typ, ","),
+		})
+	} else {
+		path, err = uritemplates.Expand("/{index}/_update_by_query", map[string]string{
+			"index": strings.Join(s.index, ","),
+		})
+	}
+	if err != nil {
+		return "", url.Values{}, err
+	}
+
+	// Add query string parameters
+	params := url.Values{}
+	if s.pretty {
+		params.Set("pretty", "1")
+	}
+	if s.conflicts != "" {
+		params.Set("conflicts", s.conflicts)
+	}
+	if s.refresh != "" {
+		params.Set("refresh", s.refresh)
+	}
+	if s.requestsPerSecond != nil {
+		params.Set("requests_per_second", fmt.Sprintf("%v", *s.requestsPerSecond))
+	}
+	if s.scrollSize != nil {
+		params.Set("scroll_size", fmt.Sprintf("%d", *s.scrollSize))
+	}
+	if s.timeout != "" {
+		params.Set("timeout", s.timeout)
+	}
+	if s.waitForCompletion != nil {
+		params.Set("wait_for_completion", fmt.Sprintf("%v", *s.waitForCompletion))
+	}
+	return path, params, nil
+}
+
+// Validate checks if the operation is valid.
+func (s *UpdateByQueryService) Validate() error {
+	var invalid []string
+	if len(s.index) == 0 {
+		invalid = append(invalid, "Index")
+	}
+	if len(invalid) > 0 {
+		return fmt.Errorf("missing required fields: %v", invalid)
+	}
+	return nil
+}
+
+// body returns the request body. Without a query, all documents are
+// updated.
+func (s *UpdateByQueryService) body() (interface{}, error) {
+	body := map[string]interface{}{}
+	if s.query != nil {
+		src, err := s.query.Source()
+		if err != nil {
+			return nil, err
+		}
+		body["query"] = src
+	}
+	if s.script != nil {
+		src, err := s.script.Source()
+		if err != nil {
+			return nil, err
+		}
+		body["script"] = src
+	}
+	return body, nil
+}
+
+// Do executes the update-by-query operation, waiting for it to complete
+// unless WaitForCompletion(false) is set.
+func (s *UpdateByQueryService) Do(ctx context.Context) (*BulkIndexByScrollResponse, error) {
+	res, err := s.perform(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return operation response
+	ret := new(BulkIndexByScrollResponse)
+	if err := s.client.decoder.Decode(res.Body, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// DoAsync starts the update-by-query operation as a task and returns its
+// id, to be followed with the tasks API.
+func (s *UpdateByQueryService) DoAsync(ctx context.Context) (*StartTaskResult, error) {
+	s.WaitForCompletion(false)
+	res, err := s.perform(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Return operation response
+	ret := new(StartTaskResult)
+	if err := s.client.decoder.Decode(res.Body, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (s *UpdateByQueryService) perform(ctx context.Context) (*Response, error) {
+	// Check pre-conditions
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Get URL for request
+	path, params, err := s.buildURL()
+	if err != nil {
+		return nil, err
+	}
+
+	// Setup HTTP request body
+	body, err := s.body()
+	if err != nil {
+		return nil, err
+	}
+
+	// Get HTTP response
+	return s.client.PerformRequest(ctx, "POST", path, params, body)
+}
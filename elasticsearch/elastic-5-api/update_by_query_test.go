@@ -0,0 +1,73 @@
+// Copyright 2012-present Oliver Eilhard. All rights reserved.
+// Use of this source code is governed by a MIT-license.
+// See http://olivere.mit-license.org/license.txt for details.
+
+package elastic
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestUpdateByQueryBuildURL(t *testing.T) {
+	tests := []struct {
+		Indices  []string
+		Types    []string
+		Expected string
+	}{
+		{
+			[]string{"index1"},
+			nil,
+			"/index1/_update_by_query",
+		},
+		{
+			[]string{"index1", "index2"},
+			[]string{"type1"},
+			"/index1%2Cindex2/type1/_update_by_query",
+		},
+	}
+
+	for i, test := range tests {
+		path, _, err := NewUpdateByQueryService(nil).Index(test.Indices...).Type(test.Types...).buildURL()
+		if err != nil {
+			t.Errorf("case #%d: %v", i+1, err)
+			continue
+		}
+		if path != test.Expected {
+			t.Errorf("case #%d: expected %q; got: %q", i+1, test.Expected, path)
+		}
+	}
+}
+
+func TestUpdateByQueryBody(t *testing.T) {
+	tests := []struct {
+		Query    Query
+		Script   *Script
+		Expected string
+	}{
+		{
+			nil,
+			nil,
+			`{}`,
+		},
+		{
+			NewTermQuery("user", "olivere"),
+			NewScriptInline("ctx._source.likes++").Lang("painless"),
+			`{"query":{"term":{"user":"olivere"}},"script":{"inline":"ctx._source.likes++","lang":"painless"}}`,
+		},
+	}
+
+	for i, test := range tests {
+		body, err := NewUpdateByQueryService(nil).Index("index1").Query(test.Query).Script(test.Script).body()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatalf("marshaling to JSON failed: %v", err)
+		}
+		if got := string(data); got != test.Expected {
+			t.Errorf("case #%d: expected\n%s\ngot:\n%s", i+1, test.Expected, got)
+		}
+	}
+}
@@ -77,3 +77,36 @@ func TestUpdateViaDocAndUpsert(t *testing.T) {
 		t.Errorf("expected\n%s\ngot:\n%s", expected, got)
 	}
 }
+
+func TestUpdateViaScript(t *testing.T) {
+	client := setupTestClient(t)
+	update := client.Update().
+		Index("test").Type("type1").Id("1").
+		Script(NewScript("ctx._source.tags += tag").Params(map[string]interface{}{"tag": "blue"}).Lang("painless")).
+		Upsert(map[string]interface{}{"tags": []string{"blue"}})
+	path, params, err := update.url()
+	if err != nil {
+		t.Fatalf("expected to return URL, got: %v", err)
+	}
+	expectedPath := `/test/type1/1/_update`
+	if expectedPath != path {
+		t.Errorf("expected URL path\n%s\ngot:\n%s", expectedPath, path)
+	}
+	expectedParams := url.Values{}
+	if expectedParams.Encode() != params.Encode() {
+		t.Errorf("expected URL parameters\n%s\ngot:\n%s", expectedParams.Encode(), params.Encode())
+	}
+	body, err := update.body()
+	if err != nil {
+		t.Fatalf("expected to return body, got: %v", err)
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		t.Fatalf("expected to marshal body as JSON, got: %v", err)
+	}
+	got := string(data)
+	expected := `{"script":{"inline":"ctx._source.tags += tag","lang":"painless","params":{"tag":"blue"}},"upsert":{"tags":["blue"]}}`
+	if got != expected {
+		t.Errorf("expected\n%s\ngot:\n%s", expected, got)
+	}
+}
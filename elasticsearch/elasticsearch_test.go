@@ -15,9 +15,19 @@
 package elasticsearch
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -26,6 +36,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 	"github.com/venicegeo/pz-gocommon/gocommon"
+	"golang.org/x/net/context"
 )
 
 type EsTester struct {
@@ -40,6 +51,10 @@ func (suite *EsTester) SetupSuite() {
 func (suite *EsTester) TearDownSuite() {
 }
 
+func (suite *EsTester) SetupTest() {
+	ResetMockCluster()
+}
+
 func TestRunSuite(t *testing.T) {
 	s1 := new(EsTester)
 	suite.Run(t, s1)
@@ -349,6 +364,74 @@ func (suite *EsTester) Test09FullPercolation() {
 	ok, err := esi.IndexExists()
 	assert.NoError(err)
 	assert.True(ok)
+
+	type EventType1 struct {
+		ID  string `json:"id"`
+		Str string `json:"str"`
+		Num int    `json:"num"`
+	}
+	type EventType2 struct {
+		ID  string `json:"id"`
+		Boo bool   `json:"boo"`
+		Num int    `json:"num"`
+	}
+
+	queries := map[string]string{
+		"Q1": `{"query": {"match": {"str": {"query": "kitten"}}}}`,
+		"Q2": `{"query": {"match": {"boo": true}}}`,
+		"Q3": `{"query": {"match": {"num": 17}}}`,
+		"Q4": `{"query": {"bool": {"must_not": {"term": {"num": 17}}, "should": [{"match": {"str": "lemur"}}, {"term": {"boo": false}}]}}}`,
+		"Q5": `{"query": {"bool": {"must": {"match": {"num": 17}}, "filter": {"term": {"_type": "EventType2"}}}}}`,
+		"Q6": `{"query": {"bool": {"must": [{"match": {"num": 17}}, {"match": {"_type": "EventType1"}}]}}}`,
+	}
+	for id, q := range queries {
+		_, err = esi.AddPercolationQuery(id, piazza.JsonString(q))
+		assert.NoError(err)
+	}
+
+	_, err = esi.AddPercolationQuery("bad", `{"query": {"fuzzy": {"str": "kiten"}}}`)
+	assert.Error(err)
+
+	tests := []struct {
+		typ      string
+		event    interface{}
+		expected []string
+	}{
+		{"EventType1", EventType1{ID: "E1", Str: "kitten", Num: 17}, []string{"Q1", "Q3", "Q6"}},
+		{"EventType2", EventType2{ID: "E2", Boo: true, Num: 17}, []string{"Q2", "Q3", "Q5"}},
+		{"EventType1", EventType1{ID: "E3", Str: "Lemur!", Num: -31}, []string{"Q4"}},
+		{"EventType2", EventType2{ID: "E4", Boo: true, Num: 3}, []string{"Q2"}},
+	}
+	for i, test := range tests {
+		resp, err := esi.AddPercolationDocument(test.typ, test.event)
+		assert.NoError(err)
+		assert.EqualValues(len(test.expected), resp.Total, "test #%d", i)
+		for j, match := range resp.Matches {
+			assert.Equal(test.expected[j], match.Id, "test #%d", i)
+			assert.Equal(esi.IndexName(), match.Index)
+		}
+	}
+
+	// percolated documents are not stored, and the queries are found only
+	// by a search of their type
+	hits, err := esi.FilterByMatchAll("", &piazza.JsonPagination{PerPage: 10})
+	assert.NoError(err)
+	assert.EqualValues(0, hits.TotalHits())
+	hits, err = esi.SearchByJSON("", `{"query": {"match_all": {}}}`)
+	assert.NoError(err)
+	assert.EqualValues(0, hits.TotalHits())
+	hits, err = esi.FilterByMatchAll(percolateTypeName, nil)
+	assert.NoError(err)
+	assert.EqualValues(len(queries), hits.TotalHits())
+
+	resp, err := esi.DeletePercolationQuery("Q3")
+	assert.NoError(err)
+	assert.True(resp.Found)
+
+	presp, err := esi.AddPercolationDocument("EventType1", EventType1{ID: "E5", Str: "cat", Num: 17})
+	assert.NoError(err)
+	assert.EqualValues(1, presp.Total)
+	assert.Equal("Q6", presp.Matches[0].Id)
 }
 
 func (suite *EsTester) Test10GetAll() {
@@ -724,3 +807,2306 @@ func (suite *EsTester) Test15NewIndex2() {
 	_, err = NewIndexInterface(sys, "", "", false)
 	assert.Error(err)
 }
+
+func (suite *EsTester) Test16Bulk() {
+	t := suite.T()
+	assert := assert.New(t)
+
+	esi := suite.SetUpIndex()
+	assert.NotNil(esi)
+	defer closerT(t, esi)
+
+	items := []*BulkItem{
+		{ID: "b0", Obj: Obj{ID: "b0", Data: "bulk0", Tags: "x"}},
+		{ID: "b1", Obj: Obj{ID: "b1", Data: "bulk1", Tags: "y"}},
+		{ID: "id0", Obj: Obj{ID: "id0", Data: "data0-updated", Tags: "foo bar"}},
+	}
+	bulkResponse, err := esi.BulkPostData(mapping, items)
+	assert.NoError(err)
+	assert.False(bulkResponse.Errors)
+	assert.Len(bulkResponse.Items, 3)
+	assert.Equal(201, bulkResponse.Items[0].Status)
+	assert.Equal(200, bulkResponse.Items[2].Status)
+	assert.Len(bulkResponse.Failed(), 0)
+
+	getResult, err := esi.GetByID(mapping, "b1")
+	assert.NoError(err)
+	assert.True(getResult.Found)
+
+	bulkResponse, err = esi.BulkDeleteByID(mapping, []string{"b0", "nosuchid"})
+	assert.NoError(err)
+	assert.Len(bulkResponse.Items, 2)
+	assert.True(bulkResponse.Items[0].Found)
+	assert.Equal(404, bulkResponse.Items[1].Status)
+	assert.Len(bulkResponse.Failed(), 1)
+
+	// a create item conflicts with an existing document
+	bulkResponse, err = esi.BulkPostData(mapping, []*BulkItem{
+		{ID: "b1", Obj: Obj{ID: "b1", Data: "bulk1-again"}, Create: true},
+		{ID: "b2", Obj: Obj{ID: "b2", Data: "bulk2"}, Create: true},
+	})
+	assert.NoError(err)
+	assert.True(bulkResponse.Errors)
+	assert.Equal("create", bulkResponse.Items[0].Action)
+	assert.Equal(409, bulkResponse.Items[0].Status)
+	assert.NotEmpty(bulkResponse.Items[0].Error)
+	assert.Equal(201, bulkResponse.Items[1].Status)
+	getResult, err = esi.GetByID(mapping, "b1")
+	assert.NoError(err)
+	assert.Contains(string(*getResult.Source), `"bulk1"`)
+
+	// batches of two, flushed on Close
+	flushes := []*BulkResponse{}
+	settings := NewBulkSettings()
+	settings.Actions = 2
+	settings.After = func(resp *BulkResponse, err error) {
+		assert.NoError(err)
+		flushes = append(flushes, resp)
+	}
+	bulker, err := esi.NewBulkIndexer(settings)
+	assert.NoError(err)
+	for i := 0; i < 5; i++ {
+		id := fmt.Sprintf("p%d", i)
+		err = bulker.PostData(mapping, id, Obj{ID: id, Data: id})
+		assert.NoError(err)
+	}
+	assert.Len(flushes, 2)
+	err = bulker.DeleteByID(mapping, "p0")
+	assert.NoError(err)
+	assert.Len(flushes, 3)
+	err = bulker.Close()
+	assert.NoError(err)
+	assert.Len(flushes, 3)
+	err = bulker.PostData(mapping, "p9", Obj{})
+	assert.Error(err)
+
+	ok, err := esi.ItemExists(mapping, "p0")
+	assert.NoError(err)
+	assert.False(ok)
+	ok, err = esi.ItemExists(mapping, "p4")
+	assert.NoError(err)
+	assert.True(ok)
+
+	// After may use the indexer, and what can't be marshalled isn't queued
+	settings = NewBulkSettings()
+	settings.Actions = 1
+	var chained IBulkIndexer
+	settings.After = func(resp *BulkResponse, err error) {
+		assert.NoError(err)
+		if resp.Items[0].ID == "c0" {
+			assert.NoError(chained.PostData(mapping, "c1", Obj{ID: "c1", Data: "c1"}))
+			assert.NoError(chained.Flush())
+		}
+	}
+	chained, err = esi.NewBulkIndexer(settings)
+	assert.NoError(err)
+	assert.Error(chained.PostData(mapping, "c2", map[string]interface{}{"bad": make(chan int)}))
+	assert.NoError(chained.PostData(mapping, "c0", Obj{ID: "c0", Data: "c0"}))
+	assert.NoError(chained.Close())
+	ok, err = esi.ItemExists(mapping, "c1")
+	assert.NoError(err)
+	assert.True(ok)
+	ok, err = esi.ItemExists(mapping, "c2")
+	assert.NoError(err)
+	assert.False(ok)
+
+	// so may it with an Index's indexer
+	bulked := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		items := []map[string]interface{}{}
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var line map[string]interface{}
+			assert.NoError(json.Unmarshal(scanner.Bytes(), &line))
+			if action, ok := line["index"].(map[string]interface{}); ok {
+				bulked = append(bulked, action["_id"].(string))
+				items = append(items, map[string]interface{}{"index": map[string]interface{}{"_id": action["_id"], "status": 201}})
+			}
+		}
+		byts, _ := json.Marshal(map[string]interface{}{"items": items})
+		w.Write(byts)
+	}))
+	defer server.Close()
+	client, err := elastic.NewClient(elastic.SetURL(server.URL), elastic.SetSniff(false), elastic.SetHealthcheck(false))
+	assert.NoError(err)
+	live := &Index{lib: client, index: "estest16"}
+	chained, err = live.NewBulkIndexer(settings)
+	assert.NoError(err)
+	done := make(chan error)
+	go func() {
+		if err := chained.PostData(mapping, "c0", Obj{ID: "c0", Data: "c0"}); err != nil {
+			done <- err
+			return
+		}
+		done <- chained.Close()
+	}()
+	select {
+	case err = <-done:
+		assert.NoError(err)
+	case <-time.After(5 * time.Second):
+		assert.Fail("bulk indexer deadlocked")
+	}
+	assert.Equal([]string{"c0", "c1"}, bulked)
+}
+
+func (suite *EsTester) Test17Scan() {
+	t := suite.T()
+	assert := assert.New(t)
+
+	esi := suite.SetUpIndex()
+	assert.NotNil(esi)
+	defer closerT(t, esi)
+
+	items := []*BulkItem{}
+	for i := 0; i < 25; i++ {
+		id := fmt.Sprintf("s%02d", i)
+		items = append(items, &BulkItem{ID: id, Obj: Obj{ID: id, Data: "scan", Tags: "x"}})
+	}
+	_, err := esi.BulkPostData(mapping, items)
+	assert.NoError(err)
+
+	it, err := esi.Scan(mapping, nil)
+	assert.NoError(err)
+	it.PageSize(7)
+	seen := map[string]bool{}
+	for {
+		hit, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(err)
+		assert.False(seen[hit.ID])
+		seen[hit.ID] = true
+	}
+	assert.Len(seen, 25+len(objs))
+
+	_, err = it.Next()
+	assert.Equal(io.EOF, err)
+
+	it, err = esi.Scan(mapping, elastic.NewTermQuery("data", "scan"))
+	assert.NoError(err)
+	hits, err := it.PageSize(5).All()
+	assert.NoError(err)
+	assert.Len(hits, 25)
+	assert.Equal("s00", hits[0].ID)
+	assert.Equal("s24", hits[24].ID)
+}
+
+func (suite *EsTester) Test18FilterByQuery() {
+	t := suite.T()
+	assert := assert.New(t)
+
+	esi := suite.SetUpIndex()
+	assert.NotNil(esi)
+	defer closerT(t, esi)
+
+	ids := func(q elastic.Query) []string {
+		resp, err := esi.FilterByQuery(mapping, q, nil)
+		assert.NoError(err)
+		if resp == nil {
+			return nil
+		}
+		result := []string{}
+		for _, hit := range *resp.GetHits() {
+			result = append(result, hit.ID)
+		}
+		return result
+	}
+
+	assert.Equal([]string{"id0", "id2"}, ids(elastic.NewMatchQuery("tags", "foo")))
+	assert.Equal([]string{"id0", "id1"}, ids(elastic.NewTermsQuery("data", "data0", "data1", "data9")))
+	assert.Equal([]string{"id1", "id2"}, ids(elastic.NewRangeQuery("data").Gt("data0")))
+	assert.Equal([]string{"id0", "id1", "id2"}, ids(elastic.NewExistsQuery("tags")))
+	assert.Equal([]string{}, ids(elastic.NewExistsQuery("nope")))
+	assert.Equal([]string{"id0", "id1", "id2"}, ids(elastic.NewPrefixQuery("data", "dat")))
+	assert.Equal([]string{"id1"}, ids(elastic.NewWildcardQuery("data", "d?t*1")))
+	assert.Equal([]string{"id2"}, ids(elastic.NewIdsQuery(mapping).Ids("id2", "id7")))
+	assert.Equal([]string{"id0"}, ids(elastic.NewBoolQuery().
+		Must(elastic.NewMatchQuery("tags", "bar")).
+		MustNot(elastic.NewTermQuery("id", "id1"))))
+	assert.Equal([]string{"id1", "id2"}, ids(elastic.NewBoolQuery().
+		Should(elastic.NewTermQuery("id", "id1"), elastic.NewTermQuery("id", "id2"))))
+
+	format := &piazza.JsonPagination{PerPage: 2, Page: 1}
+	resp, err := esi.FilterByQuery("", elastic.NewMatchAllQuery(), format)
+	assert.NoError(err)
+	assert.EqualValues(3, resp.TotalHits())
+	assert.Equal(1, resp.NumHits())
+	assert.Equal("id2", resp.GetHit(0).ID)
+
+	_, err = esi.FilterByQuery(mapping, nil, nil)
+	assert.Error(err)
+	_, err = esi.FilterByQuery(mapping, elastic.NewNestedQuery("x", elastic.NewMatchAllQuery()), nil)
+	assert.Error(err)
+}
+
+func (suite *EsTester) Test19Geo() {
+	t := suite.T()
+	assert := assert.New(t)
+
+	esi, err := NewIndexInterface(suite.sys, "estest19$", "", true)
+	assert.NoError(err)
+	err = esi.Create("")
+	assert.NoError(err)
+	defer closerT(t, esi)
+
+	items := map[string]MappingElementTypeName{
+		"loc":  MappingElementTypeGeoPoint,
+		"area": MappingElementTypeGeoShape,
+	}
+	jsn, err := ConstructMappingSchema("Place", items)
+	assert.NoError(err)
+	err = esi.SetMapping("Place", jsn)
+	assert.NoError(err)
+
+	places := map[string]interface{}{
+		"berlin": map[string]interface{}{
+			"loc": map[string]float64{"lat": 52.52, "lon": 13.405},
+			"area": map[string]interface{}{
+				"type":        "envelope",
+				"coordinates": [][]float64{{13.0, 52.7}, {13.8, 52.3}},
+			},
+		},
+		"potsdam": map[string]interface{}{
+			"loc": []float64{13.06, 52.39},
+		},
+		"hamburg": map[string]interface{}{
+			"loc": "53.55,9.99",
+			"area": map[string]interface{}{
+				"type":        "polygon",
+				"coordinates": [][][]float64{{{9.7, 53.4}, {10.3, 53.4}, {10.3, 53.7}, {9.7, 53.7}, {9.7, 53.4}}},
+			},
+		},
+		"sydney": map[string]interface{}{
+			"loc": "-33.87,151.21",
+		},
+	}
+	for id, place := range places {
+		_, err = esi.PostData("Place", id, place)
+		assert.NoError(err)
+	}
+
+	ids := func(resp *SearchResult, err error) []string {
+		assert.NoError(err)
+		if resp == nil {
+			return nil
+		}
+		result := []string{}
+		for _, hit := range *resp.GetHits() {
+			result = append(result, hit.ID)
+		}
+		return result
+	}
+
+	berlin := elastic.GeoPointFromLatLon(52.52, 13.405)
+	assert.Equal([]string{"berlin", "potsdam"}, ids(esi.FilterByDistance("Place", "loc", berlin, "50km", nil)))
+	assert.Equal([]string{"berlin", "hamburg", "potsdam"}, ids(esi.FilterByDistance("Place", "loc", berlin, "200mi", nil)))
+	_, err = esi.FilterByDistance("Place", "loc", berlin, "50 parsecs", nil)
+	assert.Error(err)
+
+	topLeft := elastic.GeoPointFromLatLon(54, 9)
+	bottomRight := elastic.GeoPointFromLatLon(52.45, 14)
+	assert.Equal([]string{"berlin", "hamburg"}, ids(esi.FilterByBoundingBox("Place", "loc", topLeft, bottomRight, nil)))
+
+	// across the dateline
+	topLeft = elastic.GeoPointFromLatLon(0, 150)
+	bottomRight = elastic.GeoPointFromLatLon(-40, -170)
+	assert.Equal([]string{"sydney"}, ids(esi.FilterByBoundingBox("Place", "loc", topLeft, bottomRight, nil)))
+
+	polygon := elastic.NewGeoPolygonQuery("loc").
+		AddPoint(52, 12).
+		AddPoint(54, 9).
+		AddPoint(54, 14).
+		AddPoint(52, 14)
+	assert.Equal([]string{"berlin", "hamburg", "potsdam"}, ids(esi.FilterByQuery("Place", polygon, nil)))
+
+	within := elastic.NewGeoShapeQuery("area").Envelope(53, 12, 52, 14).Relation("within")
+	assert.Equal([]string{"berlin"}, ids(esi.FilterByQuery("Place", within, nil)))
+
+	line := elastic.NewGeoShapeQuery("area").Shape("linestring", [][]float64{{9.5, 53.5}, {10.5, 53.5}})
+	assert.Equal([]string{"hamburg"}, ids(esi.FilterByQuery("Place", line, nil)))
+
+	contains := elastic.NewGeoShapeQuery("area").Point(52.5, 13.4).Relation("contains")
+	assert.Equal([]string{"berlin"}, ids(esi.FilterByQuery("Place", contains, nil)))
+
+	disjoint := elastic.NewGeoShapeQuery("area").Point(52.5, 13.4).Relation("disjoint")
+	assert.Equal([]string{"hamburg"}, ids(esi.FilterByQuery("Place", disjoint, nil)))
+}
+
+func (suite *EsTester) Test20Aggregations() {
+	t := suite.T()
+	assert := assert.New(t)
+
+	esi, err := NewIndexInterface(suite.sys, "estest20$", "", true)
+	assert.NoError(err)
+	err = esi.Create("")
+	assert.NoError(err)
+	defer closerT(t, esi)
+
+	items := map[string]MappingElementTypeName{
+		"status":   MappingElementTypeKeyword,
+		"created":  MappingElementTypeDate,
+		"duration": MappingElementTypeInteger,
+		"user":     MappingElementTypeKeyword,
+		"loc":      MappingElementTypeGeoPoint,
+	}
+	jsn, err := ConstructMappingSchema("Job", items)
+	assert.NoError(err)
+	err = esi.SetMapping("Job", jsn)
+	assert.NoError(err)
+
+	type Job struct {
+		Status   string `json:"status"`
+		Created  string `json:"created"`
+		Duration int    `json:"duration"`
+		User     string `json:"user"`
+		Loc      string `json:"loc"`
+	}
+	jobs := map[string]Job{
+		"j1": {"Success", "2017-03-01T10:00:00Z", 10, "alice", "52.52,13.405"},
+		"j2": {"Success", "2017-03-01T14:30:00Z", 20, "bob", "52.52,13.405"},
+		"j3": {"Error", "2017-03-01T23:59:59Z", 30, "alice", "53.55,9.99"},
+		"j4": {"Success", "2017-03-03T08:00:00Z", 40, "carol", "52.52,13.405"},
+		"j5": {"Running", "2017-03-03T09:00:00Z", 50, "bob", "53.55,9.99"},
+	}
+	for id, job := range jobs {
+		_, err = esi.PostData("Job", id, job)
+		assert.NoError(err)
+	}
+
+	aggs := map[string]elastic.Aggregation{
+		"statuses": elastic.NewTermsAggregation().Field("status").
+			SubAggregation("duration", elastic.NewStatsAggregation().Field("duration")),
+		"perDay": elastic.NewDateHistogramAggregation().Field("created").Interval("day"),
+		"users":  elastic.NewCardinalityAggregation().Field("user"),
+		"cells":  elastic.NewGeoHashGridAggregation().Field("loc").Precision(3),
+	}
+	resp, err := esi.Aggregate("Job", nil, aggs)
+	assert.NoError(err)
+	assert.EqualValues(5, resp.TotalHits())
+	assert.Equal(0, resp.NumHits())
+
+	statuses, ok := resp.Aggregations.Terms("statuses")
+	assert.True(ok)
+	assert.Len(statuses.Buckets, 3)
+	assert.Equal("Success", statuses.Buckets[0].Key)
+	assert.EqualValues(3, statuses.Buckets[0].DocCount)
+	assert.Equal("Error", statuses.Buckets[1].Key)
+	assert.Equal("Running", statuses.Buckets[2].Key)
+	duration, ok := statuses.Buckets[0].Stats("duration")
+	assert.True(ok)
+	assert.EqualValues(3, duration.Count)
+	assert.InDelta(70.0/3.0, *duration.Avg, 0.0001)
+	assert.EqualValues(40, *duration.Max)
+
+	perDay, ok := resp.Aggregations.DateHistogram("perDay")
+	assert.True(ok)
+	counts := []int64{}
+	for _, bucket := range perDay.Buckets {
+		counts = append(counts, bucket.DocCount)
+	}
+	assert.Equal([]int64{3, 0, 2}, counts)
+	assert.Equal("2017-03-01T00:00:00.000Z", *perDay.Buckets[0].KeyAsString)
+	assert.EqualValues(1488326400000, perDay.Buckets[0].Key)
+
+	users, ok := resp.Aggregations.Cardinality("users")
+	assert.True(ok)
+	assert.EqualValues(3, *users.Value)
+
+	cells, ok := resp.Aggregations.GeoHash("cells")
+	assert.True(ok)
+	assert.Len(cells.Buckets, 2)
+	assert.Equal("u33", cells.Buckets[0].Key)
+	assert.EqualValues(3, cells.Buckets[0].DocCount)
+
+	// the query narrows the documents aggregated over
+	resp, err = esi.Aggregate("Job", elastic.NewTermQuery("user", "bob"), map[string]elastic.Aggregation{
+		"statuses": elastic.NewTermsAggregation().Field("status").Size(1),
+	})
+	assert.NoError(err)
+	assert.EqualValues(2, resp.TotalHits())
+	statuses, ok = resp.Aggregations.Terms("statuses")
+	assert.True(ok)
+	assert.Len(statuses.Buckets, 1)
+	assert.Equal("Running", statuses.Buckets[0].Key)
+	assert.EqualValues(1, statuses.SumOfOtherDocCount)
+
+	_, err = esi.Aggregate("Job", nil, nil)
+	assert.Error(err)
+	_, err = esi.Aggregate("Job", nil, map[string]elastic.Aggregation{
+		"bad": elastic.NewDateHistogramAggregation().Field("created").Interval("fortnight"),
+	})
+	assert.Error(err)
+}
+
+func (suite *EsTester) Test21MockSearch() {
+	t := suite.T()
+	assert := assert.New(t)
+
+	esi, err := NewIndexInterface(suite.sys, "estest21$", "", true)
+	assert.NoError(err)
+	err = esi.Create("")
+	assert.NoError(err)
+	defer closerT(t, esi)
+
+	items := map[string]MappingElementTypeName{
+		"name":  MappingElementTypeText,
+		"count": MappingElementTypeInteger,
+		"ok":    MappingElementTypeBool,
+	}
+	jsn, err := ConstructMappingSchema("Thing", items)
+	assert.NoError(err)
+	err = esi.SetMapping("Thing", jsn)
+	assert.NoError(err)
+
+	type Thing struct {
+		Name  string `json:"name"`
+		Count int    `json:"count"`
+		Ok    bool   `json:"ok"`
+	}
+	things := map[string]Thing{
+		"a": {"quick brown fox", 3, true},
+		"b": {"lazy brown dog", 1, false},
+		"c": {"quick red fox", 4, true},
+		"d": {"slow green turtle", 1, true},
+		"e": {"lazy cat", 5, false},
+	}
+	for id, thing := range things {
+		_, err = esi.PostData("Thing", id, thing)
+		assert.NoError(err)
+	}
+
+	ids := func(resp *SearchResult, err error) []string {
+		assert.NoError(err)
+		if resp == nil {
+			return nil
+		}
+		result := []string{}
+		for _, hit := range *resp.GetHits() {
+			result = append(result, hit.ID)
+		}
+		return result
+	}
+
+	// sorting and pagination
+	format := &piazza.JsonPagination{PerPage: 3, Page: 0, SortBy: "count", Order: piazza.SortOrderDescending}
+	assert.Equal([]string{"e", "c", "a"}, ids(esi.FilterByMatchAll("Thing", format)))
+	format.Page = 1
+	assert.Equal([]string{"b", "d"}, ids(esi.FilterByMatchAll("Thing", format)))
+
+	// match queries
+	assert.Equal([]string{"a", "b"}, ids(esi.FilterByMatchQuery("Thing", "name", "brown", nil)))
+	assert.Equal([]string{"a", "b", "c"}, ids(esi.FilterByMatchQuery("Thing", "name", "brown quick", nil)))
+	_, err = esi.FilterByMatchQuery("Nothing", "name", "brown", nil)
+	assert.Error(err)
+
+	// term queries on non-string fields, with pagination
+	resp, err := esi.FilterByTermQuery("Thing", "ok", true, &piazza.JsonPagination{PerPage: 2, Page: 0, SortBy: "count", Order: piazza.SortOrderAscending})
+	assert.Equal([]string{"d", "a"}, ids(resp, err))
+	assert.EqualValues(3, resp.TotalHits())
+	assert.Equal([]string{"b", "d"}, ids(esi.FilterByTermQuery("Thing", "count", 1, nil)))
+
+	// raw JSON
+	body := `{
+		"query": {
+			"bool": {
+				"must": {"match": {"name": "quick lazy"}},
+				"filter": {"range": {"count": {"gte": 2}}}
+			}
+		},
+		"sort": [{"count": {"order": "asc"}}],
+		"from": 1,
+		"size": 2
+	}`
+	resp, err = esi.SearchByJSON("Thing", body)
+	assert.Equal([]string{"c", "e"}, ids(resp, err))
+	assert.EqualValues(3, resp.TotalHits())
+
+	body = `{"size": 0, "aggs": {"oks": {"terms": {"field": "ok"}}}}`
+	resp, err = esi.SearchByJSON("Thing", body)
+	assert.NoError(err)
+	assert.Equal(0, resp.NumHits())
+	oks, ok := resp.Aggregations.Terms("oks")
+	assert.True(ok)
+	assert.Len(oks.Buckets, 2)
+	assert.EqualValues(3, oks.Buckets[0].DocCount)
+
+	_, err = esi.SearchByJSON("Thing", `{"rescore": {}}`)
+	assert.Error(err)
+	_, err = esi.SearchByJSON("Thing", `{"query": {"more_like_this": {}}}`)
+	assert.Error(err)
+
+	// everything
+	resp, err = esi.GetAllElements("Thing")
+	assert.Equal([]string{"a", "b", "c", "d", "e"}, ids(resp, err))
+
+	// mappings
+	mapping, err := esi.GetMapping("Thing")
+	assert.NoError(err)
+	props := mapping.(map[string]interface{})["Thing"].(map[string]interface{})["properties"].(map[string]interface{})
+	assert.Len(props, 3)
+}
+
+func (suite *EsTester) Test22Context() {
+	t := suite.T()
+	assert := assert.New(t)
+
+	esi := suite.SetUpIndex()
+	assert.NotNil(esi)
+	defer closerT(t, esi)
+
+	// a view shares the index's documents
+	ctx, cancel := context.WithCancel(context.Background())
+	view := esi.WithContext(ctx)
+	_, err := view.PostData(mapping, "id9", Obj{ID: "id9", Data: "data9", Tags: "baz"})
+	assert.NoError(err)
+	getResult, err := esi.GetByID(mapping, "id9")
+	assert.NoError(err)
+	assert.True(getResult.Found)
+
+	// once cancelled, the view's operations fail, but the index's don't
+	cancel()
+	_, err = view.GetByID(mapping, "id9")
+	assert.Equal(context.Canceled, err)
+	_, err = view.FilterByMatchAll(mapping, nil)
+	assert.Equal(context.Canceled, err)
+	_, err = view.PostData(mapping, "id10", Obj{ID: "id10", Data: "data10", Tags: "baz"})
+	assert.Equal(context.Canceled, err)
+	err = view.SetMapping("Other", piazza.JsonString(`{"Other":{}}`))
+	assert.Equal(context.Canceled, err)
+	_, err = esi.GetByID(mapping, "id9")
+	assert.NoError(err)
+
+	// so do the flushes of its bulk indexers, with no retrying
+	bulker, err := view.NewBulkIndexer(&BulkSettings{Actions: -1, Size: -1})
+	assert.NoError(err)
+	assert.NoError(bulker.PostData(mapping, "id11", objs[0]))
+	assert.Equal(context.Canceled, bulker.Flush())
+	assert.NoError(bulker.Close())
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`{"items":[]}`))
+	}))
+	defer server.Close()
+	client, err := elastic.NewClient(elastic.SetURL(server.URL), elastic.SetSniff(false), elastic.SetHealthcheck(false))
+	assert.NoError(err)
+	live := &Index{lib: client, index: "estest22"}
+	bulker, err = live.WithContext(ctx).NewBulkIndexer(&BulkSettings{Actions: -1, Size: -1})
+	assert.NoError(err)
+	assert.NoError(bulker.PostData(mapping, "id0", objs[0]))
+	start := time.Now()
+	err = bulker.Flush()
+	assert.Equal(context.Canceled, err)
+	assert.True(time.Since(start) < time.Second)
+	assert.NoError(bulker.Close())
+	bulker, err = live.NewBulkIndexer(&BulkSettings{Actions: -1, Size: -1})
+	assert.NoError(err)
+	assert.NoError(bulker.PostData(mapping, "id0", objs[0]))
+	assert.NoError(bulker.Flush())
+	assert.NoError(bulker.Close())
+	assert.Equal(2, requests, "the type is checked, then the bulk request made")
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	<-ctx.Done()
+	_, err = esi.WithContext(ctx).FilterByQuery(mapping, elastic.NewMatchAllQuery(), nil)
+	assert.True(IsTimeout(err))
+
+	// zero timeouts take the defaults
+	options := (&IndexOptions{ReadTimeout: time.Second, WriteTimeout: -1}).withDefaults()
+	assert.Equal(time.Second, options.ReadTimeout)
+	assert.EqualValues(-1, options.WriteTimeout)
+	assert.Equal(DefaultAdminTimeout, options.AdminTimeout)
+	_, err = NewIndex2("", "", "", "x", "", NewIndexOptions(), NewIndexOptions())
+	assert.Error(err)
+}
+
+func (suite *EsTester) Test23Versions() {
+	t := suite.T()
+	assert := assert.New(t)
+
+	esi := suite.SetUpIndex()
+	assert.NotNil(esi)
+	defer closerT(t, esi)
+
+	// create-only
+	resp, err := esi.CreateData(mapping, "id7", Obj{ID: "id7", Data: "data7", Tags: "foo"})
+	assert.NoError(err)
+	assert.True(resp.Created)
+	assert.Equal(1, resp.Version)
+	_, err = esi.CreateData(mapping, "id7", Obj{ID: "id7", Data: "other", Tags: "foo"})
+	assert.True(IsConflict(err))
+
+	// put-if-version
+	resp, err = esi.PutDataIfVersion(mapping, "id7", Obj{ID: "id7", Data: "data7b", Tags: "foo"}, 1)
+	assert.NoError(err)
+	assert.False(resp.Created)
+	assert.Equal(2, resp.Version)
+	_, err = esi.PutDataIfVersion(mapping, "id7", Obj{ID: "id7", Data: "stale", Tags: "foo"}, 1)
+	assert.True(IsConflict(err))
+	getResult, err := esi.GetByID(mapping, "id7")
+	assert.NoError(err)
+	assert.Equal(2, getResult.Version)
+	var obj Obj
+	assert.NoError(json.Unmarshal(*getResult.Source, &obj))
+	assert.Equal("data7b", obj.Data)
+
+	// partial updates
+	resp, err = esi.UpdateData(mapping, "id7", &Update{Doc: map[string]string{"tags": "bar"}, Version: 2})
+	assert.NoError(err)
+	assert.Equal(3, resp.Version)
+	getResult, err = esi.GetByID(mapping, "id7")
+	assert.NoError(err)
+	assert.NoError(json.Unmarshal(*getResult.Source, &obj))
+	assert.Equal(Obj{ID: "id7", Data: "data7b", Tags: "bar"}, obj)
+	_, err = esi.UpdateData(mapping, "id7", &Update{Doc: map[string]string{"tags": "baz"}, Version: 2})
+	assert.True(IsConflict(err))
+
+	resp, err = esi.UpdateData(mapping, "id7", &Update{Doc: map[string]string{"tags": "bar"}})
+	assert.NoError(err)
+	assert.Equal(3, resp.Version, "a no-op update keeps the version")
+
+	_, err = esi.UpdateData(mapping, "id8", &Update{Doc: map[string]string{"tags": "bar"}})
+	assert.Error(err)
+	assert.False(IsConflict(err))
+	resp, err = esi.UpdateData(mapping, "id8", &Update{Doc: Obj{ID: "id8", Data: "data8"}, DocAsUpsert: true})
+	assert.NoError(err)
+	assert.True(resp.Created)
+	assert.Equal(1, resp.Version)
+
+	// scripted updates
+	script := elastic.NewScriptInline("ctx._source.tags = params.tags; ctx._source.remove('data')").
+		Param("tags", "qux")
+	resp, err = esi.UpdateData(mapping, "id7", &Update{Script: script, Version: 3})
+	assert.NoError(err)
+	assert.Equal(4, resp.Version)
+	getResult, err = esi.GetByID(mapping, "id7")
+	assert.NoError(err)
+	obj = Obj{}
+	assert.NoError(json.Unmarshal(*getResult.Source, &obj))
+	assert.Equal(Obj{ID: "id7", Tags: "qux"}, obj)
+	resp, err = esi.UpdateData(mapping, "id7", &Update{Script: script})
+	assert.NoError(err)
+	assert.Equal(4, resp.Version, "a no-op update keeps the version")
+	_, err = esi.UpdateData(mapping, "id7", &Update{Script: elastic.NewScriptInline("ctx._source.n += 1")})
+	assert.Error(err)
+	_, err = esi.UpdateData(mapping, "id7", &Update{Script: elastic.NewScriptInline(`ctx._source.owner.name = "x"`)})
+	assert.Error(err)
+	getResult, err = esi.GetByID(mapping, "id7")
+	assert.NoError(err)
+	assert.Equal(4, getResult.Version)
+	resp, err = esi.UpdateData(mapping, "id9", &Update{Script: script, Upsert: Obj{ID: "id9", Tags: "foo"}})
+	assert.NoError(err)
+	assert.True(resp.Created)
+
+	_, err = esi.UpdateData(mapping, "id8", &Update{})
+	assert.Error(err)
+	_, err = esi.UpdateData(mapping, "id8", &Update{Doc: obj, Version: 1, RetryOnConflict: 3})
+	assert.Error(err)
+
+	_, err = esi.PutData(mapping, "", obj)
+	assert.Error(err)
+}
+
+func (suite *EsTester) Test24Aliases() {
+	t := suite.T()
+	assert := assert.New(t)
+
+	const alias = "estest24"
+
+	v1 := NewMockIndex(BackingIndexName(alias, 1))
+	assert.NoError(v1.Create(""))
+	defer closerT(t, v1)
+	assert.NoError(v1.SetMapping(mapping, objMapping))
+	for _, o := range objs {
+		_, err := v1.PostData(mapping, o.ID, o)
+		assert.NoError(err)
+	}
+	_, err := v1.AddPercolationQuery("q1", `{"query": {"term": {"tags": "foo"}}}`)
+	assert.NoError(err)
+	assert.NoError(v1.AddAlias(alias))
+	assert.Error(v1.AddAlias(v1.IndexName()))
+
+	// the alias reads and writes through to the backing index
+	esi := NewMockIndex(alias)
+	getResult, err := esi.GetAllElements(mapping)
+	assert.NoError(err)
+	assert.EqualValues(len(objs), getResult.TotalHits())
+	aliases, err := esi.GetAliases()
+	assert.NoError(err)
+	assert.Equal([]string{alias}, aliases)
+
+	name, err := NextBackingIndexName(esi, alias)
+	assert.NoError(err)
+	assert.Equal("estest24_v2", name)
+	a, version, ok := ParseBackingIndexName(name)
+	assert.True(ok)
+	assert.Equal(alias, a)
+	assert.Equal(2, version)
+	_, _, ok = ParseBackingIndexName("estest24")
+	assert.False(ok)
+
+	// change a mapping by reindexing
+	v2 := NewMockIndex(name)
+	assert.NoError(v2.Create(`{"mappings": {"Obj": {"properties": {"tags": {"type": "keyword"}}}}}`))
+	defer closerT(t, v2)
+	n, err := Reindex(alias, esi, v2)
+	assert.NoError(err)
+	assert.Equal(len(objs)+1, n)
+
+	indices, err := v2.AliasIndices(alias)
+	assert.NoError(err)
+	assert.Equal([]string{name}, indices)
+	aliases, err = v1.GetAliases()
+	assert.NoError(err)
+	assert.Empty(aliases)
+
+	esi = NewMockIndex(alias)
+	assert.Equal(name, esi.IndexName())
+	m, err := esi.GetMapping(mapping)
+	assert.NoError(err)
+	assert.Equal(map[string]interface{}{
+		"Obj": map[string]interface{}{
+			"properties": map[string]interface{}{"tags": map[string]interface{}{"type": "keyword"}},
+		},
+	}, m)
+	getResult, err = esi.GetAllElements(mapping)
+	assert.NoError(err)
+	assert.EqualValues(len(objs), getResult.TotalHits())
+	presp, err := esi.AddPercolationDocument(mapping, objs[2])
+	assert.NoError(err)
+	assert.EqualValues(1, presp.Total)
+
+	// an alias can point to several indices
+	assert.NoError(v1.AddAlias(alias))
+	indices, err = v1.AliasIndices(alias)
+	assert.NoError(err)
+	assert.Equal([]string{BackingIndexName(alias, 1), name}, indices)
+	assert.NoError(v1.RemoveAlias(alias))
+	assert.Error(v1.RemoveAlias(alias))
+
+	// deleting an index drops its aliases
+	assert.NoError(v2.SwapAlias("estest24b"))
+	assert.NoError(v2.Delete())
+	indices, err = v1.AliasIndices("estest24b")
+	assert.NoError(err)
+	assert.Empty(indices)
+	assert.NoError(v2.Create(""))
+
+	// between tests, aliases and templates are forgotten
+	assert.NoError(v1.AddAlias(alias))
+	assert.NoError(v1.PutTemplate("estest24", NewIndexTemplate("estest24*", nil)))
+	ResetMockCluster()
+	indices, err = v1.AliasIndices(alias)
+	assert.NoError(err)
+	assert.Empty(indices)
+	assert.Error(v1.DeleteTemplate("estest24"))
+}
+
+func (suite *EsTester) Test25Mapping() {
+	t := suite.T()
+	assert := assert.New(t)
+
+	esi := suite.SetUpIndex()
+	assert.NotNil(esi)
+	defer closerT(t, esi)
+
+	m := NewMapping("Event").
+		Dynamic("strict").
+		Property("id", NewProperty(MappingElementTypeKeyword)).
+		Property("text", NewProperty(MappingElementTypeText).Analyzer("english").KeywordField()).
+		Property("tags", NewProperty(MappingElementTypeKeywordA)).
+		Property("when", NewProperty(MappingElementTypeDate).Format("epoch_millis")).
+		Property("blob", NewProperty(MappingElementTypeBinary).Index(false)).
+		Property("owner", NewObjectProperty().
+			Property("name", NewProperty(MappingElementTypeKeyword))).
+		Property("hits", NewNestedProperty().
+			Property("count", NewProperty(MappingElementTypeLong))).
+		DynamicTemplate(NewDynamicTemplate("strings", NewProperty(MappingElementTypeKeyword)).
+			MatchMappingType("string")).
+		Param("_all", map[string]interface{}{"enabled": false})
+
+	jsn, err := m.JSON()
+	assert.NoError(err)
+	expected := `{"Event":{` +
+		`"_all":{"enabled":false},` +
+		`"dynamic":"strict",` +
+		`"dynamic_templates":[{"strings":{"mapping":{"type":"keyword"},"match_mapping_type":"string"}}],` +
+		`"properties":{` +
+		`"blob":{"index":false,"type":"binary"},` +
+		`"hits":{"properties":{"count":{"type":"long"}},"type":"nested"},` +
+		`"id":{"type":"keyword"},` +
+		`"owner":{"properties":{"name":{"type":"keyword"}},"type":"object"},` +
+		`"tags":{"type":"keyword"},` +
+		`"text":{"analyzer":"english","fields":{"keyword":{"ignore_above":256,"type":"keyword"}},"type":"text"},` +
+		`"when":{"format":"epoch_millis","type":"date"}}}}`
+	assert.Equal(expected, string(jsn))
+
+	// round trip
+	assert.NoError(esi.SetMapping("Event", jsn))
+	got, err := esi.GetMapping("Event")
+	assert.NoError(err)
+	parsed, err := ParseMapping("Event", got)
+	assert.NoError(err)
+	assert.Equal(m, parsed)
+	assert.NoError(parsed.Validate())
+
+	// Elasticsearch leaves out the type of objects
+	parsed, err = ParseMapping("Obj", piazza.JsonString(`{"Obj": {"properties": {"a": {"properties": {}}}}}`))
+	assert.NoError(err)
+	assert.Equal(NewMapping("Obj").Property("a", NewObjectProperty()), parsed)
+	_, err = ParseMapping("Obj", piazza.JsonString(`{"Obj": {"properties": {"a": {"ignore_above": "x"}}}}`))
+	assert.Error(err)
+
+	// validation
+	bad := []*Mapping{
+		NewMapping(""),
+		NewMapping("_x"),
+		NewMapping("x").Dynamic("sometimes"),
+		NewMapping("x").Property("a", NewProperty("string")),
+		NewMapping("x").Property("a", NewProperty(MappingElementTypeLong).Analyzer("english")),
+		NewMapping("x").Property("a", NewProperty(MappingElementTypeText).Format("epoch_millis")),
+		NewMapping("x").Property("a", NewProperty(MappingElementTypeText).IgnoreAbove(10)),
+		NewMapping("x").Property("a", NewProperty(MappingElementTypeText).Property("b", NewProperty(MappingElementTypeText))),
+		NewMapping("x").Property("a", NewObjectProperty().Index(false)),
+		NewMapping("x").Property("a", NewObjectProperty().Property("b", NewProperty("bogus"))),
+		NewMapping("x").Property("a", NewProperty(MappingElementTypeText).Field("b", NewObjectProperty())),
+		NewMapping("x").Property("", NewProperty(MappingElementTypeText)),
+		NewMapping("x").Property("a", nil),
+		NewMapping("x").DynamicTemplate(NewDynamicTemplate("t", NewProperty(MappingElementTypeText))),
+		NewMapping("x").DynamicTemplate(NewDynamicTemplate("t", nil).Match("*")),
+	}
+	for i, m := range bad {
+		_, err = m.JSON()
+		assert.Error(err, "case %d", i)
+	}
+	_, err = NewMapping("x").
+		DynamicTemplate(NewDynamicTemplate("t", NewProperty(mappingDynamicType).Index(false)).Match("raw_*")).
+		JSON()
+	assert.NoError(err)
+
+	_, err = ConstructMappingSchema("x", map[string]MappingElementTypeName{"a": "bogus"})
+	assert.Error(err)
+	jsn, err = ConstructMappingSchema("x", map[string]MappingElementTypeName{"a": MappingElementTypeTextA})
+	assert.NoError(err)
+	assert.Equal(`{"x":{"properties":{"a":{"type":"text"}}}}`, string(jsn))
+}
+
+func (suite *EsTester) Test26MappingDiff() {
+	t := suite.T()
+	assert := assert.New(t)
+
+	esi := suite.SetUpIndex()
+	assert.NotNil(esi)
+	defer closerT(t, esi)
+
+	v1 := NewMapping("Event").
+		Property("id", NewProperty(MappingElementTypeKeyword)).
+		Property("text", NewProperty(MappingElementTypeText).Analyzer("english")).
+		Property("owner", NewObjectProperty().
+			Property("name", NewProperty(MappingElementTypeKeyword)))
+
+	diff, err := UpdateMapping(esi, v1)
+	assert.NoError(err)
+	assert.Equal(MappingAdditive, diff.Change)
+	assert.Equal([]string{"id", "owner", "text"}, diff.Added)
+	_, err = esi.PostData("Event", "e1", map[string]interface{}{"id": "e1", "text": "hello"})
+	assert.NoError(err)
+
+	diff, err = UpdateMapping(esi, v1)
+	assert.NoError(err)
+	assert.Equal(MappingIdentical, diff.Change)
+	assert.Equal("identical", diff.Change.String())
+
+	// fields and multi-fields may be added, and some settings changed
+	v2 := NewMapping("Event").
+		Property("id", NewProperty(MappingElementTypeKeyword)).
+		Property("text", NewProperty(MappingElementTypeText).Analyzer("english").SearchAnalyzer("standard").KeywordField()).
+		Property("owner", NewObjectProperty().
+			Property("name", NewProperty(MappingElementTypeKeyword)).
+			Property("email", NewProperty(MappingElementTypeKeyword)))
+	diff, err = UpdateMapping(esi, v2)
+	assert.NoError(err)
+	assert.Equal(MappingAdditive, diff.Change)
+	assert.Equal([]string{"owner.email", "text.keyword"}, diff.Added)
+	assert.Equal([]string{"text"}, diff.Updated)
+	diff, err = DiffMapping(esi, v2)
+	assert.NoError(err)
+	assert.Equal(MappingIdentical, diff.Change)
+	getResult, err := esi.GetByID("Event", "e1")
+	assert.NoError(err)
+	assert.True(getResult.Found, "updating a mapping keeps the documents")
+
+	// leaving fields out changes nothing
+	diff, err = UpdateMapping(esi, NewMapping("Event").Property("id", NewProperty(MappingElementTypeKeyword)))
+	assert.NoError(err)
+	assert.Equal(MappingIdentical, diff.Change)
+	assert.Equal([]string{"owner", "text"}, diff.Removed)
+
+	// anything else needs a reindex
+	v3 := NewMapping("Event").
+		Property("id", NewProperty(MappingElementTypeLong)).
+		Property("text", NewProperty(MappingElementTypeText).Analyzer("standard")).
+		Property("owner", NewNestedProperty())
+	diff, err = UpdateMapping(esi, v3)
+	assert.Error(err)
+	assert.True(IsIncompatibleMapping(err))
+	assert.Equal(MappingIncompatible, diff.Change)
+	assert.Len(diff.Conflicts, 3)
+	assert.Error(esi.SetMapping("Event", `{"properties": {"id": {"type": "long"}}}`))
+	diff, err = DiffMapping(esi, v2)
+	assert.NoError(err)
+	assert.Equal(MappingIdentical, diff.Change)
+
+	current := NewMapping("x").Property("a", NewProperty(MappingElementTypeDate).Format("epoch_millis"))
+	wanted := NewMapping("x").Property("a", NewProperty(MappingElementTypeDate).Format("yyyy-MM-dd").Index(false)).
+		Param("_all", map[string]bool{"enabled": false}).Param("_meta", map[string]int{"v": 2}).Dynamic("strict")
+	diff = CompareMappings(current, wanted)
+	assert.Equal(MappingIncompatible, diff.Change)
+	assert.Equal([]string{
+		"_all: cannot change from unset to {\"enabled\":false}",
+		"a: cannot change format from \"epoch_millis\" to \"yyyy-MM-dd\"",
+		"a: cannot change index from unset to false",
+	}, diff.Conflicts)
+	assert.Equal([]string{"_meta", "dynamic"}, diff.Updated)
+
+	// Elasticsearch leaves out the parameters which take their defaults
+	explicit := piazza.JsonString(`{"Obj":{"properties":{
+		"when":{"type":"date","format":"strict_date_optional_time||epoch_millis","index":true,"doc_values":true},
+		"name":{"type":"keyword","index":true,"norms":false,"doc_values":true,"store":false},
+		"text":{"type":"text","norms":true,"boost":1.0,"fields":{"raw":{"type":"keyword","doc_values":true}}},
+		"count":{"type":"long","coerce":true},
+		"owner":{"type":"object","enabled":true,"properties":{"id":{"type":"keyword","index":true}}}}}}`)
+	reported := piazza.JsonString(`{"Obj":{"properties":{
+		"when":{"type":"date"},
+		"name":{"type":"keyword"},
+		"text":{"type":"text","fields":{"raw":{"type":"keyword"}}},
+		"count":{"type":"long"},
+		"owner":{"properties":{"id":{"type":"keyword"}}}}}}`)
+	wanted, err = ParseMapping("Obj", explicit)
+	assert.NoError(err)
+	current, err = ParseMapping("Obj", reported)
+	assert.NoError(err)
+	diff = CompareMappings(current, wanted)
+	assert.Equal(MappingIdentical, diff.Change, "%v", diff.Conflicts)
+	diff = CompareMappings(wanted, current)
+	assert.Equal(MappingIdentical, diff.Change, "%v", diff.Conflicts)
+
+	// but not those which don't
+	wanted, err = ParseMapping("Obj", piazza.JsonString(`{"Obj":{"properties":{
+		"when":{"type":"date","format":"epoch_millis"},
+		"name":{"type":"keyword","index":false,"norms":true},
+		"text":{"type":"text","fields":{"raw":{"type":"keyword","doc_values":false}}},
+		"count":{"type":"long"},
+		"owner":{"properties":{"id":{"type":"keyword"}}}}}}`))
+	assert.NoError(err)
+	diff = CompareMappings(current, wanted)
+	assert.Equal(MappingIncompatible, diff.Change)
+	assert.Len(diff.Conflicts, 4)
+}
+
+func (suite *EsTester) Test27Settings() {
+	t := suite.T()
+	assert := assert.New(t)
+
+	settings := NewIndexSettings().
+		Shards(1).
+		Replicas(0).
+		RefreshInterval("5s").
+		Analyzer("folded", "standard", "lowercase", "asciifolding").
+		Param("max_result_window", 50000).
+		Mapping(NewMapping("Obj").Property("tags", NewProperty(MappingElementTypeText).Analyzer("folded")))
+	jsn, err := settings.JSON()
+	assert.NoError(err)
+	assert.Equal(`{"mappings":{"Obj":{"properties":{"tags":{"analyzer":"folded","type":"text"}}}},`+
+		`"settings":{"analysis":{"analyzer":{"folded":{"filter":["lowercase","asciifolding"],"tokenizer":"standard","type":"custom"}}},`+
+		`"max_result_window":50000,"number_of_replicas":0,"number_of_shards":1,"refresh_interval":"5s"}}`, jsn)
+
+	esi := NewMockIndex("estest27")
+	assert.NoError(esi.Create(jsn))
+	defer closerT(t, esi)
+
+	got, err := esi.GetSettings()
+	assert.NoError(err)
+	assert.Equal(1, got.GetShards())
+	replicas, ok := got.GetReplicas()
+	assert.True(ok)
+	assert.Equal(0, replicas)
+	assert.Equal("5s", got.GetRefreshInterval())
+	_, ok = got.GetAnalysis(AnalysisAnalyzer, "folded")
+	assert.True(ok)
+	ok, err = esi.TypeExists("Obj")
+	assert.NoError(err)
+	assert.True(ok)
+
+	assert.NoError(esi.UpdateSettings(NewIndexSettings().Replicas(2).RefreshInterval("-1")))
+	got, err = esi.GetSettings()
+	assert.NoError(err)
+	replicas, _ = got.GetReplicas()
+	assert.Equal(2, replicas)
+	assert.Equal("-1", got.GetRefreshInterval())
+	assert.Equal(1, got.GetShards())
+	assert.Error(esi.UpdateSettings(NewIndexSettings().Shards(3)))
+	assert.Error(esi.UpdateSettings(NewIndexSettings().Mapping(NewMapping("Other"))))
+	assert.Error(esi.UpdateSettings(NewIndexSettings().Replicas(-1)))
+
+	// what Elasticsearch reports, strings and all
+	parsed, err := ParseIndexSettings(map[string]interface{}{
+		"index": map[string]interface{}{
+			"number_of_shards":           "5",
+			"number_of_replicas":         "1",
+			"creation_date":              "1500000000000",
+			"uuid":                       "abc",
+			"version":                    map[string]interface{}{"created": "5050099"},
+			"provided_name":              "estest27",
+			"mapping.total_fields.limit": "2000",
+		},
+	})
+	assert.NoError(err)
+	assert.Equal(5, parsed.GetShards())
+	replicas, _ = parsed.GetReplicas()
+	assert.Equal(1, replicas)
+	limit, ok := parsed.GetParam("mapping")
+	assert.True(ok)
+	assert.Equal(map[string]interface{}{"total_fields": map[string]interface{}{"limit": "2000"}}, limit)
+	_, ok = parsed.GetParam("uuid")
+	assert.False(ok)
+	parsed, err = ParseIndexSettings(map[string]interface{}{"index.number_of_shards": "2"})
+	assert.NoError(err)
+	assert.Equal(2, parsed.GetShards())
+	_, err = ParseIndexSettings(map[string]interface{}{"number_of_shards": "many"})
+	assert.Error(err)
+
+	// defaults are reported
+	plain := NewMockIndex("estest27b")
+	assert.NoError(plain.Create(""))
+	defer closerT(t, plain)
+	got, err = plain.GetSettings()
+	assert.NoError(err)
+	assert.Equal(5, got.GetShards())
+
+	// templates
+	logs := NewIndexTemplate("estest27-logs-*", NewIndexSettings().
+		Shards(2).
+		Mapping(NewMapping("LogData").Property("message", NewProperty(MappingElementTypeText)))).
+		Alias("estest27-logs")
+	assert.NoError(esi.PutTemplate("logs", logs))
+	assert.NoError(esi.PutTemplate("logs-override", NewIndexTemplate("estest27-logs-2017*", NewIndexSettings().Shards(3)).Order(1)))
+	tjsn, err := logs.JSON()
+	assert.NoError(err)
+	assert.Equal(`{"aliases":{"estest27-logs":{}},"mappings":{"LogData":{"properties":{"message":{"type":"text"}}}},`+
+		`"order":0,"settings":{"number_of_shards":2},"template":"estest27-logs-*"}`, string(tjsn))
+	assert.True(logs.Matches("estest27-logs-2017.01.01"))
+	assert.False(logs.Matches("estest27-other"))
+	assert.False(NewIndexTemplate("a*b*c", nil).Matches("abca"))
+	assert.True(NewIndexTemplate("a*b*c", nil).Matches("axbyc"))
+
+	daily := NewMockIndex("estest27-logs-2017.01.01")
+	assert.NoError(daily.Create(""))
+	defer closerT(t, daily)
+	ok, err = daily.TypeExists("LogData")
+	assert.NoError(err)
+	assert.True(ok)
+	got, err = daily.GetSettings()
+	assert.NoError(err)
+	assert.Equal(3, got.GetShards())
+	aliases, err := daily.GetAliases()
+	assert.NoError(err)
+	assert.Equal([]string{"estest27-logs"}, aliases)
+
+	assert.NoError(esi.DeleteTemplate("logs"))
+	assert.NoError(esi.DeleteTemplate("logs-override"))
+	assert.Error(esi.DeleteTemplate("logs"))
+	later := NewMockIndex("estest27-logs-2017.01.02")
+	assert.NoError(later.Create(""))
+	defer closerT(t, later)
+	ok, err = later.TypeExists("LogData")
+	assert.NoError(err)
+	assert.False(ok)
+}
+
+func (suite *EsTester) Test28RollingIndex() {
+	t := suite.T()
+	assert := assert.New(t)
+
+	_, err := NewRollingIndex("estest28", &RollingSettings{RetentionDays: 1}, NewMockIndexOpener())
+	assert.Error(err)
+
+	settings := &RollingSettings{
+		Daily:         true,
+		MaxDocs:       2,
+		RetentionDays: 2,
+		Settings:      NewIndexSettings().Mapping(NewMapping(mapping).Property("id", NewProperty(MappingElementTypeKeyword))),
+	}
+	open := NewMockIndexOpener()
+	rolling, err := NewRollingIndex("estest28", settings, open)
+	assert.NoError(err)
+	now := time.Date(2017, 3, 1, 10, 0, 0, 0, time.UTC)
+	rolling.now = func() time.Time { return now }
+	assert.Equal("estest28-write", rolling.WriteAlias())
+	assert.Equal("estest28-*", rolling.Pattern())
+
+	// the first generation is made on first use
+	writer, err := rolling.Current()
+	assert.NoError(err)
+	assert.Equal("estest28-2017.03.01-000001", writer.IndexName())
+	ok, err := writer.TypeExists(mapping)
+	assert.NoError(err)
+	assert.True(ok)
+	for _, o := range objs[:2] {
+		_, err = writer.PostData(mapping, o.ID, o)
+		assert.NoError(err)
+	}
+
+	// a full generation is rolled, an empty one isn't
+	rolled, err := rolling.Roll()
+	assert.NoError(err)
+	assert.True(rolled)
+	rolled, err = rolling.Roll()
+	assert.NoError(err)
+	assert.False(rolled)
+	writer, err = rolling.Current()
+	assert.NoError(err)
+	assert.Equal("estest28-2017.03.01-000002", writer.IndexName())
+	_, err = writer.PostData(mapping, objs[2].ID, objs[2])
+	assert.NoError(err)
+
+	// percolation queries aren't counted as documents
+	_, err = writer.AddPercolationQuery("q1", `{"query": {"match_all": {}}}`)
+	assert.NoError(err)
+	rolled, err = rolling.Roll()
+	assert.NoError(err)
+	assert.False(rolled)
+	indices, err := writer.AliasIndices(rolling.WriteAlias())
+	assert.NoError(err)
+	assert.Equal([]string{writer.IndexName()}, indices)
+
+	// the read alias searches every generation, but can't be written
+	reader, err := rolling.Reader()
+	assert.NoError(err)
+	getResult, err := reader.GetAllElements(mapping)
+	assert.NoError(err)
+	assert.EqualValues(3, getResult.TotalHits())
+	_, err = reader.PostData(mapping, "x", objs[0])
+	assert.Error(err)
+
+	// a new day starts a new generation
+	now = now.AddDate(0, 0, 1)
+	rolled, err = rolling.Roll()
+	assert.NoError(err)
+	assert.True(rolled)
+	gens, err := rolling.Generations()
+	assert.NoError(err)
+	assert.Equal([]string{"estest28-2017.03.01-000001", "estest28-2017.03.01-000002", "estest28-2017.03.02-000001"}, gens)
+
+	// another RollingIndex carries on with the current generation
+	again, err := NewRollingIndex("estest28", settings, open)
+	assert.NoError(err)
+	again.now = rolling.now
+	writer, err = again.Current()
+	assert.NoError(err)
+	assert.Equal("estest28-2017.03.02-000001", writer.IndexName())
+
+	// generations are kept for RetentionDays after they're superseded
+	deleted, err := rolling.Expire()
+	assert.NoError(err)
+	assert.Empty(deleted)
+	now = now.AddDate(0, 0, 3)
+	deleted, err = rolling.Expire()
+	assert.NoError(err)
+	assert.Equal([]string{"estest28-2017.03.01-000001", "estest28-2017.03.01-000002"}, deleted)
+	gens, err = rolling.Generations()
+	assert.NoError(err)
+	assert.Equal([]string{"estest28-2017.03.02-000001"}, gens)
+
+	reader, err = rolling.Reader()
+	assert.NoError(err)
+	getResult, err = reader.GetAllElements(mapping)
+	assert.NoError(err)
+	assert.EqualValues(0, getResult.TotalHits())
+	assert.NoError(writer.Delete())
+}
+
+func (suite *EsTester) Test29ByQuery() {
+	t := suite.T()
+	assert := assert.New(t)
+
+	esi := suite.SetUpIndex()
+	assert.NotNil(esi)
+	defer closerT(t, esi)
+
+	// reindexing in place bumps the versions
+	task, err := esi.UpdateByQuery(mapping, elastic.NewMatchQuery("tags", "foo"), nil)
+	assert.NoError(err)
+	status, err := task.Wait()
+	assert.NoError(err)
+	assert.True(status.Completed)
+	assert.EqualValues(2, status.Total)
+	assert.EqualValues(2, status.Updated)
+	assert.EqualValues(2, status.Done())
+	assert.Empty(status.Failures)
+	getResult, err := esi.GetByID(mapping, "id2")
+	assert.NoError(err)
+	assert.Equal(2, getResult.Version)
+
+	// delete matching documents only
+	task, err = esi.DeleteByQuery(mapping, elastic.NewMatchQuery("tags", "bar"))
+	assert.NoError(err)
+	status, err = task.Status()
+	assert.NoError(err)
+	assert.True(status.Completed)
+	assert.EqualValues(2, status.Deleted)
+	assert.EqualValues(1, status.Batches)
+	searchResult, err := esi.GetAllElements(mapping)
+	assert.NoError(err)
+	assert.EqualValues(1, searchResult.TotalHits())
+	assert.Equal("id2", searchResult.GetHit(0).ID)
+
+	// scripts which set and remove fields can be run under mocking
+	script := elastic.NewScriptInline("ctx._source.tags = params.tags; ctx._source.remove('data'); ctx._source.n = 1").
+		Lang("painless").Param("tags", "qux; quux")
+	task, err = esi.UpdateByQuery(mapping, nil, script)
+	assert.NoError(err)
+	status, err = task.Wait()
+	assert.NoError(err)
+	assert.EqualValues(1, status.Updated)
+	getResult, err = esi.GetByID(mapping, "id2")
+	assert.NoError(err)
+	var doc map[string]interface{}
+	assert.NoError(json.Unmarshal(*getResult.Source, &doc))
+	assert.Equal(map[string]interface{}{"id": "id2", "tags": "qux; quux", "n": 1.0}, doc)
+
+	task, err = esi.UpdateByQuery(mapping, nil, elastic.NewScriptInline(`ctx._source.owner.name = "x"`))
+	assert.NoError(err)
+	status, err = task.Wait()
+	assert.NoError(err)
+	assert.EqualValues(0, status.Updated)
+	assert.Len(status.Failures, 1)
+	for _, bad := range []*elastic.Script{
+		elastic.NewScriptInline("ctx._source.n += 1"),
+		elastic.NewScriptInline("ctx._source.n = params.n + 1"),
+		elastic.NewScriptId("stored"),
+		elastic.NewScriptInline("ctx._source.n = 1").Lang("groovy"),
+	} {
+		_, err = esi.UpdateByQuery(mapping, nil, bad)
+		assert.Error(err)
+	}
+
+	// nil matches everything
+	task, err = esi.DeleteByQuery("", nil)
+	assert.NoError(err)
+	status, err = task.Wait()
+	assert.NoError(err)
+	assert.EqualValues(1, status.Deleted)
+	searchResult, err = esi.GetAllElements(mapping)
+	assert.NoError(err)
+	assert.EqualValues(0, searchResult.TotalHits())
+
+	_, err = NewMockIndex("estest29-missing").DeleteByQuery(mapping, nil)
+	assert.Error(err)
+
+	// an Index built by hand waits under the background context, and a
+	// view gives up when its deadline passes
+	polls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		polls++
+		if polls == 2 {
+			w.Write([]byte(`{"completed":true,"response":{"total":1,"deleted":1}}`))
+			return
+		}
+		w.Write([]byte(`{"completed":false,"task":{"status":{"total":1}}}`))
+	}))
+	defer server.Close()
+	client, err := elastic.NewClient(elastic.SetURL(server.URL), elastic.SetSniff(false), elastic.SetHealthcheck(false))
+	assert.NoError(err)
+	live := &Index{lib: client, index: "estest29"}
+	status, err = live.byQueryTask("n:1").Wait()
+	assert.NoError(err)
+	assert.True(status.Completed)
+	assert.EqualValues(1, status.Deleted)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	view := live.WithContext(ctx).(*Index)
+	status, err = view.byQueryTask("n:1").Wait()
+	assert.True(IsTimeout(err))
+	assert.False(status.Completed)
+}
+
+func (suite *EsTester) Test30MultiGetSearch() {
+	t := suite.T()
+	assert := assert.New(t)
+
+	esi := suite.SetUpIndex()
+	assert.NotNil(esi)
+	defer closerT(t, esi)
+
+	// results come back in the order asked for
+	results, err := esi.GetByIDs(mapping, []string{"id2", "id9", "id0"})
+	assert.NoError(err)
+	assert.Len(results, 3)
+	assert.Equal([]string{"id2", "id9", "id0"}, []string{results[0].ID, results[1].ID, results[2].ID})
+	assert.True(results[0].Found)
+	assert.False(results[1].Found)
+	assert.Empty(results[1].Error)
+	assert.True(results[2].Found)
+	var obj Obj
+	assert.NoError(json.Unmarshal(*results[2].Source, &obj))
+	assert.Equal(objs[0], obj)
+	assert.Equal(1, results[2].Version)
+
+	results, err = esi.GetByIDs(mapping, []string{})
+	assert.NoError(err)
+	assert.Empty(results)
+
+	missing := NewMockIndex("estest30-missing")
+	results, err = missing.GetByIDs(mapping, []string{"id0"})
+	assert.NoError(err)
+	assert.False(results[0].Found)
+	assert.NotEmpty(results[0].Error)
+
+	// one search failing doesn't fail the others
+	format := &piazza.JsonPagination{PerPage: 1, Page: 0, Order: piazza.SortOrderDescending, SortBy: "id"}
+	items, err := esi.MultiSearch([]*SearchRequest{
+		{Type: mapping, Query: elastic.NewMatchQuery("tags", "foo")},
+		{Type: mapping, Format: format},
+		{Type: mapping, Query: elastic.NewGeoDistanceQuery("tags")},
+	})
+	assert.NoError(err)
+	assert.Len(items, 3)
+	assert.Empty(items[0].Error)
+	assert.EqualValues(2, items[0].Result.TotalHits())
+	assert.EqualValues(3, items[1].Result.TotalHits())
+	assert.Equal(1, items[1].Result.NumHits())
+	assert.Equal("id2", items[1].Result.GetHit(0).ID)
+	assert.Nil(items[2].Result)
+	assert.NotEmpty(items[2].Error)
+}
+
+func (suite *EsTester) Test31NotFoundErrors() {
+	t := suite.T()
+	assert := assert.New(t)
+
+	esi := suite.SetUpIndex()
+	assert.NotNil(esi)
+	defer closerT(t, esi)
+
+	getResult, err := esi.GetByID(mapping, "id9")
+	assert.True(IsDocumentNotFound(err))
+	assert.False(getResult.Found)
+
+	deleteResult, err := esi.DeleteByID(mapping, "id9")
+	assert.True(IsDocumentNotFound(err))
+	assert.False(deleteResult.Found)
+
+	_, err = esi.UpdateData(mapping, "id9", &Update{Doc: map[string]string{"tags": "x"}})
+	assert.True(IsDocumentNotFound(err))
+
+	_, err = esi.FilterByTermQuery("nosuchtype", "id", "id0", nil)
+	assert.True(IsTypeNotFound(err))
+	assert.False(IsIndexNotFound(err))
+	_, err = esi.GetMapping("nosuchtype")
+	assert.True(IsTypeNotFound(err))
+	_, err = esi.PostData("nosuchtype", "id0", objs[0])
+	assert.True(IsTypeNotFound(err))
+	_, err = esi.UpdateData("nosuchtype", "id0", &Update{Doc: objs[0], DocAsUpsert: true})
+	assert.True(IsTypeNotFound(err))
+	_, err = esi.BulkPostData("nosuchtype", []*BulkItem{{ID: "id0", Obj: objs[0]}})
+	assert.True(IsTypeNotFound(err))
+	ok, err := esi.TypeExists("nosuchtype")
+	assert.NoError(err)
+	assert.False(ok)
+
+	missing := NewMockIndex("estest31-missing")
+	_, err = missing.GetByID(mapping, "id0")
+	assert.True(IsIndexNotFound(err))
+	assert.False(IsDocumentNotFound(err))
+	_, err = missing.DeleteByID(mapping, "id0")
+	assert.True(IsIndexNotFound(err))
+	_, err = missing.FilterByTermQuery(mapping, "id", "id0", nil)
+	assert.True(IsIndexNotFound(err))
+	_, err = missing.PostData(mapping, "id0", objs[0])
+	assert.True(IsIndexNotFound(err))
+	assert.True(IsIndexNotFound(missing.Close()))
+	assert.True(IsIndexNotFound(missing.Delete()))
+	assert.Contains(err.Error(), "estest31-missing")
+
+	// once its type is cached, a write is a single request; an index deleted
+	// behind the Index's back is reported by Elasticsearch, when it may not
+	// create indices itself
+	deleted := false
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch {
+		case deleted:
+			w.WriteHeader(http.StatusNotFound)
+			if r.Method != "HEAD" {
+				w.Write([]byte(`{"error":{"type":"index_not_found_exception","reason":"no such index"},"status":404}`))
+			}
+		case r.Method == "HEAD":
+		default:
+			w.Write([]byte(`{"_index":"estest31","_type":"Obj","_id":"id0","_version":1,"created":true}`))
+		}
+	}))
+	defer server.Close()
+	client, err := elastic.NewClient(elastic.SetURL(server.URL), elastic.SetSniff(false), elastic.SetHealthcheck(false))
+	assert.NoError(err)
+	live := &Index{lib: client, index: "estest31", types: newTypeCache()}
+	_, err = live.PostData(mapping, "id0", objs[0])
+	assert.NoError(err)
+	assert.True(live.types.has(mapping))
+	assert.Equal(2, requests)
+	_, err = live.PostData(mapping, "id0", objs[0])
+	assert.NoError(err)
+	_, err = live.BulkPostData(mapping, []*BulkItem{})
+	assert.NoError(err)
+	assert.Equal(3, requests)
+	deleted = true
+	_, err = live.PostData(mapping, "id0", objs[0])
+	assert.True(IsIndexNotFound(err))
+	assert.False(live.types.has(mapping))
+	assert.Equal(4, requests)
+	_, err = live.PostData(mapping, "id0", objs[0])
+	assert.True(IsIndexNotFound(err))
+	_, err = live.UpdateData(mapping, "id0", &Update{Doc: map[string]string{"tags": "x"}})
+	assert.True(IsIndexNotFound(err))
+}
+
+func (suite *EsTester) Test32ErrorTaxonomy() {
+	t := suite.T()
+	assert := assert.New(t)
+
+	esi := suite.SetUpIndex()
+	assert.NotNil(esi)
+	defer closerT(t, esi)
+
+	// errors from Elasticsearch get the matching cause
+	conflict := &elastic.Error{Status: 409, Details: &elastic.ErrorDetails{Type: "version_conflict_engine_exception"}}
+	assert.True(IsConflict(typedError(conflict)))
+	parsing := &elastic.Error{Status: 400, Details: &elastic.ErrorDetails{Type: "mapper_parsing_exception"}}
+	assert.True(IsInvalidMapping(typedError(parsing)))
+	assert.True(IsUnavailable(typedError(&elastic.Error{Status: 503})))
+	assert.True(IsUnavailable(typedError(elastic.ErrNoClient)))
+	assert.True(IsTimeout(typedError(&elastic.Error{Status: 408})))
+	assert.True(IsTimeout(typedError(context.DeadlineExceeded)))
+	assert.Equal(context.Canceled, typedError(context.Canceled))
+	other := &elastic.Error{Status: 400}
+	assert.Equal(other, typedError(other))
+	assert.NoError(typedError(nil))
+
+	// the mock returns the same
+	_, err := esi.GetByID(mapping, "id9")
+	assert.True(IsNotFound(err))
+	_, err = esi.CreateData(mapping, "id0", objs[0])
+	assert.True(IsConflict(err))
+	err = esi.SetMapping(mapping, piazza.JsonString(`{"Obj":{"properties":{"id":{"type":"integer"}}}}`))
+	assert.True(IsInvalidMapping(err))
+
+	missing := NewMockIndex("estest32-missing")
+	_, err = missing.FilterByMatchAll(mapping, nil)
+	assert.True(IsIndexNotFound(err))
+	assert.True(IsNotFound(err))
+
+	// which services report with the right status
+	assert.Equal(http.StatusOK, StatusCode(nil))
+	assert.Equal(http.StatusNotFound, StatusCode(err))
+	assert.Equal(http.StatusConflict, StatusCode(typedError(conflict)))
+	assert.Equal(http.StatusBadRequest, StatusCode(typedError(parsing)))
+	assert.Equal(http.StatusServiceUnavailable, StatusCode(typedError(elastic.ErrNoClient)))
+	assert.Equal(http.StatusGatewayTimeout, StatusCode(typedError(context.DeadlineExceeded)))
+	assert.Equal(http.StatusInternalServerError, StatusCode(other))
+	resp := NewErrorResponse(err)
+	assert.Equal(http.StatusNotFound, resp.StatusCode)
+	assert.Contains(resp.Message, "estest32-missing")
+	assert.True(resp.IsError())
+}
+
+func (suite *EsTester) Test33HitMetadata() {
+	t := suite.T()
+	assert := assert.New(t)
+
+	esi := suite.SetUpIndex()
+	assert.NotNil(esi)
+	defer closerT(t, esi)
+
+	format := &piazza.JsonPagination{PerPage: 10, Page: 0, Order: piazza.SortOrderDescending, SortBy: "id"}
+	result, err := esi.FilterByMatchAll(mapping, format)
+	assert.NoError(err)
+	assert.Equal(3, result.NumHits())
+
+	hit := result.GetHit(0)
+	assert.Equal("id2", hit.ID)
+	assert.Equal(esi.IndexName(), hit.Index)
+	assert.Equal(mapping, hit.Type)
+	assert.Equal(1, hit.Version)
+	assert.Equal([]interface{}{"id2"}, hit.Sort)
+	assert.Zero(hit.Score)
+
+	var obj Obj
+	assert.NoError(hit.Unmarshal(&obj))
+	assert.Equal(objs[2], obj)
+
+	var all []Obj
+	assert.NoError(result.DecodeInto(&all))
+	assert.Equal([]Obj{objs[2], objs[1], objs[0]}, all)
+
+	// unsorted hits are scored
+	result, err = esi.FilterByMatchQuery(mapping, "tags", "foo", nil)
+	assert.NoError(err)
+	assert.Equal(2, result.NumHits())
+	assert.Equal(1.0, result.GetHit(0).Score)
+	assert.Nil(result.GetHit(0).Sort)
+
+	getResult, err := esi.GetByID(mapping, "id1")
+	assert.NoError(err)
+	assert.Equal(mapping, getResult.Type)
+	assert.Equal(esi.IndexName(), getResult.Index)
+	assert.NoError(getResult.Unmarshal(&obj))
+	assert.Equal(objs[1], obj)
+
+	assert.Error((&GetResult{ID: "x"}).Unmarshal(&obj))
+	assert.Error((&SearchResult{hits: []*SearchResultHit{{ID: "x"}}}).DecodeInto(&all))
+}
+
+func (suite *EsTester) Test34HighlightSuggest() {
+	t := suite.T()
+	assert := assert.New(t)
+
+	esi := suite.SetUpIndex()
+	assert.NotNil(esi)
+	defer closerT(t, esi)
+
+	// highlighting
+	format := &piazza.JsonPagination{PerPage: 10, Page: 0, Order: piazza.SortOrderAscending, SortBy: "id"}
+	highlight := elastic.NewHighlight().Field("tags").Field("data")
+	result, err := esi.SearchWithHighlight(mapping, elastic.NewMatchQuery("tags", "foo"), highlight, format)
+	assert.NoError(err)
+	assert.Equal(2, result.NumHits())
+	assert.Equal(map[string][]string{"tags": {"<em>foo</em> bar"}}, result.GetHit(0).Highlight)
+	assert.Equal(map[string][]string{"tags": {"<em>foo</em>"}}, result.GetHit(1).Highlight)
+
+	highlight = elastic.NewHighlight().Field("tags").PreTags("[").PostTags("]")
+	query := elastic.NewBoolQuery().Must(elastic.NewMatchQuery("tags", "bar"), elastic.NewMatchQuery("tags", "baz"))
+	result, err = esi.SearchWithHighlight(mapping, query, highlight, format)
+	assert.NoError(err)
+	assert.Equal(1, result.NumHits())
+	assert.Equal([]string{"[bar] [baz]"}, result.GetHit(0).Highlight["tags"])
+
+	_, err = esi.SearchWithHighlight(mapping, query, nil, format)
+	assert.Error(err)
+
+	// spelling
+	suggestions, err := esi.Suggest(mapping, elastic.NewTermSuggester("spelling").Text("fooo baz").Field("tags"))
+	assert.NoError(err)
+	assert.Len(suggestions, 2)
+	assert.Equal("fooo", suggestions[0].Text)
+	assert.Len(suggestions[0].Options, 1)
+	assert.Equal("foo", suggestions[0].Options[0].Text)
+	assert.Equal(2, suggestions[0].Options[0].Freq)
+	assert.Equal(5, suggestions[1].Offset)
+	assert.Empty(suggestions[1].Options)
+
+	_, err = esi.Suggest(mapping, elastic.NewPhraseSuggester("phrase").Text("fooo").Field("tags"))
+	assert.Error(err)
+	_, err = esi.Suggest(mapping, nil)
+	assert.Error(err)
+
+	// autocompletion
+	services := NewMockIndex("estest34-services")
+	assert.NoError(services.Create(""))
+	defer func() { assert.NoError(services.Delete()) }()
+	assert.NoError(services.SetMapping("Service", piazza.JsonString(
+		`{"Service":{"properties":{"name":{"type":"text"},"suggest":{"type":"completion"}}}}`)))
+	docs := map[string]string{
+		"s1": `{"name": "Hello World", "suggest": {"input": ["hello world", "world"], "weight": 3}}`,
+		"s2": `{"name": "Help Desk", "suggest": "Help desk"}`,
+		"s3": `{"name": "Geocoder", "suggest": ["geocoder", "geo"]}`,
+	}
+	for id, doc := range docs {
+		var obj map[string]interface{}
+		assert.NoError(json.Unmarshal([]byte(doc), &obj))
+		_, err = services.PostData("Service", id, obj)
+		assert.NoError(err)
+	}
+
+	suggestions, err = services.Suggest("Service", elastic.NewCompletionSuggester("names").Field("suggest").Prefix("hel"))
+	assert.NoError(err)
+	assert.Len(suggestions, 1)
+	options := suggestions[0].Options
+	assert.Len(options, 2)
+	assert.Equal([]string{"hello world", "Help desk"}, []string{options[0].Text, options[1].Text})
+	assert.Equal("s1", options[0].ID)
+	assert.EqualValues(3, options[0].Score)
+	var service struct {
+		Name string `json:"name"`
+	}
+	assert.NoError(options[1].Unmarshal(&service))
+	assert.Equal("Help Desk", service.Name)
+
+	suggestions, err = services.Suggest("Service", elastic.NewCompletionSuggester("names").Field("suggest").Prefix("geo").Size(5))
+	assert.NoError(err)
+	assert.Len(suggestions[0].Options, 1)
+	assert.Equal("geocoder", suggestions[0].Options[0].Text)
+}
+
+func (suite *EsTester) Test35ConcurrencyAndRefresh() {
+	t := suite.T()
+	assert := assert.New(t)
+
+	esi := NewMockIndex("estest35")
+	assert.NoError(esi.Create(""))
+	defer func() { assert.NoError(esi.Delete()) }()
+	assert.NoError(esi.SetMapping(mapping, objMapping))
+
+	count := func(esi IIndex) int64 {
+		result, err := esi.SearchByJSON(mapping, `{"size": 0}`)
+		assert.NoError(err)
+		return result.TotalHits()
+	}
+
+	// concurrent writers, racing to create the same document, and readers
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	created := 0
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				id := fmt.Sprintf("w%d-%d", w, i)
+				_, err := esi.PostData(mapping, id, Obj{ID: id, Data: "data", Tags: "foo"})
+				assert.NoError(err)
+				_, err = esi.UpdateData(mapping, id, &Update{Doc: map[string]string{"tags": "bar"}})
+				assert.NoError(err)
+				if _, err = esi.CreateData(mapping, "shared", Obj{ID: id}); err == nil {
+					mu.Lock()
+					created++
+					mu.Unlock()
+				} else {
+					assert.True(IsConflict(err))
+				}
+				_, err = esi.FilterByMatchQuery(mapping, "tags", "bar", nil)
+				assert.NoError(err)
+				_, err = esi.GetByID(mapping, id)
+				assert.NoError(err)
+			}
+		}(w)
+	}
+	wg.Wait()
+	assert.Equal(1, created)
+	assert.EqualValues(201, count(esi))
+
+	// near-real-time: writes are seen by gets at once, by searches only once
+	// refreshed
+	nrt := NewMockIndex("estest35-nrt")
+	assert.NoError(nrt.Create(""))
+	defer func() { assert.NoError(nrt.Delete()) }()
+	assert.NoError(nrt.SetMapping(mapping, objMapping))
+	_, err := nrt.PostData(mapping, "id0", objs[0])
+	assert.NoError(err)
+
+	nrt.SetNearRealTime(-1)
+	assert.EqualValues(1, count(nrt))
+	_, err = nrt.PostData(mapping, "id1", objs[1])
+	assert.NoError(err)
+	_, err = nrt.GetByID(mapping, "id1")
+	assert.NoError(err)
+	assert.EqualValues(1, count(nrt))
+	assert.NoError(nrt.Refresh())
+	assert.EqualValues(2, count(nrt))
+
+	// a delete isn't seen by searches until refreshed either, unless waited for
+	_, err = nrt.DeleteByID(mapping, "id0")
+	assert.NoError(err)
+	assert.EqualValues(2, count(nrt))
+	_, err = nrt.DeleteByIDWait(mapping, "id1")
+	assert.NoError(err)
+	assert.EqualValues(0, count(nrt))
+
+	// refreshed every second
+	start := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	now := start
+	nrt.SetNearRealTime(time.Second)
+	nrt.nrt.start = start
+	nrt.nrt.now = func() time.Time { return now }
+
+	now = start.Add(1500 * time.Millisecond)
+	_, err = nrt.PostData(mapping, "id2", objs[2])
+	assert.NoError(err)
+	assert.EqualValues(0, count(nrt))
+	now = start.Add(1999 * time.Millisecond)
+	assert.EqualValues(0, count(nrt))
+	now = start.Add(2 * time.Second)
+	assert.EqualValues(1, count(nrt))
+
+	// by-query operations work from what searches see, and leave alone what
+	// has changed since
+	now = start.Add(2500 * time.Millisecond)
+	_, err = nrt.PutData(mapping, "id2", objs[2])
+	assert.NoError(err)
+	task, err := nrt.DeleteByQuery(mapping, nil)
+	assert.NoError(err)
+	status, err := task.Status()
+	assert.NoError(err)
+	assert.EqualValues(1, status.Total)
+	assert.EqualValues(0, status.Deleted)
+	assert.EqualValues(1, status.VersionConflicts)
+	assert.NoError(nrt.Refresh())
+	task, err = nrt.DeleteByQuery(mapping, nil)
+	assert.NoError(err)
+	status, err = task.Status()
+	assert.NoError(err)
+	assert.EqualValues(1, status.Deleted)
+	ok, err := nrt.ItemExists(mapping, "id2")
+	assert.NoError(err)
+	assert.False(ok)
+
+	// percolation queries are matched as soon as they are added
+	_, err = nrt.AddPercolationQuery("q1", `{"query": {"match": {"tags": "foo"}}}`)
+	assert.NoError(err)
+	matches, err := nrt.AddPercolationDocument(mapping, objs[0])
+	assert.NoError(err)
+	assert.EqualValues(1, matches.Total)
+
+	assert.Error(NewMockIndex("estest35-missing").Refresh())
+}
+
+func (suite *EsTester) Test36FileIndex() {
+	t := suite.T()
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "estest36")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	// reopen forgets the open index, as a new process would once the old
+	// one died
+	path, err := filepath.Abs(filepath.Join(dir, "estest36"))
+	assert.NoError(err)
+	reopen := func() *FileIndex {
+		fileIndices.Lock()
+		if data, ok := fileIndices.indices[path]; ok {
+			data.mu.Lock()
+			data.journal.release()
+			data.mu.Unlock()
+			delete(fileIndices.indices, path)
+		}
+		fileIndices.Unlock()
+		esi, err := NewFileIndex(dir, "estest36")
+		assert.NoError(err)
+		return esi
+	}
+	ids := func(esi IIndex) []string {
+		result, err := esi.FilterByMatchQuery(mapping, "tags", "foo", nil)
+		assert.NoError(err)
+		ids := []string{}
+		for _, hit := range *result.GetHits() {
+			ids = append(ids, hit.ID)
+		}
+		return ids
+	}
+
+	os.Setenv(IndexDirEnv, dir)
+	defer os.Unsetenv(IndexDirEnv)
+	iface, err := NewIndexInterface(nil, "estest36", `{"settings": {"number_of_shards": 2}}`, false)
+	assert.NoError(err)
+	esi, ok := iface.(*FileIndex)
+	assert.True(ok)
+	assert.NoError(esi.SetMapping(mapping, piazza.JsonString(objMapping)))
+	for _, o := range objs {
+		_, err = esi.PostData(mapping, o.ID, o)
+		assert.NoError(err)
+	}
+	_, err = esi.PostData(mapping, "", Obj{Data: "data9", Tags: "foo"})
+	assert.NoError(err)
+	_, err = esi.DeleteByID(mapping, "1")
+	assert.NoError(err)
+	assert.Equal([]string{"id0", "id2"}, ids(esi))
+
+	// opening the index again shares it, but another process can't
+	again, err := NewFileIndex(dir, "estest36")
+	assert.NoError(err)
+	assert.Equal([]string{"id0", "id2"}, ids(again))
+	_, err = lockFile(filepath.Join(path, "lock"))
+	assert.Error(err)
+
+	// without a checkpoint, the log is replayed
+	esi = reopen()
+	assert.Equal([]string{"id0", "id2"}, ids(esi))
+	settings, err := esi.GetSettings()
+	assert.NoError(err)
+	assert.Equal(2, settings.GetShards())
+	_, err = esi.GetMapping(mapping)
+	assert.NoError(err)
+	resp, err := esi.PostData(mapping, "", Obj{Data: "data10"})
+	assert.NoError(err)
+	assert.Equal("2", resp.ID)
+
+	// Close folds the log into the data files
+	resp, err = esi.PutData(mapping, "id2", Obj{ID: "id2", Data: "data2", Tags: "foo baz"})
+	assert.NoError(err)
+	assert.Equal(2, resp.Version)
+	assert.NoError(esi.Close())
+	wal, err := ioutil.ReadFile(filepath.Join(dir, "estest36", "wal.jsonl"))
+	assert.NoError(err)
+	assert.Empty(wal)
+
+	// and lets go of the directory; the closed index can't be changed
+	assert.Equal([]string{"id0", "id2"}, ids(esi))
+	_, err = esi.PostData(mapping, "id5", Obj{ID: "id5"})
+	assert.Error(err)
+	assert.NoError(esi.Close())
+	fileIndices.Lock()
+	assert.NotContains(fileIndices.indices, path)
+	fileIndices.Unlock()
+	lock, err := lockFile(filepath.Join(path, "lock"))
+	assert.NoError(err)
+	_, err = NewFileIndex(dir, "estest36")
+	assert.Error(err)
+	unlockFile(lock)
+	esi = reopen()
+	assert.Equal([]string{"id0", "id2"}, ids(esi))
+	got, err := esi.GetByID(mapping, "id2")
+	assert.NoError(err)
+	assert.Equal(2, got.Version)
+
+	// a change logged only in part is dropped
+	_, err = esi.PostData(mapping, "id3", Obj{ID: "id3", Tags: "foo"})
+	assert.NoError(err)
+	f, err := os.OpenFile(filepath.Join(dir, "estest36", "wal.jsonl"), os.O_WRONLY|os.O_APPEND, 0644)
+	assert.NoError(err)
+	_, err = f.WriteString(`{"op":"index","type":"Obj","id":"id4`)
+	assert.NoError(err)
+	assert.NoError(f.Close())
+	esi = reopen()
+	assert.Equal([]string{"id0", "id2", "id3"}, ids(esi))
+	_, err = esi.PostData(mapping, "id4", Obj{ID: "id4", Tags: "foo"})
+	assert.NoError(err)
+	esi = reopen()
+	assert.Equal([]string{"id0", "id2", "id3", "id4"}, ids(esi))
+
+	// a long log is folded into the data files as it grows
+	assert.NoError(esi.SetMapping("Many", piazza.JsonString(`{"Many":{"properties":{"data":{"type":"keyword"}}}}`)))
+	for i := 0; i <= FileCheckpointInterval; i++ {
+		_, err = esi.PostData("Many", "", Obj{Data: "many"})
+		assert.NoError(err)
+	}
+	wal, err = ioutil.ReadFile(filepath.Join(dir, "estest36", "wal.jsonl"))
+	assert.NoError(err)
+	assert.True(bytes.Count(wal, []byte("\n")) < FileCheckpointInterval)
+	esi = reopen()
+	result, err := esi.SearchByJSON("Many", `{"size": 0}`)
+	assert.NoError(err)
+	assert.EqualValues(FileCheckpointInterval+1, result.TotalHits())
+
+	// deleting the index, even closed, deletes its files
+	assert.NoError(esi.Close())
+	assert.NoError(esi.Delete())
+	esi = reopen()
+	ok, err = esi.IndexExists()
+	assert.NoError(err)
+	assert.False(ok)
+	files, err := ioutil.ReadDir(filepath.Join(dir, "estest36", "types"))
+	assert.NoError(err)
+	assert.Empty(files)
+	assert.NoError(esi.Create(""))
+	assert.NoError(esi.Close())
+	again, err = NewFileIndex(dir, "estest36")
+	assert.NoError(err)
+	assert.Error(esi.Delete())
+	assert.NoError(again.Delete())
+	assert.NoError(again.Close())
+
+	_, err = NewFileIndex(dir, "../escape")
+	assert.Error(err)
+}
+
+func (suite *EsTester) Test37FaultyIndex() {
+	t := suite.T()
+	assert := assert.New(t)
+
+	_, err := NewFaultyIndex(NewMockIndex("estest37"), &FaultSettings{MethodErrorRates: map[string]float64{"PostDocument": 1}})
+	assert.Error(err)
+
+	// random failures, the same for the same seed
+	failures := func() []int {
+		mock := NewMockIndex("estest37")
+		assert.NoError(mock.Create(""))
+		defer func() { assert.NoError(mock.Delete()) }()
+		assert.NoError(mock.SetMapping(mapping, objMapping))
+		esi, err := NewFaultyIndex(mock, &FaultSettings{Seed: 37, MethodErrorRates: map[string]float64{"PostData": 0.5}})
+		assert.NoError(err)
+
+		failed := []int{}
+		for i := 0; i < 100; i++ {
+			_, err := esi.PostData(mapping, fmt.Sprintf("id%d", i), objs[0])
+			if err != nil {
+				assert.True(IsUnavailable(err))
+				assert.Equal(http.StatusServiceUnavailable, StatusCode(err))
+				failed = append(failed, i)
+			}
+			_, err = esi.GetByID(mapping, "id0")
+			assert.True(err == nil || IsDocumentNotFound(err))
+		}
+		return failed
+	}
+	failed := failures()
+	assert.InDelta(50, len(failed), 20)
+	assert.Equal(failed, failures())
+
+	mock := NewMockIndex("estest37")
+	assert.NoError(mock.Create(""))
+	assert.NoError(mock.SetMapping(mapping, objMapping))
+	esi, err := NewFaultyIndex(mock, &FaultSettings{ConflictRate: 1, BulkItemFailureRate: 0.5})
+	assert.NoError(err)
+
+	// conflicts, on writes only
+	_, err = esi.PostData(mapping, "id0", objs[0])
+	assert.True(IsConflict(err))
+	_, err = esi.PutDataIfVersion(mapping, "id0", objs[0], 1)
+	assert.True(IsConflict(err))
+	_, err = esi.TypeExists(mapping)
+	assert.NoError(err)
+
+	// partial bulk failures
+	items := make([]*BulkItem, 20)
+	for i := range items {
+		items[i] = &BulkItem{ID: fmt.Sprintf("id%d", i), Obj: objs[0]}
+	}
+	resp, err := esi.BulkPostData(mapping, items)
+	assert.NoError(err)
+	assert.True(resp.Errors)
+	assert.Len(resp.Items, 20)
+	rejected := 0
+	for i, item := range resp.Items {
+		assert.Equal(items[i].ID, item.ID)
+		ok, err := mock.ItemExists(mapping, item.ID)
+		assert.NoError(err)
+		assert.Equal(item.Succeeded(), ok)
+		if !item.Succeeded() {
+			assert.Equal(429, item.Status)
+			rejected++
+		}
+	}
+	assert.True(rejected > 0 && rejected < 20)
+
+	// bulk flushes fail through After, scan pages through Next and Err
+	assert.NoError(esi.Script("BulkFlush", &Fault{Err: ErrUnavailable}))
+	flushErrs := []error{}
+	bulker, err := esi.NewBulkIndexer(&BulkSettings{Actions: 1, Size: -1, After: func(resp *BulkResponse, err error) {
+		flushErrs = append(flushErrs, err)
+	}})
+	assert.NoError(err)
+	assert.NoError(bulker.PostData(mapping, "b0", objs[0]))
+	assert.NoError(bulker.PostData(mapping, "b1", objs[0]))
+	assert.NoError(bulker.Close())
+	assert.Len(flushErrs, 2)
+	assert.True(IsUnavailable(flushErrs[0]))
+	assert.NoError(flushErrs[1])
+
+	assert.NoError(esi.Script("ScanPage", nil, &Fault{Err: ErrUnavailable}))
+	it, err := esi.Scan(mapping, nil)
+	assert.NoError(err)
+	it.PageSize(1)
+	_, err = it.Next()
+	assert.NoError(err)
+	_, err = it.Next()
+	assert.True(IsUnavailable(err))
+	assert.True(IsUnavailable(it.Err()))
+	_, err = it.Next()
+	assert.NoError(err)
+	assert.NoError(it.Err())
+
+	// scripted faults come first, and the index can disappear
+	assert.Error(esi.Script("GetById", nil))
+	assert.NoError(esi.Script("GetByID", &Fault{Err: ErrTimeout}, nil, &Fault{Disappear: true}))
+	_, err = esi.GetByID(mapping, "id0")
+	assert.True(IsTimeout(err))
+	_, err = esi.GetByID(mapping, "id0")
+	assert.NoError(err)
+	_, err = esi.GetByID(mapping, "id0")
+	assert.True(IsIndexNotFound(err))
+	ok, err := mock.IndexExists()
+	assert.NoError(err)
+	assert.False(ok)
+
+	// latency gives way to the context
+	slow, err := NewFaultyIndex(mock, &FaultSettings{Latency: time.Minute})
+	assert.NoError(err)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = slow.WithContext(ctx).IndexExists()
+	assert.True(IsTimeout(err))
+}
+
+type testMetric struct {
+	name   string
+	value  float64
+	object string
+}
+
+type testMetricLogger struct {
+	metrics []testMetric
+}
+
+func (logger *testMetricLogger) Metric(name string, value float64, object string, text string, v ...interface{}) error {
+	logger.metrics = append(logger.metrics, testMetric{name: name, value: value, object: object})
+	return nil
+}
+
+func (suite *EsTester) Test38InstrumentedIndex() {
+	t := suite.T()
+	assert := assert.New(t)
+
+	logger := &testMetricLogger{}
+	faulty, err := NewFaultyIndex(NewMockIndex("estest38"), &FaultSettings{})
+	assert.NoError(err)
+	esi := NewInstrumentedIndex(faulty, logger)
+	assert.NoError(esi.Create(""))
+	defer func() { assert.NoError(esi.Delete()) }()
+	assert.NoError(esi.SetMapping(mapping, objMapping))
+	assert.Empty(esi.Snapshot()["PostData"])
+
+	assert.NoError(faulty.Script("PostData", nil, &Fault{Err: ErrUnavailable}, &Fault{Latency: 30 * time.Millisecond}))
+	for i := 0; i < 4; i++ {
+		esi.PostData(mapping, fmt.Sprintf("id%d", i), objs[0])
+	}
+	_, err = esi.WithContext(context.Background()).GetByID(mapping, "id1")
+	assert.True(IsDocumentNotFound(err))
+
+	snap := esi.Snapshot()
+	post := snap["PostData"]
+	assert.EqualValues(4, post.Calls)
+	assert.EqualValues(1, post.Errors)
+	assert.True(post.MaxLatency >= 30*time.Millisecond)
+	assert.True(post.TotalLatency >= post.MaxLatency)
+	assert.Equal(post.TotalLatency/4, post.MeanLatency())
+	total := int64(0)
+	for _, n := range post.Buckets {
+		total += n
+	}
+	assert.EqualValues(4, total)
+	assert.Len(post.Buckets, len(LatencyBuckets)+1)
+	assert.EqualValues(1, post.Buckets[5]) // (20ms, 50ms]
+	assert.True(post.Percentile(0.75) <= 20*time.Millisecond)
+	assert.Equal(post.MaxLatency, post.Percentile(0.95)) // below its bucket's bound
+	assert.Equal(post.MaxLatency, post.Percentile(1))
+	assert.EqualValues(1, snap["GetByID"].Calls)
+	assert.EqualValues(1, snap["GetByID"].Errors)
+
+	// the snapshot is a copy
+	post.Calls = 0
+	post.Buckets[0] = 100
+	assert.EqualValues(4, esi.Snapshot()["PostData"].Calls)
+	assert.NotEqual(int64(100), esi.Snapshot()["PostData"].Buckets[0])
+
+	assert.NoError(esi.LogMetrics())
+	found := map[string]float64{}
+	for _, m := range logger.metrics {
+		assert.Equal("estest38", m.object)
+		found[m.name] = m.value
+	}
+	assert.Equal(float64(4), found["elasticsearch.PostData.calls"])
+	assert.Equal(float64(1), found["elasticsearch.PostData.errors"])
+	assert.Equal(float64(1), found["elasticsearch.GetByID.errors"])
+	assert.True(found["elasticsearch.PostData.latency.max"] >= 30)
+	assert.Equal(found["elasticsearch.PostData.latency.max"], found["elasticsearch.PostData.latency.p95"])
+	assert.Contains(found, "elasticsearch.Create.latency.mean")
+
+	// so is the work done after a method has returned
+	esi.Reset()
+	assert.NoError(faulty.Script("BulkFlush", nil, &Fault{Err: ErrUnavailable}))
+	bulker, err := esi.NewBulkIndexer(&BulkSettings{Actions: 1, Size: -1})
+	assert.NoError(err)
+	assert.NoError(bulker.PostData(mapping, "b0", objs[0]))
+	assert.NoError(bulker.PostData(mapping, "b1", objs[0]))
+	assert.NoError(bulker.Close())
+	flush := esi.Snapshot()["BulkFlush"]
+	assert.EqualValues(2, flush.Calls)
+	assert.EqualValues(1, flush.Errors)
+
+	assert.NoError(faulty.Script("ScanPage", &Fault{Err: ErrUnavailable}))
+	it, err := esi.Scan(mapping, nil)
+	assert.NoError(err)
+	_, err = it.All()
+	assert.True(IsUnavailable(err))
+	hits, err := it.All()
+	assert.NoError(err)
+	assert.NotEmpty(hits)
+	page := esi.Snapshot()["ScanPage"]
+	assert.EqualValues(2, page.Calls)
+	assert.EqualValues(1, page.Errors)
+
+	task, err := esi.DeleteByQuery(mapping, nil)
+	assert.NoError(err)
+	_, err = task.Wait()
+	assert.NoError(err)
+	assert.EqualValues(1, esi.Snapshot()["ByQueryStatus"].Calls)
+
+	esi.Reset()
+	assert.Empty(esi.Snapshot())
+	assert.NoError(NewInstrumentedIndex(faulty, nil).LogMetrics())
+}
+
+type testLogger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (logger *testLogger) log(level string, text string, v ...interface{}) error {
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	logger.messages = append(logger.messages, level+" "+fmt.Sprintf(text, v...))
+	return nil
+}
+
+func (logger *testLogger) Error(text string, v ...interface{}) error {
+	return logger.log("error", text, v...)
+}
+
+func (logger *testLogger) Warn(text string, v ...interface{}) error {
+	return logger.log("warn", text, v...)
+}
+
+func (logger *testLogger) Info(text string, v ...interface{}) error {
+	return logger.log("info", text, v...)
+}
+
+func (logger *testLogger) Debug(text string, v ...interface{}) error {
+	return logger.log("debug", text, v...)
+}
+
+func (suite *EsTester) Test39ClientLogging() {
+	t := suite.T()
+	assert := assert.New(t)
+
+	index, typ := requestTarget("/estest39/Obj/id0")
+	assert.Equal([]string{"estest39", "Obj"}, []string{index, typ})
+	index, typ = requestTarget("/estest39/_search")
+	assert.Equal([]string{"estest39", ""}, []string{index, typ})
+	index, typ = requestTarget("/_bulk")
+	assert.Equal([]string{"", ""}, []string{index, typ})
+
+	logger := &testLogger{}
+	assert.Empty(clientLogOptions(NewIndexOptions()))
+	assert.Len(clientLogOptions(&IndexOptions{Logger: logger}), 2)
+	options := (&IndexOptions{Logger: logger, Trace: true, SlowThreshold: time.Second}).withDefaults()
+	assert.Equal(logger, options.Logger)
+	assert.True(options.Trace)
+	assert.Equal(time.Second, options.SlowThreshold)
+	assert.Len(clientLogOptions(options), 4)
+	clientLog(logger.Error).Printf("elastic: %s is dead", "node")
+	assert.Equal([]string{"error elastic: node is dead"}, logger.messages)
+	logger.messages = nil
+
+	// only the slow requests are logged, with their bodies
+	received := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		received = append(received, string(body))
+		if strings.HasSuffix(r.URL.Path, "_search") {
+			time.Sleep(30 * time.Millisecond)
+		}
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: &slowLogTransport{base: http.DefaultTransport, threshold: 20 * time.Millisecond, logger: logger}}
+
+	resp, err := client.Post(server.URL+"/estest39/Obj/id0", "application/json", strings.NewReader(`{"data":"fast"}`))
+	assert.NoError(err)
+	resp.Body.Close()
+	resp, err = client.Post(server.URL+"/estest39/Obj/_search", "application/json", strings.NewReader(`{"query":{"match_all":{}}}`))
+	assert.NoError(err)
+	resp.Body.Close()
+	resp, err = client.Post(server.URL+"/estest39/_search", "application/json", strings.NewReader(strings.Repeat("x", SlowLogMaxBody+1)))
+	assert.NoError(err)
+	resp.Body.Close()
+
+	assert.Equal([]string{`{"data":"fast"}`, `{"query":{"match_all":{}}}`, strings.Repeat("x", SlowLogMaxBody+1)}, received)
+	assert.Len(logger.messages, 2)
+	msg := logger.messages[0]
+	assert.True(strings.HasPrefix(msg, `warn elasticsearch: slow request: POST /estest39/Obj/_search, index "estest39", type "Obj", 200 OK after `), msg)
+	assert.True(strings.HasSuffix(msg, `: {"query":{"match_all":{}}}`), msg)
+	assert.True(strings.HasSuffix(logger.messages[1], strings.Repeat("x", SlowLogMaxBody)+"..."))
+}
+
+// bulkHookIndex calls hook before each bulk post, as another client might
+// write to an index while it is being copied.
+type bulkHookIndex struct {
+	IIndex
+	hook func()
+}
+
+func (esi *bulkHookIndex) BulkPostData(typ string, items []*BulkItem) (*BulkResponse, error) {
+	esi.hook()
+	return esi.IIndex.BulkPostData(typ, items)
+}
+
+func (suite *EsTester) Test40ReindexWhileWriting() {
+	t := suite.T()
+	assert := assert.New(t)
+
+	const alias = "estest40"
+
+	src := NewMockIndex(BackingIndexName(alias, 1))
+	assert.NoError(src.Create(""))
+	defer closerT(t, src)
+	assert.NoError(src.SetMapping(mapping, objMapping))
+	for _, o := range objs {
+		_, err := src.PostData(mapping, o.ID, o)
+		assert.NoError(err)
+	}
+	assert.NoError(src.AddAlias(alias))
+
+	// writes made during the copy are caught up with
+	v2 := NewMockIndex(BackingIndexName(alias, 2))
+	assert.NoError(v2.Create(""))
+	defer closerT(t, v2)
+	writes := 0
+	dst := &bulkHookIndex{IIndex: v2, hook: func() {
+		if writes++; writes > 1 {
+			return
+		}
+		_, err := src.PostData(mapping, "id0", Obj{ID: "id0", Data: "changed"})
+		assert.NoError(err)
+		_, err = src.DeleteByID(mapping, "id1")
+		assert.NoError(err)
+		_, err = src.PostData(mapping, "id9", Obj{ID: "id9"})
+		assert.NoError(err)
+	}}
+	n, err := Reindex(alias, src, dst)
+	assert.NoError(err)
+	assert.Equal(len(objs), n)
+	getResult, err := v2.GetByID(mapping, "id0")
+	assert.NoError(err)
+	assert.Contains(string(*getResult.Source), "changed")
+	ok, err := v2.ItemExists(mapping, "id1")
+	assert.NoError(err)
+	assert.False(ok)
+	ok, err = v2.ItemExists(mapping, "id9")
+	assert.NoError(err)
+	assert.True(ok)
+	indices, err := v2.AliasIndices(alias)
+	assert.NoError(err)
+	assert.Equal([]string{v2.IndexName()}, indices)
+
+	// an index that doesn't stop changing isn't swapped to
+	v3 := NewMockIndex(BackingIndexName(alias, 3))
+	assert.NoError(v3.Create(""))
+	defer closerT(t, v3)
+	writes = 0
+	dst = &bulkHookIndex{IIndex: v3, hook: func() {
+		writes++
+		_, err := v2.PostData(mapping, fmt.Sprintf("new%d", writes), Obj{ID: "new"})
+		assert.NoError(err)
+	}}
+	_, err = Reindex(alias, v2, dst)
+	assert.Error(err)
+	assert.Equal(reindexPasses+1, writes)
+	indices, err = v3.AliasIndices(alias)
+	assert.NoError(err)
+	assert.Equal([]string{v2.IndexName()}, indices)
+}
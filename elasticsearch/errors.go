@@ -0,0 +1,195 @@
+// Copyright 2016, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elasticsearch
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/context"
+
+	"github.com/venicegeo/pz-gocommon/elasticsearch/elastic-5-api"
+	"github.com/venicegeo/pz-gocommon/gocommon"
+)
+
+// Index and MockIndex return errors whose causes are the values below, so
+// that callers can tell what went wrong without parsing messages:
+//
+//	ErrIndexNotFound, ErrTypeNotFound,
+//	ErrDocumentNotFound  the thing referred to doesn't exist (IsNotFound)
+//	ErrConflict          a document already exists or has changed
+//	ErrInvalidMapping    a mapping, or a document, doesn't fit
+//	ErrUnavailable       Elasticsearch couldn't be reached or is overloaded
+//	ErrTimeout           the operation's deadline passed
+//
+// StatusCode and NewErrorResponse turn them into HTTP responses. Errors
+// with other causes, such as invalid arguments, are returned as they are.
+
+// ErrConflict is the cause of the error returned when a create-only or
+// versioned write finds that the document already exists or has changed.
+// Test for it with IsConflict.
+var ErrConflict = errors.New("version conflict")
+
+// IsConflict reports whether err was caused by a version conflict.
+func IsConflict(err error) bool {
+	return errors.Cause(err) == ErrConflict
+}
+
+// The causes of the errors returned when an operation finds that what it
+// refers to doesn't exist. Elasticsearch doesn't tell a missing type from a
+// missing document when getting or deleting one, so both are reported as
+// ErrDocumentNotFound there.
+var (
+	ErrIndexNotFound    = errors.New("index not found")
+	ErrTypeNotFound     = errors.New("type not found")
+	ErrDocumentNotFound = errors.New("document not found")
+)
+
+// IsIndexNotFound reports whether err was caused by a missing index.
+func IsIndexNotFound(err error) bool {
+	return errors.Cause(err) == ErrIndexNotFound
+}
+
+// IsTypeNotFound reports whether err was caused by a missing type.
+func IsTypeNotFound(err error) bool {
+	return errors.Cause(err) == ErrTypeNotFound
+}
+
+// IsDocumentNotFound reports whether err was caused by a missing document.
+func IsDocumentNotFound(err error) bool {
+	return errors.Cause(err) == ErrDocumentNotFound
+}
+
+// IsNotFound reports whether err was caused by a missing index, type or
+// document.
+func IsNotFound(err error) bool {
+	return IsIndexNotFound(err) || IsTypeNotFound(err) || IsDocumentNotFound(err)
+}
+
+// ErrInvalidMapping is the cause of the error returned when Elasticsearch
+// rejects a mapping, or a document that doesn't fit its type's mapping.
+var ErrInvalidMapping = errors.New("invalid mapping")
+
+// IsInvalidMapping reports whether err was caused by an invalid mapping,
+// including a mapping change that can't be made in place.
+func IsInvalidMapping(err error) bool {
+	return errors.Cause(err) == ErrInvalidMapping || IsIncompatibleMapping(err)
+}
+
+// ErrUnavailable is the cause of the error returned when no Elasticsearch
+// node could be reached, or the cluster was unable to serve the request.
+var ErrUnavailable = errors.New("elasticsearch unavailable")
+
+// IsUnavailable reports whether err was caused by Elasticsearch being
+// unavailable.
+func IsUnavailable(err error) bool {
+	return errors.Cause(err) == ErrUnavailable
+}
+
+// ErrTimeout is the cause of the error returned when an operation's
+// deadline passes, whether that of its IndexOptions timeout or of the
+// context given to WithContext.
+var ErrTimeout = errors.New("timeout")
+
+// IsTimeout reports whether err was caused by a timeout.
+func IsTimeout(err error) bool {
+	return errors.Cause(err) == ErrTimeout
+}
+
+// StatusCode returns the HTTP status code that best describes err: 404 for
+// the not-found errors, 409 for conflicts, 400 for invalid mappings, 503 if
+// Elasticsearch is unavailable, 504 for timeouts and 500 otherwise.
+func StatusCode(err error) int {
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case IsNotFound(err):
+		return http.StatusNotFound
+	case IsConflict(err):
+		return http.StatusConflict
+	case IsInvalidMapping(err):
+		return http.StatusBadRequest
+	case IsUnavailable(err):
+		return http.StatusServiceUnavailable
+	case IsTimeout(err):
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
+
+// NewErrorResponse returns a response reporting err with its StatusCode.
+func NewErrorResponse(err error) *piazza.JsonResponse {
+	return &piazza.JsonResponse{StatusCode: StatusCode(err), Message: err.Error()}
+}
+
+// typedError returns an error from Elasticsearch, or from the HTTP request
+// to it, with one of the causes above if one fits, and unchanged otherwise.
+// Not-found errors depend on what was asked for, so are left to the caller.
+func typedError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Cause(err) == context.Canceled:
+		return err
+	case elastic.IsConflict(err):
+		return errors.WithMessage(ErrConflict, err.Error())
+	case elasticErrorType(err) == "mapper_parsing_exception":
+		return errors.WithMessage(ErrInvalidMapping, err.Error())
+	case elastic.IsConnErr(err), elasticStatus(err) == http.StatusServiceUnavailable:
+		return errors.WithMessage(ErrUnavailable, err.Error())
+	case isTimeout(err):
+		return errors.WithMessage(ErrTimeout, err.Error())
+	}
+	return err
+}
+
+func isTimeout(err error) bool {
+	cause := errors.Cause(err)
+	if cause == context.DeadlineExceeded || elastic.IsTimeout(cause) {
+		return true
+	}
+	netErr, ok := cause.(net.Error)
+	return ok && netErr.Timeout()
+}
+
+// elasticStatus returns the HTTP status of an error Elasticsearch reported,
+// or 0 if err isn't one.
+func elasticStatus(err error) int {
+	if e, ok := errors.Cause(err).(*elastic.Error); ok {
+		return e.Status
+	}
+	return 0
+}
+
+func elasticErrorType(err error) string {
+	if e, ok := errors.Cause(err).(*elastic.Error); ok && e.Details != nil {
+		return e.Details.Type
+	}
+	return ""
+}
+
+func indexNotFoundError(index string) error {
+	return errors.WithMessage(ErrIndexNotFound, fmt.Sprintf("index %s", index))
+}
+
+func typeNotFoundError(index string, typ string) error {
+	return errors.WithMessage(ErrTypeNotFound, fmt.Sprintf("type %s in index %s", typ, index))
+}
+
+func documentNotFoundError(index string, typ string, id string) error {
+	return errors.WithMessage(ErrDocumentNotFound, fmt.Sprintf("document %s of type %s in index %s", id, typ, index))
+}
@@ -0,0 +1,687 @@
+// Copyright 2016, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elasticsearch
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/venicegeo/pz-gocommon/elasticsearch/elastic-5-api"
+	"github.com/venicegeo/pz-gocommon/gocommon"
+	"golang.org/x/net/context"
+)
+
+// A FaultyIndex wraps an IIndex, usually a MockIndex, and makes some of its
+// calls fail, so that tests can exercise the error and retry paths of the
+// code using it:
+//
+//	esi, err := NewFaultyIndex(NewMockIndex("test"), &FaultSettings{
+//		Seed:             1,
+//		MethodErrorRates: map[string]float64{"PostData": 0.2},
+//	})
+//	esi.Script("GetByID", &Fault{Err: ErrTimeout}, nil, &Fault{Disappear: true})
+//
+// Random faults are drawn from a generator seeded by FaultSettings.Seed, so
+// a run made with the same seed and the same sequence of calls fails in
+// the same places. Scripted faults are taken first, in order, one per call
+// of the method.
+//
+// Besides the IIndex methods, faults befall the pages a ScanIterator
+// fetches, under the name "ScanPage", and the flushes of a bulk indexer,
+// under "BulkFlush".
+
+// FaultSettings says how often a FaultyIndex fails its calls, and how.
+type FaultSettings struct {
+	// Seed seeds the random choices.
+	Seed int64
+
+	// ErrorRate is the probability, from 0 to 1, that a call fails with an
+	// error whose cause is ErrUnavailable.
+	ErrorRate float64
+	// MethodErrorRates overrides ErrorRate for the IIndex methods named,
+	// such as "PostData", and for "ScanPage" and "BulkFlush".
+	MethodErrorRates map[string]float64
+
+	// ConflictRate is the probability that a document write, by PostData,
+	// PutData, CreateData, PutDataIfVersion or UpdateData, fails with an
+	// error whose cause is ErrConflict.
+	ConflictRate float64
+
+	// BulkItemFailureRate is the probability that each item of a
+	// BulkPostData or BulkDeleteByID fails, as Elasticsearch reports an
+	// item it rejected, with status 429, while the rest are carried out.
+	BulkItemFailureRate float64
+
+	// DisappearRate is the probability that the index is deleted before a
+	// call is made, as if by someone else.
+	DisappearRate float64
+
+	// Latency is added to every call, and up to LatencyJitter more at
+	// random.
+	Latency       time.Duration
+	LatencyJitter time.Duration
+}
+
+// Fault is what befalls a call of a FaultyIndex.
+type Fault struct {
+	// Latency is waited out before anything else.
+	Latency time.Duration
+	// Disappear deletes the index before the call is made.
+	Disappear bool
+	// Err, if not nil, is returned instead of making the call.
+	Err error
+}
+
+// faultWrites are the methods ConflictRate applies to.
+var faultWrites = map[string]bool{
+	"PostData":         true,
+	"PutData":          true,
+	"CreateData":       true,
+	"PutDataIfVersion": true,
+	"UpdateData":       true,
+}
+
+// faultEvents are the names faults are drawn under besides those of the
+// IIndex methods.
+var faultEvents = map[string]bool{
+	"ScanPage":  true,
+	"BulkFlush": true,
+}
+
+var _ IIndex = (*FaultyIndex)(nil)
+
+// FaultyIndex is safe for concurrent use if the index it wraps is.
+type FaultyIndex struct {
+	*faultState
+
+	index IIndex
+	ctx   context.Context
+}
+
+// faultState is shared by a FaultyIndex and its WithContext views.
+type faultState struct {
+	settings FaultSettings
+
+	mu      sync.Mutex
+	rng     *rand.Rand
+	scripts map[string][]*Fault
+}
+
+// NewFaultyIndex wraps the index. The settings' method names are checked
+// against IIndex.
+func NewFaultyIndex(esi IIndex, settings *FaultSettings) (*FaultyIndex, error) {
+	if settings == nil {
+		settings = &FaultSettings{}
+	}
+	for method := range settings.MethodErrorRates {
+		if err := checkFaultMethod(method); err != nil {
+			return nil, err
+		}
+	}
+	state := &faultState{
+		settings: *settings,
+		rng:      rand.New(rand.NewSource(settings.Seed)),
+		scripts:  make(map[string][]*Fault),
+	}
+	return &FaultyIndex{faultState: state, index: esi, ctx: context.Background()}, nil
+}
+
+func checkFaultMethod(method string) error {
+	if faultEvents[method] {
+		return nil
+	}
+	if _, ok := reflect.TypeOf((*IIndex)(nil)).Elem().MethodByName(method); !ok {
+		return fmt.Errorf("no such IIndex method: %s", method)
+	}
+	return nil
+}
+
+// Script queues faults for the next calls of the method, ahead of the
+// random ones; a nil fault lets its call through untouched.
+func (esi *FaultyIndex) Script(method string, faults ...*Fault) error {
+	if err := checkFaultMethod(method); err != nil {
+		return err
+	}
+	esi.mu.Lock()
+	defer esi.mu.Unlock()
+	esi.scripts[method] = append(esi.scripts[method], faults...)
+	return nil
+}
+
+// next returns the fault for a call of the method.
+func (s *faultState) next(method string) *Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if script := s.scripts[method]; len(script) > 0 {
+		s.scripts[method] = script[1:]
+		if script[0] == nil {
+			return &Fault{}
+		}
+		return script[0]
+	}
+
+	// draw every number every time, so that the sequence of faults depends
+	// only on that of the calls
+	fault := &Fault{Latency: s.settings.Latency}
+	jitter := s.rng.Float64()
+	disappear := s.rng.Float64()
+	failure := s.rng.Float64()
+	conflict := s.rng.Float64()
+
+	fault.Latency += time.Duration(jitter * float64(s.settings.LatencyJitter))
+	fault.Disappear = disappear < s.settings.DisappearRate
+	rate, ok := s.settings.MethodErrorRates[method]
+	if !ok {
+		rate = s.settings.ErrorRate
+	}
+	switch {
+	case failure < rate:
+		fault.Err = errors.WithMessage(ErrUnavailable, "injected fault in "+method)
+	case faultWrites[method] && conflict < s.settings.ConflictRate:
+		fault.Err = errors.WithMessage(ErrConflict, "injected conflict in "+method)
+	}
+	return fault
+}
+
+// bulkKeep chooses the items of a bulk request to carry out, failing the
+// others.
+func (s *faultState) bulkKeep(n int) ([]bool, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keep := make([]bool, n)
+	kept := 0
+	for i := range keep {
+		keep[i] = s.rng.Float64() >= s.settings.BulkItemFailureRate
+		if keep[i] {
+			kept++
+		}
+	}
+	return keep, kept
+}
+
+// inject visits the fault on the call, returning its error, if any.
+func (esi *FaultyIndex) inject(method string) error {
+	fault := esi.next(method)
+	if fault.Latency > 0 {
+		select {
+		case <-time.After(fault.Latency):
+		case <-esi.ctx.Done():
+			return typedError(esi.ctx.Err())
+		}
+	}
+	if fault.Disappear {
+		if err := esi.index.Delete(); err != nil && !IsIndexNotFound(err) {
+			return err
+		}
+	}
+	return fault.Err
+}
+
+// bulk makes the request for the items not chosen to fail and reports
+// those that were as rejected, in place.
+func (esi *FaultyIndex) bulk(action string, typ string, ids []string, do func(keep []bool) (*BulkResponse, error)) (*BulkResponse, error) {
+	keep, kept := esi.bulkKeep(len(ids))
+	done := &BulkResponse{Items: []*BulkResponseItem{}}
+	if kept > 0 {
+		var err error
+		if done, err = do(keep); err != nil {
+			return done, err
+		}
+	}
+
+	resp := &BulkResponse{Errors: done.Errors, Items: make([]*BulkResponseItem, 0, len(ids))}
+	next := 0
+	for i, id := range ids {
+		if keep[i] {
+			if next < len(done.Items) {
+				resp.Items = append(resp.Items, done.Items[next])
+				next++
+			}
+			continue
+		}
+		resp.Items = append(resp.Items, &BulkResponseItem{
+			Action: action,
+			ID:     id,
+			Type:   typ,
+			Status: 429,
+			Error:  "injected fault: rejected execution",
+		})
+		resp.Errors = true
+	}
+	return resp, nil
+}
+
+func (esi *FaultyIndex) GetVersion() string {
+	return esi.index.GetVersion()
+}
+
+// WithContext returns a view of the index, sharing its faults, whose
+// operations are abandoned when ctx is done.
+func (esi *FaultyIndex) WithContext(ctx context.Context) IIndex {
+	return &FaultyIndex{faultState: esi.faultState, index: esi.index.WithContext(ctx), ctx: ctx}
+}
+
+func (esi *FaultyIndex) IndexName() string {
+	return esi.index.IndexName()
+}
+
+func (esi *FaultyIndex) IndexExists() (bool, error) {
+	if err := esi.inject("IndexExists"); err != nil {
+		return false, err
+	}
+	return esi.index.IndexExists()
+}
+
+func (esi *FaultyIndex) TypeExists(typ string) (bool, error) {
+	if err := esi.inject("TypeExists"); err != nil {
+		return false, err
+	}
+	return esi.index.TypeExists(typ)
+}
+
+func (esi *FaultyIndex) ItemExists(typ string, id string) (bool, error) {
+	if err := esi.inject("ItemExists"); err != nil {
+		return false, err
+	}
+	return esi.index.ItemExists(typ, id)
+}
+
+func (esi *FaultyIndex) Create(settings string) error {
+	if err := esi.inject("Create"); err != nil {
+		return err
+	}
+	return esi.index.Create(settings)
+}
+
+func (esi *FaultyIndex) Close() error {
+	if err := esi.inject("Close"); err != nil {
+		return err
+	}
+	return esi.index.Close()
+}
+
+func (esi *FaultyIndex) Delete() error {
+	if err := esi.inject("Delete"); err != nil {
+		return err
+	}
+	return esi.index.Delete()
+}
+
+func (esi *FaultyIndex) PostData(typ string, id string, obj interface{}) (*IndexResponse, error) {
+	if err := esi.inject("PostData"); err != nil {
+		return nil, err
+	}
+	return esi.index.PostData(typ, id, obj)
+}
+
+func (esi *FaultyIndex) PutData(typ string, id string, obj interface{}) (*IndexResponse, error) {
+	if err := esi.inject("PutData"); err != nil {
+		return nil, err
+	}
+	return esi.index.PutData(typ, id, obj)
+}
+
+func (esi *FaultyIndex) CreateData(typ string, id string, obj interface{}) (*IndexResponse, error) {
+	if err := esi.inject("CreateData"); err != nil {
+		return nil, err
+	}
+	return esi.index.CreateData(typ, id, obj)
+}
+
+func (esi *FaultyIndex) PutDataIfVersion(typ string, id string, obj interface{}, version int) (*IndexResponse, error) {
+	if err := esi.inject("PutDataIfVersion"); err != nil {
+		return nil, err
+	}
+	return esi.index.PutDataIfVersion(typ, id, obj, version)
+}
+
+func (esi *FaultyIndex) UpdateData(typ string, id string, update *Update) (*IndexResponse, error) {
+	if err := esi.inject("UpdateData"); err != nil {
+		return nil, err
+	}
+	return esi.index.UpdateData(typ, id, update)
+}
+
+func (esi *FaultyIndex) GetByID(typ string, id string) (*GetResult, error) {
+	if err := esi.inject("GetByID"); err != nil {
+		return nil, err
+	}
+	return esi.index.GetByID(typ, id)
+}
+
+func (esi *FaultyIndex) GetByIDs(typ string, ids []string) ([]*GetResult, error) {
+	if err := esi.inject("GetByIDs"); err != nil {
+		return nil, err
+	}
+	return esi.index.GetByIDs(typ, ids)
+}
+
+func (esi *FaultyIndex) DeleteByID(typ string, id string) (*DeleteResponse, error) {
+	if err := esi.inject("DeleteByID"); err != nil {
+		return nil, err
+	}
+	return esi.index.DeleteByID(typ, id)
+}
+
+func (esi *FaultyIndex) DeleteByIDWait(typ string, id string) (*DeleteResponse, error) {
+	if err := esi.inject("DeleteByIDWait"); err != nil {
+		return nil, err
+	}
+	return esi.index.DeleteByIDWait(typ, id)
+}
+
+func (esi *FaultyIndex) Refresh() error {
+	if err := esi.inject("Refresh"); err != nil {
+		return err
+	}
+	return esi.index.Refresh()
+}
+
+// BulkPostData may, besides failing whole, fail some of the items; see
+// FaultSettings.BulkItemFailureRate.
+func (esi *FaultyIndex) BulkPostData(typ string, items []*BulkItem) (*BulkResponse, error) {
+	if err := esi.inject("BulkPostData"); err != nil {
+		return nil, err
+	}
+	ids := make([]string, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
+	}
+	return esi.bulk("index", typ, ids, func(keep []bool) (*BulkResponse, error) {
+		kept := []*BulkItem{}
+		for i, item := range items {
+			if keep[i] {
+				kept = append(kept, item)
+			}
+		}
+		return esi.index.BulkPostData(typ, kept)
+	})
+}
+
+// BulkDeleteByID may, besides failing whole, fail some of the items; see
+// FaultSettings.BulkItemFailureRate.
+func (esi *FaultyIndex) BulkDeleteByID(typ string, ids []string) (*BulkResponse, error) {
+	if err := esi.inject("BulkDeleteByID"); err != nil {
+		return nil, err
+	}
+	return esi.bulk("delete", typ, ids, func(keep []bool) (*BulkResponse, error) {
+		kept := []string{}
+		for i, id := range ids {
+			if keep[i] {
+				kept = append(kept, id)
+			}
+		}
+		return esi.index.BulkDeleteByID(typ, kept)
+	})
+}
+
+func (esi *FaultyIndex) DeleteByQuery(typ string, query elastic.Query) (*ByQueryTask, error) {
+	if err := esi.inject("DeleteByQuery"); err != nil {
+		return nil, err
+	}
+	return esi.index.DeleteByQuery(typ, query)
+}
+
+func (esi *FaultyIndex) UpdateByQuery(typ string, query elastic.Query, script *elastic.Script) (*ByQueryTask, error) {
+	if err := esi.inject("UpdateByQuery"); err != nil {
+		return nil, err
+	}
+	return esi.index.UpdateByQuery(typ, query, script)
+}
+
+// NewBulkIndexer may fail, and so may each flush of the indexer returned:
+// the error of the fault drawn for "BulkFlush" is passed to settings.After
+// in place of the flush's response. The flush's operations are made all
+// the same, as when a request times out after Elasticsearch has received
+// it, so that retrying them must do no harm.
+func (esi *FaultyIndex) NewBulkIndexer(settings *BulkSettings) (IBulkIndexer, error) {
+	if err := esi.inject("NewBulkIndexer"); err != nil {
+		return nil, err
+	}
+	if settings == nil {
+		settings = NewBulkSettings()
+	}
+	faulty := *settings
+	faulty.After = func(resp *BulkResponse, err error) {
+		if ferr := esi.inject("BulkFlush"); ferr != nil && err == nil {
+			resp, err = nil, ferr
+		}
+		if settings.After != nil {
+			settings.After(resp, err)
+		}
+	}
+	return esi.index.NewBulkIndexer(&faulty)
+}
+
+func (esi *FaultyIndex) FilterByMatchAll(typ string, format *piazza.JsonPagination) (*SearchResult, error) {
+	if err := esi.inject("FilterByMatchAll"); err != nil {
+		return nil, err
+	}
+	return esi.index.FilterByMatchAll(typ, format)
+}
+
+func (esi *FaultyIndex) GetAllElements(typ string) (*SearchResult, error) {
+	if err := esi.inject("GetAllElements"); err != nil {
+		return nil, err
+	}
+	return esi.index.GetAllElements(typ)
+}
+
+// Scan may fail, and so may each page the iterator returned fetches; see
+// "ScanPage".
+func (esi *FaultyIndex) Scan(typ string, query elastic.Query) (*ScanIterator, error) {
+	if err := esi.inject("Scan"); err != nil {
+		return nil, err
+	}
+	it, err := esi.index.Scan(typ, query)
+	if err != nil {
+		return nil, err
+	}
+	fetch := it.fetch
+	it.fetch = func(after []interface{}, size int) ([]*SearchResultHit, []interface{}, error) {
+		if err := esi.inject("ScanPage"); err != nil {
+			return nil, nil, err
+		}
+		return fetch(after, size)
+	}
+	return it, nil
+}
+
+func (esi *FaultyIndex) FilterByTermQuery(typ string, name string, value interface{}, format *piazza.JsonPagination) (*SearchResult, error) {
+	if err := esi.inject("FilterByTermQuery"); err != nil {
+		return nil, err
+	}
+	return esi.index.FilterByTermQuery(typ, name, value, format)
+}
+
+func (esi *FaultyIndex) FilterByMatchQuery(typ string, name string, value interface{}, format *piazza.JsonPagination) (*SearchResult, error) {
+	if err := esi.inject("FilterByMatchQuery"); err != nil {
+		return nil, err
+	}
+	return esi.index.FilterByMatchQuery(typ, name, value, format)
+}
+
+func (esi *FaultyIndex) FilterByQuery(typ string, query elastic.Query, format *piazza.JsonPagination) (*SearchResult, error) {
+	if err := esi.inject("FilterByQuery"); err != nil {
+		return nil, err
+	}
+	return esi.index.FilterByQuery(typ, query, format)
+}
+
+func (esi *FaultyIndex) FilterByBoundingBox(typ string, field string, topLeft *elastic.GeoPoint, bottomRight *elastic.GeoPoint, format *piazza.JsonPagination) (*SearchResult, error) {
+	if err := esi.inject("FilterByBoundingBox"); err != nil {
+		return nil, err
+	}
+	return esi.index.FilterByBoundingBox(typ, field, topLeft, bottomRight, format)
+}
+
+func (esi *FaultyIndex) FilterByDistance(typ string, field string, center *elastic.GeoPoint, distance string, format *piazza.JsonPagination) (*SearchResult, error) {
+	if err := esi.inject("FilterByDistance"); err != nil {
+		return nil, err
+	}
+	return esi.index.FilterByDistance(typ, field, center, distance, format)
+}
+
+func (esi *FaultyIndex) SearchByJSON(typ string, jsn string) (*SearchResult, error) {
+	if err := esi.inject("SearchByJSON"); err != nil {
+		return nil, err
+	}
+	return esi.index.SearchByJSON(typ, jsn)
+}
+
+func (esi *FaultyIndex) MultiSearch(requests []*SearchRequest) ([]*MultiSearchItem, error) {
+	if err := esi.inject("MultiSearch"); err != nil {
+		return nil, err
+	}
+	return esi.index.MultiSearch(requests)
+}
+
+func (esi *FaultyIndex) Aggregate(typ string, query elastic.Query, aggs map[string]elastic.Aggregation) (*SearchResult, error) {
+	if err := esi.inject("Aggregate"); err != nil {
+		return nil, err
+	}
+	return esi.index.Aggregate(typ, query, aggs)
+}
+
+func (esi *FaultyIndex) SearchWithHighlight(typ string, query elastic.Query, highlight *elastic.Highlight, format *piazza.JsonPagination) (*SearchResult, error) {
+	if err := esi.inject("SearchWithHighlight"); err != nil {
+		return nil, err
+	}
+	return esi.index.SearchWithHighlight(typ, query, highlight, format)
+}
+
+func (esi *FaultyIndex) Suggest(typ string, suggester elastic.Suggester) ([]*Suggestion, error) {
+	if err := esi.inject("Suggest"); err != nil {
+		return nil, err
+	}
+	return esi.index.Suggest(typ, suggester)
+}
+
+func (esi *FaultyIndex) SetMapping(typename string, jsn piazza.JsonString) error {
+	if err := esi.inject("SetMapping"); err != nil {
+		return err
+	}
+	return esi.index.SetMapping(typename, jsn)
+}
+
+func (esi *FaultyIndex) GetTypes() ([]string, error) {
+	if err := esi.inject("GetTypes"); err != nil {
+		return nil, err
+	}
+	return esi.index.GetTypes()
+}
+
+func (esi *FaultyIndex) GetMapping(typ string) (interface{}, error) {
+	if err := esi.inject("GetMapping"); err != nil {
+		return nil, err
+	}
+	return esi.index.GetMapping(typ)
+}
+
+func (esi *FaultyIndex) AddAlias(alias string) error {
+	if err := esi.inject("AddAlias"); err != nil {
+		return err
+	}
+	return esi.index.AddAlias(alias)
+}
+
+func (esi *FaultyIndex) RemoveAlias(alias string) error {
+	if err := esi.inject("RemoveAlias"); err != nil {
+		return err
+	}
+	return esi.index.RemoveAlias(alias)
+}
+
+func (esi *FaultyIndex) SwapAlias(alias string) error {
+	if err := esi.inject("SwapAlias"); err != nil {
+		return err
+	}
+	return esi.index.SwapAlias(alias)
+}
+
+func (esi *FaultyIndex) GetAliases() ([]string, error) {
+	if err := esi.inject("GetAliases"); err != nil {
+		return nil, err
+	}
+	return esi.index.GetAliases()
+}
+
+func (esi *FaultyIndex) AliasIndices(alias string) ([]string, error) {
+	if err := esi.inject("AliasIndices"); err != nil {
+		return nil, err
+	}
+	return esi.index.AliasIndices(alias)
+}
+
+func (esi *FaultyIndex) GetSettings() (*IndexSettings, error) {
+	if err := esi.inject("GetSettings"); err != nil {
+		return nil, err
+	}
+	return esi.index.GetSettings()
+}
+
+func (esi *FaultyIndex) UpdateSettings(settings *IndexSettings) error {
+	if err := esi.inject("UpdateSettings"); err != nil {
+		return err
+	}
+	return esi.index.UpdateSettings(settings)
+}
+
+func (esi *FaultyIndex) PutTemplate(name string, template *IndexTemplate) error {
+	if err := esi.inject("PutTemplate"); err != nil {
+		return err
+	}
+	return esi.index.PutTemplate(name, template)
+}
+
+func (esi *FaultyIndex) DeleteTemplate(name string) error {
+	if err := esi.inject("DeleteTemplate"); err != nil {
+		return err
+	}
+	return esi.index.DeleteTemplate(name)
+}
+
+func (esi *FaultyIndex) AddPercolationQuery(id string, query piazza.JsonString) (*IndexResponse, error) {
+	if err := esi.inject("AddPercolationQuery"); err != nil {
+		return nil, err
+	}
+	return esi.index.AddPercolationQuery(id, query)
+}
+
+func (esi *FaultyIndex) DeletePercolationQuery(id string) (*DeleteResponse, error) {
+	if err := esi.inject("DeletePercolationQuery"); err != nil {
+		return nil, err
+	}
+	return esi.index.DeletePercolationQuery(id)
+}
+
+func (esi *FaultyIndex) AddPercolationDocument(typ string, doc interface{}) (*PercolateResponse, error) {
+	if err := esi.inject("AddPercolationDocument"); err != nil {
+		return nil, err
+	}
+	return esi.index.AddPercolationDocument(typ, doc)
+}
+
+func (esi *FaultyIndex) DirectAccess(verb string, endpoint string, input interface{}, output interface{}) error {
+	if err := esi.inject("DirectAccess"); err != nil {
+		return err
+	}
+	return esi.index.DirectAccess(verb, endpoint, input, output)
+}
@@ -0,0 +1,571 @@
+// Copyright 2016, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elasticsearch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// IndexDirEnv names the environment variable which, if set, has
+// NewIndexInterface keep indices as FileIndexes in the directory it names,
+// rather than in Elasticsearch.
+const IndexDirEnv = "PIAZZA_INDEX_DIR"
+
+// FileCheckpointInterval is the number of changes a FileIndex logs before
+// folding them into its data files.
+const FileCheckpointInterval = 1000
+
+var _ IIndex = (*FileIndex)(nil)
+
+// FileIndex is an index kept in a local directory instead of Elasticsearch,
+// for development and demonstrations without a cluster. It holds its
+// documents in memory and evaluates queries as MockIndex does, with the
+// same limits, but its documents, mappings and settings outlive the
+// process. Aliases and templates, as under mocking, don't. It is safe for
+// concurrent use, but only one process may have the index open at a time,
+// which a lock on its directory sees to.
+//
+// The index's directory holds index.json, with its settings and mappings,
+// a file of JSON lines per type under types/, and wal.jsonl, the log of the
+// changes made since those were written. Each change is logged, and synced
+// to disk, before it is made. The log is replayed when the index is
+// opened, and folded into the other files by Close and every
+// FileCheckpointInterval changes.
+type FileIndex struct {
+	*MockIndex
+}
+
+// fileIndices holds the data of each FileIndex opened, by directory, so
+// that opening one again shares it.
+var fileIndices = struct {
+	sync.Mutex
+	indices map[string]*mockIndexData
+}{indices: make(map[string]*mockIndexData)}
+
+// NewFileIndex opens the index kept in dir/indexName, reading what was
+// written to it before. As with Elasticsearch, the index must be created
+// unless it already exists.
+func NewFileIndex(dir string, indexName string) (*FileIndex, error) {
+	if indexName == "" || strings.ContainsAny(indexName, `/\`) || indexName == "." || indexName == ".." {
+		return nil, fmt.Errorf("invalid index name %q", indexName)
+	}
+	path, err := filepath.Abs(filepath.Join(dir, indexName))
+	if err != nil {
+		return nil, err
+	}
+
+	fileIndices.Lock()
+	defer fileIndices.Unlock()
+
+	data, ok := fileIndices.indices[path]
+	if !ok {
+		data = &mockIndexData{name: indexName, types: make(map[string]*MockIndexType)}
+		data.journal, err = openFileJournal(path, data)
+		if err != nil {
+			return nil, err
+		}
+		fileIndices.indices[path] = data
+	}
+	return &FileIndex{MockIndex: &MockIndex{mockIndexData: data, ctx: context.Background()}}, nil
+}
+
+// WithContext returns a view of the index whose operations fail with the
+// context's error once it is cancelled or its deadline has passed.
+func (esi *FileIndex) WithContext(ctx context.Context) IIndex {
+	return &FileIndex{MockIndex: &MockIndex{mockIndexData: esi.mockIndexData, ctx: ctx}}
+}
+
+// Close writes the index's data files, empties its log and lets go of its
+// directory, which NewFileIndex may then open again, in this process or
+// another. Every FileIndex sharing the data is closed; it can still be
+// read and deleted, but changing it fails.
+func (esi *FileIndex) Close() error {
+	if err := esi.ctxErr(); err != nil {
+		return err
+	}
+
+	fileIndices.Lock()
+	defer fileIndices.Unlock()
+	esi.mu.Lock()
+	defer esi.mu.Unlock()
+
+	j := esi.journal
+	if j.closed() {
+		return nil
+	}
+	if err := j.checkpoint(); err != nil {
+		return err
+	}
+	if err := j.close(); err != nil {
+		return err
+	}
+	if fileIndices.indices[j.path] == esi.mockIndexData {
+		delete(fileIndices.indices, j.path)
+	}
+	esi.open = false
+	return nil
+}
+
+// Delete deletes the index, and its data files with it. A closed index is
+// opened again to be deleted, unless it has been since.
+func (esi *FileIndex) Delete() error {
+	if err := esi.ctxErr(); err != nil {
+		return err
+	}
+
+	fileIndices.Lock()
+	defer fileIndices.Unlock()
+
+	j := esi.journal
+	esi.mu.Lock()
+	closed := j.closed()
+	esi.mu.Unlock()
+	if closed {
+		if _, ok := fileIndices.indices[j.path]; ok {
+			return fmt.Errorf("index %s has been opened again", j.path)
+		}
+		esi.mu.Lock()
+		err := j.reopen()
+		esi.mu.Unlock()
+		if err != nil {
+			return err
+		}
+		defer func() {
+			esi.mu.Lock()
+			_ = j.close()
+			esi.mu.Unlock()
+		}()
+	}
+
+	if err := esi.MockIndex.Delete(); err != nil {
+		return err
+	}
+	esi.mu.Lock()
+	defer esi.mu.Unlock()
+	return j.checkpoint()
+}
+
+// journalEntry is a change to an index, as logged. Each entry says what
+// some part of the index is to be, not how it differs, so that replaying
+// one already made changes nothing.
+type journalEntry struct {
+	// Op is one of create, drop, settings, mapping, index and delete.
+	Op       string                 `json:"op"`
+	Type     string                 `json:"type,omitempty"`
+	ID       string                 `json:"id,omitempty"`
+	Version  int                    `json:"version,omitempty"`
+	Source   *json.RawMessage       `json:"source,omitempty"`
+	Mapping  interface{}            `json:"mapping,omitempty"`
+	Settings map[string]interface{} `json:"settings,omitempty"`
+	IDSource int                    `json:"id_source,omitempty"`
+}
+
+// record logs the change, if the data is journalled, before it is made.
+// data.mu must be held.
+func (data *mockIndexData) record(e *journalEntry) error {
+	if data.journal == nil {
+		return nil
+	}
+	return data.journal.record(e)
+}
+
+// replay makes a logged change. data.mu must be held.
+func (data *mockIndexData) replay(e *journalEntry) error {
+	switch e.Op {
+	case "create", "settings":
+		settings, err := ParseIndexSettings(e.Settings)
+		if err != nil {
+			return err
+		}
+		if e.Op == "create" {
+			data.exists = true
+			data.types = make(map[string]*MockIndexType)
+		}
+		data.settings = settings
+	case "drop":
+		data.exists = false
+		data.types = make(map[string]*MockIndexType)
+	case "mapping":
+		if typ, ok := data.types[e.Type]; ok {
+			typ.mapping = e.Mapping
+		} else {
+			data.types[e.Type] = newMockIndexType(e.Mapping)
+		}
+	case "index":
+		typ, ok := data.types[e.Type]
+		if !ok {
+			typ = newMockIndexType(nil)
+			data.types[e.Type] = typ
+		}
+		typ.items[e.ID] = e.Source
+		typ.versions[e.ID] = e.Version
+		if e.IDSource > data.idSource {
+			data.idSource = e.IDSource
+		}
+	case "delete":
+		if typ, ok := data.types[e.Type]; ok {
+			delete(typ.items, e.ID)
+			delete(typ.versions, e.ID)
+		}
+	default:
+		return fmt.Errorf("unknown operation %q", e.Op)
+	}
+	return nil
+}
+
+// fileSnapshot is the content of index.json.
+type fileSnapshot struct {
+	Settings map[string]interface{} `json:"settings"`
+	Mappings map[string]interface{} `json:"mappings"`
+	IDSource int                    `json:"id_source"`
+}
+
+// fileDocument is a line of a type's file.
+type fileDocument struct {
+	ID      string           `json:"_id"`
+	Version int              `json:"_version"`
+	Source  *json.RawMessage `json:"_source"`
+}
+
+// fileJournal keeps the data of a FileIndex on disk. Its methods must be
+// called with data.mu held.
+type fileJournal struct {
+	path    string
+	data    *mockIndexData
+	entries int
+
+	// lock and wal are nil once the journal is closed.
+	lock *os.File
+	wal  *os.File
+}
+
+func openFileJournal(path string, data *mockIndexData) (*fileJournal, error) {
+	j := &fileJournal{path: path, data: data}
+	if err := os.MkdirAll(j.typesDir(), 0755); err != nil {
+		return nil, err
+	}
+	if err := j.lockDir(); err != nil {
+		return nil, err
+	}
+	if err := j.load(); err != nil {
+		j.release()
+		return nil, fmt.Errorf("index %s: %s", path, err.Error())
+	}
+	if err := j.openWAL(); err != nil {
+		j.release()
+		return nil, err
+	}
+	return j, nil
+}
+
+// lockDir takes the lock on the index's directory, failing if another
+// process holds it.
+func (j *fileJournal) lockDir() error {
+	f, err := lockFile(j.lockPath())
+	if err != nil {
+		return fmt.Errorf("index %s is in use by another process: %s", j.path, err.Error())
+	}
+	j.lock = f
+	return nil
+}
+
+func (j *fileJournal) openWAL() error {
+	var err error
+	j.wal, err = os.OpenFile(j.walPath(), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	return err
+}
+
+// reopen takes the lock again after close, without reading what is on
+// disk, which the data in memory is newer than.
+func (j *fileJournal) reopen() error {
+	if err := j.lockDir(); err != nil {
+		return err
+	}
+	if err := j.openWAL(); err != nil {
+		j.release()
+		return err
+	}
+	return nil
+}
+
+func (j *fileJournal) closed() bool {
+	return j.wal == nil
+}
+
+// close syncs the log and lets go of the files.
+func (j *fileJournal) close() error {
+	err := j.wal.Sync()
+	j.release()
+	return err
+}
+
+// release closes the files and lets go of the lock, as a process dying
+// would.
+func (j *fileJournal) release() {
+	if j.wal != nil {
+		_ = j.wal.Close()
+		j.wal = nil
+	}
+	if j.lock != nil {
+		unlockFile(j.lock)
+		j.lock = nil
+	}
+}
+
+func (j *fileJournal) snapshotPath() string {
+	return filepath.Join(j.path, "index.json")
+}
+
+func (j *fileJournal) typesDir() string {
+	return filepath.Join(j.path, "types")
+}
+
+func (j *fileJournal) typePath(typeName string) string {
+	return filepath.Join(j.typesDir(), url.QueryEscape(typeName)+".jsonl")
+}
+
+func (j *fileJournal) walPath() string {
+	return filepath.Join(j.path, "wal.jsonl")
+}
+
+func (j *fileJournal) lockPath() string {
+	return filepath.Join(j.path, "lock")
+}
+
+// load reads the data files and then replays the log.
+func (j *fileJournal) load() error {
+	byts, err := ioutil.ReadFile(j.snapshotPath())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		var snapshot fileSnapshot
+		if err = json.Unmarshal(byts, &snapshot); err != nil {
+			return fmt.Errorf("index.json: %s", err.Error())
+		}
+		if err = j.data.replay(&journalEntry{Op: "create", Settings: snapshot.Settings}); err != nil {
+			return err
+		}
+		for typeName, mapping := range snapshot.Mappings {
+			j.data.types[typeName] = newMockIndexType(mapping)
+		}
+		j.data.idSource = snapshot.IDSource
+
+		files, err := ioutil.ReadDir(j.typesDir())
+		if err != nil {
+			return err
+		}
+		for _, f := range files {
+			if err = j.loadType(f.Name()); err != nil {
+				return err
+			}
+		}
+	}
+
+	byts, err = ioutil.ReadFile(j.walPath())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	lines := bytes.Split(byts, []byte("\n"))
+	for i, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+		var e journalEntry
+		if err = json.Unmarshal(line, &e); err != nil {
+			// the last change may not have been logged in full, and the
+			// next must not be appended to it
+			if i == len(lines)-1 {
+				return os.Truncate(j.walPath(), int64(len(byts)-len(line)))
+			}
+			return fmt.Errorf("wal.jsonl, line %d: %s", i+1, err.Error())
+		}
+		if err = j.data.replay(&e); err != nil {
+			return fmt.Errorf("wal.jsonl, line %d: %s", i+1, err.Error())
+		}
+		j.entries++
+	}
+	return nil
+}
+
+func (j *fileJournal) loadType(file string) error {
+	if !strings.HasSuffix(file, ".jsonl") {
+		return nil
+	}
+	typeName, err := url.QueryUnescape(strings.TrimSuffix(file, ".jsonl"))
+	if err != nil {
+		return err
+	}
+	byts, err := ioutil.ReadFile(filepath.Join(j.typesDir(), file))
+	if err != nil {
+		return err
+	}
+
+	typ, ok := j.data.types[typeName]
+	if !ok {
+		typ = newMockIndexType(nil)
+		j.data.types[typeName] = typ
+	}
+	for i, line := range bytes.Split(byts, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		var doc fileDocument
+		if err = json.Unmarshal(line, &doc); err != nil {
+			return fmt.Errorf("types/%s, line %d: %s", file, i+1, err.Error())
+		}
+		typ.items[doc.ID] = doc.Source
+		typ.versions[doc.ID] = doc.Version
+	}
+	return nil
+}
+
+// record logs the change, first folding the log into the data files if it
+// has grown long. data.mu must be held.
+func (j *fileJournal) record(e *journalEntry) error {
+	if j.closed() {
+		return fmt.Errorf("index %s is closed", j.path)
+	}
+	if j.entries >= FileCheckpointInterval {
+		if err := j.checkpoint(); err != nil {
+			return err
+		}
+	}
+	byts, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err = j.wal.Write(append(byts, '\n')); err != nil {
+		return err
+	}
+	if err = j.wal.Sync(); err != nil {
+		return err
+	}
+	j.entries++
+	return nil
+}
+
+// checkpoint writes the data files and empties the log. Each file is
+// replaced whole, so that if the process dies part way through, the log,
+// replayed over whichever files were replaced, still gives the data as it
+// was; the log is emptied only once the replacements are on disk. data.mu
+// must be held.
+func (j *fileJournal) checkpoint() error {
+	files, err := ioutil.ReadDir(j.typesDir())
+	if err != nil {
+		return err
+	}
+	stale := map[string]bool{}
+	for _, f := range files {
+		stale[f.Name()] = true
+	}
+
+	if !j.data.exists {
+		if err = os.Remove(j.snapshotPath()); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	} else {
+		snapshot := &fileSnapshot{
+			Settings: j.data.settings.indexSource(),
+			Mappings: map[string]interface{}{},
+			IDSource: j.data.idSource,
+		}
+		for typeName, typ := range j.data.types {
+			snapshot.Mappings[typeName] = typ.mapping
+			if err = j.writeType(typeName, typ); err != nil {
+				return err
+			}
+			delete(stale, filepath.Base(j.typePath(typeName)))
+		}
+		byts, err := json.Marshal(snapshot)
+		if err != nil {
+			return err
+		}
+		if err = writeFileAtomic(j.snapshotPath(), byts); err != nil {
+			return err
+		}
+	}
+
+	for name := range stale {
+		if err = os.Remove(filepath.Join(j.typesDir(), name)); err != nil {
+			return err
+		}
+	}
+	if err = syncDir(j.typesDir()); err != nil {
+		return err
+	}
+	if err = syncDir(j.path); err != nil {
+		return err
+	}
+	if err = j.wal.Truncate(0); err != nil {
+		return err
+	}
+	if err = j.wal.Sync(); err != nil {
+		return err
+	}
+	j.entries = 0
+	return nil
+}
+
+func (j *fileJournal) writeType(typeName string, typ *MockIndexType) error {
+	ids := make([]string, 0, len(typ.items))
+	for id := range typ.items {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var buf bytes.Buffer
+	for _, id := range ids {
+		byts, err := json.Marshal(&fileDocument{ID: id, Version: typ.versions[id], Source: typ.items[id]})
+		if err != nil {
+			return err
+		}
+		buf.Write(byts)
+		buf.WriteByte('\n')
+	}
+	return writeFileAtomic(j.typePath(typeName), buf.Bytes())
+}
+
+// writeFileAtomic replaces the file, so that it is found either as it was
+// or as it is to be.
+func writeFileAtomic(path string, byts []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(byts); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
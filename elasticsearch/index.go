@@ -17,9 +17,12 @@ package elasticsearch
 import (
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/venicegeo/pz-gocommon/gocommon"
 
 	"github.com/venicegeo/pz-gocommon/elasticsearch/elastic-5-api"
@@ -29,6 +32,12 @@ import (
 var _ IIndex = (*Index)(nil)
 
 // Index is a representation of the Elasticsearch index.
+//
+// Writes check that their type exists, but only ask Elasticsearch the
+// first time; see typeCache. Elasticsearch must therefore be run with
+// action.auto_create_index set to false, or to patterns leaving out the
+// index, so that a write to an index deleted by another process fails
+// with ErrIndexNotFound rather than recreating it with a dynamic mapping.
 type Index struct {
 	lib     *elastic.Client
 	version string
@@ -36,6 +45,12 @@ type Index struct {
 	url     string
 	user    string
 	pass    string
+
+	// ctx is the parent of every request's context; see WithContext.
+	ctx     context.Context
+	options *IndexOptions
+
+	types *typeCache
 }
 
 // NewIndex is the initializing constructor for the type Index.
@@ -48,39 +63,62 @@ func NewIndex(sys *piazza.SystemConfig, index string, settings string) (*Index,
 	return NewIndex2(url, "", "", index, settings)
 }
 
-func NewIndex2(url, user, pass, index, settings string) (*Index, error) {
+// NewIndex2 connects to the Elasticsearch at url, creating the index if need
+// be. At most one IndexOptions may be given; without one, the defaults apply.
+func NewIndex2(url, user, pass, index, settings string, options ...*IndexOptions) (*Index, error) {
+	esi, err := openIndex(url, user, pass, index, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	// This does nothing if the index is already created, but creates it if not
+	err = esi.Create(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	return esi, nil
+}
+
+// openIndex connects to the Elasticsearch at url, leaving the index, or
+// alias, as it finds it.
+func openIndex(url, user, pass, index string, options ...*IndexOptions) (*Index, error) {
 	if strings.HasSuffix(index, "$") {
 		index = fmt.Sprintf("%s.%x", index[0:len(index)-1], time.Now().Nanosecond())
 	}
+	if len(options) > 1 {
+		return nil, fmt.Errorf("NewIndex2: at most one IndexOptions may be given")
+	}
+	var opts *IndexOptions
+	if len(options) == 1 {
+		opts = options[0]
+	}
 
 	esi := &Index{
-		index: index,
-		url:   url,
-		user:  user,
-		pass:  pass,
+		index:   index,
+		url:     url,
+		user:    user,
+		pass:    pass,
+		ctx:     context.Background(),
+		options: opts.withDefaults(),
+		types:   newTypeCache(),
 	}
 
-	var err error
-
-	esi.lib, err = elastic.NewClient(
+	clientOptions := []elastic.ClientOptionFunc{
 		elastic.SetURL(url),
 		elastic.SetBasicAuth(user, pass),
 		elastic.SetSniff(false),
 		elastic.SetMaxRetries(5),
-		//elastic.SetErrorLog(log.New(os.Stderr, "ELASTIC ", log.LstdFlags)), // TODO
-		//elastic.SetInfoLog(log.New(os.Stdout, "", log.LstdFlags)),
-	)
-	if err != nil {
-		return nil, err
 	}
+	clientOptions = append(clientOptions, clientLogOptions(esi.options)...)
 
-	esi.version, err = esi.lib.ElasticsearchVersion(url)
+	var err error
+	esi.lib, err = elastic.NewClient(clientOptions...)
 	if err != nil {
 		return nil, err
 	}
 
-	// This does nothing if the index is already created, but creates it if not
-	err = esi.Create(settings)
+	esi.version, err = esi.lib.ElasticsearchVersion(url)
 	if err != nil {
 		return nil, err
 	}
@@ -88,37 +126,66 @@ func NewIndex2(url, user, pass, index, settings string) (*Index, error) {
 	return esi, nil
 }
 
-func IndexExists(sys *piazza.SystemConfig, index string) (bool, error) {
+// IndexExists reports whether the index, or alias, exists in the
+// Elasticsearch of the system. At most one IndexOptions may be given.
+func IndexExists(sys *piazza.SystemConfig, index string, options ...*IndexOptions) (bool, error) {
 	url, err := sys.GetURL(piazza.PzElasticSearch)
 	if err != nil {
 		return false, err
 	}
-	if strings.HasSuffix(index, "$") {
-		index = fmt.Sprintf("%s.%x", index[0:len(index)-1], time.Now().Nanosecond())
+
+	esi, err := openIndex(url, "", "", index, options...)
+	if err != nil {
+		return false, err
 	}
 
-	esi := &Index{
-		index: index,
-		url:   url,
+	return esi.IndexExists()
+}
+
+// WithContext returns a view of the index whose operations run under ctx,
+// so that they are abandoned when it is cancelled or its deadline passes.
+// The per-operation timeouts of the IndexOptions still apply.
+func (esi *Index) WithContext(ctx context.Context) IIndex {
+	view := *esi
+	view.ctx = ctx
+	return &view
+}
+
+// parentContext and indexOptions return the index's context and options. An
+// Index built by hand, rather than by NewIndex2, gets the defaults.
+func (esi *Index) parentContext() context.Context {
+	if esi.ctx == nil {
+		return context.Background()
 	}
+	return esi.ctx
+}
 
-	esi.lib, err = elastic.NewClient(
-		elastic.SetURL(url),
-		elastic.SetSniff(false),
-		elastic.SetMaxRetries(5),
-		//elastic.SetErrorLog(log.New(os.Stderr, "ELASTIC ", log.LstdFlags)), // TODO
-		//elastic.SetInfoLog(log.New(os.Stdout, "", log.LstdFlags)),
-	)
-	if err != nil {
-		return false, err
+func (esi *Index) indexOptions() *IndexOptions {
+	if esi.options == nil {
+		return NewIndexOptions()
 	}
+	return esi.options
+}
 
-	esi.version, err = esi.lib.ElasticsearchVersion(url)
-	if err != nil {
-		return false, err
+// operationContext derives a request's context from the index's context.
+func (esi *Index) operationContext(timeout func(*IndexOptions) time.Duration) (context.Context, context.CancelFunc) {
+	parent := esi.parentContext()
+	if t := timeout(esi.indexOptions()); t >= 0 {
+		return context.WithTimeout(parent, t)
 	}
+	return context.WithCancel(parent)
+}
 
-	return esi.IndexExists()
+func (esi *Index) readContext() (context.Context, context.CancelFunc) {
+	return esi.operationContext(func(o *IndexOptions) time.Duration { return o.ReadTimeout })
+}
+
+func (esi *Index) writeContext() (context.Context, context.CancelFunc) {
+	return esi.operationContext(func(o *IndexOptions) time.Duration { return o.WriteTimeout })
+}
+
+func (esi *Index) adminContext() (context.Context, context.CancelFunc) {
+	return esi.operationContext(func(o *IndexOptions) time.Duration { return o.AdminTimeout })
 }
 
 // GetVersion returns the Elasticsearch version.
@@ -133,49 +200,97 @@ func (esi *Index) IndexName() string {
 
 // IndexExists checks to see if the index exists.
 func (esi *Index) IndexExists() (bool, error) {
-	ok, err := esi.lib.IndexExists(esi.index).Do(context.Background())
+	ctx, cancel := esi.readContext()
+	defer cancel()
+
+	ok, err := esi.lib.IndexExists(esi.index).Do(ctx)
 	if err != nil {
-		return false, err
+		return false, esi.typedError(err, nil)
 	}
 	return ok, nil
 }
 
-// TypeExists checks to see if the specified type exists within the index.
+// TypeExists checks to see if the specified type exists within the index;
+// it doesn't if the index doesn't. Types once found are remembered.
 func (esi *Index) TypeExists(typ string) (bool, error) {
-	ok, err := esi.IndexExists()
-	if err != nil {
-		return false, err
-	}
-	if !ok {
-		return false, nil
+	if esi.types.has(typ) {
+		return true, nil
 	}
 
-	ok, err = esi.lib.TypeExists().Index(esi.index).Type(typ).Do(context.Background())
+	ctx, cancel := esi.readContext()
+	defer cancel()
+
+	ok, err := esi.lib.TypeExists().Index(esi.index).Type(typ).Do(ctx)
 	if err != nil {
-		return false, err
+		return false, esi.typedError(err, nil)
+	}
+	if ok {
+		esi.types.add(typ)
 	}
 	return ok, nil
 }
 
 // ItemExists checks to see if the specified item exists within the type and index specified.
 func (esi *Index) ItemExists(typ string, id string) (bool, error) {
+	ctx, cancel := esi.readContext()
+	defer cancel()
+
+	ok, err := esi.lib.Exists().Index(esi.index).Type(typ).Id(id).Do(ctx)
+	if err != nil {
+		return false, esi.typedError(err, nil)
+	}
+	return ok, nil
+}
+
+// requireType returns nil if the type exists and otherwise an error whose
+// cause is ErrTypeNotFound or, if the index is missing too,
+// ErrIndexNotFound. Searches, which would find nothing rather than fail,
+// and writes, which Elasticsearch would let create the type, check with it
+// first. Once the type is cached, this costs no request.
+func (esi *Index) requireType(typ string) error {
 	ok, err := esi.TypeExists(typ)
+	if err != nil || ok {
+		return err
+	}
+	ok, err = esi.IndexExists()
 	if err != nil {
-		return false, err
+		return err
 	}
 	if !ok {
-		return false, nil
+		esi.types.clear()
+		return indexNotFoundError(esi.index)
 	}
+	return typeNotFoundError(esi.index, typ)
+}
 
-	ok, err = esi.lib.Exists().Index(esi.index).Type(typ).Id(id).Do(context.Background())
-	if err != nil {
-		return false, err
+// typedError returns an error from Elasticsearch as an ErrIndexNotFound if
+// it reports the index missing, as notFound if it is any other 404, and
+// otherwise as the package-level typedError does.
+func (esi *Index) typedError(err error, notFound error) error {
+	switch {
+	case elastic.IsIndexNotFound(err):
+		esi.types.clear()
+		return indexNotFoundError(esi.index)
+	case notFound != nil && elastic.IsNotFound(err):
+		return notFound
+	}
+	return typedError(err)
+}
+
+// mappingError is typedError for requests carrying mappings, which
+// Elasticsearch rejects with a 400.
+func (esi *Index) mappingError(err error) error {
+	if elasticStatus(err) == http.StatusBadRequest {
+		return errors.WithMessage(ErrInvalidMapping, err.Error())
 	}
-	return ok, nil
+	return esi.typedError(err, nil)
 }
 
 // Create the index; if index already exists, does nothing.
 func (esi *Index) Create(settings string) error {
+	ctx, cancel := esi.adminContext()
+	defer cancel()
+
 	ok, err := esi.IndexExists()
 	if err != nil {
 		return err
@@ -185,10 +300,9 @@ func (esi *Index) Create(settings string) error {
 		return nil
 	}
 
-	createIndex, err := esi.lib.CreateIndex(esi.index).Body(settings).Do(context.Background())
-
+	createIndex, err := esi.lib.CreateIndex(esi.index).Body(settings).Do(ctx)
 	if err != nil {
-		return err
+		return esi.mappingError(err)
 	}
 
 	if !createIndex.Acknowledged {
@@ -198,34 +312,25 @@ func (esi *Index) Create(settings string) error {
 	return nil
 }
 
-// Close the index; if index doesn't already exist, does nothing.
+// Close the index. If it doesn't exist, the error's cause is ErrIndexNotFound.
 func (esi *Index) Close() error {
-	// TODO: the caller should enforce this instead
-	ok, err := esi.IndexExists()
-	if err != nil {
-		return err
-	}
-	if !ok {
-		return fmt.Errorf("Index %s does not already exist", esi.index)
-	}
+	ctx, cancel := esi.adminContext()
+	defer cancel()
 
-	_, err = esi.lib.CloseIndex(esi.index).Do(context.Background())
-	return err
+	_, err := esi.lib.CloseIndex(esi.index).Do(ctx)
+	return esi.typedError(err, nil)
 }
 
-// Delete the index; if index doesn't already exist, does nothing.
+// Delete the index. If it doesn't exist, the error's cause is ErrIndexNotFound.
 func (esi *Index) Delete() error {
-	ok, err := esi.IndexExists()
-	if err != nil {
-		return err
-	}
-	if !ok {
-		return fmt.Errorf("Index %s does not exist", esi.index)
-	}
+	ctx, cancel := esi.adminContext()
+	defer cancel()
 
-	deleteIndex, err := esi.lib.DeleteIndex(esi.index).Do(context.Background())
+	esi.types.clear()
+
+	deleteIndex, err := esi.lib.DeleteIndex(esi.index).Do(ctx)
 	if err != nil {
-		return err
+		return esi.typedError(err, nil)
 	}
 
 	if !deleteIndex.Acknowledged {
@@ -234,104 +339,357 @@ func (esi *Index) Delete() error {
 	return nil
 }
 
-// PostData send JSON data to the index.
+// PostData adds a document to the index, replacing any existing document
+// with the same id. If id is empty, Elasticsearch assigns one.
 func (esi *Index) PostData(typ string, id string, obj interface{}) (*IndexResponse, error) {
-	ok, err := esi.IndexExists()
-	if err != nil {
-		return nil, err
+	return esi.indexDocument(typ, id, obj, false, 0)
+}
+
+// PutData stores a document under the given id, replacing any existing one.
+func (esi *Index) PutData(typ string, id string, obj interface{}) (*IndexResponse, error) {
+	if id == "" {
+		return nil, fmt.Errorf("PutData: id may not be empty")
 	}
-	if !ok {
-		return nil, fmt.Errorf("Index %s does not exist", esi.index)
+	return esi.indexDocument(typ, id, obj, false, 0)
+}
+
+// CreateData stores a document only if none exists with the same id;
+// otherwise it fails with an ErrConflict.
+func (esi *Index) CreateData(typ string, id string, obj interface{}) (*IndexResponse, error) {
+	if id == "" {
+		return nil, fmt.Errorf("CreateData: id may not be empty")
 	}
-	ok, err = esi.TypeExists(typ)
-	if err != nil {
-		return nil, err
+	return esi.indexDocument(typ, id, obj, true, 0)
+}
+
+// PutDataIfVersion replaces a document only if it is still at the given
+// version, as returned in IndexResponse.Version or GetResult.Version;
+// otherwise it fails with an ErrConflict.
+func (esi *Index) PutDataIfVersion(typ string, id string, obj interface{}, version int) (*IndexResponse, error) {
+	if id == "" {
+		return nil, fmt.Errorf("PutDataIfVersion: id may not be empty")
 	}
-	if !ok {
-		err = fmt.Errorf("Type %s in index %s does not exist", typ, esi.index)
+	if version <= 0 {
+		return nil, fmt.Errorf("PutDataIfVersion: invalid version %d", version)
+	}
+	return esi.indexDocument(typ, id, obj, false, version)
+}
+
+func (esi *Index) indexDocument(typ string, id string, obj interface{}, create bool, version int) (*IndexResponse, error) {
+	ctx, cancel := esi.writeContext()
+	defer cancel()
+
+	// Elasticsearch would create the type itself
+	if err := esi.requireType(typ); err != nil {
 		return nil, err
 	}
 
-	indexResponse, err := esi.lib.Index().
+	f := esi.lib.Index().
 		Index(esi.index).
 		Type(typ).
 		Id(id).
-		BodyJson(obj).
-		Do(context.Background())
+		BodyJson(obj)
+	if create {
+		f = f.OpType("create")
+	}
+	if version != 0 {
+		f = f.Version(version)
+	}
 
+	indexResponse, err := f.Do(ctx)
 	if err != nil {
-		return nil, err
+		return nil, esi.typedError(err, nil)
 	}
 	return NewIndexResponse(indexResponse), nil
 }
 
-//TODO
-func (esi *Index) PutData(typ string, id string, obj interface{}) (*IndexResponse, error) {
-	return esi.PostData(typ, id, obj)
+// UpdateData applies a partial update to a document.
+func (esi *Index) UpdateData(typ string, id string, update *Update) (*IndexResponse, error) {
+	err := update.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := esi.writeContext()
+	defer cancel()
+
+	// Elasticsearch would create the type itself when upserting
+	if err = esi.requireType(typ); err != nil {
+		return nil, err
+	}
+
+	f := esi.lib.Update().
+		Index(esi.index).
+		Type(typ).
+		Id(id)
+	if update.Doc != nil {
+		f = f.Doc(update.Doc)
+	}
+	if update.Script != nil {
+		f = f.Script(update.Script)
+	}
+	if update.Upsert != nil {
+		f = f.Upsert(update.Upsert)
+	}
+	if update.DocAsUpsert {
+		f = f.DocAsUpsert(true)
+	}
+	if update.Version != 0 {
+		f = f.Version(int64(update.Version))
+	}
+	if update.RetryOnConflict != 0 {
+		f = f.RetryOnConflict(update.RetryOnConflict)
+	}
+
+	updateResponse, err := f.Do(ctx)
+	if err != nil {
+		return nil, esi.typedError(err, documentNotFoundError(esi.index, typ, id))
+	}
+	return NewUpdateResponse(updateResponse), nil
 }
 
-// GetByID returns a document by ID within the specified index and type.
+// GetByID returns a document by ID within the specified index and type. If
+// there is no such document, the error's cause is ErrDocumentNotFound.
 func (esi *Index) GetByID(typ string, id string) (*GetResult, error) {
-	// TODO: the caller should enforce this instead (here and elsewhere)
-	ok, err := esi.ItemExists(typ, id)
+	ctx, cancel := esi.readContext()
+	defer cancel()
+
+	getResult, err := esi.lib.Get().Index(esi.index).Type(typ).Id(id).Do(ctx)
 	if err != nil {
-		return nil, err
+		return &GetResult{Found: false}, esi.typedError(err, documentNotFoundError(esi.index, typ, id))
 	}
-	if !ok {
-		return &GetResult{Found: false}, fmt.Errorf("Item %s in index %s and type %s does not exist", id, esi.index, typ)
+	return NewGetResult(getResult), nil
+}
+
+// GetByIDs gets the documents in a single request, without first checking
+// that they exist. The results are in the order of ids; a document that
+// doesn't exist isn't Found, and one that couldn't be looked up has Error
+// set.
+func (esi *Index) GetByIDs(typ string, ids []string) ([]*GetResult, error) {
+	ctx, cancel := esi.readContext()
+	defer cancel()
+
+	results := make([]*GetResult, len(ids))
+	if len(ids) == 0 {
+		return results, nil
+	}
+
+	svc := esi.lib.Mget().Index(esi.index).Type(typ)
+	for _, id := range ids {
+		svc = svc.Add(elastic.NewMultiGetItem().Id(id))
+	}
+	resp, err := svc.Do(ctx)
+	if err != nil {
+		return nil, esi.typedError(err, nil)
+	}
+	if len(resp.Docs) != len(ids) {
+		return nil, fmt.Errorf("GetByIDs: asked for %d documents, got %d", len(ids), len(resp.Docs))
 	}
 
-	getResult, err := esi.lib.Get().Index(esi.index).Type(typ).Id(id).Do(context.Background())
-	return NewGetResult(getResult), err
+	for i, doc := range resp.Docs {
+		results[i] = NewGetResult(doc)
+		results[i].ID = ids[i]
+	}
+	return results, nil
 }
 
-// DeleteByID deletes a document by ID within a specified index and type.
+// DeleteByID deletes a document by ID within a specified index and type. If
+// there is no such document, the error's cause is ErrDocumentNotFound.
 func (esi *Index) DeleteByID(typ string, id string) (*DeleteResponse, error) {
-	ok, err := esi.ItemExists(typ, id)
+	return esi.deleteByID(typ, id, "")
+}
+
+// DeleteByIDWait deletes a document by ID within a specified index and type
+// and waits for the deletion to be visible to searches before returning.
+func (esi *Index) DeleteByIDWait(typ string, id string) (*DeleteResponse, error) {
+	return esi.deleteByID(typ, id, "wait_for")
+}
+
+func (esi *Index) deleteByID(typ string, id string, refresh string) (*DeleteResponse, error) {
+	ctx, cancel := esi.writeContext()
+	defer cancel()
+
+	f := esi.lib.Delete().
+		Index(esi.index).
+		Type(typ).
+		Id(id)
+	if refresh != "" {
+		f = f.Refresh(refresh)
+	}
+
+	deleteResponse, err := f.Do(ctx)
 	if err != nil {
+		return &DeleteResponse{Found: false}, esi.typedError(err, documentNotFoundError(esi.index, typ, id))
+	}
+	return NewDeleteResponse(deleteResponse), nil
+}
+
+// Refresh makes every change to the index visible to searches. Elasticsearch
+// does so on its own once a second, or as the index's refresh interval says.
+func (esi *Index) Refresh() error {
+	ctx, cancel := esi.writeContext()
+	defer cancel()
+
+	_, err := esi.lib.Refresh(esi.index).Do(ctx)
+	return esi.typedError(err, nil)
+}
+
+// BulkPostData sends a batch of documents to the index in a single request.
+// Per-document failures are reported in the response, not as an error.
+func (esi *Index) BulkPostData(typ string, items []*BulkItem) (*BulkResponse, error) {
+	ctx, cancel := esi.writeContext()
+	defer cancel()
+
+	if len(items) == 0 {
+		return &BulkResponse{Items: []*BulkResponseItem{}}, nil
+	}
+	if err := esi.requireType(typ); err != nil {
 		return nil, err
 	}
-	if !ok {
-		return &DeleteResponse{Found: false}, fmt.Errorf("Item %s in index %s and type %s does not exist", id, esi.index, typ)
+
+	bulk := esi.lib.Bulk().Index(esi.index).Type(typ)
+	for _, item := range items {
+		req := elastic.NewBulkIndexRequest().Id(item.ID).Doc(item.Obj)
+		if item.Create {
+			req = req.OpType("create")
+		}
+		bulk.Add(req)
 	}
 
-	deleteResponse, err := esi.lib.Delete().
-		Index(esi.index).
-		Type(typ).
-		Id(id).
-		Do(context.Background())
-	return NewDeleteResponse(deleteResponse), err
+	bulkResponse, err := bulk.Do(ctx)
+	if err != nil {
+		return nil, esi.typedError(err, nil)
+	}
+	return NewBulkResponse(bulkResponse), nil
 }
 
-// DeleteByID deletes a document by ID within a specified index and type and waits before returning.
-func (esi *Index) DeleteByIDWait(typ string, id string) (*DeleteResponse, error) {
-	ok, err := esi.ItemExists(typ, id)
+// BulkDeleteByID deletes a batch of documents from the index in a single request.
+// Per-document failures are reported in the response, not as an error.
+func (esi *Index) BulkDeleteByID(typ string, ids []string) (*BulkResponse, error) {
+	ctx, cancel := esi.writeContext()
+	defer cancel()
+
+	if len(ids) == 0 {
+		return &BulkResponse{Items: []*BulkResponseItem{}}, nil
+	}
+
+	bulk := esi.lib.Bulk().Index(esi.index).Type(typ)
+	for _, id := range ids {
+		bulk.Add(elastic.NewBulkDeleteRequest().Id(id))
+	}
+
+	bulkResponse, err := bulk.Do(ctx)
 	if err != nil {
-		return nil, err
+		return nil, esi.typedError(err, nil)
 	}
-	if !ok {
-		return &DeleteResponse{Found: false}, fmt.Errorf("Item %s in index %s and type %s does not exist", id, esi.index, typ)
+	return NewBulkResponse(bulkResponse), nil
+}
+
+// NewBulkIndexer returns an IBulkIndexer which batches operations against
+// the index according to the given flush policy. A nil settings uses the defaults.
+func (esi *Index) NewBulkIndexer(settings *BulkSettings) (IBulkIndexer, error) {
+	return newBulkIndexer(indexBulkCommit(esi), settings), nil
+}
+
+// DeleteByQuery starts deleting the documents of the type, or of every type
+// if typ is "", that match the query, or every document if query is nil.
+// Documents changed while the task runs are counted as version conflicts
+// and left alone.
+func (esi *Index) DeleteByQuery(typ string, query elastic.Query) (*ByQueryTask, error) {
+	ctx, cancel := esi.writeContext()
+	defer cancel()
+
+	svc := esi.lib.DeleteByQuery(esi.index).Query(searchQuery(typ, query)).ProceedOnVersionConflict()
+	if typ != "" {
+		svc = svc.Type(typ)
 	}
+	resp, err := svc.DoAsync(ctx)
+	if err != nil {
+		return nil, esi.typedError(err, nil)
+	}
+	return esi.byQueryTask(resp.TaskId), nil
+}
 
-	deleteResponse, err := esi.lib.Delete().
-		Index(esi.index).
-		Type(typ).
-		Id(id).
-		Refresh("wait_for").
-		Do(context.Background())
-	return NewDeleteResponse(deleteResponse), err
+// UpdateByQuery starts running the script on each document of the type, or
+// of every type if typ is "", that matches the query, or on every document
+// if query is nil. Without a script, the documents are reindexed in place,
+// which picks up fields added to the mapping since they were written.
+func (esi *Index) UpdateByQuery(typ string, query elastic.Query, script *elastic.Script) (*ByQueryTask, error) {
+	ctx, cancel := esi.writeContext()
+	defer cancel()
+
+	svc := esi.lib.UpdateByQuery(esi.index).Query(searchQuery(typ, query)).Script(script).ProceedOnVersionConflict()
+	if typ != "" {
+		svc = svc.Type(typ)
+	}
+	resp, err := svc.DoAsync(ctx)
+	if err != nil {
+		return nil, esi.typedError(err, nil)
+	}
+	return esi.byQueryTask(resp.TaskId), nil
+}
+
+func (esi *Index) byQueryTask(id string) *ByQueryTask {
+	status := func() (*ByQueryStatus, error) {
+		ctx, cancel := esi.readContext()
+		defer cancel()
+
+		resp, err := esi.lib.TasksGetTask().TaskId(id).Do(ctx)
+		if err != nil {
+			return nil, esi.typedError(err, nil)
+		}
+		if resp.Error != nil {
+			return nil, fmt.Errorf("task %s failed: %s", id, resp.Error.Reason)
+		}
+
+		raw := resp.Response
+		if !resp.Completed && resp.Task != nil {
+			raw = resp.Task.Status
+		}
+		result := &elastic.BulkIndexByScrollResponse{}
+		if raw != nil {
+			if err = json.Unmarshal(*raw, result); err != nil {
+				return nil, err
+			}
+		}
+		return newByQueryStatus(result, resp.Completed), nil
+	}
+	return &ByQueryTask{ID: id, ctx: esi.parentContext(), status: status}
+}
+
+// searchQuery returns the query to run over the type or, if typ is "", over
+// every type but that of the percolation queries, which are stored as
+// documents but aren't searched as such; MockIndex leaves them out the same
+// way. A nil query matches every document.
+func searchQuery(typ string, query elastic.Query) elastic.Query {
+	if query == nil {
+		query = elastic.NewMatchAllQuery()
+	}
+	if typ != "" {
+		return query
+	}
+	return elastic.NewBoolQuery().Must(query).MustNot(elastic.NewTypeQuery(percolateTypeName))
+}
+
+// rawQuery is a query given as decoded JSON.
+type rawQuery struct {
+	source interface{}
+}
+
+func (q rawQuery) Source() (interface{}, error) {
+	return q.source, nil
 }
 
 // FilterByMatchAll returns all documents of a specified type, in the format
 // specified by the realFormat parameter.
 func (esi *Index) FilterByMatchAll(typ string, realFormat *piazza.JsonPagination) (*SearchResult, error) {
-	// ok := typ != "" && esi.TypeExists(typ)
-	// if !ok {
-	// 	return nil, fmt.Errorf("Type %s in index %s does not exist", typ, esi.index)
-	// }
+	ctx, cancel := esi.readContext()
+	defer cancel()
 
-	q := elastic.NewMatchAllQuery()
-	f := esi.lib.Search().Index(esi.index).Type(typ).Query(q)
+	f := esi.lib.Search().Index(esi.index).Query(searchQuery(typ, nil)).Version(true)
+	if typ != "" {
+		f = f.Type(typ)
+	}
 
 	if realFormat != nil {
 		format := NewQueryFormat(realFormat)
@@ -340,59 +698,92 @@ func (esi *Index) FilterByMatchAll(typ string, realFormat *piazza.JsonPagination
 		f = f.Sort(format.Key, format.Order)
 	}
 
-	searchResult, err := f.Do(context.Background())
+	searchResult, err := f.Do(ctx)
 	if err != nil {
-		// if the mapping (or the index?) doesn't exist yet, squash the error
-		// (this is the case in some of the unit tests which ((try to)) assure the DB is empty)
-		resp := &SearchResult{totalHits: 0, hits: make([]*SearchResultHit, 0)}
-		return resp, nil
+		return nil, esi.typedError(err, nil)
 	}
 
 	resp := NewSearchResult(searchResult)
 	return resp, nil
 }
 
-// GetAllElements returns all documents of a specified type.
+// GetAllElements returns all documents of a specified type, however many there are.
 func (esi *Index) GetAllElements(typ string) (*SearchResult, error) {
 	if typ == "" {
 		return nil, fmt.Errorf("elasticsearch.Index.GetAllElements: empty type")
 	}
+	if err := esi.requireType(typ); err != nil {
+		return nil, err
+	}
 
-	ok, err := esi.TypeExists(typ)
+	it, err := esi.Scan(typ, nil)
 	if err != nil {
 		return nil, err
 	}
-	if !ok {
-		return nil, fmt.Errorf("elasticsearch.Index.GetAllElements: type %s in index %s does not exist", typ, esi.index)
-	}
-
-	q := elastic.NewMatchAllQuery()
-	result, err := esi.lib.Search().
-		Index(esi.index).
-		Type(typ).
-		Query(q).
-		Do(context.Background())
+	hits, err := it.All()
 	if err != nil {
 		return nil, err
 	}
 
-	resp := NewSearchResult(result)
+	resp := &SearchResult{
+		totalHits: int64(len(hits)),
+		hits:      hits,
+		Found:     true,
+	}
 	return resp, nil
 }
 
+// Scan returns an iterator over every document of the specified type (or of
+// all types, if typ is empty) matching the query. A nil query matches all
+// documents. Documents are read in pages using search_after, ordered by _uid.
+func (esi *Index) Scan(typ string, query elastic.Query) (*ScanIterator, error) {
+	query = searchQuery(typ, query)
+
+	fetch := func(after []interface{}, size int) ([]*SearchResultHit, []interface{}, error) {
+		ctx, cancel := esi.readContext()
+		defer cancel()
+
+		f := esi.lib.Search().
+			Index(esi.index).
+			Query(query).
+			Size(size).
+			Sort("_uid", true).
+			Version(true)
+		if typ != "" {
+			f = f.Type(typ)
+		}
+		if after != nil {
+			f = f.SearchAfter(after...)
+		}
+
+		searchResult, err := f.Do(ctx)
+		if err != nil {
+			return nil, nil, esi.typedError(err, nil)
+		}
+
+		resp := NewSearchResult(searchResult)
+		var next []interface{}
+		if n := len(searchResult.Hits.Hits); n > 0 {
+			next = searchResult.Hits.Hits[n-1].Sort
+		}
+		return resp.hits, next, nil
+	}
+
+	return newScanIterator(fetch), nil
+}
+
 // FilterByTermQuery creates an Elasticsearch term query and performs the query over the specified type.
 // For more information on term queries, see
 // https://www.elastic.co/guide/en/elasticsearch/reference/current/query-dsl-term-query.html
 func (esi *Index) FilterByTermQuery(typ string, name string, value interface{}, realFormat *piazza.JsonPagination) (*SearchResult, error) {
+	ctx, cancel := esi.readContext()
+	defer cancel()
+
 	if typ == "" {
 		return nil, fmt.Errorf("Can't filter on type \"\"")
 	}
-	ok, err := esi.TypeExists(typ)
-	if err != nil {
-		return nil, err
-	}
-	if !ok {
-		return &SearchResult{Found: false}, fmt.Errorf("Type %s in index %s does not exist", typ, esi.index)
+	if err := esi.requireType(typ); err != nil {
+		return &SearchResult{Found: false}, err
 	}
 
 	// Returns a query of the form {"term":{"name":"value"}}
@@ -402,7 +793,8 @@ func (esi *Index) FilterByTermQuery(typ string, name string, value interface{},
 	f := esi.lib.Search().
 		Index(esi.index).
 		Type(typ).
-		Query(termQuery)
+		Query(termQuery).
+		Version(true)
 
 	if realFormat != nil {
 		format := NewQueryFormat(realFormat)
@@ -411,31 +803,87 @@ func (esi *Index) FilterByTermQuery(typ string, name string, value interface{},
 		f = f.Sort(format.Key, format.Order)
 	}
 
-	searchResult, err := f.Do(context.Background())
+	searchResult, err := f.Do(ctx)
+	if err != nil {
+		return nil, esi.typedError(err, nil)
+	}
 
-	return NewSearchResult(searchResult), err
+	return NewSearchResult(searchResult), nil
 }
 
 // FilterByMatchQuery creates an Elasticsearch match query and performs the query over the specified type.
 // For more information on match queries, see
 // https://www.elastic.co/guide/en/elasticsearch/reference/current/query-dsl-match-query.html
 func (esi *Index) FilterByMatchQuery(typ string, name string, value interface{}, realFormat *piazza.JsonPagination) (*SearchResult, error) {
+	ctx, cancel := esi.readContext()
+	defer cancel()
+
 	if typ == "" {
 		return nil, fmt.Errorf("Can't filter on type \"\"")
 	}
-	ok, err := esi.TypeExists(typ)
-	if err != nil {
+	if err := esi.requireType(typ); err != nil {
 		return nil, err
 	}
-	if !ok {
-		return nil, fmt.Errorf("Type %s in index %s does not exist", typ, esi.index)
-	}
 
 	matchQuery := elastic.NewMatchQuery(name, value)
 	f := esi.lib.Search().
 		Index(esi.index).
 		Type(typ).
-		Query(matchQuery)
+		Query(matchQuery).
+		Version(true)
+
+	if realFormat != nil {
+		format := NewQueryFormat(realFormat)
+		f = f.From(format.From)
+		f = f.Size(format.Size)
+		f = f.Sort(format.Key, format.Order)
+	}
+
+	searchResult, err := f.Do(ctx)
+	if err != nil {
+		return nil, esi.typedError(err, nil)
+	}
+
+	return NewSearchResult(searchResult), nil
+}
+
+// FilterByQuery performs the query over the specified type (or over all types,
+// if typ is empty). Queries are built with the elastic package, e.g.
+// elastic.NewBoolQuery().Must(...).
+func (esi *Index) FilterByQuery(typ string, query elastic.Query, realFormat *piazza.JsonPagination) (*SearchResult, error) {
+	if query == nil {
+		return nil, fmt.Errorf("FilterByQuery: query may not be nil")
+	}
+	return esi.filterByQuery(typ, query, nil, realFormat)
+}
+
+// SearchWithHighlight is FilterByQuery with the fragments of the matching
+// text marked in the hits' Highlight, e.g. for
+// elastic.NewHighlight().Field("description").
+func (esi *Index) SearchWithHighlight(typ string, query elastic.Query, highlight *elastic.Highlight, realFormat *piazza.JsonPagination) (*SearchResult, error) {
+	if query == nil {
+		return nil, fmt.Errorf("SearchWithHighlight: query may not be nil")
+	}
+	if highlight == nil {
+		return nil, fmt.Errorf("SearchWithHighlight: highlight may not be nil")
+	}
+	return esi.filterByQuery(typ, query, highlight, realFormat)
+}
+
+func (esi *Index) filterByQuery(typ string, query elastic.Query, highlight *elastic.Highlight, realFormat *piazza.JsonPagination) (*SearchResult, error) {
+	ctx, cancel := esi.readContext()
+	defer cancel()
+
+	f := esi.lib.Search().
+		Index(esi.index).
+		Query(searchQuery(typ, query)).
+		Version(true)
+	if typ != "" {
+		f = f.Type(typ)
+	}
+	if highlight != nil {
+		f = f.Highlight(highlight)
+	}
 
 	if realFormat != nil {
 		format := NewQueryFormat(realFormat)
@@ -444,45 +892,168 @@ func (esi *Index) FilterByMatchQuery(typ string, name string, value interface{},
 		f = f.Sort(format.Key, format.Order)
 	}
 
-	searchResult, err := f.Do(context.Background())
+	searchResult, err := f.Do(ctx)
+	if err != nil {
+		return nil, esi.typedError(err, nil)
+	}
+
+	return NewSearchResult(searchResult), nil
+}
+
+// Suggest runs the suggester over the specified type (or over all types, if
+// typ is empty). Suggesters are built with the elastic package, e.g.
+// elastic.NewCompletionSuggester(name).
+func (esi *Index) Suggest(typ string, suggester elastic.Suggester) ([]*Suggestion, error) {
+	ctx, cancel := esi.readContext()
+	defer cancel()
+
+	if suggester == nil {
+		return nil, fmt.Errorf("Suggest: suggester may not be nil")
+	}
+
+	f := esi.lib.Search().
+		Index(esi.index).
+		Size(0).
+		Suggester(suggester)
+	if typ != "" {
+		f = f.Type(typ)
+	}
+
+	searchResult, err := f.Do(ctx)
+	if err != nil {
+		return nil, esi.typedError(err, nil)
+	}
+
+	return newSuggestions(searchResult.Suggest[suggester.Name()]), nil
+}
 
-	return NewSearchResult(searchResult), err
+// FilterByBoundingBox returns the documents whose geo_point field lies within
+// the box. For more information on bounding box queries, see
+// https://www.elastic.co/guide/en/elasticsearch/reference/current/query-dsl-geo-bounding-box-query.html
+func (esi *Index) FilterByBoundingBox(typ string, field string, topLeft *elastic.GeoPoint, bottomRight *elastic.GeoPoint, realFormat *piazza.JsonPagination) (*SearchResult, error) {
+	q := elastic.NewGeoBoundingBoxQuery(field).
+		TopLeftFromGeoPoint(topLeft).
+		BottomRightFromGeoPoint(bottomRight)
+	return esi.FilterByQuery(typ, q, realFormat)
+}
+
+// FilterByDistance returns the documents whose geo_point field lies within the
+// distance (e.g. "12km") of the center. For more information on distance queries, see
+// https://www.elastic.co/guide/en/elasticsearch/reference/current/query-dsl-geo-distance-query.html
+func (esi *Index) FilterByDistance(typ string, field string, center *elastic.GeoPoint, distance string, realFormat *piazza.JsonPagination) (*SearchResult, error) {
+	q := elastic.NewGeoDistanceQuery(field).
+		GeoPoint(center).
+		Distance(distance)
+	return esi.FilterByQuery(typ, q, realFormat)
+}
+
+// Aggregate computes the named aggregations over the documents of the
+// specified type (or of all types, if typ is empty) that match the query;
+// a nil query matches all documents. No hits are returned, only the total
+// and the aggregation results.
+func (esi *Index) Aggregate(typ string, query elastic.Query, aggs map[string]elastic.Aggregation) (*SearchResult, error) {
+	ctx, cancel := esi.readContext()
+	defer cancel()
+
+	if len(aggs) == 0 {
+		return nil, fmt.Errorf("Aggregate: no aggregations given")
+	}
+
+	f := esi.lib.Search().
+		Index(esi.index).
+		Query(searchQuery(typ, query)).
+		Size(0)
+	if typ != "" {
+		f = f.Type(typ)
+	}
+	for name, agg := range aggs {
+		f = f.Aggregation(name, agg)
+	}
+
+	searchResult, err := f.Do(ctx)
+	if err != nil {
+		return nil, esi.typedError(err, nil)
+	}
+
+	return NewSearchResult(searchResult), nil
 }
 
 // SearchByJSON performs a search over the index via raw JSON.
 func (esi *Index) SearchByJSON(typ string, jsn string) (*SearchResult, error) {
+	ctx, cancel := esi.readContext()
+	defer cancel()
+
 	var obj interface{}
 	err := json.Unmarshal([]byte(jsn), &obj)
 	if err != nil {
 		return nil, err
 	}
+	if body, ok := obj.(map[string]interface{}); ok && typ == "" {
+		var query elastic.Query
+		if q, ok := body["query"]; ok {
+			query = rawQuery{q}
+		}
+		if body["query"], err = searchQuery(typ, query).Source(); err != nil {
+			return nil, err
+		}
+	}
 
-	searchResult, err := esi.lib.Search().
+	f := esi.lib.Search().
 		Index(esi.index).
-		Type(typ).
-		Source(obj).
-		Do(context.Background())
+		Source(obj)
+	if typ != "" {
+		f = f.Type(typ)
+	}
+
+	searchResult, err := f.Do(ctx)
 	if err != nil {
-		return nil, err
+		return nil, esi.typedError(err, nil)
 	}
 
 	return NewSearchResult(searchResult), nil
 }
 
-// SetMapping sets the _mapping field for a new type.
-func (esi *Index) SetMapping(typename string, jsn piazza.JsonString) error {
+// MultiSearch runs the searches in a single request. The results are in the
+// order of the requests; a search that fails doesn't fail the others.
+func (esi *Index) MultiSearch(requests []*SearchRequest) ([]*MultiSearchItem, error) {
+	ctx, cancel := esi.readContext()
+	defer cancel()
 
-	ok, err := esi.IndexExists()
+	items := make([]*MultiSearchItem, len(requests))
+	if len(requests) == 0 {
+		return items, nil
+	}
+
+	svc := esi.lib.MultiSearch().Index(esi.index)
+	for _, req := range requests {
+		svc = svc.Add(req.request())
+	}
+	resp, err := svc.Do(ctx)
 	if err != nil {
-		return err
+		return nil, esi.typedError(err, nil)
 	}
-	if !ok {
-		return fmt.Errorf("Index %s does not exist", esi.index)
+	if len(resp.Responses) != len(requests) {
+		return nil, fmt.Errorf("MultiSearch: ran %d searches, got %d results", len(requests), len(resp.Responses))
 	}
 
-	putresp, err := esi.lib.PutMapping().Index(esi.index).Type(typename).BodyString(string(jsn)).Do(context.Background())
+	for i, searchResult := range resp.Responses {
+		if searchResult.Error != nil {
+			items[i] = &MultiSearchItem{Error: searchResult.Error.Reason}
+			continue
+		}
+		items[i] = &MultiSearchItem{Result: NewSearchResult(searchResult)}
+	}
+	return items, nil
+}
+
+// SetMapping sets the _mapping field for a new type.
+func (esi *Index) SetMapping(typename string, jsn piazza.JsonString) error {
+	ctx, cancel := esi.adminContext()
+	defer cancel()
+
+	putresp, err := esi.lib.PutMapping().Index(esi.index).Type(typename).BodyString(string(jsn)).Do(ctx)
 	if err != nil {
-		return err
+		return esi.mappingError(err)
 	}
 	if putresp == nil {
 		return fmt.Errorf("expected put mapping response; got: %v", putresp)
@@ -491,22 +1062,18 @@ func (esi *Index) SetMapping(typename string, jsn piazza.JsonString) error {
 		return fmt.Errorf("expected put mapping ack; got: %v", putresp.Acknowledged)
 	}
 
+	esi.types.add(typename)
 	return nil
 }
 
 // GetTypes returns the list of types within the index.
 func (esi *Index) GetTypes() ([]string, error) {
-	ok, err := esi.IndexExists()
-	if err != nil {
-		return nil, err
-	}
-	if !ok {
-		return nil, fmt.Errorf("Index %s does not exist", esi.index)
-	}
+	ctx, cancel := esi.readContext()
+	defer cancel()
 
-	getresp, err := esi.lib.IndexGet().Feature("_mappings").Index(esi.index).Do(context.Background())
+	getresp, err := esi.lib.IndexGet().Feature("_mappings").Index(esi.index).Do(ctx)
 	if err != nil {
-		return nil, err
+		return nil, esi.typedError(err, nil)
 	}
 
 	result := []string{}
@@ -519,33 +1086,279 @@ func (esi *Index) GetTypes() ([]string, error) {
 	return result, nil
 }
 
-// GetMapping returns the _mapping of a type.
+// GetMapping returns the _mapping of a type. If there is no such type, the
+// error's cause is ErrTypeNotFound.
 func (esi *Index) GetMapping(typ string) (interface{}, error) {
+	ctx, cancel := esi.readContext()
+	defer cancel()
 
-	ok, err := esi.TypeExists(typ)
+	getresp, err := esi.lib.GetMapping().Index(esi.index).Type(typ).Do(ctx)
+	if err != nil {
+		return nil, esi.typedError(err, typeNotFoundError(esi.index, typ))
+	}
+	if getresp == nil {
+		return nil, fmt.Errorf("expected get mapping response; got: %v", getresp)
+	}
+
+	for _, props := range getresp {
+		return props.(map[string]interface{})["mappings"], nil
+	}
+
+	return nil, typeNotFoundError(esi.index, typ)
+}
+
+// AddPercolationQuery registers a query, given as {"query": {...}}, under the
+// specified id. The fields the query refers to must already be mapped in the index.
+func (esi *Index) AddPercolationQuery(id string, query piazza.JsonString) (*IndexResponse, error) {
+	ctx, cancel := esi.writeContext()
+	defer cancel()
+
+	err := esi.requireType(percolateTypeName)
+	if IsTypeNotFound(err) {
+		mapping := fmt.Sprintf(`{"%s":{"properties":{"%s":{"type":"percolator"}}}}`,
+			percolateTypeName, percolateFieldName)
+		err = esi.SetMapping(percolateTypeName, piazza.JsonString(mapping))
+	}
 	if err != nil {
 		return nil, err
 	}
-	if !ok {
-		return nil, fmt.Errorf("Type %s in index %s does not exist", typ, esi.index)
+
+	indexResponse, err := esi.lib.Index().
+		Index(esi.index).
+		Type(percolateTypeName).
+		Id(id).
+		BodyString(string(query)).
+		Refresh("wait_for").
+		Do(ctx)
+	if err != nil {
+		return nil, esi.typedError(err, nil)
 	}
+	return NewIndexResponse(indexResponse), nil
+}
+
+// DeletePercolationQuery removes a registered query.
+func (esi *Index) DeletePercolationQuery(id string) (*DeleteResponse, error) {
+	return esi.DeleteByIDWait(percolateTypeName, id)
+}
 
-	getresp, err := esi.lib.GetMapping().Index(esi.index).Type(typ).Do(context.Background())
+// AddPercolationDocument matches a document of the specified type against the
+// registered queries. The document itself is not stored.
+func (esi *Index) AddPercolationDocument(typ string, doc interface{}) (*PercolateResponse, error) {
+	err := esi.requireType(percolateTypeName)
+	if IsTypeNotFound(err) {
+		return &PercolateResponse{Matches: []*PercolateResponseMatch{}}, nil
+	}
 	if err != nil {
-		return nil, fmt.Errorf("expected get mapping to succeed; got: %v", err)
+		return nil, err
 	}
-	if getresp == nil {
-		return nil, fmt.Errorf("expected get mapping response; got: %v", getresp)
+
+	query := elastic.NewPercolatorQuery().
+		Field(percolateFieldName).
+		DocumentType(typ).
+		Document(doc)
+
+	it, err := esi.Scan(percolateTypeName, query)
+	if err != nil {
+		return nil, err
+	}
+	hits, err := it.All()
+	if err != nil {
+		return nil, err
 	}
 
-	for _, props := range getresp {
-		return props.(map[string]interface{})["mappings"], nil
+	resp := &PercolateResponse{
+		Total:   int64(len(hits)),
+		Matches: make([]*PercolateResponseMatch, len(hits)),
+	}
+	for i, hit := range hits {
+		resp.Matches[i] = &PercolateResponseMatch{Id: hit.ID, Index: esi.index}
+	}
+	return resp, nil
+}
+
+// AddAlias makes alias point to the index, as well as to any indices it
+// already points to.
+func (esi *Index) AddAlias(alias string) error {
+	ctx, cancel := esi.adminContext()
+	defer cancel()
+
+	resp, err := esi.lib.Alias().Add(esi.index, alias).Do(ctx)
+	if err != nil {
+		return esi.typedError(err, nil)
+	}
+	if !resp.Acknowledged {
+		return fmt.Errorf("elasticsearch.Index.AddAlias: add alias not acknowledged")
+	}
+	return nil
+}
+
+// RemoveAlias stops alias from pointing to the index.
+func (esi *Index) RemoveAlias(alias string) error {
+	ctx, cancel := esi.adminContext()
+	defer cancel()
+
+	resp, err := esi.lib.Alias().Remove(esi.index, alias).Do(ctx)
+	if err != nil {
+		return esi.typedError(err, nil)
+	}
+	if !resp.Acknowledged {
+		return fmt.Errorf("elasticsearch.Index.RemoveAlias: remove alias not acknowledged")
+	}
+	return nil
+}
+
+// SwapAlias points alias at the index alone, removing it from any other
+// index in the same atomic operation, so that readers of the alias never
+// see neither or both.
+func (esi *Index) SwapAlias(alias string) error {
+	holders, err := esi.AliasIndices(alias)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := esi.adminContext()
+	defer cancel()
+
+	svc := esi.lib.Alias().Add(esi.index, alias)
+	for _, index := range holders {
+		if index != esi.index {
+			svc = svc.Remove(index, alias)
+		}
+	}
+	resp, err := svc.Do(ctx)
+	if err != nil {
+		return esi.typedError(err, nil)
+	}
+	if !resp.Acknowledged {
+		return fmt.Errorf("elasticsearch.Index.SwapAlias: alias actions not acknowledged")
+	}
+	return nil
+}
+
+// GetAliases returns the aliases pointing to the index, sorted.
+func (esi *Index) GetAliases() ([]string, error) {
+	ctx, cancel := esi.readContext()
+	defer cancel()
+
+	resp, err := esi.lib.Aliases().Index(esi.index).Do(ctx)
+	if err != nil {
+		return nil, esi.typedError(err, nil)
+	}
+
+	aliases := []string{}
+	for _, index := range resp.Indices {
+		for _, alias := range index.Aliases {
+			aliases = append(aliases, alias.AliasName)
+		}
+	}
+	sort.Strings(aliases)
+	return aliases, nil
+}
+
+// AliasIndices returns the indices alias points to, sorted; none if the
+// alias doesn't exist. It need not point to this index.
+func (esi *Index) AliasIndices(alias string) ([]string, error) {
+	ctx, cancel := esi.readContext()
+	defer cancel()
+
+	resp, err := esi.lib.Aliases().Do(ctx)
+	if err != nil {
+		return nil, esi.typedError(err, nil)
+	}
+
+	indices := resp.IndicesByAlias(alias)
+	if indices == nil {
+		indices = []string{}
+	}
+	sort.Strings(indices)
+	return indices, nil
+}
+
+// GetSettings returns the settings of the index. Elasticsearch reports the
+// number of shards and replicas even when they were left at the defaults.
+func (esi *Index) GetSettings() (*IndexSettings, error) {
+	ctx, cancel := esi.readContext()
+	defer cancel()
+
+	resp, err := esi.lib.IndexGetSettings(esi.index).Do(ctx)
+	if err != nil {
+		return nil, esi.typedError(err, nil)
+	}
+	if len(resp) != 1 {
+		return nil, fmt.Errorf("elasticsearch.Index.GetSettings: %s names %d indices", esi.index, len(resp))
+	}
+	for _, settings := range resp {
+		return ParseIndexSettings(settings.Settings)
 	}
+	return nil, nil
+}
+
+// UpdateSettings changes the settings of the index. The number of shards is
+// fixed, and analysis settings can only be changed while the index is
+// closed.
+func (esi *Index) UpdateSettings(settings *IndexSettings) error {
+	ctx, cancel := esi.adminContext()
+	defer cancel()
 
-	return nil, fmt.Errorf("type not found after loop; got: %v", getresp)
+	body, err := settings.updateSource()
+	if err != nil {
+		return err
+	}
+	resp, err := esi.lib.IndexPutSettings(esi.index).BodyJson(body).Do(ctx)
+	if err != nil {
+		return esi.typedError(err, nil)
+	}
+	if !resp.Acknowledged {
+		return fmt.Errorf("elasticsearch.Index.UpdateSettings: update settings not acknowledged")
+	}
+	return nil
 }
 
+// PutTemplate creates or replaces the named index template. Templates
+// belong to the cluster, not to the index; they apply to indices created
+// afterwards.
+func (esi *Index) PutTemplate(name string, template *IndexTemplate) error {
+	ctx, cancel := esi.adminContext()
+	defer cancel()
+
+	body, err := template.Source()
+	if err != nil {
+		return err
+	}
+	resp, err := esi.lib.IndexPutTemplate(name).BodyJson(body).Do(ctx)
+	if err != nil {
+		return esi.mappingError(err)
+	}
+	if !resp.Acknowledged {
+		return fmt.Errorf("elasticsearch.Index.PutTemplate: put template not acknowledged")
+	}
+	return nil
+}
+
+// DeleteTemplate removes the named index template.
+func (esi *Index) DeleteTemplate(name string) error {
+	ctx, cancel := esi.adminContext()
+	defer cancel()
+
+	resp, err := esi.lib.IndexDeleteTemplate(name).Do(ctx)
+	if err != nil {
+		return esi.typedError(err, nil)
+	}
+	if !resp.Acknowledged {
+		return fmt.Errorf("elasticsearch.Index.DeleteTemplate: delete template not acknowledged")
+	}
+	return nil
+}
+
+// DirectAccess bypasses the client; the context is checked before the
+// request is made but can't interrupt it.
 func (esi *Index) DirectAccess(verb string, endpoint string, input interface{}, output interface{}) error {
+	ctx, cancel := esi.readContext()
+	defer cancel()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	h := &piazza.Http{
 		BaseUrl: esi.url,
 		User:    esi.user,
@@ -0,0 +1,127 @@
+// Copyright 2016, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elasticsearch
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/venicegeo/pz-gocommon/elasticsearch/elastic-5-api"
+)
+
+// SlowLogMaxBody is the most of a slow request's body that is logged.
+const SlowLogMaxBody = 4096
+
+// Logger receives the log messages of an Index; *syslog.Logger is one. It
+// must not itself write, through an Index logging to it, to Elasticsearch,
+// or each message would beget another.
+type Logger interface {
+	Error(text string, v ...interface{}) error
+	Warn(text string, v ...interface{}) error
+	Info(text string, v ...interface{}) error
+	Debug(text string, v ...interface{}) error
+}
+
+// clientLog makes a Logger method an elastic.Logger.
+type clientLog func(text string, v ...interface{}) error
+
+func (log clientLog) Printf(format string, v ...interface{}) {
+	_ = log(format, v...)
+}
+
+// clientLogOptions sends the client's logs to the options' Logger: its
+// errors at Error, the requests it makes and their response times at Info,
+// and, if Trace is set, every request and response in full at Debug. Slow
+// requests are logged at Warn.
+func clientLogOptions(options *IndexOptions) []elastic.ClientOptionFunc {
+	logger := options.Logger
+	if logger == nil {
+		return nil
+	}
+	opts := []elastic.ClientOptionFunc{
+		elastic.SetErrorLog(clientLog(logger.Error)),
+		elastic.SetInfoLog(clientLog(logger.Info)),
+	}
+	if options.Trace {
+		opts = append(opts, elastic.SetTraceLog(clientLog(logger.Debug)))
+	}
+	if options.SlowThreshold > 0 {
+		transport := &slowLogTransport{
+			base:      http.DefaultTransport,
+			threshold: options.SlowThreshold,
+			logger:    logger,
+		}
+		opts = append(opts, elastic.SetHttpClient(&http.Client{Transport: transport}))
+	}
+	return opts
+}
+
+// slowLogTransport logs the requests whose responses take longer than the
+// threshold to begin arriving.
+type slowLogTransport struct {
+	base      http.RoundTripper
+	threshold time.Duration
+	logger    Logger
+}
+
+func (t *slowLogTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		_ = req.Body.Close()
+		r := *req
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		req = &r
+	}
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	took := time.Since(start)
+	if took <= t.threshold {
+		return resp, err
+	}
+
+	index, typ := requestTarget(req.URL.Path)
+	status := "failed"
+	if err == nil {
+		status = resp.Status
+	}
+	if len(body) > SlowLogMaxBody {
+		body = append(body[:SlowLogMaxBody:SlowLogMaxBody], "..."...)
+	}
+	_ = t.logger.Warn("elasticsearch: slow request: %s %s, index %q, type %q, %s after %v: %s",
+		req.Method, req.URL.Path, index, typ, status, took, body)
+	return resp, err
+}
+
+// requestTarget returns the index and type a request path names, such as
+// "/index/type/id" or "/index/_search"; either may be empty.
+func requestTarget(path string) (string, string) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	target := []string{"", ""}
+	for i := 0; i < len(parts) && i < 2; i++ {
+		if strings.HasPrefix(parts[i], "_") {
+			break
+		}
+		target[i] = parts[i]
+	}
+	return target[0], target[1]
+}
@@ -0,0 +1,645 @@
+// Copyright 2016, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elasticsearch
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/venicegeo/pz-gocommon/elasticsearch/elastic-5-api"
+	"github.com/venicegeo/pz-gocommon/gocommon"
+	"golang.org/x/net/context"
+)
+
+// An InstrumentedIndex wraps an IIndex and keeps, for each of its methods,
+// the number of calls, the number that returned an error and a histogram of
+// how long they took:
+//
+//	esi := NewInstrumentedIndex(index, logger)
+//	...
+//	stats := esi.Snapshot()["PostData"]
+//	fmt.Println(stats.Calls, stats.Errors, stats.Percentile(0.95))
+//
+// The figures can also be sent, by LogMetrics, as metric SDEs through a
+// syslog.Logger. A bulk request counts as one call, an error only if the
+// request failed as a whole.
+//
+// The work done after a method has returned is counted too, under names of
+// its own: each page a ScanIterator fetches under "ScanPage", each flush of
+// a bulk indexer under "BulkFlush", and each request for the status of a
+// ByQueryTask, made by Status or Wait, under "ByQueryStatus".
+
+// LatencyBuckets are the upper bounds of the latency histogram buckets.
+var LatencyBuckets = []time.Duration{
+	1 * time.Millisecond,
+	2 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	20 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	200 * time.Millisecond,
+	500 * time.Millisecond,
+	1 * time.Second,
+	2 * time.Second,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// MetricLogger sends metrics; *syslog.Logger is one.
+type MetricLogger interface {
+	Metric(name string, value float64, object string, text string, v ...interface{}) error
+}
+
+// OperationMetrics are the figures kept for one method.
+type OperationMetrics struct {
+	Calls  int64
+	Errors int64
+
+	TotalLatency time.Duration
+	MaxLatency   time.Duration
+	// Buckets[i] counts the calls that took longer than LatencyBuckets[i-1]
+	// but no longer than LatencyBuckets[i]; the last element counts those
+	// that took longer than every bound.
+	Buckets []int64
+}
+
+func newOperationMetrics() *OperationMetrics {
+	return &OperationMetrics{Buckets: make([]int64, len(LatencyBuckets)+1)}
+}
+
+func (m *OperationMetrics) add(latency time.Duration, failed bool) {
+	m.Calls++
+	if failed {
+		m.Errors++
+	}
+	m.TotalLatency += latency
+	if latency > m.MaxLatency {
+		m.MaxLatency = latency
+	}
+	i := sort.Search(len(LatencyBuckets), func(i int) bool { return latency <= LatencyBuckets[i] })
+	m.Buckets[i]++
+}
+
+func (m *OperationMetrics) copy() *OperationMetrics {
+	c := *m
+	c.Buckets = append([]int64{}, m.Buckets...)
+	return &c
+}
+
+// MeanLatency returns the average time a call took.
+func (m *OperationMetrics) MeanLatency() time.Duration {
+	if m.Calls == 0 {
+		return 0
+	}
+	return m.TotalLatency / time.Duration(m.Calls)
+}
+
+// Percentile returns the time within which the fraction p, from 0 to 1, of
+// the calls completed, rounded up to a bucket bound but no more than
+// MaxLatency.
+func (m *OperationMetrics) Percentile(p float64) time.Duration {
+	if m.Calls == 0 {
+		return 0
+	}
+	rank := int64(p*float64(m.Calls) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	seen := int64(0)
+	for i, n := range m.Buckets[:len(LatencyBuckets)] {
+		seen += n
+		if seen >= rank {
+			if LatencyBuckets[i] < m.MaxLatency {
+				return LatencyBuckets[i]
+			}
+			break
+		}
+	}
+	return m.MaxLatency
+}
+
+var _ IIndex = (*InstrumentedIndex)(nil)
+
+// InstrumentedIndex is safe for concurrent use if the index it wraps is.
+type InstrumentedIndex struct {
+	*instrumentState
+
+	index IIndex
+}
+
+// instrumentState is shared by an InstrumentedIndex and its WithContext
+// views.
+type instrumentState struct {
+	logger MetricLogger
+
+	mu         sync.Mutex
+	operations map[string]*OperationMetrics
+}
+
+// NewInstrumentedIndex wraps the index. The logger, which may be nil, is
+// the one LogMetrics uses.
+func NewInstrumentedIndex(esi IIndex, logger MetricLogger) *InstrumentedIndex {
+	state := &instrumentState{
+		logger:     logger,
+		operations: make(map[string]*OperationMetrics),
+	}
+	return &InstrumentedIndex{instrumentState: state, index: esi}
+}
+
+// observe records a call of the method, begun at start.
+func (s *instrumentState) observe(method string, start time.Time, err error) {
+	latency := time.Since(start)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, ok := s.operations[method]
+	if !ok {
+		m = newOperationMetrics()
+		s.operations[method] = m
+	}
+	m.add(latency, err != nil)
+}
+
+// Snapshot returns a copy of the figures, by method name, of the methods
+// called so far.
+func (s *instrumentState) Snapshot() map[string]*OperationMetrics {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snap := make(map[string]*OperationMetrics, len(s.operations))
+	for method, m := range s.operations {
+		snap[method] = m.copy()
+	}
+	return snap
+}
+
+// Reset forgets the figures kept so far.
+func (s *instrumentState) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.operations = make(map[string]*OperationMetrics)
+}
+
+// LogMetrics sends the figures of each method called so far as metrics named
+// "elasticsearch.<method>.<figure>", whose object is the index name: calls
+// and errors, and the mean, 50th, 95th, 99th percentile and maximum
+// latencies, in milliseconds. It does nothing if there is no logger, and
+// returns the first error the logger does.
+func (esi *InstrumentedIndex) LogMetrics() error {
+	if esi.logger == nil {
+		return nil
+	}
+	snap := esi.Snapshot()
+	methods := make([]string, 0, len(snap))
+	for method := range snap {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+
+	var first error
+	send := func(name string, value float64) {
+		err := esi.logger.Metric(name, value, esi.index.IndexName(), "%s = %g", name, value)
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+	ms := func(d time.Duration) float64 {
+		return float64(d) / float64(time.Millisecond)
+	}
+	for _, method := range methods {
+		m := snap[method]
+		prefix := "elasticsearch." + method + "."
+		send(prefix+"calls", float64(m.Calls))
+		send(prefix+"errors", float64(m.Errors))
+		send(prefix+"latency.mean", ms(m.MeanLatency()))
+		send(prefix+"latency.p50", ms(m.Percentile(0.50)))
+		send(prefix+"latency.p95", ms(m.Percentile(0.95)))
+		send(prefix+"latency.p99", ms(m.Percentile(0.99)))
+		send(prefix+"latency.max", ms(m.MaxLatency))
+	}
+	return first
+}
+
+func (esi *InstrumentedIndex) GetVersion() string {
+	return esi.index.GetVersion()
+}
+
+// WithContext returns a view of the index, sharing its figures, whose
+// operations are abandoned when ctx is done.
+func (esi *InstrumentedIndex) WithContext(ctx context.Context) IIndex {
+	return &InstrumentedIndex{instrumentState: esi.instrumentState, index: esi.index.WithContext(ctx)}
+}
+
+func (esi *InstrumentedIndex) IndexName() string {
+	return esi.index.IndexName()
+}
+
+func (esi *InstrumentedIndex) IndexExists() (bool, error) {
+	start := time.Now()
+	ret, err := esi.index.IndexExists()
+	esi.observe("IndexExists", start, err)
+	return ret, err
+}
+
+func (esi *InstrumentedIndex) TypeExists(typ string) (bool, error) {
+	start := time.Now()
+	ret, err := esi.index.TypeExists(typ)
+	esi.observe("TypeExists", start, err)
+	return ret, err
+}
+
+func (esi *InstrumentedIndex) ItemExists(typ string, id string) (bool, error) {
+	start := time.Now()
+	ret, err := esi.index.ItemExists(typ, id)
+	esi.observe("ItemExists", start, err)
+	return ret, err
+}
+
+func (esi *InstrumentedIndex) Create(settings string) error {
+	start := time.Now()
+	err := esi.index.Create(settings)
+	esi.observe("Create", start, err)
+	return err
+}
+
+func (esi *InstrumentedIndex) Close() error {
+	start := time.Now()
+	err := esi.index.Close()
+	esi.observe("Close", start, err)
+	return err
+}
+
+func (esi *InstrumentedIndex) Delete() error {
+	start := time.Now()
+	err := esi.index.Delete()
+	esi.observe("Delete", start, err)
+	return err
+}
+
+func (esi *InstrumentedIndex) PostData(typ string, id string, obj interface{}) (*IndexResponse, error) {
+	start := time.Now()
+	ret, err := esi.index.PostData(typ, id, obj)
+	esi.observe("PostData", start, err)
+	return ret, err
+}
+
+func (esi *InstrumentedIndex) PutData(typ string, id string, obj interface{}) (*IndexResponse, error) {
+	start := time.Now()
+	ret, err := esi.index.PutData(typ, id, obj)
+	esi.observe("PutData", start, err)
+	return ret, err
+}
+
+func (esi *InstrumentedIndex) CreateData(typ string, id string, obj interface{}) (*IndexResponse, error) {
+	start := time.Now()
+	ret, err := esi.index.CreateData(typ, id, obj)
+	esi.observe("CreateData", start, err)
+	return ret, err
+}
+
+func (esi *InstrumentedIndex) PutDataIfVersion(typ string, id string, obj interface{}, version int) (*IndexResponse, error) {
+	start := time.Now()
+	ret, err := esi.index.PutDataIfVersion(typ, id, obj, version)
+	esi.observe("PutDataIfVersion", start, err)
+	return ret, err
+}
+
+func (esi *InstrumentedIndex) UpdateData(typ string, id string, update *Update) (*IndexResponse, error) {
+	start := time.Now()
+	ret, err := esi.index.UpdateData(typ, id, update)
+	esi.observe("UpdateData", start, err)
+	return ret, err
+}
+
+func (esi *InstrumentedIndex) GetByID(typ string, id string) (*GetResult, error) {
+	start := time.Now()
+	ret, err := esi.index.GetByID(typ, id)
+	esi.observe("GetByID", start, err)
+	return ret, err
+}
+
+func (esi *InstrumentedIndex) GetByIDs(typ string, ids []string) ([]*GetResult, error) {
+	start := time.Now()
+	ret, err := esi.index.GetByIDs(typ, ids)
+	esi.observe("GetByIDs", start, err)
+	return ret, err
+}
+
+func (esi *InstrumentedIndex) DeleteByID(typ string, id string) (*DeleteResponse, error) {
+	start := time.Now()
+	ret, err := esi.index.DeleteByID(typ, id)
+	esi.observe("DeleteByID", start, err)
+	return ret, err
+}
+
+func (esi *InstrumentedIndex) DeleteByIDWait(typ string, id string) (*DeleteResponse, error) {
+	start := time.Now()
+	ret, err := esi.index.DeleteByIDWait(typ, id)
+	esi.observe("DeleteByIDWait", start, err)
+	return ret, err
+}
+
+func (esi *InstrumentedIndex) Refresh() error {
+	start := time.Now()
+	err := esi.index.Refresh()
+	esi.observe("Refresh", start, err)
+	return err
+}
+
+func (esi *InstrumentedIndex) BulkPostData(typ string, items []*BulkItem) (*BulkResponse, error) {
+	start := time.Now()
+	ret, err := esi.index.BulkPostData(typ, items)
+	esi.observe("BulkPostData", start, err)
+	return ret, err
+}
+
+func (esi *InstrumentedIndex) BulkDeleteByID(typ string, ids []string) (*BulkResponse, error) {
+	start := time.Now()
+	ret, err := esi.index.BulkDeleteByID(typ, ids)
+	esi.observe("BulkDeleteByID", start, err)
+	return ret, err
+}
+
+func (esi *InstrumentedIndex) DeleteByQuery(typ string, query elastic.Query) (*ByQueryTask, error) {
+	start := time.Now()
+	ret, err := esi.index.DeleteByQuery(typ, query)
+	esi.observe("DeleteByQuery", start, err)
+	return esi.task(ret), err
+}
+
+func (esi *InstrumentedIndex) UpdateByQuery(typ string, query elastic.Query, script *elastic.Script) (*ByQueryTask, error) {
+	start := time.Now()
+	ret, err := esi.index.UpdateByQuery(typ, query, script)
+	esi.observe("UpdateByQuery", start, err)
+	return esi.task(ret), err
+}
+
+// task times the requests for the task's status.
+func (esi *InstrumentedIndex) task(t *ByQueryTask) *ByQueryTask {
+	if t == nil {
+		return nil
+	}
+	status := t.status
+	t.status = func() (*ByQueryStatus, error) {
+		start := time.Now()
+		ret, err := status()
+		esi.observe("ByQueryStatus", start, err)
+		return ret, err
+	}
+	return t
+}
+
+func (esi *InstrumentedIndex) NewBulkIndexer(settings *BulkSettings) (IBulkIndexer, error) {
+	start := time.Now()
+	if settings == nil {
+		settings = NewBulkSettings()
+	}
+	var mu sync.Mutex
+	var began time.Time
+	instrumented := *settings
+	instrumented.Before = func() {
+		mu.Lock()
+		began = time.Now()
+		mu.Unlock()
+		if settings.Before != nil {
+			settings.Before()
+		}
+	}
+	instrumented.After = func(resp *BulkResponse, err error) {
+		mu.Lock()
+		flushStart := began
+		mu.Unlock()
+		esi.observe("BulkFlush", flushStart, err)
+		if settings.After != nil {
+			settings.After(resp, err)
+		}
+	}
+	ret, err := esi.index.NewBulkIndexer(&instrumented)
+	esi.observe("NewBulkIndexer", start, err)
+	return ret, err
+}
+
+func (esi *InstrumentedIndex) FilterByMatchAll(typ string, format *piazza.JsonPagination) (*SearchResult, error) {
+	start := time.Now()
+	ret, err := esi.index.FilterByMatchAll(typ, format)
+	esi.observe("FilterByMatchAll", start, err)
+	return ret, err
+}
+
+func (esi *InstrumentedIndex) GetAllElements(typ string) (*SearchResult, error) {
+	start := time.Now()
+	ret, err := esi.index.GetAllElements(typ)
+	esi.observe("GetAllElements", start, err)
+	return ret, err
+}
+
+func (esi *InstrumentedIndex) Scan(typ string, query elastic.Query) (*ScanIterator, error) {
+	start := time.Now()
+	ret, err := esi.index.Scan(typ, query)
+	esi.observe("Scan", start, err)
+	if err != nil {
+		return nil, err
+	}
+	fetch := ret.fetch
+	ret.fetch = func(after []interface{}, size int) ([]*SearchResultHit, []interface{}, error) {
+		start := time.Now()
+		hits, next, err := fetch(after, size)
+		esi.observe("ScanPage", start, err)
+		return hits, next, err
+	}
+	return ret, nil
+}
+
+func (esi *InstrumentedIndex) FilterByTermQuery(typ string, name string, value interface{}, format *piazza.JsonPagination) (*SearchResult, error) {
+	start := time.Now()
+	ret, err := esi.index.FilterByTermQuery(typ, name, value, format)
+	esi.observe("FilterByTermQuery", start, err)
+	return ret, err
+}
+
+func (esi *InstrumentedIndex) FilterByMatchQuery(typ string, name string, value interface{}, format *piazza.JsonPagination) (*SearchResult, error) {
+	start := time.Now()
+	ret, err := esi.index.FilterByMatchQuery(typ, name, value, format)
+	esi.observe("FilterByMatchQuery", start, err)
+	return ret, err
+}
+
+func (esi *InstrumentedIndex) FilterByQuery(typ string, query elastic.Query, format *piazza.JsonPagination) (*SearchResult, error) {
+	start := time.Now()
+	ret, err := esi.index.FilterByQuery(typ, query, format)
+	esi.observe("FilterByQuery", start, err)
+	return ret, err
+}
+
+func (esi *InstrumentedIndex) FilterByBoundingBox(typ string, field string, topLeft *elastic.GeoPoint, bottomRight *elastic.GeoPoint, format *piazza.JsonPagination) (*SearchResult, error) {
+	start := time.Now()
+	ret, err := esi.index.FilterByBoundingBox(typ, field, topLeft, bottomRight, format)
+	esi.observe("FilterByBoundingBox", start, err)
+	return ret, err
+}
+
+func (esi *InstrumentedIndex) FilterByDistance(typ string, field string, center *elastic.GeoPoint, distance string, format *piazza.JsonPagination) (*SearchResult, error) {
+	start := time.Now()
+	ret, err := esi.index.FilterByDistance(typ, field, center, distance, format)
+	esi.observe("FilterByDistance", start, err)
+	return ret, err
+}
+
+func (esi *InstrumentedIndex) SearchByJSON(typ string, jsn string) (*SearchResult, error) {
+	start := time.Now()
+	ret, err := esi.index.SearchByJSON(typ, jsn)
+	esi.observe("SearchByJSON", start, err)
+	return ret, err
+}
+
+func (esi *InstrumentedIndex) MultiSearch(requests []*SearchRequest) ([]*MultiSearchItem, error) {
+	start := time.Now()
+	ret, err := esi.index.MultiSearch(requests)
+	esi.observe("MultiSearch", start, err)
+	return ret, err
+}
+
+func (esi *InstrumentedIndex) Aggregate(typ string, query elastic.Query, aggs map[string]elastic.Aggregation) (*SearchResult, error) {
+	start := time.Now()
+	ret, err := esi.index.Aggregate(typ, query, aggs)
+	esi.observe("Aggregate", start, err)
+	return ret, err
+}
+
+func (esi *InstrumentedIndex) SearchWithHighlight(typ string, query elastic.Query, highlight *elastic.Highlight, format *piazza.JsonPagination) (*SearchResult, error) {
+	start := time.Now()
+	ret, err := esi.index.SearchWithHighlight(typ, query, highlight, format)
+	esi.observe("SearchWithHighlight", start, err)
+	return ret, err
+}
+
+func (esi *InstrumentedIndex) Suggest(typ string, suggester elastic.Suggester) ([]*Suggestion, error) {
+	start := time.Now()
+	ret, err := esi.index.Suggest(typ, suggester)
+	esi.observe("Suggest", start, err)
+	return ret, err
+}
+
+func (esi *InstrumentedIndex) SetMapping(typename string, jsn piazza.JsonString) error {
+	start := time.Now()
+	err := esi.index.SetMapping(typename, jsn)
+	esi.observe("SetMapping", start, err)
+	return err
+}
+
+func (esi *InstrumentedIndex) GetTypes() ([]string, error) {
+	start := time.Now()
+	ret, err := esi.index.GetTypes()
+	esi.observe("GetTypes", start, err)
+	return ret, err
+}
+
+func (esi *InstrumentedIndex) GetMapping(typ string) (interface{}, error) {
+	start := time.Now()
+	ret, err := esi.index.GetMapping(typ)
+	esi.observe("GetMapping", start, err)
+	return ret, err
+}
+
+func (esi *InstrumentedIndex) AddAlias(alias string) error {
+	start := time.Now()
+	err := esi.index.AddAlias(alias)
+	esi.observe("AddAlias", start, err)
+	return err
+}
+
+func (esi *InstrumentedIndex) RemoveAlias(alias string) error {
+	start := time.Now()
+	err := esi.index.RemoveAlias(alias)
+	esi.observe("RemoveAlias", start, err)
+	return err
+}
+
+func (esi *InstrumentedIndex) SwapAlias(alias string) error {
+	start := time.Now()
+	err := esi.index.SwapAlias(alias)
+	esi.observe("SwapAlias", start, err)
+	return err
+}
+
+func (esi *InstrumentedIndex) GetAliases() ([]string, error) {
+	start := time.Now()
+	ret, err := esi.index.GetAliases()
+	esi.observe("GetAliases", start, err)
+	return ret, err
+}
+
+func (esi *InstrumentedIndex) AliasIndices(alias string) ([]string, error) {
+	start := time.Now()
+	ret, err := esi.index.AliasIndices(alias)
+	esi.observe("AliasIndices", start, err)
+	return ret, err
+}
+
+func (esi *InstrumentedIndex) GetSettings() (*IndexSettings, error) {
+	start := time.Now()
+	ret, err := esi.index.GetSettings()
+	esi.observe("GetSettings", start, err)
+	return ret, err
+}
+
+func (esi *InstrumentedIndex) UpdateSettings(settings *IndexSettings) error {
+	start := time.Now()
+	err := esi.index.UpdateSettings(settings)
+	esi.observe("UpdateSettings", start, err)
+	return err
+}
+
+func (esi *InstrumentedIndex) PutTemplate(name string, template *IndexTemplate) error {
+	start := time.Now()
+	err := esi.index.PutTemplate(name, template)
+	esi.observe("PutTemplate", start, err)
+	return err
+}
+
+func (esi *InstrumentedIndex) DeleteTemplate(name string) error {
+	start := time.Now()
+	err := esi.index.DeleteTemplate(name)
+	esi.observe("DeleteTemplate", start, err)
+	return err
+}
+
+func (esi *InstrumentedIndex) AddPercolationQuery(id string, query piazza.JsonString) (*IndexResponse, error) {
+	start := time.Now()
+	ret, err := esi.index.AddPercolationQuery(id, query)
+	esi.observe("AddPercolationQuery", start, err)
+	return ret, err
+}
+
+func (esi *InstrumentedIndex) DeletePercolationQuery(id string) (*DeleteResponse, error) {
+	start := time.Now()
+	ret, err := esi.index.DeletePercolationQuery(id)
+	esi.observe("DeletePercolationQuery", start, err)
+	return ret, err
+}
+
+func (esi *InstrumentedIndex) AddPercolationDocument(typ string, doc interface{}) (*PercolateResponse, error) {
+	start := time.Now()
+	ret, err := esi.index.AddPercolationDocument(typ, doc)
+	esi.observe("AddPercolationDocument", start, err)
+	return ret, err
+}
+
+func (esi *InstrumentedIndex) DirectAccess(verb string, endpoint string, input interface{}, output interface{}) error {
+	start := time.Now()
+	err := esi.index.DirectAccess(verb, endpoint, input, output)
+	esi.observe("DirectAccess", start, err)
+	return err
+}
@@ -0,0 +1,678 @@
+// Copyright 2016, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elasticsearch
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/venicegeo/pz-gocommon/gocommon"
+)
+
+// The types of fields which hold other fields. They aren't valid as event
+// parameter types, so they're kept apart from those.
+const (
+	MappingElementTypeObject MappingElementTypeName = "object"
+	MappingElementTypeNested MappingElementTypeName = "nested"
+)
+
+// mappingDynamicType is the placeholder a dynamic template's mapping may use
+// for the type Elasticsearch detected.
+const mappingDynamicType MappingElementTypeName = "{dynamic_type}"
+
+// Mapping is the schema of a type, built up with chained calls and then
+// given to SetMapping, either directly as JSON or as part of the index
+// settings:
+//
+//	m := NewMapping("Event").
+//		Property("id", NewProperty(MappingElementTypeKeyword)).
+//		Property("text", NewProperty(MappingElementTypeText).Analyzer("english").KeywordField()).
+//		Property("when", NewProperty(MappingElementTypeDate).Format("epoch_millis")).
+//		Property("owner", NewObjectProperty().
+//			Property("name", NewProperty(MappingElementTypeKeyword)))
+//	jsn, err := m.JSON()
+//
+// See https://www.elastic.co/guide/en/elasticsearch/reference/5.5/mapping.html.
+type Mapping struct {
+	name       string
+	dynamic    string
+	properties map[string]*Property
+	templates  []*DynamicTemplate
+	params     map[string]interface{}
+}
+
+// NewMapping starts the mapping of the named type.
+func NewMapping(name string) *Mapping {
+	return &Mapping{
+		name:       name,
+		properties: make(map[string]*Property),
+	}
+}
+
+// Name returns the name of the type.
+func (m *Mapping) Name() string {
+	return m.name
+}
+
+// Dynamic sets what happens to fields the mapping doesn't name: "true" maps
+// them, "false" ignores them and "strict" rejects the document.
+func (m *Mapping) Dynamic(dynamic string) *Mapping {
+	m.dynamic = dynamic
+	return m
+}
+
+// Property adds or replaces a field.
+func (m *Mapping) Property(name string, prop *Property) *Mapping {
+	m.properties[name] = prop
+	return m
+}
+
+// Properties returns the fields, by name.
+func (m *Mapping) Properties() map[string]*Property {
+	return m.properties
+}
+
+// DynamicTemplate adds a template for mapping fields the mapping doesn't
+// name. Templates are tried in the order they were added.
+func (m *Mapping) DynamicTemplate(template *DynamicTemplate) *Mapping {
+	m.templates = append(m.templates, template)
+	return m
+}
+
+// Param sets a type-level setting the Mapping doesn't model, such as
+// "_source" or "_all".
+func (m *Mapping) Param(name string, value interface{}) *Mapping {
+	if m.params == nil {
+		m.params = make(map[string]interface{})
+	}
+	m.params[name] = value
+	return m
+}
+
+// Validate checks the mapping for settings Elasticsearch would reject.
+func (m *Mapping) Validate() error {
+	if m.name == "" {
+		return fmt.Errorf("mapping: type name may not be empty")
+	}
+	if strings.HasPrefix(m.name, "_") || strings.HasPrefix(m.name, ".") {
+		return fmt.Errorf("mapping: type name %s may not begin with '_' or '.'", m.name)
+	}
+	switch m.dynamic {
+	case "", "true", "false", "strict":
+	default:
+		return fmt.Errorf("mapping %s: invalid dynamic setting %q", m.name, m.dynamic)
+	}
+	for _, name := range sortedPropertyNames(m.properties) {
+		err := m.properties[name].validate(name, false)
+		if err != nil {
+			return fmt.Errorf("mapping %s: %s", m.name, err.Error())
+		}
+	}
+	for _, t := range m.templates {
+		err := t.validate()
+		if err != nil {
+			return fmt.Errorf("mapping %s: %s", m.name, err.Error())
+		}
+	}
+	return nil
+}
+
+// Source returns the mapping in the form SetMapping takes, {name: {...}}.
+func (m *Mapping) Source() (interface{}, error) {
+	err := m.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	body := make(map[string]interface{})
+	for k, v := range m.params {
+		body[k] = v
+	}
+	if m.dynamic != "" {
+		body["dynamic"] = m.dynamic
+	}
+	if len(m.templates) > 0 {
+		templates := make([]interface{}, len(m.templates))
+		for i, t := range m.templates {
+			templates[i] = t.source()
+		}
+		body["dynamic_templates"] = templates
+	}
+	body["properties"] = propertiesSource(m.properties)
+
+	return map[string]interface{}{m.name: body}, nil
+}
+
+// JSON returns the mapping as SetMapping takes it.
+func (m *Mapping) JSON() (piazza.JsonString, error) {
+	src, err := m.Source()
+	if err != nil {
+		return "", err
+	}
+	byts, err := json.Marshal(src)
+	if err != nil {
+		return "", err
+	}
+	return piazza.JsonString(byts), nil
+}
+
+//---------------------------------------------------------------------------
+
+// Property is the mapping of a single field.
+type Property struct {
+	typ            MappingElementTypeName
+	properties     map[string]*Property
+	fields         map[string]*Property
+	analyzer       string
+	searchAnalyzer string
+	index          *bool
+	format         string
+	ignoreAbove    int
+	params         map[string]interface{}
+}
+
+// NewProperty returns a field of the given type. Since any field may hold
+// an array, an array type such as "[text]" maps as its element type.
+func NewProperty(typ MappingElementTypeName) *Property {
+	if typ.isValidArrayMappingType() {
+		typ = typ[1 : len(typ)-1]
+	}
+	return &Property{typ: typ}
+}
+
+// NewObjectProperty returns a field holding an object, whose fields are
+// added with Property.
+func NewObjectProperty() *Property {
+	return &Property{
+		typ:        MappingElementTypeObject,
+		properties: make(map[string]*Property),
+	}
+}
+
+// NewNestedProperty returns a field holding an array of objects, each of
+// which is indexed, and may be queried, separately.
+func NewNestedProperty() *Property {
+	return &Property{
+		typ:        MappingElementTypeNested,
+		properties: make(map[string]*Property),
+	}
+}
+
+// Type returns the type of the field.
+func (p *Property) Type() MappingElementTypeName {
+	return p.typ
+}
+
+// Property adds or replaces a field of an object or nested field.
+func (p *Property) Property(name string, prop *Property) *Property {
+	if p.properties == nil {
+		p.properties = make(map[string]*Property)
+	}
+	p.properties[name] = prop
+	return p
+}
+
+// Properties returns the fields of an object or nested field, by name.
+func (p *Property) Properties() map[string]*Property {
+	return p.properties
+}
+
+// Field adds a multi-field: another way of indexing the same value,
+// queried as "field.name".
+func (p *Property) Field(name string, prop *Property) *Property {
+	if p.fields == nil {
+		p.fields = make(map[string]*Property)
+	}
+	p.fields[name] = prop
+	return p
+}
+
+// KeywordField adds the "keyword" multi-field Elasticsearch gives strings it
+// maps dynamically, for sorting and aggregating on a text field.
+func (p *Property) KeywordField() *Property {
+	return p.Field("keyword", NewProperty(MappingElementTypeKeyword).IgnoreAbove(256))
+}
+
+// Analyzer sets the analyzer of a text field.
+func (p *Property) Analyzer(analyzer string) *Property {
+	p.analyzer = analyzer
+	return p
+}
+
+// SearchAnalyzer sets the analyzer applied to queries of a text field, if
+// it differs from that applied to documents.
+func (p *Property) SearchAnalyzer(analyzer string) *Property {
+	p.searchAnalyzer = analyzer
+	return p
+}
+
+// Index sets whether the field is searchable. A field that isn't is still
+// kept in the source.
+func (p *Property) Index(index bool) *Property {
+	p.index = &index
+	return p
+}
+
+// Format sets the formats a date field accepts, e.g. "epoch_millis" or
+// "yyyy-MM-dd||epoch_millis".
+func (p *Property) Format(format string) *Property {
+	p.format = format
+	return p
+}
+
+// IgnoreAbove sets the length beyond which a keyword isn't indexed.
+func (p *Property) IgnoreAbove(length int) *Property {
+	p.ignoreAbove = length
+	return p
+}
+
+// Param sets a mapping parameter the Property doesn't model, such as
+// "copy_to" or "null_value".
+func (p *Property) Param(name string, value interface{}) *Property {
+	if p.params == nil {
+		p.params = make(map[string]interface{})
+	}
+	p.params[name] = value
+	return p
+}
+
+func (p *Property) isObject() bool {
+	return p.typ == MappingElementTypeObject || p.typ == MappingElementTypeNested
+}
+
+// validate checks the field, called name. In a dynamic template, the type
+// may be left out or be "{dynamic_type}".
+func (p *Property) validate(name string, inTemplate bool) error {
+	if name == "" {
+		return fmt.Errorf("field name may not be empty")
+	}
+	if p == nil {
+		return fmt.Errorf("field %s: no mapping", name)
+	}
+
+	switch {
+	case p.isObject():
+		if p.analyzer != "" || p.searchAnalyzer != "" || p.format != "" || p.ignoreAbove != 0 ||
+			p.index != nil || len(p.fields) > 0 {
+			return fmt.Errorf("field %s: %s fields take only properties", name, p.typ)
+		}
+		for _, sub := range sortedPropertyNames(p.properties) {
+			err := p.properties[sub].validate(sub, inTemplate)
+			if err != nil {
+				return fmt.Errorf("%s.%s", name, err.Error())
+			}
+		}
+		return nil
+	case inTemplate && (p.typ == "" || p.typ == mappingDynamicType):
+	case !p.typ.isValidScalarMappingType():
+		return fmt.Errorf("field %s: invalid type %q", name, p.typ)
+	}
+
+	if len(p.properties) > 0 {
+		return fmt.Errorf("field %s: only object and nested fields have properties", name)
+	}
+	if (p.analyzer != "" || p.searchAnalyzer != "") &&
+		p.typ != MappingElementTypeText && p.typ != MappingElementTypeCompletion && p.typ != "" && p.typ != mappingDynamicType {
+		return fmt.Errorf("field %s: %s fields aren't analyzed", name, p.typ)
+	}
+	if p.format != "" && p.typ != MappingElementTypeDate && p.typ != "" && p.typ != mappingDynamicType {
+		return fmt.Errorf("field %s: only date fields have a format", name)
+	}
+	if p.ignoreAbove < 0 {
+		return fmt.Errorf("field %s: ignore_above may not be negative", name)
+	}
+	if p.ignoreAbove > 0 && p.typ != MappingElementTypeKeyword && p.typ != "" && p.typ != mappingDynamicType {
+		return fmt.Errorf("field %s: only keyword fields have ignore_above", name)
+	}
+	for _, sub := range sortedPropertyNames(p.fields) {
+		field := p.fields[sub]
+		if field != nil && (field.isObject() || len(field.fields) > 0) {
+			return fmt.Errorf("field %s: multi-field %s must be a simple field", name, sub)
+		}
+		err := field.validate(sub, false)
+		if err != nil {
+			return fmt.Errorf("%s.%s", name, err.Error())
+		}
+	}
+	return nil
+}
+
+func (p *Property) source() map[string]interface{} {
+	src := make(map[string]interface{})
+	for k, v := range p.params {
+		src[k] = v
+	}
+	if p.typ != "" {
+		src["type"] = string(p.typ)
+	}
+	if p.isObject() {
+		src["properties"] = propertiesSource(p.properties)
+	}
+	if len(p.fields) > 0 {
+		src["fields"] = propertiesSource(p.fields)
+	}
+	if p.analyzer != "" {
+		src["analyzer"] = p.analyzer
+	}
+	if p.searchAnalyzer != "" {
+		src["search_analyzer"] = p.searchAnalyzer
+	}
+	if p.index != nil {
+		src["index"] = *p.index
+	}
+	if p.format != "" {
+		src["format"] = p.format
+	}
+	if p.ignoreAbove != 0 {
+		src["ignore_above"] = p.ignoreAbove
+	}
+	return src
+}
+
+func propertiesSource(props map[string]*Property) map[string]interface{} {
+	src := make(map[string]interface{}, len(props))
+	for name, prop := range props {
+		src[name] = prop.source()
+	}
+	return src
+}
+
+func sortedPropertyNames(props map[string]*Property) []string {
+	names := make([]string, 0, len(props))
+	for name := range props {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//---------------------------------------------------------------------------
+
+// DynamicTemplate maps the fields a Mapping doesn't name which match its
+// conditions.
+type DynamicTemplate struct {
+	name             string
+	match            string
+	unmatch          string
+	pathMatch        string
+	pathUnmatch      string
+	matchMappingType string
+	mapping          *Property
+}
+
+// NewDynamicTemplate returns a template applying mapping to the fields
+// matching its conditions. The mapping's type may be left empty, or be
+// "{dynamic_type}", to keep the type Elasticsearch detects.
+func NewDynamicTemplate(name string, mapping *Property) *DynamicTemplate {
+	return &DynamicTemplate{name: name, mapping: mapping}
+}
+
+// Match limits the template to fields whose names match the pattern.
+func (t *DynamicTemplate) Match(pattern string) *DynamicTemplate {
+	t.match = pattern
+	return t
+}
+
+// Unmatch excludes fields whose names match the pattern.
+func (t *DynamicTemplate) Unmatch(pattern string) *DynamicTemplate {
+	t.unmatch = pattern
+	return t
+}
+
+// PathMatch limits the template to fields whose dotted paths match the pattern.
+func (t *DynamicTemplate) PathMatch(pattern string) *DynamicTemplate {
+	t.pathMatch = pattern
+	return t
+}
+
+// PathUnmatch excludes fields whose dotted paths match the pattern.
+func (t *DynamicTemplate) PathUnmatch(pattern string) *DynamicTemplate {
+	t.pathUnmatch = pattern
+	return t
+}
+
+// MatchMappingType limits the template to fields of the JSON type
+// Elasticsearch detects: "string", "long", "double", "boolean", "date" or
+// "object".
+func (t *DynamicTemplate) MatchMappingType(typ string) *DynamicTemplate {
+	t.matchMappingType = typ
+	return t
+}
+
+func (t *DynamicTemplate) validate() error {
+	if t.name == "" {
+		return fmt.Errorf("dynamic template name may not be empty")
+	}
+	if t.match == "" && t.pathMatch == "" && t.matchMappingType == "" {
+		return fmt.Errorf("dynamic template %s: match, path_match or match_mapping_type is required", t.name)
+	}
+	err := t.mapping.validate("mapping", true)
+	if err != nil {
+		return fmt.Errorf("dynamic template %s: %s", t.name, err.Error())
+	}
+	return nil
+}
+
+func (t *DynamicTemplate) source() map[string]interface{} {
+	src := map[string]interface{}{"mapping": t.mapping.source()}
+	if t.match != "" {
+		src["match"] = t.match
+	}
+	if t.unmatch != "" {
+		src["unmatch"] = t.unmatch
+	}
+	if t.pathMatch != "" {
+		src["path_match"] = t.pathMatch
+	}
+	if t.pathUnmatch != "" {
+		src["path_unmatch"] = t.pathUnmatch
+	}
+	if t.matchMappingType != "" {
+		src["match_mapping_type"] = t.matchMappingType
+	}
+	return map[string]interface{}{t.name: src}
+}
+
+//---------------------------------------------------------------------------
+
+// ParseMapping reads the mapping of the type typ, as returned by GetMapping,
+// into a Mapping. Parameters the Mapping doesn't model are kept as Params.
+// The result isn't validated, since Elasticsearch may report mappings, such
+// as those of upgraded indices, that it would no longer accept.
+func ParseMapping(typ string, mapping interface{}) (*Mapping, error) {
+	var obj map[string]interface{}
+	switch m := mapping.(type) {
+	case piazza.JsonString:
+		err := json.Unmarshal([]byte(m), &obj)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		byts, err := json.Marshal(mapping)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(byts, &obj)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if body, ok := obj[typ].(map[string]interface{}); ok && len(obj) == 1 {
+		obj = body
+	}
+
+	m := NewMapping(typ)
+	for k, v := range obj {
+		switch k {
+		case "properties":
+			props, err := parseProperties(v, false)
+			if err != nil {
+				return nil, fmt.Errorf("mapping %s: %s", typ, err.Error())
+			}
+			m.properties = props
+		case "dynamic":
+			// reported as a string, but accepted as a boolean too
+			m.dynamic = fmt.Sprintf("%v", v)
+		case "dynamic_templates":
+			list, ok := v.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("mapping %s: malformed dynamic_templates: %v", typ, v)
+			}
+			for _, item := range list {
+				t, err := parseDynamicTemplate(item)
+				if err != nil {
+					return nil, fmt.Errorf("mapping %s: %s", typ, err.Error())
+				}
+				m.templates = append(m.templates, t)
+			}
+		default:
+			m.Param(k, v)
+		}
+	}
+	return m, nil
+}
+
+func parseProperties(v interface{}, inTemplate bool) (map[string]*Property, error) {
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("malformed properties: %v", v)
+	}
+	props := make(map[string]*Property, len(obj))
+	for name, pv := range obj {
+		prop, err := parseProperty(pv, inTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %s", name, err.Error())
+		}
+		props[name] = prop
+	}
+	return props, nil
+}
+
+// parseProperty reads a field's mapping. Elasticsearch leaves out the type
+// of object fields, except in dynamic templates, where no type means the
+// detected one.
+func parseProperty(v interface{}, inTemplate bool) (*Property, error) {
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("malformed mapping: %v", v)
+	}
+
+	p := &Property{}
+	if s, ok := obj["type"].(string); ok {
+		p.typ = MappingElementTypeName(s)
+	} else if _, ok := obj["type"]; ok {
+		return nil, fmt.Errorf("malformed type: %v", obj["type"])
+	} else if _, ok := obj["properties"]; ok || !inTemplate {
+		p.typ = MappingElementTypeObject
+	}
+	if p.isObject() {
+		p.properties = make(map[string]*Property)
+	}
+
+	var err error
+	for k, v := range obj {
+		switch k {
+		case "type":
+		case "properties":
+			p.properties, err = parseProperties(v, inTemplate)
+		case "fields":
+			p.fields, err = parseProperties(v, false)
+		case "analyzer", "search_analyzer", "format":
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("malformed %s: %v", k, v)
+			}
+			switch k {
+			case "analyzer":
+				p.analyzer = s
+			case "search_analyzer":
+				p.searchAnalyzer = s
+			default:
+				p.format = s
+			}
+		case "index":
+			// Elasticsearch 5 reports index as a boolean; older mappings
+			// used strings such as "not_analyzed", which are kept as given
+			b, ok := v.(bool)
+			if ok {
+				p.index = &b
+			} else {
+				p.Param(k, v)
+			}
+		case "ignore_above":
+			n, ok := v.(float64)
+			if !ok || n != float64(int(n)) {
+				return nil, fmt.Errorf("malformed ignore_above: %v", v)
+			}
+			p.ignoreAbove = int(n)
+		default:
+			p.Param(k, v)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+func parseDynamicTemplate(v interface{}) (*DynamicTemplate, error) {
+	obj, ok := v.(map[string]interface{})
+	if !ok || len(obj) != 1 {
+		return nil, fmt.Errorf("malformed dynamic template: %v", v)
+	}
+
+	for name, body := range obj {
+		fields, ok := body.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("malformed dynamic template %s: %v", name, body)
+		}
+		t := &DynamicTemplate{name: name}
+		for k, v := range fields {
+			if k == "mapping" {
+				prop, err := parseProperty(v, true)
+				if err != nil {
+					return nil, fmt.Errorf("dynamic template %s: %s", name, err.Error())
+				}
+				t.mapping = prop
+				continue
+			}
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("dynamic template %s: malformed %s: %v", name, k, v)
+			}
+			switch k {
+			case "match":
+				t.match = s
+			case "unmatch":
+				t.unmatch = s
+			case "path_match":
+				t.pathMatch = s
+			case "path_unmatch":
+				t.pathUnmatch = s
+			case "match_mapping_type":
+				t.matchMappingType = s
+			default:
+				return nil, fmt.Errorf("dynamic template %s: %s not supported", name, k)
+			}
+		}
+		if t.mapping == nil {
+			return nil, fmt.Errorf("dynamic template %s: no mapping", name)
+		}
+		return t, nil
+	}
+	return nil, nil
+}
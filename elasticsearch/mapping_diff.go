@@ -0,0 +1,318 @@
+// Copyright 2016, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elasticsearch
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// MappingChange classifies the difference between a type's mapping and the
+// one wanted.
+type MappingChange int
+
+const (
+	// MappingIdentical means there is nothing to do.
+	MappingIdentical MappingChange = iota
+	// MappingAdditive means SetMapping can apply the change in place: it
+	// only adds fields and multi-fields, or changes settings Elasticsearch
+	// allows to be updated.
+	MappingAdditive
+	// MappingIncompatible means the documents must be reindexed into a new
+	// index with the wanted mapping; see Reindex.
+	MappingIncompatible
+)
+
+func (c MappingChange) String() string {
+	switch c {
+	case MappingIdentical:
+		return "identical"
+	case MappingAdditive:
+		return "additive"
+	case MappingIncompatible:
+		return "incompatible"
+	}
+	return fmt.Sprintf("MappingChange(%d)", int(c))
+}
+
+// ErrIncompatibleMapping is the cause of the error UpdateMapping returns when
+// the wanted mapping can't be applied in place.
+var ErrIncompatibleMapping = errors.New("incompatible mapping")
+
+// IsIncompatibleMapping reports whether err was caused by a mapping change
+// that can't be made in place.
+func IsIncompatibleMapping(err error) bool {
+	return errors.Cause(err) == ErrIncompatibleMapping
+}
+
+// MappingDiff describes how a type's mapping differs from the one wanted.
+// Fields are named by their dotted paths, multi-fields included.
+type MappingDiff struct {
+	Change MappingChange
+
+	// Added are the fields only the wanted mapping has.
+	Added []string
+	// Updated are the fields, or type-level settings, whose changes can be
+	// applied in place.
+	Updated []string
+	// Removed are the fields only the current mapping has. Elasticsearch
+	// keeps them when a mapping is updated, so they don't stop it.
+	Removed []string
+	// Conflicts describe the changes that can't be applied in place.
+	Conflicts []string
+}
+
+func (d *MappingDiff) conflict(format string, args ...interface{}) {
+	d.Conflicts = append(d.Conflicts, fmt.Sprintf(format, args...))
+}
+
+// CompareMappings works out how current must change to become wanted.
+func CompareMappings(current *Mapping, wanted *Mapping) *MappingDiff {
+	d := &MappingDiff{}
+
+	if current.dynamic != wanted.dynamic && wanted.dynamic != "" {
+		d.Updated = append(d.Updated, "dynamic")
+	}
+	if !mappingValuesEqual(current.templates, wanted.templates) && wanted.templates != nil {
+		d.Updated = append(d.Updated, "dynamic_templates")
+	}
+	for _, k := range sortedParamNames(current.params, wanted.params) {
+		cv, cok := current.params[k]
+		wv, wok := wanted.params[k]
+		switch {
+		case !wok || mappingValuesEqual(cv, wv):
+		case k == "_meta":
+			d.Updated = append(d.Updated, k)
+		default:
+			d.conflict("%s: cannot change from %s to %s", k, mappingValueString(cv, cok), mappingValueString(wv, wok))
+		}
+	}
+
+	d.compareProperties("", current.properties, wanted.properties)
+
+	for _, list := range [][]string{d.Added, d.Updated, d.Removed, d.Conflicts} {
+		sort.Strings(list)
+	}
+	switch {
+	case len(d.Conflicts) > 0:
+		d.Change = MappingIncompatible
+	case len(d.Added) > 0 || len(d.Updated) > 0:
+		d.Change = MappingAdditive
+	}
+	return d
+}
+
+func (d *MappingDiff) compareProperties(prefix string, current map[string]*Property, wanted map[string]*Property) {
+	for name, cp := range current {
+		if _, ok := wanted[name]; !ok {
+			d.Removed = append(d.Removed, prefix+name)
+			continue
+		}
+		d.compareProperty(prefix+name, cp, wanted[name])
+	}
+	for name := range wanted {
+		if _, ok := current[name]; !ok {
+			d.Added = append(d.Added, prefix+name)
+		}
+	}
+}
+
+func (d *MappingDiff) compareProperty(path string, current *Property, wanted *Property) {
+	if current.typ != wanted.typ {
+		d.conflict("%s: cannot change type from %s to %s", path, current.typ, wanted.typ)
+		return
+	}
+	if current.analyzer != wanted.analyzer {
+		d.conflict("%s: cannot change analyzer from %q to %q", path, current.analyzer, wanted.analyzer)
+	}
+	if !mappingValuesEqual(current.indexed(), wanted.indexed()) {
+		d.conflict("%s: cannot change index from %s to %s", path,
+			mappingValueString(current.index, current.index != nil), mappingValueString(wanted.index, wanted.index != nil))
+	}
+	if current.dateFormat() != wanted.dateFormat() {
+		d.conflict("%s: cannot change format from %q to %q", path, current.format, wanted.format)
+	}
+	if current.searchAnalyzer != wanted.searchAnalyzer || current.ignoreAbove != wanted.ignoreAbove {
+		d.Updated = append(d.Updated, path)
+	}
+	for _, k := range sortedParamNames(current.params, wanted.params) {
+		cv, cok := current.params[k]
+		wv, wok := wanted.params[k]
+		if !mappingValuesEqual(current.param(k), wanted.param(k)) {
+			d.conflict("%s: cannot change %s from %s to %s", path, k, mappingValueString(cv, cok), mappingValueString(wv, wok))
+		}
+	}
+
+	d.compareProperties(path+".", current.properties, wanted.properties)
+	d.compareProperties(path+".", current.fields, wanted.fields)
+}
+
+// defaultDateFormat is the format of date fields that don't give one.
+const defaultDateFormat = "strict_date_optional_time||epoch_millis"
+
+// mappingParamDefaults are the values Elasticsearch gives field parameters
+// which aren't set, by field type; "" holds those of every type. Mappings
+// read back from Elasticsearch leave them out, so a mapping which spells
+// one out must still compare equal.
+var mappingParamDefaults = map[MappingElementTypeName]map[string]interface{}{
+	"": {
+		"boost":                 1,
+		"doc_values":            true,
+		"eager_global_ordinals": false,
+		"include_in_all":        true,
+		"store":                 false,
+	},
+	MappingElementTypeText: {
+		"fielddata":              false,
+		"index_options":          "positions",
+		"norms":                  true,
+		"position_increment_gap": 100,
+		"term_vector":            "no",
+	},
+	MappingElementTypeKeyword: {
+		"index_options": "docs",
+		"norms":         false,
+	},
+	MappingElementTypeDate:     {"ignore_malformed": false},
+	MappingElementTypeGeoPoint: {"ignore_malformed": false},
+	MappingElementTypeLong:     {"coerce": true, "ignore_malformed": false},
+	MappingElementTypeInteger:  {"coerce": true, "ignore_malformed": false},
+	MappingElementTypeShort:    {"coerce": true, "ignore_malformed": false},
+	MappingElementTypeByte:     {"coerce": true, "ignore_malformed": false},
+	MappingElementTypeDouble:   {"coerce": true, "ignore_malformed": false},
+	MappingElementTypeFloat:    {"coerce": true, "ignore_malformed": false},
+	MappingElementTypeObject:   {"enabled": true},
+}
+
+// param returns the value of a field parameter, or its default if unset.
+func (p *Property) param(k string) interface{} {
+	if v, ok := p.params[k]; ok {
+		return v
+	}
+	if v, ok := mappingParamDefaults[p.typ][k]; ok {
+		return v
+	}
+	if v, ok := mappingParamDefaults[""][k]; ok && !p.isObject() {
+		return v
+	}
+	return nil
+}
+
+// indexed returns whether the field is indexed, which it is by default.
+func (p *Property) indexed() bool {
+	return p.index == nil || *p.index
+}
+
+// dateFormat returns the field's format, or the default one for dates.
+func (p *Property) dateFormat() string {
+	if p.format == "" && p.typ == MappingElementTypeDate {
+		return defaultDateFormat
+	}
+	return p.format
+}
+
+func sortedParamNames(a map[string]interface{}, b map[string]interface{}) []string {
+	names := []string{}
+	for k := range a {
+		names = append(names, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// mappingValuesEqual compares values by their JSON, so that a parameter
+// given as, say, a map[string]bool equals the map[string]interface{} read
+// back from Elasticsearch.
+func mappingValuesEqual(a interface{}, b interface{}) bool {
+	return mappingValueString(a, true) == mappingValueString(b, true)
+}
+
+func mappingValueString(v interface{}, ok bool) string {
+	if !ok {
+		return "unset"
+	}
+	switch v := v.(type) {
+	case []*DynamicTemplate:
+		srcs := make([]interface{}, len(v))
+		for i, t := range v {
+			srcs[i] = t.source()
+		}
+		return mappingValueString(srcs, true)
+	case *bool:
+		if v == nil {
+			return "unset"
+		}
+		return fmt.Sprintf("%v", *v)
+	}
+	byts, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(byts)
+}
+
+// DiffMapping compares the mapping of the wanted type in the index with
+// wanted. If the type doesn't exist yet, every field is added.
+func DiffMapping(esi IIndex, wanted *Mapping) (*MappingDiff, error) {
+	current := NewMapping(wanted.Name())
+	ok, err := esi.TypeExists(wanted.Name())
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		obj, err := esi.GetMapping(wanted.Name())
+		if err != nil {
+			return nil, err
+		}
+		current, err = ParseMapping(wanted.Name(), obj)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return CompareMappings(current, wanted), nil
+}
+
+// UpdateMapping brings the mapping of the wanted type up to date, creating
+// the type if need be, and returns what it found. If the change can't be
+// made in place, nothing is done and the error's cause is
+// ErrIncompatibleMapping.
+func UpdateMapping(esi IIndex, wanted *Mapping) (*MappingDiff, error) {
+	jsn, err := wanted.JSON()
+	if err != nil {
+		return nil, err
+	}
+	diff, err := DiffMapping(esi, wanted)
+	if err != nil {
+		return nil, err
+	}
+
+	switch diff.Change {
+	case MappingIncompatible:
+		return diff, errors.WithMessage(ErrIncompatibleMapping,
+			fmt.Sprintf("type %s: %s", wanted.Name(), strings.Join(diff.Conflicts, "; ")))
+	case MappingAdditive:
+		return diff, esi.SetMapping(wanted.Name(), jsn)
+	}
+	return diff, nil
+}
@@ -0,0 +1,667 @@
+// Copyright 2016, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elasticsearch
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/venicegeo/pz-gocommon/elasticsearch/elastic-5-api"
+)
+
+// The mock aggregation evaluator works, like the query evaluator, on the
+// JSON form of the aggregations, and produces the JSON Elasticsearch would
+// return, so that the results are read with elastic.Aggregations as usual.
+
+// mockAggregationSources converts the named aggregations into their JSON form.
+func mockAggregationSources(aggs map[string]elastic.Aggregation) (map[string]interface{}, error) {
+	srcs := map[string]interface{}{}
+	for name, agg := range aggs {
+		src, err := agg.Source()
+		if err != nil {
+			return nil, err
+		}
+		srcs[name] = src
+	}
+	return mockNormalize(srcs)
+}
+
+// mockAggregationResults computes the aggregations over the documents.
+func mockAggregationResults(aggs map[string]interface{}, docs []map[string]interface{}) (elastic.Aggregations, error) {
+	results, err := mockAggregate(aggs, docs)
+	if err != nil {
+		return nil, err
+	}
+
+	out := elastic.Aggregations{}
+	for name, result := range results {
+		byts, err := json.Marshal(result)
+		if err != nil {
+			return nil, err
+		}
+		raw := json.RawMessage(byts)
+		out[name] = &raw
+	}
+	return out, nil
+}
+
+func mockAggregate(aggs map[string]interface{}, docs []map[string]interface{}) (map[string]interface{}, error) {
+	results := map[string]interface{}{}
+	for name, v := range aggs {
+		src, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("malformed aggregation %s: %v", name, v)
+		}
+		result, err := mockAggregation(src, docs)
+		if err != nil {
+			return nil, err
+		}
+		results[name] = result
+	}
+	return results, nil
+}
+
+func mockAggregation(src map[string]interface{}, docs []map[string]interface{}) (map[string]interface{}, error) {
+	var subAggs map[string]interface{}
+	var kind string
+	var opts map[string]interface{}
+	for k, v := range src {
+		switch k {
+		case "aggregations", "aggs":
+			subAggs, _ = v.(map[string]interface{})
+		case "meta":
+		default:
+			kind = k
+			opts, _ = v.(map[string]interface{})
+		}
+	}
+	if opts == nil {
+		return nil, fmt.Errorf("malformed aggregation: %v", src)
+	}
+
+	var result map[string]interface{}
+	var err error
+	switch kind {
+	case "terms":
+		result, err = mockAggTerms(opts, subAggs, docs)
+	case "date_histogram":
+		result, err = mockAggDateHistogram(opts, subAggs, docs)
+	case "geohash_grid":
+		result, err = mockAggGeoHashGrid(opts, subAggs, docs)
+	case "stats":
+		result, err = mockAggStats(opts, docs)
+	case "cardinality":
+		result, err = mockAggCardinality(opts, docs)
+	default:
+		return nil, fmt.Errorf("aggregation type %s not supported under mocking", kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if meta, ok := src["meta"]; ok {
+		result["meta"] = meta
+	}
+	return result, nil
+}
+
+// mockAggValues returns a document's distinct values of the aggregation's
+// field, or its "missing" value.
+func mockAggValues(opts map[string]interface{}, doc map[string]interface{}) []interface{} {
+	field, _ := opts["field"].(string)
+	values := []interface{}{}
+	seen := map[string]bool{}
+	for _, v := range mockLookup(doc, field) {
+		key := fmt.Sprintf("%v", v)
+		if !seen[key] {
+			seen[key] = true
+			values = append(values, v)
+		}
+	}
+	if len(values) == 0 {
+		if missing, ok := opts["missing"]; ok {
+			values = append(values, missing)
+		}
+	}
+	return values
+}
+
+// mockBucket is a bucket under construction: its JSON form and its documents.
+type mockBucket struct {
+	result map[string]interface{}
+	docs   []map[string]interface{}
+}
+
+// mockFinishBuckets fills in the doc counts and sub-aggregations of the buckets.
+func mockFinishBuckets(buckets []*mockBucket, subAggs map[string]interface{}) error {
+	for _, b := range buckets {
+		b.result["doc_count"] = len(b.docs)
+		sub, err := mockAggregate(subAggs, b.docs)
+		if err != nil {
+			return err
+		}
+		for k, v := range sub {
+			b.result[k] = v
+		}
+	}
+	return nil
+}
+
+// mockBucketOrder returns a less function for the aggregation's "order",
+// which may be a single {key: dir} object or an array of them. Buckets
+// that tie are ordered by ascending key.
+func mockBucketOrder(order interface{}, def string, defAsc bool) func(a, b *mockBucket) bool {
+	type criterion struct {
+		key string
+		asc bool
+	}
+	criteria := []criterion{}
+	for _, o := range mockFlatten(order) {
+		m, ok := o.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for k, v := range m {
+			criteria = append(criteria, criterion{key: k, asc: v != "desc"})
+		}
+	}
+	if len(criteria) == 0 {
+		criteria = append(criteria, criterion{key: def, asc: defAsc})
+	}
+	criteria = append(criteria, criterion{key: "_key", asc: true})
+
+	value := func(b *mockBucket, key string) interface{} {
+		switch key {
+		case "_count":
+			return b.result["doc_count"]
+		case "_term", "_key":
+			return b.result["key"]
+		}
+		// a sub-aggregation: "name" for single-value metrics, "name.metric" otherwise
+		name, metric := key, "value"
+		if i := strings.Index(key, "."); i >= 0 {
+			name, metric = key[:i], key[i+1:]
+		}
+		if sub, ok := b.result[name].(map[string]interface{}); ok {
+			return sub[metric]
+		}
+		return nil
+	}
+
+	return func(a, b *mockBucket) bool {
+		for _, c := range criteria {
+			cmp := mockCompare(value(a, c.key), value(b, c.key))
+			if cmp != 0 {
+				return (cmp < 0) == c.asc
+			}
+		}
+		return false
+	}
+}
+
+func mockSortBuckets(buckets []*mockBucket, less func(a, b *mockBucket) bool) {
+	sort.SliceStable(buckets, func(i, j int) bool { return less(buckets[i], buckets[j]) })
+}
+
+func mockBucketResults(buckets []*mockBucket) []interface{} {
+	results := make([]interface{}, len(buckets))
+	for i, b := range buckets {
+		results[i] = b.result
+	}
+	return results
+}
+
+func mockAggTerms(opts map[string]interface{}, subAggs map[string]interface{}, docs []map[string]interface{}) (map[string]interface{}, error) {
+	size := 10
+	if n, ok := mockNumber(opts["size"]); ok {
+		size = int(n)
+	}
+	minDocCount := 1
+	if n, ok := mockNumber(opts["min_doc_count"]); ok {
+		minDocCount = int(n)
+	}
+	var include, exclude *regexp.Regexp
+	var err error
+	if s, ok := opts["include"].(string); ok {
+		if include, err = regexp.Compile("^(" + s + ")$"); err != nil {
+			return nil, err
+		}
+	}
+	if s, ok := opts["exclude"].(string); ok {
+		if exclude, err = regexp.Compile("^(" + s + ")$"); err != nil {
+			return nil, err
+		}
+	}
+
+	byKey := map[string]*mockBucket{}
+	buckets := []*mockBucket{}
+	for _, doc := range docs {
+		for _, v := range mockAggValues(opts, doc) {
+			str := fmt.Sprintf("%v", v)
+			if (include != nil && !include.MatchString(str)) || (exclude != nil && exclude.MatchString(str)) {
+				continue
+			}
+			b, ok := byKey[str]
+			if !ok {
+				b = &mockBucket{result: map[string]interface{}{"key": v}}
+				// booleans are keyed as 1 and 0, as in Elasticsearch
+				if t, isBool := v.(bool); isBool {
+					b.result["key"] = 0
+					if t {
+						b.result["key"] = 1
+					}
+					b.result["key_as_string"] = str
+				}
+				byKey[str] = b
+				buckets = append(buckets, b)
+			}
+			b.docs = append(b.docs, doc)
+		}
+	}
+
+	if err = mockFinishBuckets(buckets, subAggs); err != nil {
+		return nil, err
+	}
+	mockSortBuckets(buckets, mockBucketOrder(opts["order"], "_count", false))
+
+	kept := []*mockBucket{}
+	other := 0
+	for _, b := range buckets {
+		if len(b.docs) < minDocCount {
+			continue
+		}
+		if len(kept) < size {
+			kept = append(kept, b)
+		} else {
+			other += len(b.docs)
+		}
+	}
+
+	return map[string]interface{}{
+		"doc_count_error_upper_bound": 0,
+		"sum_other_doc_count":         other,
+		"buckets":                     mockBucketResults(kept),
+	}, nil
+}
+
+// mockParseTime reads a date as Elasticsearch's default date format does:
+// an ISO 8601 date or date-time, or milliseconds since the epoch.
+func mockParseTime(v interface{}) (time.Time, bool) {
+	if n, ok := mockNumber(v); ok {
+		return time.Unix(0, int64(n)*int64(time.Millisecond)).UTC(), true
+	}
+	s, ok := v.(string)
+	if !ok {
+		return time.Time{}, false
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(0, n*int64(time.Millisecond)).UTC(), true
+	}
+	return time.Time{}, false
+}
+
+// mockParseTimeZone accepts IANA names and offsets such as "+01:00".
+func mockParseTimeZone(tz string) (*time.Location, error) {
+	if tz == "" {
+		return time.UTC, nil
+	}
+	if strings.HasPrefix(tz, "+") || strings.HasPrefix(tz, "-") {
+		t, err := time.Parse("-07:00", tz)
+		if err != nil {
+			return nil, fmt.Errorf("invalid time_zone: %s", tz)
+		}
+		_, offset := t.Zone()
+		return time.FixedZone(tz, offset), nil
+	}
+	return time.LoadLocation(tz)
+}
+
+// mockParseInterval returns a function which truncates a time to the
+// start of its interval, and one which steps to the start of the next.
+func mockParseInterval(interval string, loc *time.Location) (func(time.Time) time.Time, func(time.Time) time.Time, error) {
+	calendar := map[string]string{
+		"year": "y", "1y": "y",
+		"quarter": "q", "1q": "q",
+		"month": "M", "1M": "M",
+		"week": "w", "1w": "w",
+		"day": "d", "1d": "d",
+		"hour": "h", "1h": "h",
+		"minute": "m", "1m": "m",
+		"second": "s", "1s": "s",
+	}
+	if unit, ok := calendar[interval]; ok {
+		trunc := func(t time.Time) time.Time {
+			t = t.In(loc)
+			y, mo, d := t.Date()
+			switch unit {
+			case "y":
+				return time.Date(y, 1, 1, 0, 0, 0, 0, loc)
+			case "q":
+				return time.Date(y, mo-(mo-1)%3, 1, 0, 0, 0, 0, loc)
+			case "M":
+				return time.Date(y, mo, 1, 0, 0, 0, 0, loc)
+			case "w":
+				// weeks start on Monday
+				return time.Date(y, mo, d-(int(t.Weekday())+6)%7, 0, 0, 0, 0, loc)
+			case "d":
+				return time.Date(y, mo, d, 0, 0, 0, 0, loc)
+			case "h":
+				return time.Date(y, mo, d, t.Hour(), 0, 0, 0, loc)
+			case "m":
+				return time.Date(y, mo, d, t.Hour(), t.Minute(), 0, 0, loc)
+			}
+			return time.Date(y, mo, d, t.Hour(), t.Minute(), t.Second(), 0, loc)
+		}
+		next := func(t time.Time) time.Time {
+			switch unit {
+			case "y":
+				return t.AddDate(1, 0, 0)
+			case "q":
+				return t.AddDate(0, 3, 0)
+			case "M":
+				return t.AddDate(0, 1, 0)
+			case "w":
+				return t.AddDate(0, 0, 7)
+			case "d":
+				return t.AddDate(0, 0, 1)
+			case "h":
+				return t.Add(time.Hour)
+			case "m":
+				return t.Add(time.Minute)
+			}
+			return t.Add(time.Second)
+		}
+		return trunc, next, nil
+	}
+
+	d, err := mockParseDuration(interval)
+	if err != nil || d <= 0 {
+		return nil, nil, fmt.Errorf("invalid interval: %s", interval)
+	}
+	trunc := func(t time.Time) time.Time {
+		_, offset := t.In(loc).Zone()
+		shift := time.Duration(offset) * time.Second
+		return t.Add(shift).Truncate(d).Add(-shift).In(loc)
+	}
+	next := func(t time.Time) time.Time {
+		return t.Add(d)
+	}
+	return trunc, next, nil
+}
+
+// mockParseDuration extends time.ParseDuration with the "d" and "w" units.
+func mockParseDuration(s string) (time.Duration, error) {
+	sign := time.Duration(1)
+	if strings.HasPrefix(s, "+") {
+		s = s[1:]
+	} else if strings.HasPrefix(s, "-") {
+		sign, s = -1, s[1:]
+	}
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if strings.HasSuffix(s, suffix) {
+			n, err := strconv.ParseFloat(strings.TrimSuffix(s, suffix), 64)
+			if err != nil {
+				return 0, err
+			}
+			return sign * time.Duration(n*float64(unit)), nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	return sign * d, err
+}
+
+func mockAggDateHistogram(opts map[string]interface{}, subAggs map[string]interface{}, docs []map[string]interface{}) (map[string]interface{}, error) {
+	if f, ok := opts["format"].(string); ok && f != "" {
+		return nil, fmt.Errorf("date_histogram format not supported under mocking")
+	}
+	tz, _ := opts["time_zone"].(string)
+	loc, err := mockParseTimeZone(tz)
+	if err != nil {
+		return nil, err
+	}
+	interval, _ := opts["interval"].(string)
+	trunc, next, err := mockParseInterval(interval, loc)
+	if err != nil {
+		return nil, err
+	}
+	var offset time.Duration
+	if s, ok := opts["offset"].(string); ok {
+		if offset, err = mockParseDuration(s); err != nil {
+			return nil, fmt.Errorf("invalid offset: %s", s)
+		}
+	}
+	minDocCount := 0
+	if n, ok := mockNumber(opts["min_doc_count"]); ok {
+		minDocCount = int(n)
+	}
+
+	start := func(t time.Time) time.Time {
+		return trunc(t.Add(-offset)).Add(offset)
+	}
+	key := func(t time.Time) int64 {
+		return t.UnixNano() / int64(time.Millisecond)
+	}
+
+	byKey := map[int64]*mockBucket{}
+	var first, last *time.Time
+	extend := func(t time.Time) {
+		if first == nil || t.Before(*first) {
+			first = &t
+		}
+		if last == nil || t.After(*last) {
+			last = &t
+		}
+	}
+	newBucket := func(t time.Time) *mockBucket {
+		return &mockBucket{result: map[string]interface{}{
+			"key":           key(t),
+			"key_as_string": t.In(loc).Format("2006-01-02T15:04:05.000Z07:00"),
+		}}
+	}
+
+	for _, doc := range docs {
+		for _, v := range mockAggValues(opts, doc) {
+			t, ok := mockParseTime(v)
+			if !ok {
+				return nil, fmt.Errorf("date_histogram: invalid date %v", v)
+			}
+			t = start(t)
+			b, ok := byKey[key(t)]
+			if !ok {
+				b = newBucket(t)
+				byKey[key(t)] = b
+				extend(t)
+			}
+			b.docs = append(b.docs, doc)
+		}
+	}
+
+	if bounds, ok := opts["extended_bounds"].(map[string]interface{}); ok && minDocCount == 0 {
+		for _, bound := range []interface{}{bounds["min"], bounds["max"]} {
+			if bound == nil {
+				continue
+			}
+			t, ok := mockParseTime(bound)
+			if !ok {
+				return nil, fmt.Errorf("date_histogram: invalid extended bound %v", bound)
+			}
+			extend(start(t))
+		}
+	}
+
+	buckets := []*mockBucket{}
+	if first != nil {
+		for t := *first; !t.After(*last); t = start(next(t.Add(-offset)).Add(offset)) {
+			b, ok := byKey[key(t)]
+			if !ok {
+				b = newBucket(t)
+			}
+			if len(b.docs) >= minDocCount {
+				buckets = append(buckets, b)
+			}
+		}
+	}
+
+	if err = mockFinishBuckets(buckets, subAggs); err != nil {
+		return nil, err
+	}
+	mockSortBuckets(buckets, mockBucketOrder(opts["order"], "_key", true))
+
+	return map[string]interface{}{"buckets": mockBucketResults(buckets)}, nil
+}
+
+const mockGeohashBase32 = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// mockGeohash encodes a point as a geohash of the given length.
+func mockGeohash(pt mockPoint, precision int) string {
+	latLo, latHi := -90.0, 90.0
+	lonLo, lonHi := -180.0, 180.0
+	hash := make([]byte, 0, precision)
+	bits, ch := 0, 0
+	even := true
+	for len(hash) < precision {
+		if even {
+			mid := (lonLo + lonHi) / 2
+			if pt.lon >= mid {
+				ch = ch<<1 | 1
+				lonLo = mid
+			} else {
+				ch = ch << 1
+				lonHi = mid
+			}
+		} else {
+			mid := (latLo + latHi) / 2
+			if pt.lat >= mid {
+				ch = ch<<1 | 1
+				latLo = mid
+			} else {
+				ch = ch << 1
+				latHi = mid
+			}
+		}
+		even = !even
+		bits++
+		if bits == 5 {
+			hash = append(hash, mockGeohashBase32[ch])
+			bits, ch = 0, 0
+		}
+	}
+	return string(hash)
+}
+
+func mockAggGeoHashGrid(opts map[string]interface{}, subAggs map[string]interface{}, docs []map[string]interface{}) (map[string]interface{}, error) {
+	field, _ := opts["field"].(string)
+	precision := 5
+	if n, ok := mockNumber(opts["precision"]); ok {
+		precision = int(n)
+	}
+	if precision < 1 || precision > 12 {
+		return nil, fmt.Errorf("geohash_grid: invalid precision %d", precision)
+	}
+	size := 10000
+	if n, ok := mockNumber(opts["size"]); ok {
+		size = int(n)
+	}
+
+	byKey := map[string]*mockBucket{}
+	buckets := []*mockBucket{}
+	for _, doc := range docs {
+		seen := map[string]bool{}
+		for _, pt := range mockGeoPoints(doc, field) {
+			hash := mockGeohash(pt, precision)
+			if seen[hash] {
+				continue
+			}
+			seen[hash] = true
+			b, ok := byKey[hash]
+			if !ok {
+				b = &mockBucket{result: map[string]interface{}{"key": hash}}
+				byKey[hash] = b
+				buckets = append(buckets, b)
+			}
+			b.docs = append(b.docs, doc)
+		}
+	}
+
+	if err := mockFinishBuckets(buckets, subAggs); err != nil {
+		return nil, err
+	}
+	mockSortBuckets(buckets, mockBucketOrder(nil, "_count", false))
+	if len(buckets) > size {
+		buckets = buckets[:size]
+	}
+
+	return map[string]interface{}{"buckets": mockBucketResults(buckets)}, nil
+}
+
+func mockAggStats(opts map[string]interface{}, docs []map[string]interface{}) (map[string]interface{}, error) {
+	field, _ := opts["field"].(string)
+	count := 0
+	sum := 0.0
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, doc := range docs {
+		values := mockLookup(doc, field)
+		if len(values) == 0 {
+			if missing, ok := opts["missing"]; ok {
+				values = []interface{}{missing}
+			}
+		}
+		for _, v := range values {
+			n, ok := mockNumber(v)
+			if !ok {
+				return nil, fmt.Errorf("stats on %s: %v is not a number", field, v)
+			}
+			count++
+			sum += n
+			min = math.Min(min, n)
+			max = math.Max(max, n)
+		}
+	}
+
+	result := map[string]interface{}{
+		"count": count,
+		"min":   nil,
+		"max":   nil,
+		"avg":   nil,
+		"sum":   sum,
+	}
+	if count > 0 {
+		result["min"] = min
+		result["max"] = max
+		result["avg"] = sum / float64(count)
+	}
+	return result, nil
+}
+
+func mockAggCardinality(opts map[string]interface{}, docs []map[string]interface{}) (map[string]interface{}, error) {
+	seen := map[string]bool{}
+	for _, doc := range docs {
+		for _, v := range mockAggValues(opts, doc) {
+			seen[fmt.Sprintf("%v", v)] = true
+		}
+	}
+	return map[string]interface{}{"value": len(seen)}, nil
+}
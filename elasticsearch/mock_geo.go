@@ -0,0 +1,525 @@
+// Copyright 2016, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elasticsearch
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// In-process geometry for the mock query evaluator. Distances are computed
+// on a sphere and polygons are treated as planar in (lon, lat), which is
+// close enough to what Elasticsearch does for test-sized shapes.
+
+// mockEarthRadius is the mean earth radius, in meters, that Elasticsearch uses.
+const mockEarthRadius = 6371008.7714
+
+type mockPoint struct {
+	lat float64
+	lon float64
+}
+
+// mockShape is a geo_shape reduced to what the relations need: its
+// vertices, its edges, and, for polygons and envelopes, its outer ring.
+type mockShape struct {
+	points []mockPoint
+	edges  [][2]mockPoint
+	ring   []mockPoint
+}
+
+// mockParsePoint accepts the geo_point forms {"lat": ..., "lon": ...},
+// [lon, lat] and "lat,lon".
+func mockParsePoint(v interface{}) (mockPoint, bool) {
+	switch p := v.(type) {
+	case map[string]interface{}:
+		lat, latok := mockNumber(p["lat"])
+		lon, lonok := mockNumber(p["lon"])
+		return mockPoint{lat: lat, lon: lon}, latok && lonok
+	case []interface{}:
+		if len(p) != 2 {
+			return mockPoint{}, false
+		}
+		lon, lonok := mockNumber(p[0])
+		lat, latok := mockNumber(p[1])
+		return mockPoint{lat: lat, lon: lon}, latok && lonok
+	case string:
+		parts := strings.Split(p, ",")
+		if len(parts) != 2 {
+			return mockPoint{}, false
+		}
+		lat, laterr := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+		lon, lonerr := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		return mockPoint{lat: lat, lon: lon}, laterr == nil && lonerr == nil
+	}
+	return mockPoint{}, false
+}
+
+// mockGeoPoints returns the geo_points at a dotted field path. Unlike
+// mockLookup, it doesn't flatten the [lon, lat] form into two numbers.
+func mockGeoPoints(doc map[string]interface{}, field string) []mockPoint {
+	containers := []interface{}{doc}
+	leaf := field
+	if i := strings.LastIndex(field, "."); i >= 0 {
+		containers = mockLookup(doc, field[:i])
+		leaf = field[i+1:]
+	}
+
+	points := []mockPoint{}
+	var collect func(v interface{})
+	collect = func(v interface{}) {
+		if pt, ok := mockParsePoint(v); ok {
+			points = append(points, pt)
+			return
+		}
+		if arr, ok := v.([]interface{}); ok {
+			for _, e := range arr {
+				collect(e)
+			}
+		}
+	}
+	for _, c := range containers {
+		if m, ok := c.(map[string]interface{}); ok && m[leaf] != nil {
+			collect(m[leaf])
+		}
+	}
+	return points
+}
+
+// mockGeoFieldClause returns the field name and body of a geo query clause,
+// skipping the query's own parameters.
+func mockGeoFieldClause(body interface{}, params ...string) (string, interface{}, error) {
+	m, ok := body.(map[string]interface{})
+	if !ok {
+		return "", nil, fmt.Errorf("malformed geo query clause: %v", body)
+	}
+outer:
+	for field, value := range m {
+		if strings.HasPrefix(field, "_") {
+			continue
+		}
+		for _, p := range params {
+			if field == p {
+				continue outer
+			}
+		}
+		return field, value, nil
+	}
+	return "", nil, fmt.Errorf("geo query clause has no field: %v", body)
+}
+
+var mockDistanceUnits = map[string]float64{
+	"mm": 0.001, "millimeters": 0.001,
+	"cm": 0.01, "centimeters": 0.01,
+	"m": 1, "meters": 1,
+	"km": 1000, "kilometers": 1000,
+	"in": 0.0254, "inch": 0.0254,
+	"ft": 0.3048, "feet": 0.3048,
+	"yd": 0.9144, "yards": 0.9144,
+	"mi": 1609.344, "miles": 1609.344,
+	"nmi": 1852, "NM": 1852, "nauticalmiles": 1852,
+}
+
+// mockParseDistance converts a distance such as "12km" to meters. Bare
+// numbers are meters.
+func mockParseDistance(v interface{}) (float64, error) {
+	if n, ok := mockNumber(v); ok {
+		return n, nil
+	}
+	str, ok := v.(string)
+	if !ok {
+		return 0, fmt.Errorf("invalid distance: %v", v)
+	}
+	str = strings.TrimSpace(str)
+	i := strings.IndexFunc(str, func(r rune) bool {
+		return !(r >= '0' && r <= '9') && r != '.' && r != '-'
+	})
+	if i < 0 {
+		i = len(str)
+	}
+	n, err := strconv.ParseFloat(str[:i], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid distance: %s", str)
+	}
+	unit := str[i:]
+	if unit == "" {
+		return n, nil
+	}
+	factor, ok := mockDistanceUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("invalid distance unit: %s", str)
+	}
+	return n * factor, nil
+}
+
+// mockDistance returns the haversine distance between two points, in meters.
+func mockDistance(a, b mockPoint) float64 {
+	rad := math.Pi / 180
+	dlat := (b.lat - a.lat) * rad
+	dlon := (b.lon - a.lon) * rad
+	h := math.Sin(dlat/2)*math.Sin(dlat/2) +
+		math.Cos(a.lat*rad)*math.Cos(b.lat*rad)*math.Sin(dlon/2)*math.Sin(dlon/2)
+	return 2 * mockEarthRadius * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+func mockMatchGeoDistance(body interface{}, doc map[string]interface{}) (bool, error) {
+	field, value, err := mockGeoFieldClause(body, "distance", "distance_type", "optimize_bbox", "validation_method")
+	if err != nil {
+		return false, err
+	}
+	center, ok := mockParsePoint(value)
+	if !ok {
+		return false, fmt.Errorf("geo_distance on %s: unsupported center %v", field, value)
+	}
+	radius, err := mockParseDistance(body.(map[string]interface{})["distance"])
+	if err != nil {
+		return false, err
+	}
+
+	for _, pt := range mockGeoPoints(doc, field) {
+		if mockDistance(center, pt) <= radius {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func mockMatchGeoBoundingBox(body interface{}, doc map[string]interface{}) (bool, error) {
+	field, value, err := mockGeoFieldClause(body, "type", "validation_method")
+	if err != nil {
+		return false, err
+	}
+	box, ok := value.(map[string]interface{})
+	if !ok {
+		return false, fmt.Errorf("geo_bounding_box on %s: malformed box %v", field, value)
+	}
+
+	var top, left, bottom, right float64
+	if tl, ok := mockParsePoint(box["top_left"]); ok {
+		br, ok := mockParsePoint(box["bottom_right"])
+		if !ok {
+			return false, fmt.Errorf("geo_bounding_box on %s: bottom_right is required", field)
+		}
+		top, left, bottom, right = tl.lat, tl.lon, br.lat, br.lon
+	} else if tr, ok := mockParsePoint(box["top_right"]); ok {
+		bl, ok := mockParsePoint(box["bottom_left"])
+		if !ok {
+			return false, fmt.Errorf("geo_bounding_box on %s: bottom_left is required", field)
+		}
+		top, left, bottom, right = tr.lat, bl.lon, bl.lat, tr.lon
+	} else {
+		var oks [4]bool
+		top, oks[0] = mockNumber(box["top"])
+		left, oks[1] = mockNumber(box["left"])
+		bottom, oks[2] = mockNumber(box["bottom"])
+		right, oks[3] = mockNumber(box["right"])
+		if !oks[0] || !oks[1] || !oks[2] || !oks[3] {
+			return false, fmt.Errorf("geo_bounding_box on %s: unsupported box %v", field, box)
+		}
+	}
+
+	for _, pt := range mockGeoPoints(doc, field) {
+		if pt.lat > top || pt.lat < bottom {
+			continue
+		}
+		// a box whose left edge is east of its right edge crosses the dateline
+		if left <= right && (pt.lon < left || pt.lon > right) {
+			continue
+		}
+		if left > right && pt.lon < left && pt.lon > right {
+			continue
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+func mockMatchGeoPolygon(body interface{}, doc map[string]interface{}) (bool, error) {
+	field, value, err := mockGeoFieldClause(body, "validation_method")
+	if err != nil {
+		return false, err
+	}
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return false, fmt.Errorf("geo_polygon on %s: malformed polygon %v", field, value)
+	}
+	ring := []mockPoint{}
+	for _, v := range mockFlattenPoints(m["points"]) {
+		pt, ok := mockParsePoint(v)
+		if !ok {
+			return false, fmt.Errorf("geo_polygon on %s: unsupported point %v", field, v)
+		}
+		ring = append(ring, pt)
+	}
+	if len(ring) < 3 {
+		return false, fmt.Errorf("geo_polygon on %s: at least three points are required", field)
+	}
+
+	for _, pt := range mockGeoPoints(doc, field) {
+		if mockInRing(ring, pt) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// mockFlattenPoints returns the elements of an array of points.
+func mockFlattenPoints(v interface{}) []interface{} {
+	arr, ok := v.([]interface{})
+	if !ok {
+		return []interface{}{}
+	}
+	return arr
+}
+
+func mockMatchGeoShape(body interface{}, doc map[string]interface{}) (bool, error) {
+	field, value, err := mockGeoFieldClause(body, "ignore_unmapped", "boost")
+	if err != nil {
+		return false, err
+	}
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return false, fmt.Errorf("geo_shape on %s: malformed clause %v", field, value)
+	}
+	if _, ok := m["indexed_shape"]; ok {
+		return false, fmt.Errorf("geo_shape on %s: indexed_shape not supported under mocking", field)
+	}
+	shape, err := mockParseShape(m["shape"])
+	if err != nil {
+		return false, err
+	}
+	relation := "intersects"
+	if r, ok := m["relation"].(string); ok {
+		relation = strings.ToLower(r)
+	}
+
+	for _, v := range mockLookup(doc, field) {
+		target, err := mockParseShape(v)
+		if err != nil {
+			continue
+		}
+		var ok bool
+		switch relation {
+		case "intersects":
+			ok = mockIntersects(target, shape)
+		case "disjoint":
+			ok = !mockIntersects(target, shape)
+		case "within":
+			ok = mockCovers(shape, target)
+		case "contains":
+			ok = mockCovers(target, shape)
+		default:
+			return false, fmt.Errorf("geo_shape on %s: unknown relation %s", field, relation)
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// mockParseShape reads a GeoJSON-like shape: point, multipoint, linestring,
+// multilinestring, polygon (outer ring only) or envelope.
+func mockParseShape(v interface{}) (*mockShape, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("malformed shape: %v", v)
+	}
+	typ, _ := m["type"].(string)
+	coords := m["coordinates"]
+
+	line := func(v interface{}) ([]mockPoint, error) {
+		pts := []mockPoint{}
+		for _, e := range mockFlattenPoints(v) {
+			pt, ok := mockParsePoint(e)
+			if !ok {
+				return nil, fmt.Errorf("malformed coordinates: %v", e)
+			}
+			pts = append(pts, pt)
+		}
+		return pts, nil
+	}
+
+	shape := &mockShape{}
+	addLine := func(pts []mockPoint) {
+		shape.points = append(shape.points, pts...)
+		for i := 1; i < len(pts); i++ {
+			shape.edges = append(shape.edges, [2]mockPoint{pts[i-1], pts[i]})
+		}
+	}
+
+	switch strings.ToLower(typ) {
+	case "point":
+		pt, ok := mockParsePoint(coords)
+		if !ok {
+			return nil, fmt.Errorf("malformed point: %v", coords)
+		}
+		shape.points = []mockPoint{pt}
+	case "multipoint":
+		pts, err := line(coords)
+		if err != nil {
+			return nil, err
+		}
+		shape.points = pts
+	case "linestring":
+		pts, err := line(coords)
+		if err != nil {
+			return nil, err
+		}
+		addLine(pts)
+	case "multilinestring":
+		for _, l := range mockFlattenPoints(coords) {
+			pts, err := line(l)
+			if err != nil {
+				return nil, err
+			}
+			addLine(pts)
+		}
+	case "polygon":
+		rings := mockFlattenPoints(coords)
+		if len(rings) == 0 {
+			return nil, fmt.Errorf("malformed polygon: %v", coords)
+		}
+		pts, err := line(rings[0])
+		if err != nil {
+			return nil, err
+		}
+		addLine(pts)
+		shape.ring = pts
+	case "envelope":
+		pts, err := line(coords)
+		if err != nil || len(pts) != 2 {
+			return nil, fmt.Errorf("malformed envelope: %v", coords)
+		}
+		tl, br := pts[0], pts[1]
+		ring := []mockPoint{
+			tl,
+			{lat: tl.lat, lon: br.lon},
+			br,
+			{lat: br.lat, lon: tl.lon},
+			tl,
+		}
+		addLine(ring)
+		shape.ring = ring
+	default:
+		return nil, fmt.Errorf("shape type %s not supported under mocking", typ)
+	}
+	return shape, nil
+}
+
+// mockCovers reports whether every part of b lies within a.
+func mockCovers(a, b *mockShape) bool {
+	for _, pt := range b.points {
+		if !mockShapeHasPoint(a, pt) {
+			return false
+		}
+	}
+	if a.ring == nil {
+		return true
+	}
+	for _, eb := range b.edges {
+		for _, ea := range a.edges {
+			if mockSegmentsCross(ea, eb) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func mockIntersects(a, b *mockShape) bool {
+	for _, pt := range b.points {
+		if mockShapeHasPoint(a, pt) {
+			return true
+		}
+	}
+	for _, pt := range a.points {
+		if mockShapeHasPoint(b, pt) {
+			return true
+		}
+	}
+	for _, ea := range a.edges {
+		for _, eb := range b.edges {
+			if mockSegmentsCross(ea, eb) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func mockShapeHasPoint(s *mockShape, pt mockPoint) bool {
+	if s.ring != nil {
+		return mockInRing(s.ring, pt)
+	}
+	for _, e := range s.edges {
+		if mockOnSegment(e, pt) {
+			return true
+		}
+	}
+	for _, p := range s.points {
+		if p == pt {
+			return true
+		}
+	}
+	return false
+}
+
+// mockInRing reports whether the point is inside or on the boundary of the
+// ring, by ray casting.
+func mockInRing(ring []mockPoint, pt mockPoint) bool {
+	inside := false
+	n := len(ring)
+	for i, j := 0, n-1; i < n; j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if mockOnSegment([2]mockPoint{a, b}, pt) {
+			return true
+		}
+		if (a.lat > pt.lat) != (b.lat > pt.lat) &&
+			pt.lon < (b.lon-a.lon)*(pt.lat-a.lat)/(b.lat-a.lat)+a.lon {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// mockOrientation returns the sign of the cross product (b-a)x(c-a).
+func mockOrientation(a, b, c mockPoint) int {
+	v := (b.lon-a.lon)*(c.lat-a.lat) - (b.lat-a.lat)*(c.lon-a.lon)
+	switch {
+	case v > 0:
+		return 1
+	case v < 0:
+		return -1
+	}
+	return 0
+}
+
+func mockOnSegment(e [2]mockPoint, pt mockPoint) bool {
+	a, b := e[0], e[1]
+	return mockOrientation(a, b, pt) == 0 &&
+		pt.lon >= math.Min(a.lon, b.lon) && pt.lon <= math.Max(a.lon, b.lon) &&
+		pt.lat >= math.Min(a.lat, b.lat) && pt.lat <= math.Max(a.lat, b.lat)
+}
+
+// mockSegmentsCross reports whether two segments properly cross each other.
+func mockSegmentsCross(e, f [2]mockPoint) bool {
+	o1 := mockOrientation(e[0], e[1], f[0])
+	o2 := mockOrientation(e[0], e[1], f[1])
+	o3 := mockOrientation(f[0], f[1], e[0])
+	o4 := mockOrientation(f[0], f[1], e[1])
+	return o1*o2 < 0 && o3*o4 < 0
+}
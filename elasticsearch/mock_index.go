@@ -16,46 +16,196 @@ package elasticsearch
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
+	"net/http"
+	"reflect"
 	"sort"
 	"strconv"
+	"strings"
+	"sync"
 
+	"github.com/pkg/errors"
+	"github.com/venicegeo/pz-gocommon/elasticsearch/elastic-5-api"
 	"github.com/venicegeo/pz-gocommon/gocommon"
+	"golang.org/x/net/context"
 )
 
-const percolateTypeName = ".percolate"
-
 type MockIndexType struct {
 	// maps from id string to document body
 	items map[string]*json.RawMessage
 
+	// maps from id string to document version
+	versions map[string]int
+
 	mapping interface{}
 }
 
+func newMockIndexType(mapping interface{}) *MockIndexType {
+	return &MockIndexType{
+		items:    make(map[string]*json.RawMessage),
+		versions: make(map[string]int),
+		mapping:  mapping,
+	}
+}
+
 var _ IIndex = (*MockIndex)(nil)
 
+// MockIndex is safe for concurrent use.
 type MockIndex struct {
+	*mockIndexData
+
+	ctx context.Context
+}
+
+// mockIndexData is the state shared by a MockIndex and its WithContext views.
+// mu guards everything but name and alias, which don't change. No operation
+// holds the locks of two indices, or mu and the lock of mockAliases or
+// mockTemplates, at once.
+type mockIndexData struct {
+	mu sync.Mutex
+
 	name     string
 	types    map[string]*MockIndexType
 	exists   bool
 	open     bool
-	settings interface{}
+	settings *IndexSettings
 	idSource int
+
+	// alias is set when the data stands for an alias over several indices.
+	// It has no types of its own; reads go to the indices the alias points
+	// to when they are made, and writes fail, as they do in Elasticsearch.
+	alias string
+
+	// nrt is set in near-real-time mode; see SetNearRealTime.
+	nrt *mockRefresher
+
+	// journal, if set, is told of each change before it is made; see
+	// FileIndex.
+	journal *fileJournal
+}
+
+// mockAliases maps each alias to the indices it points to. Like those of a
+// cluster, aliases are seen by every MockIndex.
+var mockAliases = struct {
+	sync.Mutex
+	indices map[string][]*mockIndexData
+}{indices: make(map[string][]*mockIndexData)}
+
+// mockTemplates holds the index templates, which, like those of a cluster,
+// apply to every MockIndex created afterwards.
+var mockTemplates = struct {
+	sync.Mutex
+	templates map[string]*IndexTemplate
+}{templates: make(map[string]*IndexTemplate)}
+
+// ResetMockCluster forgets the aliases and templates every MockIndex sees,
+// so that tests using the same names don't see each other's. Indices keep
+// the templates already applied to them.
+func ResetMockCluster() {
+	mockAliases.Lock()
+	mockAliases.indices = make(map[string][]*mockIndexData)
+	mockAliases.Unlock()
+
+	mockTemplates.Lock()
+	mockTemplates.templates = make(map[string]*IndexTemplate)
+	mockTemplates.Unlock()
+}
+
+// mockTemplatesFor returns the templates matching the index name, in the
+// order they are applied.
+func mockTemplatesFor(index string) []*IndexTemplate {
+	mockTemplates.Lock()
+	defer mockTemplates.Unlock()
+
+	names := []string{}
+	for name, t := range mockTemplates.templates {
+		if t.Matches(index) {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := mockTemplates.templates[names[i]], mockTemplates.templates[names[j]]
+		if a.order != b.order {
+			return a.order < b.order
+		}
+		return names[i] < names[j]
+	})
+
+	templates := make([]*IndexTemplate, len(names))
+	for i, name := range names {
+		templates[i] = mockTemplates.templates[name]
+	}
+	return templates
 }
 
+// NewMockIndex returns an index which keeps its documents in memory. If
+// indexName is an alias pointing to a single index, the MockIndex shares that
+// index's data; the alias is resolved here, not on every operation. If it
+// points to several, the MockIndex searches them all but can't be written.
 func NewMockIndex(indexName string) *MockIndex {
 	var _ IIndex = new(MockIndex)
 
+	mockAliases.Lock()
+	indices := mockAliases.indices[indexName]
+	mockAliases.Unlock()
+	if len(indices) == 1 {
+		return &MockIndex{mockIndexData: indices[0], ctx: context.Background()}
+	}
+	if len(indices) > 1 {
+		data := &mockIndexData{
+			name:   indexName,
+			types:  make(map[string]*MockIndexType),
+			exists: true,
+			open:   true,
+			alias:  indexName,
+		}
+		return &MockIndex{mockIndexData: data, ctx: context.Background()}
+	}
+
 	esi := MockIndex{
-		name:   indexName,
-		types:  make(map[string]*MockIndexType),
-		exists: false,
-		open:   false,
+		mockIndexData: &mockIndexData{
+			name:   indexName,
+			types:  make(map[string]*MockIndexType),
+			exists: false,
+			open:   false,
+		},
+		ctx: context.Background(),
 	}
 	return &esi
 }
 
+// NewMockIndexOpener returns an IndexOpener for MockIndexes. An index is
+// made when first opened and shared from then on; an alias is resolved each
+// time it is opened.
+func NewMockIndexOpener() IndexOpener {
+	var mu sync.Mutex
+	indices := map[string]*MockIndex{}
+
+	return func(name string) (IIndex, error) {
+		mockAliases.Lock()
+		_, isAlias := mockAliases.indices[name]
+		mockAliases.Unlock()
+		if isAlias {
+			return NewMockIndex(name), nil
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		esi, ok := indices[name]
+		if !ok {
+			esi = NewMockIndex(name)
+			indices[name] = esi
+		}
+		return esi, nil
+	}
+}
+
+// WithContext returns a view of the index whose operations fail with the
+// context's error once it is cancelled or its deadline has passed.
+func (esi *MockIndex) WithContext(ctx context.Context) IIndex {
+	return &MockIndex{mockIndexData: esi.mockIndexData, ctx: ctx}
+}
+
 func (esi *MockIndex) GetVersion() string {
 	return "2.2.0"
 }
@@ -64,7 +214,18 @@ func (esi *MockIndex) IndexName() string {
 	return esi.name
 }
 
+// ctxErr returns the error of the MockIndex's context, if it is done, as
+// Index would report it.
+func (esi *MockIndex) ctxErr() error {
+	return typedError(esi.ctx.Err())
+}
+
 func (esi *MockIndex) IndexExists() (bool, error) {
+	if err := esi.ctxErr(); err != nil {
+		return false, err
+	}
+	esi.mu.Lock()
+	defer esi.mu.Unlock()
 	return esi.exists, nil
 }
 
@@ -77,51 +238,115 @@ func (esi *MockIndex) TypeExists(typ string) (bool, error) {
 	if !ok {
 		return false, nil
 	}
-	_, ok = esi.types[typ]
-	return ok, nil
+	for _, data := range esi.members() {
+		if data.hasType(typ) {
+			return true, nil
+		}
+	}
+	return false, nil
 }
 
-func (esi *MockIndex) ItemExists(typeName string, id string) (bool, error) {
+func (data *mockIndexData) hasType(typeName string) bool {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+	_, ok := data.types[typeName]
+	return ok && data.exists
+}
+
+// members returns the data of the indices the MockIndex reads from: its
+// own, or that of each index its alias points to.
+func (esi *MockIndex) members() []*mockIndexData {
+	if esi.alias == "" {
+		return []*mockIndexData{esi.mockIndexData}
+	}
+	mockAliases.Lock()
+	defer mockAliases.Unlock()
+	return append([]*mockIndexData{}, mockAliases.indices[esi.alias]...)
+}
+
+// single fails if the MockIndex stands for an alias over several indices,
+// which Elasticsearch won't write to or get documents from by id.
+func (esi *MockIndex) single() error {
+	if esi.alias != "" {
+		return fmt.Errorf("alias [%s] has more than one index associated with it", esi.alias)
+	}
+	return nil
+}
+
+// requireType returns nil if the type exists and otherwise an error whose
+// cause is ErrTypeNotFound or ErrIndexNotFound, as Index.requireType does.
+func (esi *MockIndex) requireType(typeName string) error {
 	ok, err := esi.TypeExists(typeName)
-	if err != nil {
-		return false, err
+	if err != nil || ok {
+		return err
+	}
+	if ok, err = esi.IndexExists(); err != nil {
+		return err
 	}
 	if !ok {
-		return false, nil
+		return indexNotFoundError(esi.name)
 	}
-	typ := esi.types[typeName]
-	_, ok = (*typ).items[id]
-	return ok, nil
+	return typeNotFoundError(esi.name, typeName)
 }
 
-// if index already exists, does nothing
-func (esi *MockIndex) Create(settings string) error {
-	if esi.exists {
-		return fmt.Errorf("Index already exists")
+func (esi *MockIndex) ItemExists(typeName string, id string) (bool, error) {
+	if err := esi.single(); err != nil {
+		return false, err
+	}
+	if err := esi.ctxErr(); err != nil {
+		return false, err
 	}
 
-	esi.exists = true
-
-	if settings == "" {
-		esi.settings = nil
-		return nil
+	esi.mu.Lock()
+	defer esi.mu.Unlock()
+	typ, ok := esi.types[typeName]
+	if !ok || !esi.exists {
+		return false, nil
 	}
+	_, ok = typ.items[id]
+	return ok, nil
+}
 
-	obj := map[string]interface{}{}
-	err := json.Unmarshal([]byte(settings), &obj)
+// Create makes the index, applying first the templates whose patterns
+// match its name, lowest order first, and then the settings given.
+func (esi *MockIndex) Create(settings string) error {
+	if err := esi.ctxErr(); err != nil {
+		return err
+	}
+	templates := mockTemplatesFor(esi.name)
+	mappings, aliases, err := esi.create(settings, templates)
 	if err != nil {
 		return err
 	}
 
-	esi.settings = obj
+	for _, t := range templates {
+		for _, m := range t.settings.GetMappings() {
+			jsn, err := m.JSON()
+			if err != nil {
+				return err
+			}
+			if err = esi.SetMapping(m.Name(), jsn); err != nil {
+				return err
+			}
+		}
+		for _, alias := range t.aliases {
+			if err = esi.AddAlias(alias); err != nil {
+				return err
+			}
+		}
+	}
 
-	for k, v := range obj["mappings"].(map[string]interface{}) {
+	for k, v := range mappings {
 		mapping, err := json.Marshal(v)
 		if err != nil {
 			return err
 		}
-		err = esi.addType(k, string(mapping))
-		if err != nil {
+		if err = esi.SetMapping(k, piazza.JsonString(mapping)); err != nil {
+			return err
+		}
+	}
+	for alias := range aliases {
+		if err = esi.AddAlias(alias); err != nil {
 			return err
 		}
 	}
@@ -129,298 +354,1334 @@ func (esi *MockIndex) Create(settings string) error {
 	return nil
 }
 
-// if index doesn't already exist, does nothing
+// create marks the index as made, with the settings of the templates and
+// then its own, and returns the mappings and aliases it is to have.
+func (esi *MockIndex) create(settings string, templates []*IndexTemplate) (map[string]interface{}, map[string]interface{}, error) {
+	esi.mu.Lock()
+	defer esi.mu.Unlock()
+	if esi.exists {
+		return nil, nil, fmt.Errorf("Index already exists")
+	}
+
+	obj := map[string]interface{}{}
+	if settings != "" {
+		err := json.Unmarshal([]byte(settings), &obj)
+		if err != nil {
+			return nil, nil, errors.WithMessage(ErrInvalidMapping, err.Error())
+		}
+	}
+	own, err := ParseIndexSettings(obj["settings"])
+	if err != nil {
+		return nil, nil, err
+	}
+	mappings, ok := obj["mappings"].(map[string]interface{})
+	if !ok && obj["mappings"] != nil {
+		return nil, nil, errors.WithMessage(ErrInvalidMapping, fmt.Sprintf("malformed mappings: %v", obj["mappings"]))
+	}
+	aliases, ok := obj["aliases"].(map[string]interface{})
+	if !ok && obj["aliases"] != nil {
+		return nil, nil, fmt.Errorf("malformed aliases: %v", obj["aliases"])
+	}
+
+	merged := NewIndexSettings()
+	for _, t := range templates {
+		merged.merge(t.settings)
+	}
+	merged.merge(own)
+	if err = esi.record(&journalEntry{Op: "create", Settings: merged.indexSource()}); err != nil {
+		return nil, nil, err
+	}
+	esi.exists = true
+	esi.settings = merged
+	return mappings, aliases, nil
+}
+
+// Close closes the index. If it doesn't exist, the error's cause is
+// ErrIndexNotFound.
 func (esi *MockIndex) Close() error {
+	if err := esi.ctxErr(); err != nil {
+		return err
+	}
+	esi.mu.Lock()
+	defer esi.mu.Unlock()
+	if !esi.exists {
+		return indexNotFoundError(esi.name)
+	}
 	esi.open = false
 	return nil
 }
 
-// if index doesn't already exist, does nothing
+// Delete deletes the index and its documents. If it doesn't exist, the
+// error's cause is ErrIndexNotFound.
 func (esi *MockIndex) Delete() error {
+	if err := esi.ctxErr(); err != nil {
+		return err
+	}
+	if err := esi.single(); err != nil {
+		return err
+	}
+
+	esi.mu.Lock()
+	if !esi.exists {
+		esi.mu.Unlock()
+		return indexNotFoundError(esi.name)
+	}
+	if err := esi.record(&journalEntry{Op: "drop"}); err != nil {
+		esi.mu.Unlock()
+		return err
+	}
 	esi.exists = false
 	esi.open = false
+	esi.types = make(map[string]*MockIndexType)
+	if esi.nrt != nil {
+		esi.nrt.reset()
+	}
+	esi.mu.Unlock()
 
-	for tk, tv := range esi.types {
-		for ik := range tv.items {
-			delete(tv.items, ik)
-		}
-		delete(esi.types, tk)
+	mockAliases.Lock()
+	for alias := range mockAliases.indices {
+		esi.unalias(alias)
 	}
+	mockAliases.Unlock()
 
 	return nil
 }
 
+// addType must be called with esi.mu held.
 func (esi *MockIndex) addType(typeName string, mapping string) error {
 
 	if mapping == "" {
-		return fmt.Errorf("addType: mapping may not be null")
+		return errors.WithMessage(ErrInvalidMapping, "addType: mapping may not be null")
 	}
 
 	obj := map[string]interface{}{}
 	err := json.Unmarshal([]byte(mapping), &obj)
 	if err != nil {
-		return err
+		return errors.WithMessage(ErrInvalidMapping, err.Error())
 	}
 
-	esi.types[typeName] = &MockIndexType{
-		mapping: obj,
-		items:   make(map[string]*json.RawMessage),
+	if err = esi.record(&journalEntry{Op: "mapping", Type: typeName, Mapping: obj}); err != nil {
+		return err
 	}
+	esi.types[typeName] = newMockIndexType(obj)
 
 	return nil
 }
 
+// SetMapping creates the type or, like Elasticsearch, merges the mapping
+// into that of the existing type, refusing changes that can't be made in
+// place.
 func (esi *MockIndex) SetMapping(typeName string, mapping piazza.JsonString) error {
-	return esi.addType(typeName, string(mapping))
+	if err := esi.ctxErr(); err != nil {
+		return err
+	}
+	if err := esi.single(); err != nil {
+		return err
+	}
+
+	esi.mu.Lock()
+	defer esi.mu.Unlock()
+	if !esi.exists {
+		return indexNotFoundError(esi.name)
+	}
+	typ, ok := esi.types[typeName]
+	if !ok {
+		return esi.addType(typeName, string(mapping))
+	}
+
+	current, err := ParseMapping(typeName, typ.mapping)
+	if err != nil {
+		return err
+	}
+	wanted, err := ParseMapping(typeName, mapping)
+	if err != nil {
+		return errors.WithMessage(ErrInvalidMapping, err.Error())
+	}
+	if diff := CompareMappings(current, wanted); diff.Change == MappingIncompatible {
+		return errors.WithMessage(ErrInvalidMapping,
+			fmt.Sprintf("mapper conflicts in type %s: %s", typeName, strings.Join(diff.Conflicts, "; ")))
+	}
+
+	obj := map[string]interface{}{}
+	err = json.Unmarshal([]byte(mapping), &obj)
+	if err != nil {
+		return err
+	}
+	merged := mockMerge(mockMappingBody(typeName, typ.mapping), mockMappingBody(typeName, obj))
+	if err = esi.record(&journalEntry{Op: "mapping", Type: typeName, Mapping: merged}); err != nil {
+		return err
+	}
+	typ.mapping = merged
+	return nil
+}
+
+// mockMappingBody returns a copy of the mapping without the {typeName: ...}
+// wrapper it may have been given in.
+func mockMappingBody(typeName string, mapping interface{}) map[string]interface{} {
+	obj, _ := mockNormalize(mapping)
+	if obj == nil {
+		return map[string]interface{}{}
+	}
+	if body, ok := obj[typeName].(map[string]interface{}); ok && len(obj) == 1 {
+		return body
+	}
+	return obj
 }
 
+// newId must be called with esi.mu held.
 func (esi *MockIndex) newId() string {
 	esi.idSource++
 	return strconv.Itoa(esi.idSource)
 }
 
 func (esi *MockIndex) PostData(typeName string, id string, obj interface{}) (*IndexResponse, error) {
-	ok, err := esi.IndexExists()
-	if err != nil {
+	return esi.write(typeName, id, obj, false, 0)
+}
+
+func (esi *MockIndex) PutData(typeName string, id string, obj interface{}) (*IndexResponse, error) {
+	if id == "" {
+		return nil, fmt.Errorf("PutData: id may not be empty")
+	}
+	return esi.write(typeName, id, obj, false, 0)
+}
+
+func (esi *MockIndex) CreateData(typeName string, id string, obj interface{}) (*IndexResponse, error) {
+	if id == "" {
+		return nil, fmt.Errorf("CreateData: id may not be empty")
+	}
+	return esi.write(typeName, id, obj, true, 0)
+}
+
+func (esi *MockIndex) PutDataIfVersion(typeName string, id string, obj interface{}, version int) (*IndexResponse, error) {
+	if id == "" {
+		return nil, fmt.Errorf("PutDataIfVersion: id may not be empty")
+	}
+	if version <= 0 {
+		return nil, fmt.Errorf("PutDataIfVersion: invalid version %d", version)
+	}
+	return esi.write(typeName, id, obj, false, version)
+}
+
+// write stores the document and bumps its version. The type must exist, as
+// it must for Index. With create, the document must not exist yet; with a
+// nonzero version, it must exist at that version.
+func (esi *MockIndex) write(typeName string, id string, obj interface{}, create bool, version int) (*IndexResponse, error) {
+	if err := esi.single(); err != nil {
 		return nil, err
 	}
-	if !ok {
-		return nil, fmt.Errorf("Index does not exist")
+	if err := esi.ctxErr(); err != nil {
+		return nil, err
 	}
-	ok, err = esi.TypeExists(typeName)
+	raw, err := mockRaw(obj)
 	if err != nil {
 		return nil, err
 	}
 
-	var typ *MockIndexType
-	if !ok {
-		typ = &MockIndexType{}
-		typ.items = make(map[string]*json.RawMessage)
-		esi.types[typeName] = typ
-	} else {
-		typ = esi.types[typeName]
-	}
+	esi.mu.Lock()
+	defer esi.mu.Unlock()
+	return esi.store(typeName, id, raw, create, version)
+}
 
-	byts, err := json.Marshal(obj)
-	if err != nil {
-		return nil, err
+// store does the work of write; esi.mu must be held.
+func (esi *MockIndex) store(typeName string, id string, raw *json.RawMessage, create bool, version int) (*IndexResponse, error) {
+	if !esi.exists {
+		return nil, indexNotFoundError(esi.name)
 	}
 
-	var raw json.RawMessage
-	err = raw.UnmarshalJSON(byts)
-	if err != nil {
-		return nil, err
+	typ, ok := esi.types[typeName]
+	if !ok {
+		return nil, typeNotFoundError(esi.name, typeName)
+	}
+	current, exists := typ.versions[id]
+	if id != "" && create && exists {
+		return nil, errors.WithMessage(ErrConflict, fmt.Sprintf("document %s already exists", id))
+	}
+	if version != 0 && (!exists || current != version) {
+		return nil, errors.WithMessage(ErrConflict,
+			fmt.Sprintf("document %s is at version %d, not %d", id, current, version))
 	}
 
 	if id == "" {
 		id = esi.newId()
 	}
 
-	typ.items[id] = &raw
+	entry := &journalEntry{Op: "index", Type: typeName, ID: id, Version: current + 1, Source: raw, IDSource: esi.idSource}
+	if err := esi.record(entry); err != nil {
+		return nil, err
+	}
+	typ.items[id] = raw
+	typ.versions[id] = current + 1
+	esi.changed(typeName, id, raw, current+1)
 
-	r := &IndexResponse{Created: true, ID: id, Index: esi.name, Type: typeName}
+	r := &IndexResponse{Created: !exists, ID: id, Index: esi.name, Type: typeName, Version: current + 1}
 	return r, nil
 }
 
-//TODO
-func (esi *MockIndex) PutData(typeName string, id string, obj interface{}) (*IndexResponse, error) {
-	return esi.PostData(typeName, id, obj)
-}
-
-func (esi *MockIndex) GetByID(typeName string, id string) (*GetResult, error) {
-	ok, err := esi.ItemExists(typeName, id)
+// mockRaw returns the object as it is stored.
+func mockRaw(obj interface{}) (*json.RawMessage, error) {
+	byts, err := json.Marshal(obj)
 	if err != nil {
 		return nil, err
 	}
-	if !ok {
-		return &GetResult{Found: false}, fmt.Errorf("GetById: id does not exist: %s", id)
-	}
 
-	typ := esi.types[typeName]
-	item := typ.items[id]
-	r := &GetResult{ID: id, Source: item, Found: true}
-	return r, nil
+	var raw json.RawMessage
+	err = raw.UnmarshalJSON(byts)
+	if err != nil {
+		return nil, err
+	}
+	return &raw, nil
 }
 
-func (esi *MockIndex) DeleteByID(typeName string, id string) (*DeleteResponse, error) {
-	ok, err := esi.TypeExists(typeName)
+// UpdateData merges Doc into the stored document, or runs Script on it.
+// Only scripts which set and remove fields can be run; see mockScript.
+func (esi *MockIndex) UpdateData(typeName string, id string, update *Update) (*IndexResponse, error) {
+	err := update.validate()
 	if err != nil {
 		return nil, err
 	}
-	if !ok {
-		return nil, fmt.Errorf("GetById: type does not exist: %s", typeName)
+	if err = esi.single(); err != nil {
+		return nil, err
 	}
-	ok, err = esi.ItemExists(typeName, id)
-	if err != nil {
+	if err = esi.ctxErr(); err != nil {
 		return nil, err
 	}
+
+	esi.mu.Lock()
+	defer esi.mu.Unlock()
+	if !esi.exists {
+		return nil, indexNotFoundError(esi.name)
+	}
+	typ, ok := esi.types[typeName]
 	if !ok {
-		return &DeleteResponse{Found: false}, err
+		return nil, typeNotFoundError(esi.name, typeName)
 	}
+	current := typ.items[id]
+	version := typ.versions[id]
 
-	typ := esi.types[typeName]
-	delete(typ.items, id)
-	r := &DeleteResponse{Found: true, ID: id}
-	return r, nil
-}
-
-func (esi *MockIndex) DeleteByIDWait(typeName string, id string) (*DeleteResponse, error) {
-	return esi.DeleteByID(typeName, id)
-}
-
-type srhByID []*SearchResultHit
-
-func (a srhByID) Len() int {
-	return len(a)
-}
-func (a srhByID) Swap(i, j int) {
-	a[i], a[j] = a[j], a[i]
-}
-func (a srhByID) Less(i, j int) bool {
-	return (*a[i]).ID < (*a[j]).ID
-}
-func srhSortMatches(matches []*SearchResultHit) []*SearchResultHit {
-	sort.Sort(srhByID(matches))
-	return matches
-}
-
-func (esi *MockIndex) FilterByMatchAll(typeName string, realFormat *piazza.JsonPagination) (*SearchResult, error) {
-	// pagination SortBy and Order are not supported!
-
-	format := NewQueryFormat(realFormat)
-
-	objs := make(map[string]*json.RawMessage)
+	if current == nil {
+		if update.Version != 0 {
+			return nil, errors.WithMessage(ErrConflict, fmt.Sprintf("document %s does not exist", id))
+		}
+		upsert := update.Upsert
+		if upsert == nil && update.DocAsUpsert {
+			upsert = update.Doc
+		}
+		if upsert == nil {
+			return nil, documentNotFoundError(esi.name, typeName, id)
+		}
+		raw, err := mockRaw(upsert)
+		if err != nil {
+			return nil, err
+		}
+		return esi.store(typeName, id, raw, true, 0)
+	}
 
-	emptyResp := &SearchResult{
-		totalHits: 0,
-		hits:      make([]*SearchResultHit, 0),
+	if update.Version != 0 && update.Version != version {
+		return nil, errors.WithMessage(ErrConflict,
+			fmt.Sprintf("document %s is at version %d, not %d", id, version, update.Version))
 	}
 
-	if typeName == "" {
-		if esi.types == nil {
-			return emptyResp, nil
+	merged := mockDocument(current)
+	if update.Script != nil {
+		script, err := newMockScript(update.Script)
+		if err != nil {
+			return nil, fmt.Errorf("UpdateData: %s", err.Error())
 		}
-		for tk, tv := range esi.types {
-			if tk == percolateTypeName {
-				continue
-			}
-			for ik, iv := range tv.items {
-				objs[ik] = iv
-			}
+		if err = script.run(merged); err != nil {
+			return nil, fmt.Errorf("UpdateData: %s", err.Error())
 		}
 	} else {
-		if esi.types[typeName] == nil {
-			return emptyResp, nil
-		}
-		for ik, iv := range esi.types[typeName].items {
-			objs[ik] = iv
+		doc, err := mockNormalize(update.Doc)
+		if err != nil {
+			return nil, err
 		}
+		merged = mockMerge(merged, doc)
 	}
 
-	resp := &SearchResult{
-		totalHits: int64(len(objs)),
-		hits:      make([]*SearchResultHit, len(objs)),
+	// as in Elasticsearch, an update that changes nothing is a no-op
+	if reflect.DeepEqual(merged, mockDocument(current)) {
+		return &IndexResponse{ID: id, Index: esi.name, Type: typeName, Version: version}, nil
 	}
+	raw, err := mockRaw(merged)
+	if err != nil {
+		return nil, err
+	}
+	return esi.store(typeName, id, raw, false, version)
+}
 
-	i := 0
-	for id, obj := range objs {
-		tmp := &SearchResultHit{
-			ID:     id,
-			Source: obj,
+// mockMerge merges src into dst, recursing into objects present in both.
+func mockMerge(dst map[string]interface{}, src map[string]interface{}) map[string]interface{} {
+	for k, v := range src {
+		sv, sok := v.(map[string]interface{})
+		dv, dok := dst[k].(map[string]interface{})
+		if sok && dok {
+			dst[k] = mockMerge(dv, sv)
+		} else {
+			dst[k] = v
 		}
-		resp.hits[i] = tmp
-		i++
 	}
+	return dst
+}
 
-	// TODO; sort key not supported
-	// TODO: sort order not supported
-
-	from := format.From
-	size := format.Size
-
-	resp.hits = srhSortMatches(resp.hits)
-
-	if from >= len(resp.hits) {
-		resp.hits = make([]*SearchResultHit, 0)
-		return resp, nil
+func (esi *MockIndex) GetByID(typeName string, id string) (*GetResult, error) {
+	if err := esi.single(); err != nil {
+		return nil, err
 	}
-
-	if from+size >= len(resp.hits) {
-		resp.hits = resp.hits[from:]
-		return resp, nil
+	if err := esi.ctxErr(); err != nil {
+		return nil, err
 	}
 
-	resp.hits = resp.hits[from : from+size]
-
-	return resp, nil
-}
+	esi.mu.Lock()
+	defer esi.mu.Unlock()
+	if !esi.exists {
+		return &GetResult{Found: false}, indexNotFoundError(esi.name)
+	}
+	var item *json.RawMessage
+	typ, ok := esi.types[typeName]
+	if ok {
+		item, ok = typ.items[id]
+	}
+	if !ok {
+		return &GetResult{Found: false}, documentNotFoundError(esi.name, typeName, id)
+	}
 
-func (esi *MockIndex) GetAllElements(typ string) (*SearchResult, error) {
-	return nil, errors.New("GetAllElements not supported under mocking")
+	r := &GetResult{ID: id, Index: esi.name, Type: typeName, Source: item, Found: true, Version: typ.versions[id]}
+	return r, nil
 }
 
-func (esi *MockIndex) FilterByMatchQuery(typ string, name string, value interface{}, realFormat *piazza.JsonPagination) (*SearchResult, error) {
-
-	return nil, errors.New("FilterByMatchQuery not supported under mocking")
-}
+// GetByIDs looks the documents up one by one. As with Elasticsearch, a
+// missing index is reported for each document rather than as an error.
+func (esi *MockIndex) GetByIDs(typeName string, ids []string) ([]*GetResult, error) {
+	if err := esi.single(); err != nil {
+		return nil, err
+	}
+	if err := esi.ctxErr(); err != nil {
+		return nil, err
+	}
 
-func (esi *MockIndex) FilterByTermQuery(typeName string, name string, value interface{}, realFormat *piazza.JsonPagination) (*SearchResult, error) {
+	esi.mu.Lock()
+	defer esi.mu.Unlock()
+	results := make([]*GetResult, len(ids))
+	for i, id := range ids {
+		results[i] = &GetResult{ID: id, Index: esi.name, Type: typeName}
+		if !esi.exists {
+			results[i].Error = fmt.Sprintf("no such index [%s]", esi.name)
+			continue
+		}
+		typ, found := esi.types[typeName]
+		if !found {
+			continue
+		}
+		if item, found := typ.items[id]; found {
+			results[i].Source = item
+			results[i].Found = true
+			results[i].Version = typ.versions[id]
+		}
+	}
+	return results, nil
+}
+
+func (esi *MockIndex) DeleteByID(typeName string, id string) (*DeleteResponse, error) {
+	if err := esi.single(); err != nil {
+		return nil, err
+	}
+	if err := esi.ctxErr(); err != nil {
+		return nil, err
+	}
+
+	esi.mu.Lock()
+	defer esi.mu.Unlock()
+	if !esi.exists {
+		return &DeleteResponse{Found: false}, indexNotFoundError(esi.name)
+	}
+	typ, ok := esi.types[typeName]
+	if ok {
+		_, ok = typ.items[id]
+	}
+	if !ok {
+		return &DeleteResponse{Found: false}, documentNotFoundError(esi.name, typeName, id)
+	}
+
+	if err := esi.record(&journalEntry{Op: "delete", Type: typeName, ID: id}); err != nil {
+		return nil, err
+	}
+	delete(typ.items, id)
+	delete(typ.versions, id)
+	esi.changed(typeName, id, nil, 0)
+	r := &DeleteResponse{Found: true, ID: id}
+	return r, nil
+}
+
+// DeleteByIDWait deletes the document and, in near-real-time mode, refreshes
+// the index, so that searches no longer find it.
+func (esi *MockIndex) DeleteByIDWait(typeName string, id string) (*DeleteResponse, error) {
+	resp, err := esi.DeleteByID(typeName, id)
+	if err != nil {
+		return resp, err
+	}
+
+	esi.mu.Lock()
+	defer esi.mu.Unlock()
+	esi.refresh()
+	return resp, nil
+}
+
+func (esi *MockIndex) BulkPostData(typeName string, items []*BulkItem) (*BulkResponse, error) {
+	ops := make([]*bulkOp, len(items))
+	for i, item := range items {
+		ops[i] = &bulkOp{action: "index", typ: typeName, id: item.ID, obj: item.Obj}
+		if item.Create {
+			ops[i].action = "create"
+		}
+	}
+	return esi.bulk(ops)
+}
+
+func (esi *MockIndex) BulkDeleteByID(typeName string, ids []string) (*BulkResponse, error) {
+	ops := make([]*bulkOp, len(ids))
+	for i, id := range ids {
+		ops[i] = &bulkOp{action: "delete", typ: typeName, id: id}
+	}
+	return esi.bulk(ops)
+}
+
+func (esi *MockIndex) NewBulkIndexer(settings *BulkSettings) (IBulkIndexer, error) {
+	return newBulkIndexer(esi.bulk, settings), nil
+}
+
+// mockScrollSize is the page size Elasticsearch scrolls through documents
+// with when deleting or updating by query, by which batches are counted.
+const mockScrollSize = 1000
+
+// DeleteByQuery deletes the matching documents at once; the task returned
+// has already completed. In near-real-time mode, only the documents visible
+// to searches are matched.
+func (esi *MockIndex) DeleteByQuery(typeName string, query elastic.Query) (*ByQueryTask, error) {
+	hits, err := esi.byQueryHits(typeName, query)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &elastic.BulkIndexByScrollResponse{
+		Total:   int64(len(hits)),
+		Batches: int64((len(hits) + mockScrollSize - 1) / mockScrollSize),
+	}
+
+	esi.mu.Lock()
+	defer esi.mu.Unlock()
+	for _, hit := range hits {
+		// documents changed since the search are left alone, as they are by
+		// Elasticsearch when told to proceed on conflicts
+		typ, ok := esi.types[hit.typ]
+		if !ok || typ.versions[hit.id] != hit.version {
+			resp.VersionConflicts++
+			continue
+		}
+		if err = esi.record(&journalEntry{Op: "delete", Type: hit.typ, ID: hit.id}); err != nil {
+			return nil, err
+		}
+		delete(typ.items, hit.id)
+		delete(typ.versions, hit.id)
+		esi.changed(hit.typ, hit.id, nil, 0)
+		resp.Deleted++
+	}
+	return esi.completedTask(resp), nil
+}
+
+// UpdateByQuery updates the matching documents at once, bumping their
+// versions; the task returned has already completed. Only scripts which set
+// and remove fields can be run; see mockScript. As with Elasticsearch, the
+// first document a script fails on is reported in the status's Failures,
+// and no more are updated.
+func (esi *MockIndex) UpdateByQuery(typeName string, query elastic.Query, script *elastic.Script) (*ByQueryTask, error) {
+	var run *mockScript
+	if script != nil {
+		var err error
+		if run, err = newMockScript(script); err != nil {
+			return nil, fmt.Errorf("UpdateByQuery: %s", err.Error())
+		}
+	}
+	hits, err := esi.byQueryHits(typeName, query)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &elastic.BulkIndexByScrollResponse{
+		Total:   int64(len(hits)),
+		Batches: int64((len(hits) + mockScrollSize - 1) / mockScrollSize),
+	}
+	for _, hit := range hits {
+		var obj interface{} = hit.source
+		if run != nil {
+			source := map[string]interface{}{}
+			if err = json.Unmarshal(*hit.source, &source); err != nil {
+				return nil, err
+			}
+			if err = run.run(source); err != nil {
+				resp.Failures = append(resp.Failures, &elastic.BulkIndexByScrollFailure{
+					Index:  hit.index,
+					Type:   hit.typ,
+					Id:     hit.id,
+					Status: http.StatusInternalServerError,
+					Cause:  &elastic.ErrorDetails{Type: "script_exception", Reason: err.Error()},
+				})
+				break
+			}
+			obj = source
+		}
+		_, err = esi.write(hit.typ, hit.id, obj, false, hit.version)
+		switch {
+		case IsConflict(err):
+			resp.VersionConflicts++
+		case err != nil:
+			return nil, err
+		default:
+			resp.Updated++
+		}
+	}
+	return esi.completedTask(resp), nil
+}
+
+func (esi *MockIndex) byQueryHits(typeName string, query elastic.Query) ([]*mockHit, error) {
+	if err := esi.single(); err != nil {
+		return nil, err
+	}
+	ok, err := esi.IndexExists()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, indexNotFoundError(esi.name)
+	}
+	q, err := mockQuerySource(query)
+	if err != nil {
+		return nil, err
+	}
+	return esi.search(typeName, q)
+}
+
+func (esi *MockIndex) completedTask(resp *elastic.BulkIndexByScrollResponse) *ByQueryTask {
+	status := func() (*ByQueryStatus, error) {
+		return newByQueryStatus(resp, true), nil
+	}
+	return &ByQueryTask{ctx: esi.ctx, status: status}
+}
+
+func (esi *MockIndex) bulk(ops []*bulkOp) (*BulkResponse, error) {
+	ok, err := esi.IndexExists()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, indexNotFoundError(esi.name)
+	}
+	for _, op := range ops {
+		if op.action == "delete" {
+			continue
+		}
+		if err = esi.requireType(op.typ); err != nil {
+			return nil, err
+		}
+	}
+
+	resp := &BulkResponse{Items: make([]*BulkResponseItem, len(ops))}
 
-	objs := make(map[string]*json.RawMessage)
+	for i, op := range ops {
+		item := &BulkResponseItem{Action: op.action, ID: op.id, Type: op.typ}
+		resp.Items[i] = item
 
-	for ik, iv := range esi.types[typeName].items {
-		objs[ik] = iv
+		switch op.action {
+		case "index", "create":
+			var indexResponse *IndexResponse
+			var err error
+			if op.action == "create" {
+				indexResponse, err = esi.CreateData(op.typ, op.id, op.obj)
+			} else {
+				indexResponse, err = esi.PostData(op.typ, op.id, op.obj)
+			}
+			if err != nil {
+				item.Status = StatusCode(err)
+				item.Error = err.Error()
+				break
+			}
+			item.ID = indexResponse.ID
+			item.Version = indexResponse.Version
+			item.Status = 200
+			if indexResponse.Created {
+				item.Status = 201
+			}
+		case "delete":
+			deleteResponse, err := esi.DeleteByID(op.typ, op.id)
+			switch {
+			case IsDocumentNotFound(err):
+				item.Status = 404
+			case err != nil:
+				item.Status = StatusCode(err)
+				item.Error = err.Error()
+			case !deleteResponse.Found:
+				item.Status = 404
+			default:
+				item.Status = 200
+				item.Found = true
+			}
+		}
+
+		if item.Error != "" {
+			resp.Errors = true
+		}
+	}
+
+	return resp, nil
+}
+
+// FilterByMatchAll honors the pagination's SortBy and Order; without a sort
+// key, hits are ordered by id.
+func (esi *MockIndex) FilterByMatchAll(typeName string, realFormat *piazza.JsonPagination) (*SearchResult, error) {
+	req, err := newMockSearchRequest(nil, realFormat)
+	if err != nil {
+		return nil, err
+	}
+	return esi.execute(typeName, req)
+}
+
+func (esi *MockIndex) GetAllElements(typeName string) (*SearchResult, error) {
+	if typeName == "" {
+		return nil, fmt.Errorf("elasticsearch.MockIndex.GetAllElements: empty type")
+	}
+	if err := esi.requireType(typeName); err != nil {
+		return nil, err
+	}
+
+	it, err := esi.Scan(typeName, nil)
+	if err != nil {
+		return nil, err
+	}
+	hits, err := it.All()
+	if err != nil {
+		return nil, err
 	}
 
 	resp := &SearchResult{
-		totalHits: int64(len(objs)),
-		hits:      make([]*SearchResultHit, 0),
+		totalHits: int64(len(hits)),
+		hits:      hits,
+		Found:     true,
+	}
+	return resp, nil
+}
+
+type mockHit struct {
+	index   string
+	typ     string
+	id      string
+	version int
+	source  *json.RawMessage
+	doc     map[string]interface{}
+}
+
+// result returns the hit as Elasticsearch would for a search sorted by the
+// keys. Every document matches equally well, so scores are all one, and
+// left out as Elasticsearch does when sorting on anything else.
+func (h *mockHit) result(keys []*mockSortKey) *SearchResultHit {
+	r := &SearchResultHit{
+		ID:      h.id,
+		Source:  h.source,
+		Index:   h.index,
+		Type:    h.typ,
+		Score:   1,
+		Version: h.version,
+	}
+	for _, key := range keys {
+		if key.field != "_score" {
+			r.Score = 0
+		}
+		switch key.field {
+		case "_score":
+			r.Sort = append(r.Sort, 1.0)
+		case "_doc":
+			r.Sort = append(r.Sort, 0.0)
+		default:
+			v, _ := mockSortValue(h, key)
+			r.Sort = append(r.Sort, v)
+		}
+	}
+	return r
+}
+
+func (h *mockHit) uid() string {
+	return h.typ + "#" + h.id
+}
+
+type mockHitsByUID []*mockHit
+
+func (a mockHitsByUID) Len() int {
+	return len(a)
+}
+func (a mockHitsByUID) Swap(i, j int) {
+	a[i], a[j] = a[j], a[i]
+}
+func (a mockHitsByUID) Less(i, j int) bool {
+	return a[i].uid() < a[j].uid()
+}
+
+// search returns the documents of the type (or of all types but that of the
+// percolation queries, if typeName is empty) which match the query, ordered
+// by _uid.
+func (esi *MockIndex) search(typeName string, query map[string]interface{}) ([]*mockHit, error) {
+	ok, err := esi.IndexExists()
+	if err != nil {
+		return nil, err
 	}
+	if !ok {
+		return nil, indexNotFoundError(esi.name)
+	}
+	hits := []*mockHit{}
 
-	i := 0
-	for id, obj := range objs {
-		var iface interface{}
-		err := json.Unmarshal(*obj, &iface)
+	for _, data := range esi.members() {
+		found, err := data.search(typeName, query)
 		if err != nil {
 			return nil, err
 		}
-		actualValue := iface.(map[string]interface{})[name].(string)
-		if actualValue != value.(string) {
+		hits = append(hits, found...)
+	}
+
+	sort.Sort(mockHitsByUID(hits))
+	return hits, nil
+}
+
+func (data *mockIndexData) search(typeName string, query map[string]interface{}) ([]*mockHit, error) {
+	data.mu.Lock()
+	defer data.mu.Unlock()
+
+	hits := []*mockHit{}
+	for tk, tv := range data.searchable() {
+		// a search of every type leaves out the percolation queries, as
+		// Index's do; see searchQuery
+		if (typeName == "" && tk == percolateTypeName) || (typeName != "" && tk != typeName) {
 			continue
 		}
-		tmp := &SearchResultHit{
-			ID:     id,
-			Source: obj,
+		for ik, iv := range tv.items {
+			doc := mockDocument(iv)
+			doc["_type"] = tk
+			doc["_id"] = ik
+			ok, err := mockMatches(query, doc)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				hits = append(hits, &mockHit{index: data.name, typ: tk, id: ik, version: tv.versions[ik], source: iv, doc: doc})
+			}
+		}
+	}
+	return hits, nil
+}
+
+func (esi *MockIndex) Scan(typeName string, query elastic.Query) (*ScanIterator, error) {
+	q, err := mockQuerySource(query)
+	if err != nil {
+		return nil, err
+	}
+
+	fetch := func(after []interface{}, size int) ([]*SearchResultHit, []interface{}, error) {
+		hits, err := esi.search(typeName, q)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		start := 0
+		if len(after) == 1 {
+			uid := fmt.Sprintf("%v", after[0])
+			start = sort.Search(len(hits), func(i int) bool { return hits[i].uid() > uid })
 		}
-		resp.hits = append(resp.hits, tmp)
-		i++
+		end := start + size
+		if end > len(hits) {
+			end = len(hits)
+		}
+
+		page := make([]*SearchResultHit, end-start)
+		for i, hit := range hits[start:end] {
+			page[i] = hit.result([]*mockSortKey{{field: "_uid", asc: true}})
+		}
+
+		var next []interface{}
+		if end > start {
+			next = []interface{}{hits[end-1].uid()}
+		}
+		return page, next, nil
+	}
+
+	return newScanIterator(fetch), nil
+}
+
+func (esi *MockIndex) FilterByMatchQuery(typeName string, name string, value interface{}, realFormat *piazza.JsonPagination) (*SearchResult, error) {
+	if typeName == "" {
+		return nil, fmt.Errorf("Can't filter on type \"\"")
+	}
+	if err := esi.requireType(typeName); err != nil {
+		return nil, err
 	}
 
-	if len(resp.hits) > 0 {
-		resp.Found = true
+	req, err := newMockSearchRequest(elastic.NewMatchQuery(name, value), realFormat)
+	if err != nil {
+		return nil, err
+	}
+	return esi.execute(typeName, req)
+}
+
+func (esi *MockIndex) FilterByTermQuery(typeName string, name string, value interface{}, realFormat *piazza.JsonPagination) (*SearchResult, error) {
+	if typeName == "" {
+		return nil, fmt.Errorf("Can't filter on type \"\"")
+	}
+	if err := esi.requireType(typeName); err != nil {
+		return &SearchResult{Found: false}, err
 	}
 
-	resp.hits = srhSortMatches(resp.hits)
+	req, err := newMockSearchRequest(elastic.NewTermQuery(name, value), realFormat)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := esi.execute(typeName, req)
+	if err != nil {
+		return nil, err
+	}
 
+	// callers of the mock have always relied on Found meaning "had hits"
+	resp.Found = resp.totalHits > 0
 	return resp, nil
 }
 
-func (esi *MockIndex) SearchByJSON(typ string, jsn string) (*SearchResult, error) {
-	return nil, fmt.Errorf("SearchByJSON not supported under mocking")
+// FilterByQuery evaluates the query in memory; see mockMatches for the
+// supported query types.
+func (esi *MockIndex) FilterByQuery(typeName string, query elastic.Query, realFormat *piazza.JsonPagination) (*SearchResult, error) {
+	if query == nil {
+		return nil, fmt.Errorf("FilterByQuery: query may not be nil")
+	}
+	req, err := newMockSearchRequest(query, realFormat)
+	if err != nil {
+		return nil, err
+	}
+	return esi.execute(typeName, req)
+}
+
+func (esi *MockIndex) SearchWithHighlight(typeName string, query elastic.Query, highlight *elastic.Highlight, realFormat *piazza.JsonPagination) (*SearchResult, error) {
+	if query == nil {
+		return nil, fmt.Errorf("SearchWithHighlight: query may not be nil")
+	}
+	if highlight == nil {
+		return nil, fmt.Errorf("SearchWithHighlight: highlight may not be nil")
+	}
+	req, err := newMockSearchRequest(query, realFormat)
+	if err != nil {
+		return nil, err
+	}
+	src, err := highlight.Source()
+	if err != nil {
+		return nil, err
+	}
+	req.highlight, err = mockNormalize(src)
+	if err != nil {
+		return nil, err
+	}
+	return esi.execute(typeName, req)
+}
+
+func (esi *MockIndex) Suggest(typeName string, suggester elastic.Suggester) ([]*Suggestion, error) {
+	if suggester == nil {
+		return nil, fmt.Errorf("Suggest: suggester may not be nil")
+	}
+	src, err := suggester.Source(false)
+	if err != nil {
+		return nil, err
+	}
+	body, err := mockNormalize(src)
+	if err != nil {
+		return nil, err
+	}
+	hits, err := esi.search(typeName, map[string]interface{}{"match_all": map[string]interface{}{}})
+	if err != nil {
+		return nil, err
+	}
+	return mockSuggest(body, hits)
+}
+
+func (esi *MockIndex) FilterByBoundingBox(typeName string, field string, topLeft *elastic.GeoPoint, bottomRight *elastic.GeoPoint, realFormat *piazza.JsonPagination) (*SearchResult, error) {
+	q := elastic.NewGeoBoundingBoxQuery(field).
+		TopLeftFromGeoPoint(topLeft).
+		BottomRightFromGeoPoint(bottomRight)
+	return esi.FilterByQuery(typeName, q, realFormat)
+}
+
+func (esi *MockIndex) FilterByDistance(typeName string, field string, center *elastic.GeoPoint, distance string, realFormat *piazza.JsonPagination) (*SearchResult, error) {
+	q := elastic.NewGeoDistanceQuery(field).
+		GeoPoint(center).
+		Distance(distance)
+	return esi.FilterByQuery(typeName, q, realFormat)
+}
+
+// Aggregate computes the aggregations in memory; see mockAggregation for the
+// supported aggregation types.
+func (esi *MockIndex) Aggregate(typeName string, query elastic.Query, aggs map[string]elastic.Aggregation) (*SearchResult, error) {
+	if len(aggs) == 0 {
+		return nil, fmt.Errorf("Aggregate: no aggregations given")
+	}
+	req, err := newMockSearchRequest(query, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.size = 0
+	req.aggs, err = mockAggregationSources(aggs)
+	if err != nil {
+		return nil, err
+	}
+	return esi.execute(typeName, req)
+}
+
+// SearchByJSON honors the query, from, size, sort and aggregations of the
+// request body; other options are rejected.
+func (esi *MockIndex) SearchByJSON(typeName string, jsn string) (*SearchResult, error) {
+	req, err := parseMockSearchRequest(jsn)
+	if err != nil {
+		return nil, err
+	}
+	return esi.execute(typeName, req)
+}
+
+// MultiSearch runs the searches one by one. As with Elasticsearch, a search
+// that fails is reported in its item rather than as an error.
+func (esi *MockIndex) MultiSearch(requests []*SearchRequest) ([]*MultiSearchItem, error) {
+	if err := esi.ctxErr(); err != nil {
+		return nil, err
+	}
+
+	items := make([]*MultiSearchItem, len(requests))
+	for i, req := range requests {
+		result, err := esi.FilterByQuery(req.Type, req.query(), req.Format)
+		if err != nil {
+			items[i] = &MultiSearchItem{Error: err.Error()}
+			continue
+		}
+		items[i] = &MultiSearchItem{Result: result}
+	}
+	return items, nil
 }
 
 func (esi *MockIndex) GetTypes() ([]string, error) {
+	if err := esi.ctxErr(); err != nil {
+		return nil, err
+	}
 	var s []string
 
-	for k := range esi.types {
-		s = append(s, k)
+	seen := map[string]bool{}
+	for _, data := range esi.members() {
+		data.mu.Lock()
+		for k := range data.types {
+			if !seen[k] {
+				seen[k] = true
+				s = append(s, k)
+			}
+		}
+		data.mu.Unlock()
 	}
 
 	return s, nil
 }
 
-func (esi *MockIndex) GetMapping(typ string) (interface{}, error) {
-	return nil, errors.New("GetMapping not supported under mocking")
+// GetMapping returns the type's mapping in the form Elasticsearch does,
+// {typ: {"properties": ...}}. Types created implicitly by PostData have an
+// empty mapping.
+func (esi *MockIndex) GetMapping(typeName string) (interface{}, error) {
+	if err := esi.requireType(typeName); err != nil {
+		return nil, err
+	}
+
+	var mapping map[string]interface{}
+	for _, data := range esi.members() {
+		data.mu.Lock()
+		typ, ok := data.types[typeName]
+		if ok {
+			mapping, _ = mockNormalize(typ.mapping)
+		}
+		data.mu.Unlock()
+		if ok {
+			break
+		}
+	}
+	if mapping == nil {
+		mapping = map[string]interface{}{}
+	}
+	if _, ok := mapping[typeName]; ok && len(mapping) == 1 {
+		return mapping, nil
+	}
+	return map[string]interface{}{typeName: mapping}, nil
+}
+
+// unalias removes the index from the alias; mockAliases must be locked.
+func (esi *MockIndex) unalias(alias string) bool {
+	indices := mockAliases.indices[alias]
+	for i, data := range indices {
+		if data == esi.mockIndexData {
+			indices = append(indices[:i:i], indices[i+1:]...)
+			if len(indices) == 0 {
+				delete(mockAliases.indices, alias)
+			} else {
+				mockAliases.indices[alias] = indices
+			}
+			return true
+		}
+	}
+	return false
+}
+
+func (esi *MockIndex) AddAlias(alias string) error {
+	ok, err := esi.IndexExists()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return indexNotFoundError(esi.name)
+	}
+	if alias == esi.name {
+		return fmt.Errorf("alias %s has the same name as the index", alias)
+	}
+
+	mockAliases.Lock()
+	defer mockAliases.Unlock()
+	esi.unalias(alias)
+	mockAliases.indices[alias] = append(mockAliases.indices[alias], esi.mockIndexData)
+	return nil
+}
+
+func (esi *MockIndex) RemoveAlias(alias string) error {
+	if err := esi.ctxErr(); err != nil {
+		return err
+	}
+
+	mockAliases.Lock()
+	defer mockAliases.Unlock()
+	if !esi.unalias(alias) {
+		return fmt.Errorf("alias %s does not point to index %s", alias, esi.name)
+	}
+	return nil
+}
+
+func (esi *MockIndex) SwapAlias(alias string) error {
+	ok, err := esi.IndexExists()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return indexNotFoundError(esi.name)
+	}
+	if alias == esi.name {
+		return fmt.Errorf("alias %s has the same name as the index", alias)
+	}
+
+	mockAliases.Lock()
+	defer mockAliases.Unlock()
+	mockAliases.indices[alias] = []*mockIndexData{esi.mockIndexData}
+	return nil
+}
+
+func (esi *MockIndex) GetAliases() ([]string, error) {
+	if err := esi.ctxErr(); err != nil {
+		return nil, err
+	}
+
+	mockAliases.Lock()
+	defer mockAliases.Unlock()
+	aliases := []string{}
+	for alias, indices := range mockAliases.indices {
+		for _, data := range indices {
+			if data == esi.mockIndexData {
+				aliases = append(aliases, alias)
+			}
+		}
+	}
+	sort.Strings(aliases)
+	return aliases, nil
+}
+
+func (esi *MockIndex) AliasIndices(alias string) ([]string, error) {
+	if err := esi.ctxErr(); err != nil {
+		return nil, err
+	}
+
+	mockAliases.Lock()
+	defer mockAliases.Unlock()
+	indices := []string{}
+	for _, data := range mockAliases.indices[alias] {
+		indices = append(indices, data.name)
+	}
+	sort.Strings(indices)
+	return indices, nil
+}
+
+// GetSettings reports the number of shards and replicas, as Elasticsearch
+// does, even if they were left at the defaults.
+func (esi *MockIndex) GetSettings() (*IndexSettings, error) {
+	ok, err := esi.IndexExists()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, indexNotFoundError(esi.name)
+	}
+
+	esi.mu.Lock()
+	defer esi.mu.Unlock()
+	settings := NewIndexSettings().Shards(5).Replicas(1)
+	settings.merge(esi.settings)
+	return settings, nil
+}
+
+func (esi *MockIndex) UpdateSettings(settings *IndexSettings) error {
+	ok, err := esi.IndexExists()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return indexNotFoundError(esi.name)
+	}
+
+	_, err = settings.updateSource()
+	if err != nil {
+		return err
+	}
+	esi.mu.Lock()
+	defer esi.mu.Unlock()
+	merged := NewIndexSettings()
+	merged.merge(esi.settings)
+	merged.merge(settings)
+	if err = esi.record(&journalEntry{Op: "settings", Settings: merged.indexSource()}); err != nil {
+		return err
+	}
+	esi.settings = merged
+	return nil
+}
+
+func (esi *MockIndex) PutTemplate(name string, template *IndexTemplate) error {
+	if err := esi.ctxErr(); err != nil {
+		return err
+	}
+	if name == "" {
+		return fmt.Errorf("PutTemplate: name may not be empty")
+	}
+	_, err := template.Source()
+	if err != nil {
+		return err
+	}
+
+	mockTemplates.Lock()
+	defer mockTemplates.Unlock()
+	mockTemplates.templates[name] = template
+	return nil
+}
+
+func (esi *MockIndex) DeleteTemplate(name string) error {
+	if err := esi.ctxErr(); err != nil {
+		return err
+	}
+
+	mockTemplates.Lock()
+	defer mockTemplates.Unlock()
+	if _, ok := mockTemplates.templates[name]; !ok {
+		return fmt.Errorf("index template %s missing", name)
+	}
+	delete(mockTemplates.templates, name)
+	return nil
+}
+
+type pmByID []*PercolateResponseMatch
+
+func (a pmByID) Len() int {
+	return len(a)
+}
+func (a pmByID) Swap(i, j int) {
+	a[i], a[j] = a[j], a[i]
+}
+func (a pmByID) Less(i, j int) bool {
+	return a[i].Id < a[j].Id
+}
+
+func (esi *MockIndex) AddPercolationQuery(id string, query piazza.JsonString) (*IndexResponse, error) {
+	obj := map[string]interface{}{}
+	err := json.Unmarshal([]byte(query), &obj)
+	if err != nil {
+		return nil, err
+	}
+	q, ok := obj[percolateFieldName].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("AddPercolationQuery: field %s must hold a query", percolateFieldName)
+	}
+
+	// reject, at registration, anything the evaluator can't handle
+	_, err = mockMatches(q, map[string]interface{}{})
+	if err != nil {
+		return nil, err
+	}
+
+	err = esi.requireType(percolateTypeName)
+	if IsTypeNotFound(err) {
+		mapping := fmt.Sprintf(`{"%s":{"properties":{"%s":{"type":"percolator"}}}}`,
+			percolateTypeName, percolateFieldName)
+		err = esi.SetMapping(percolateTypeName, piazza.JsonString(mapping))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// as Index does, refresh, so that documents are matched against it at once
+	resp, err := esi.PostData(percolateTypeName, id, obj)
+	if err != nil {
+		return nil, err
+	}
+	esi.mu.Lock()
+	defer esi.mu.Unlock()
+	esi.refresh()
+	return resp, nil
+}
+
+func (esi *MockIndex) DeletePercolationQuery(id string) (*DeleteResponse, error) {
+	return esi.DeleteByIDWait(percolateTypeName, id)
+}
+
+func (esi *MockIndex) AddPercolationDocument(typeName string, doc interface{}) (*PercolateResponse, error) {
+	ok, err := esi.IndexExists()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, indexNotFoundError(esi.name)
+	}
+
+	d, err := mockNormalize(doc)
+	if err != nil {
+		return nil, err
+	}
+	d["_type"] = typeName
+
+	resp := &PercolateResponse{Matches: []*PercolateResponseMatch{}}
+
+	esi.mu.Lock()
+	defer esi.mu.Unlock()
+	typ, ok := esi.searchable()[percolateTypeName]
+	if !ok {
+		return resp, nil
+	}
+
+	for id, raw := range typ.items {
+		q, _ := mockDocument(raw)[percolateFieldName].(map[string]interface{})
+		ok, err := mockMatches(q, d)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			resp.Matches = append(resp.Matches, &PercolateResponseMatch{Id: id, Index: esi.name})
+		}
+	}
+
+	sort.Sort(pmByID(resp.Matches))
+	resp.Total = int64(len(resp.Matches))
+	return resp, nil
 }
 
 func (esi *MockIndex) DirectAccess(verb string, endpoint string, input interface{}, output interface{}) error {
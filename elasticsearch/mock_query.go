@@ -0,0 +1,557 @@
+// Copyright 2016, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elasticsearch
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/venicegeo/pz-gocommon/elasticsearch/elastic-5-api"
+)
+
+// The mock query evaluator works on the JSON form of a query (as produced by
+// elastic.Query.Source, or as sent to SearchByJSON) so that typed queries and
+// raw DSL are treated alike.
+
+// mockQuerySource converts a query into its generic JSON form. A nil query is match_all.
+func mockQuerySource(query elastic.Query) (map[string]interface{}, error) {
+	if query == nil {
+		return map[string]interface{}{"match_all": map[string]interface{}{}}, nil
+	}
+	src, err := query.Source()
+	if err != nil {
+		return nil, err
+	}
+	return mockNormalize(src)
+}
+
+// mockNormalize round-trips a value through JSON so that all numbers are
+// float64 and all objects are map[string]interface{}.
+func mockNormalize(obj interface{}) (map[string]interface{}, error) {
+	byts, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]interface{}{}
+	err = json.Unmarshal(byts, &m)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// mockDocument decodes a stored document; non-object documents are treated as empty.
+func mockDocument(raw *json.RawMessage) map[string]interface{} {
+	doc := map[string]interface{}{}
+	if raw == nil {
+		return doc
+	}
+	var obj interface{}
+	if err := json.Unmarshal(*raw, &obj); err != nil {
+		return doc
+	}
+	if m, ok := obj.(map[string]interface{}); ok {
+		return m
+	}
+	return doc
+}
+
+// mockMatches reports whether the document satisfies the query.
+func mockMatches(query map[string]interface{}, doc map[string]interface{}) (bool, error) {
+	if len(query) != 1 {
+		return false, fmt.Errorf("query must have exactly one clause, got %d", len(query))
+	}
+
+	for kind, body := range query {
+		switch kind {
+		case "match_all":
+			return true, nil
+		case "term":
+			return mockMatchTerm(body, doc)
+		case "match":
+			return mockMatchMatch(body, doc)
+		case "bool":
+			return mockMatchBool(body, doc)
+		case "terms":
+			return mockMatchTerms(body, doc)
+		case "range":
+			return mockMatchRange(body, doc)
+		case "exists":
+			return mockMatchExists(body, doc)
+		case "prefix":
+			return mockMatchPrefix(body, doc)
+		case "wildcard":
+			return mockMatchWildcard(body, doc)
+		case "ids":
+			return mockMatchIds(body, doc)
+		case "geo_distance":
+			return mockMatchGeoDistance(body, doc)
+		case "geo_bounding_box":
+			return mockMatchGeoBoundingBox(body, doc)
+		case "geo_polygon":
+			return mockMatchGeoPolygon(body, doc)
+		case "geo_shape":
+			return mockMatchGeoShape(body, doc)
+		default:
+			return false, fmt.Errorf("query type %s not supported under mocking", kind)
+		}
+	}
+	return false, nil
+}
+
+// mockFieldClause splits a {"field": value} or {"field": {...}} clause,
+// returning the field name and its body.
+func mockFieldClause(body interface{}) (string, interface{}, error) {
+	m, ok := body.(map[string]interface{})
+	if !ok {
+		return "", nil, fmt.Errorf("malformed query clause: %v", body)
+	}
+	for field, value := range m {
+		if strings.HasPrefix(field, "_") && field != "_id" && field != "_type" {
+			continue
+		}
+		return field, value, nil
+	}
+	return "", nil, fmt.Errorf("query clause has no field: %v", body)
+}
+
+func mockMatchTerm(body interface{}, doc map[string]interface{}) (bool, error) {
+	field, value, err := mockFieldClause(body)
+	if err != nil {
+		return false, err
+	}
+	if m, ok := value.(map[string]interface{}); ok {
+		value = m["value"]
+	}
+	for _, actual := range mockLookup(doc, field) {
+		if mockEqual(actual, value) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func mockMatchTerms(body interface{}, doc map[string]interface{}) (bool, error) {
+	field, value, err := mockFieldClause(body)
+	if err != nil {
+		return false, err
+	}
+	values, ok := value.([]interface{})
+	if !ok {
+		return false, fmt.Errorf("terms query on %s requires an array of values", field)
+	}
+	for _, actual := range mockLookup(doc, field) {
+		for _, v := range values {
+			if mockEqual(actual, v) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// mockMatchRange accepts both the from/to/include_* form generated by
+// elastic.RangeQuery and the gt/gte/lt/lte form. Numbers compare as numbers,
+// everything else (e.g. ISO dates) as strings.
+func mockMatchRange(body interface{}, doc map[string]interface{}) (bool, error) {
+	field, value, err := mockFieldClause(body)
+	if err != nil {
+		return false, err
+	}
+	params, ok := value.(map[string]interface{})
+	if !ok {
+		return false, fmt.Errorf("malformed range query on %s", field)
+	}
+
+	lower, upper := params["from"], params["to"]
+	includeLower, includeUpper := true, true
+	if b, ok := params["include_lower"].(bool); ok {
+		includeLower = b
+	}
+	if b, ok := params["include_upper"].(bool); ok {
+		includeUpper = b
+	}
+	if v, ok := params["gt"]; ok {
+		lower, includeLower = v, false
+	}
+	if v, ok := params["gte"]; ok {
+		lower, includeLower = v, true
+	}
+	if v, ok := params["lt"]; ok {
+		upper, includeUpper = v, false
+	}
+	if v, ok := params["lte"]; ok {
+		upper, includeUpper = v, true
+	}
+
+	for _, actual := range mockLookup(doc, field) {
+		if lower != nil {
+			c := mockCompare(actual, lower)
+			if c < 0 || (c == 0 && !includeLower) {
+				continue
+			}
+		}
+		if upper != nil {
+			c := mockCompare(actual, upper)
+			if c > 0 || (c == 0 && !includeUpper) {
+				continue
+			}
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+func mockMatchExists(body interface{}, doc map[string]interface{}) (bool, error) {
+	m, ok := body.(map[string]interface{})
+	if !ok {
+		return false, fmt.Errorf("malformed exists query: %v", body)
+	}
+	field, ok := m["field"].(string)
+	if !ok {
+		return false, fmt.Errorf("exists query requires a field")
+	}
+	return len(mockLookup(doc, field)) > 0, nil
+}
+
+func mockMatchPrefix(body interface{}, doc map[string]interface{}) (bool, error) {
+	field, value, err := mockFieldClause(body)
+	if err != nil {
+		return false, err
+	}
+	if m, ok := value.(map[string]interface{}); ok {
+		value = m["value"]
+		if v, ok := m["prefix"]; ok {
+			value = v
+		}
+	}
+	prefix := fmt.Sprintf("%v", value)
+	for _, actual := range mockLookup(doc, field) {
+		if strings.HasPrefix(fmt.Sprintf("%v", actual), prefix) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func mockMatchWildcard(body interface{}, doc map[string]interface{}) (bool, error) {
+	field, value, err := mockFieldClause(body)
+	if err != nil {
+		return false, err
+	}
+	if m, ok := value.(map[string]interface{}); ok {
+		value = m["wildcard"]
+		if v, ok := m["value"]; ok {
+			value = v
+		}
+	}
+	pattern := regexp.QuoteMeta(fmt.Sprintf("%v", value))
+	pattern = strings.Replace(pattern, `\*`, ".*", -1)
+	pattern = strings.Replace(pattern, `\?`, ".", -1)
+	re, err := regexp.Compile("^" + pattern + "$")
+	if err != nil {
+		return false, err
+	}
+	for _, actual := range mockLookup(doc, field) {
+		if re.MatchString(fmt.Sprintf("%v", actual)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func mockMatchIds(body interface{}, doc map[string]interface{}) (bool, error) {
+	m, ok := body.(map[string]interface{})
+	if !ok {
+		return false, fmt.Errorf("malformed ids query: %v", body)
+	}
+
+	types := mockFlatten(m["type"])
+	if ts, ok := m["types"]; ok {
+		types = mockFlatten(ts)
+	}
+	if len(types) > 0 && types[0] != nil {
+		found := false
+		for _, t := range types {
+			found = found || mockEqual(t, doc["_type"])
+		}
+		if !found {
+			return false, nil
+		}
+	}
+
+	for _, id := range mockFlatten(m["values"]) {
+		if mockEqual(id, doc["_id"]) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// mockMatchMatch approximates a match query: strings are compared token by
+// token, as if analyzed by the standard analyzer; other values must be equal.
+func mockMatchMatch(body interface{}, doc map[string]interface{}) (bool, error) {
+	field, value, err := mockFieldClause(body)
+	if err != nil {
+		return false, err
+	}
+	operator := "or"
+	if m, ok := value.(map[string]interface{}); ok {
+		value = m["query"]
+		if op, ok := m["operator"].(string); ok {
+			operator = strings.ToLower(op)
+		}
+	}
+
+	actuals := mockLookup(doc, field)
+
+	str, ok := value.(string)
+	if !ok {
+		for _, actual := range actuals {
+			if mockEqual(actual, value) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	have := map[string]bool{}
+	for _, actual := range actuals {
+		for _, token := range mockTokens(fmt.Sprintf("%v", actual)) {
+			have[token] = true
+		}
+	}
+
+	want := mockTokens(str)
+	if len(want) == 0 {
+		return false, nil
+	}
+	for _, token := range want {
+		if have[token] && operator != "and" {
+			return true, nil
+		}
+		if !have[token] && operator == "and" {
+			return false, nil
+		}
+	}
+	return operator == "and", nil
+}
+
+// mockTokens splits text into lowercased runs of letters and digits.
+func mockTokens(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), mockSeparator)
+}
+
+// mockTokenSpans returns the byte offsets of the start and end of each of
+// the tokens mockTokens finds in text.
+func mockTokenSpans(text string) [][2]int {
+	spans := [][2]int{}
+	start := -1
+	for i, r := range text {
+		switch {
+		case !mockSeparator(r) && start < 0:
+			start = i
+		case mockSeparator(r) && start >= 0:
+			spans = append(spans, [2]int{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, [2]int{start, len(text)})
+	}
+	return spans
+}
+
+func mockSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
+
+func mockMatchBool(body interface{}, doc map[string]interface{}) (bool, error) {
+	m, ok := body.(map[string]interface{})
+	if !ok {
+		return false, fmt.Errorf("malformed bool query: %v", body)
+	}
+
+	count := func(occur string) (int, int, error) {
+		clauses := mockClauses(m[occur])
+		matched := 0
+		for _, clause := range clauses {
+			ok, err := mockMatches(clause, doc)
+			if err != nil {
+				return 0, 0, err
+			}
+			if ok {
+				matched++
+			}
+		}
+		return matched, len(clauses), nil
+	}
+
+	for _, occur := range []string{"must", "filter"} {
+		matched, total, err := count(occur)
+		if err != nil {
+			return false, err
+		}
+		if matched != total {
+			return false, nil
+		}
+	}
+
+	matched, _, err := count("must_not")
+	if err != nil {
+		return false, err
+	}
+	if matched != 0 {
+		return false, nil
+	}
+
+	matched, total, err := count("should")
+	if err != nil {
+		return false, err
+	}
+	required := 0
+	if total > 0 && len(mockClauses(m["must"])) == 0 && len(mockClauses(m["filter"])) == 0 {
+		required = 1
+	}
+	if msm, ok := m["minimum_should_match"]; ok {
+		required, err = mockMinimumShouldMatch(msm, total)
+		if err != nil {
+			return false, err
+		}
+	}
+	return matched >= required, nil
+}
+
+// mockClauses returns the clauses of a bool occurrence, which may be given
+// as a single query or as an array of queries.
+func mockClauses(v interface{}) []map[string]interface{} {
+	clauses := []map[string]interface{}{}
+	for _, e := range mockFlatten(v) {
+		if clause, ok := e.(map[string]interface{}); ok {
+			clauses = append(clauses, clause)
+		}
+	}
+	return clauses
+}
+
+// mockMinimumShouldMatch supports the integer and percentage forms, positive or negative.
+func mockMinimumShouldMatch(v interface{}, total int) (int, error) {
+	if n, ok := mockNumber(v); ok {
+		v = strconv.Itoa(int(n))
+	}
+	str := fmt.Sprintf("%v", v)
+
+	var required int
+	if strings.HasSuffix(str, "%") {
+		pct, err := strconv.Atoi(strings.TrimSuffix(str, "%"))
+		if err != nil {
+			return 0, fmt.Errorf("unsupported minimum_should_match: %v", v)
+		}
+		required = total * pct / 100
+	} else {
+		n, err := strconv.Atoi(str)
+		if err != nil {
+			return 0, fmt.Errorf("unsupported minimum_should_match: %v", v)
+		}
+		required = n
+	}
+	if required < 0 {
+		required += total
+	}
+	return required, nil
+}
+
+// mockLookup returns the values at a dotted field path. Arrays, at any level,
+// contribute each of their elements.
+func mockLookup(doc map[string]interface{}, field string) []interface{} {
+	values := []interface{}{doc}
+	for _, part := range strings.Split(field, ".") {
+		next := []interface{}{}
+		for _, v := range values {
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			child, ok := m[part]
+			if !ok || child == nil {
+				continue
+			}
+			next = append(next, mockFlatten(child)...)
+		}
+		values = next
+	}
+	return values
+}
+
+func mockFlatten(v interface{}) []interface{} {
+	arr, ok := v.([]interface{})
+	if !ok {
+		return []interface{}{v}
+	}
+	out := []interface{}{}
+	for _, e := range arr {
+		out = append(out, mockFlatten(e)...)
+	}
+	return out
+}
+
+// mockEqual compares two JSON scalars, treating all numbers alike.
+func mockEqual(a, b interface{}) bool {
+	fa, aok := mockNumber(a)
+	fb, bok := mockNumber(b)
+	if aok && bok {
+		return fa == fb
+	}
+	return fmt.Sprintf("%v", a) == fmt.Sprintf("%v", b)
+}
+
+// mockCompare orders two JSON scalars, numerically if both are numbers.
+func mockCompare(a, b interface{}) int {
+	fa, aok := mockNumber(a)
+	fb, bok := mockNumber(b)
+	if aok && bok {
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(fmt.Sprintf("%v", a), fmt.Sprintf("%v", b))
+}
+
+func mockNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,158 @@
+// Copyright 2016, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elasticsearch
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// By default a MockIndex makes each write visible to searches at once. In
+// near-real-time mode it behaves as Elasticsearch does: a document can be
+// got by id as soon as it is written, but searches, and so deletes and
+// updates by query, see it only once the index has been refreshed, either
+// periodically or when asked. Code that searches for what it has just
+// written without refreshing then fails under test as it would against a
+// cluster.
+
+// mockChange is a write, or a delete if source is nil, that searches don't
+// see yet.
+type mockChange struct {
+	at      time.Time
+	typ     string
+	id      string
+	source  *json.RawMessage
+	version int
+}
+
+// mockRefresher holds what the searches of an index in near-real-time mode
+// see: the documents as of its last refresh.
+type mockRefresher struct {
+	// interval is the time between periodic refreshes, counted from start;
+	// if it isn't positive, the index is refreshed only when asked.
+	interval time.Duration
+	start    time.Time
+	now      func() time.Time
+
+	visible map[string]*MockIndexType
+	pending []*mockChange
+}
+
+// SetNearRealTime puts the index in near-real-time mode, refreshing it every
+// interval or, if interval isn't positive, only on Refresh, DeleteByIDWait
+// and AddPercolationQuery. The documents already in the index are visible.
+// Indices sharing the data, through an alias or WithContext, share the mode.
+func (esi *MockIndex) SetNearRealTime(interval time.Duration) {
+	esi.mu.Lock()
+	defer esi.mu.Unlock()
+
+	nrt := &mockRefresher{
+		interval: interval,
+		start:    time.Now(),
+		now:      time.Now,
+		visible:  make(map[string]*MockIndexType),
+	}
+	for tk, tv := range esi.types {
+		typ := newMockIndexType(nil)
+		for ik, iv := range tv.items {
+			typ.items[ik] = iv
+			typ.versions[ik] = tv.versions[ik]
+		}
+		nrt.visible[tk] = typ
+	}
+	esi.nrt = nrt
+}
+
+// Refresh makes every change visible to searches. Outside near-real-time
+// mode they are already.
+func (esi *MockIndex) Refresh() error {
+	if err := esi.ctxErr(); err != nil {
+		return err
+	}
+	ok, err := esi.IndexExists()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return indexNotFoundError(esi.name)
+	}
+	for _, data := range esi.members() {
+		data.mu.Lock()
+		data.refresh()
+		data.mu.Unlock()
+	}
+	return nil
+}
+
+// changed records a write, or a delete if source is nil, for the next
+// refresh to make visible. data.mu must be held.
+func (data *mockIndexData) changed(typeName string, id string, source *json.RawMessage, version int) {
+	if data.nrt == nil {
+		return
+	}
+	c := &mockChange{at: data.nrt.now(), typ: typeName, id: id, source: source, version: version}
+	data.nrt.pending = append(data.nrt.pending, c)
+}
+
+// refresh makes every change visible. data.mu must be held.
+func (data *mockIndexData) refresh() {
+	if data.nrt != nil {
+		data.nrt.apply(len(data.nrt.pending))
+	}
+}
+
+// searchable returns the types as searches see them, first making visible
+// the changes made before the latest periodic refresh. data.mu must be held.
+func (data *mockIndexData) searchable() map[string]*MockIndexType {
+	if data.nrt == nil {
+		return data.types
+	}
+	nrt := data.nrt
+	if nrt.interval > 0 {
+		elapsed := nrt.now().Sub(nrt.start)
+		last := nrt.start.Add(elapsed - elapsed%nrt.interval)
+		n := 0
+		for n < len(nrt.pending) && nrt.pending[n].at.Before(last) {
+			n++
+		}
+		nrt.apply(n)
+	}
+	return nrt.visible
+}
+
+// apply makes the first n pending changes visible.
+func (nrt *mockRefresher) apply(n int) {
+	for _, c := range nrt.pending[:n] {
+		typ, ok := nrt.visible[c.typ]
+		if !ok {
+			typ = newMockIndexType(nil)
+			nrt.visible[c.typ] = typ
+		}
+		if c.source == nil {
+			delete(typ.items, c.id)
+			delete(typ.versions, c.id)
+		} else {
+			typ.items[c.id] = c.source
+			typ.versions[c.id] = c.version
+		}
+	}
+	nrt.pending = nrt.pending[n:]
+}
+
+// reset forgets every document, as when the index is deleted.
+func (nrt *mockRefresher) reset() {
+	nrt.visible = make(map[string]*MockIndexType)
+	nrt.pending = nil
+}
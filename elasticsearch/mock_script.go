@@ -0,0 +1,206 @@
+// Copyright 2016, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elasticsearch
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/venicegeo/pz-gocommon/elasticsearch/elastic-5-api"
+)
+
+// An in-process stand-in for the painless scripts that set and clear
+// fields, which are most of those given to UpdateByQuery. A script is a
+// sequence of statements, separated by semicolons, each of the form
+//
+//	ctx._source.<field> = <value>
+//	ctx._source.remove('<field>')
+//
+// where ctx._source.<field> may be a dotted path through objects, as may
+// the object remove is called on, and <value> is params.<name>, a quoted
+// string, a number, true, false or null. Anything else is rejected.
+
+var (
+	mockScriptRemove = regexp.MustCompile(`^ctx\._source((?:\.[A-Za-z_][A-Za-z0-9_]*)*)\.remove\(\s*(?:'([^']*)'|"([^"]*)")\s*\)$`)
+	mockScriptAssign = regexp.MustCompile(`^ctx\._source((?:\.[A-Za-z_][A-Za-z0-9_]*)+)\s*=\s*([^=].*)$`)
+	mockScriptParam  = regexp.MustCompile(`^params\.([A-Za-z_][A-Za-z0-9_]*)$`)
+)
+
+// mockStatement applies one statement of a script to a document's source.
+type mockStatement func(source map[string]interface{}) error
+
+type mockScript struct {
+	statements []mockStatement
+}
+
+// newMockScript parses a script, failing, as Elasticsearch fails to compile
+// a script, before any document is touched.
+func newMockScript(script *elastic.Script) (*mockScript, error) {
+	src, err := script.Source()
+	if err != nil {
+		return nil, err
+	}
+
+	var text string
+	params := map[string]interface{}{}
+	switch src := src.(type) {
+	case string:
+		text = src
+	case map[string]interface{}:
+		for k, v := range src {
+			switch k {
+			case "inline":
+				text, _ = v.(string)
+			case "lang":
+				if v != "painless" {
+					return nil, fmt.Errorf("script language %v not supported under mocking", v)
+				}
+			case "params":
+				params, _ = v.(map[string]interface{})
+			default:
+				return nil, fmt.Errorf("%s scripts not supported under mocking", k)
+			}
+		}
+	}
+
+	s := &mockScript{}
+	for _, stmt := range splitMockScript(text) {
+		stmt = strings.TrimSpace(stmt)
+		if stmt == "" {
+			continue
+		}
+		f, err := parseMockStatement(stmt, params)
+		if err != nil {
+			return nil, err
+		}
+		s.statements = append(s.statements, f)
+	}
+	if len(s.statements) == 0 {
+		return nil, fmt.Errorf("empty script")
+	}
+	return s, nil
+}
+
+// splitMockScript splits a script at the semicolons outside quotes.
+func splitMockScript(text string) []string {
+	stmts := []string{}
+	var quote rune
+	start := 0
+	for i, r := range text {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == ';':
+			stmts = append(stmts, text[start:i])
+			start = i + 1
+		}
+	}
+	return append(stmts, text[start:])
+}
+
+func parseMockStatement(stmt string, params map[string]interface{}) (mockStatement, error) {
+	if m := mockScriptRemove.FindStringSubmatch(stmt); m != nil {
+		path := mockScriptPath(m[1])
+		field := m[2] + m[3]
+		return func(source map[string]interface{}) error {
+			obj, err := mockScriptObject(source, path)
+			if err != nil {
+				return err
+			}
+			delete(obj, field)
+			return nil
+		}, nil
+	}
+
+	if m := mockScriptAssign.FindStringSubmatch(stmt); m != nil {
+		path := mockScriptPath(m[1])
+		value, err := parseMockValue(strings.TrimSpace(m[2]), params)
+		if err != nil {
+			return nil, err
+		}
+		parent, field := path[:len(path)-1], path[len(path)-1]
+		return func(source map[string]interface{}) error {
+			obj, err := mockScriptObject(source, parent)
+			if err != nil {
+				return err
+			}
+			obj[field] = value
+			return nil
+		}, nil
+	}
+
+	return nil, fmt.Errorf("script statement %q not supported under mocking", stmt)
+}
+
+// mockScriptPath splits ".a.b" into its fields.
+func mockScriptPath(dotted string) []string {
+	if dotted == "" {
+		return nil
+	}
+	return strings.Split(dotted[1:], ".")
+}
+
+// mockScriptObject returns the object at path in source, failing, as a
+// painless script would, if there is none.
+func mockScriptObject(source map[string]interface{}, path []string) (map[string]interface{}, error) {
+	obj := source
+	for i, field := range path {
+		next, ok := obj[field].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("ctx._source.%s is not an object", strings.Join(path[:i+1], "."))
+		}
+		obj = next
+	}
+	return obj, nil
+}
+
+// parseMockValue returns the value of the right-hand side of an assignment.
+// A missing parameter is null, as it is to painless.
+func parseMockValue(expr string, params map[string]interface{}) (interface{}, error) {
+	if m := mockScriptParam.FindStringSubmatch(expr); m != nil {
+		return params[m[1]], nil
+	}
+	switch expr {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	if n := len(expr); n >= 2 && (expr[0] == '\'' || expr[0] == '"') && expr[n-1] == expr[0] {
+		return expr[1 : n-1], nil
+	}
+	if f, err := strconv.ParseFloat(expr, 64); err == nil {
+		return f, nil
+	}
+	return nil, fmt.Errorf("script value %q not supported under mocking", expr)
+}
+
+// run applies the script to a document's source.
+func (s *mockScript) run(source map[string]interface{}) error {
+	for _, stmt := range s.statements {
+		if err := stmt(source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
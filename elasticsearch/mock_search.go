@@ -0,0 +1,385 @@
+// Copyright 2016, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elasticsearch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/venicegeo/pz-gocommon/elasticsearch/elastic-5-api"
+	"github.com/venicegeo/pz-gocommon/gocommon"
+)
+
+// mockDefaultSize is the number of hits Elasticsearch returns when the
+// request doesn't say.
+const mockDefaultSize = 10
+
+// mockSearchRequest is the part of a search request the mock honors.
+type mockSearchRequest struct {
+	query map[string]interface{}
+	from  int
+	size  int
+	sort  []*mockSortKey
+	aggs  map[string]interface{}
+
+	highlight map[string]interface{}
+}
+
+type mockSortKey struct {
+	field string
+	asc   bool
+}
+
+// newMockSearchRequest builds a request from a typed query and the
+// pagination parameters. A nil query is match_all.
+func newMockSearchRequest(query elastic.Query, realFormat *piazza.JsonPagination) (*mockSearchRequest, error) {
+	q, err := mockQuerySource(query)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &mockSearchRequest{query: q, size: mockDefaultSize}
+	if realFormat != nil {
+		format := NewQueryFormat(realFormat)
+		req.from = format.From
+		req.size = format.Size
+		if format.Key != "" {
+			req.sort = []*mockSortKey{{field: format.Key, asc: format.Order}}
+		}
+	}
+	return req, nil
+}
+
+// parseMockSearchRequest reads a search body, as given to SearchByJSON.
+// Options the mock can't honor are rejected rather than ignored.
+func parseMockSearchRequest(jsn string) (*mockSearchRequest, error) {
+	body := map[string]interface{}{}
+	err := json.Unmarshal([]byte(jsn), &body)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &mockSearchRequest{
+		query: map[string]interface{}{"match_all": map[string]interface{}{}},
+		size:  mockDefaultSize,
+	}
+	for k, v := range body {
+		switch k {
+		case "query":
+			q, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("malformed query: %v", v)
+			}
+			req.query = q
+		case "from", "size":
+			n, ok := mockNumber(v)
+			if !ok || n < 0 {
+				return nil, fmt.Errorf("invalid %s: %v", k, v)
+			}
+			if k == "from" {
+				req.from = int(n)
+			} else {
+				req.size = int(n)
+			}
+		case "sort":
+			req.sort, err = mockParseSort(v)
+			if err != nil {
+				return nil, err
+			}
+		case "aggs", "aggregations":
+			aggs, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("malformed aggregations: %v", v)
+			}
+			req.aggs = aggs
+		case "highlight":
+			highlight, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("malformed highlight: %v", v)
+			}
+			req.highlight = highlight
+		case "timeout", "track_scores", "version":
+		default:
+			return nil, fmt.Errorf("search option %s not supported under mocking", k)
+		}
+	}
+	return req, nil
+}
+
+// mockParseSort reads the "sort" option: a field name, a {field: order} or
+// {field: {"order": order}} object, or an array of these.
+func mockParseSort(v interface{}) ([]*mockSortKey, error) {
+	keys := []*mockSortKey{}
+	for _, s := range mockFlatten(v) {
+		switch s := s.(type) {
+		case string:
+			// _score sorts descending by default, everything else ascending
+			keys = append(keys, &mockSortKey{field: s, asc: s != "_score"})
+		case map[string]interface{}:
+			for field, order := range s {
+				if m, ok := order.(map[string]interface{}); ok {
+					order = m["order"]
+				}
+				switch order {
+				case "asc", nil:
+					keys = append(keys, &mockSortKey{field: field, asc: true})
+				case "desc":
+					keys = append(keys, &mockSortKey{field: field, asc: false})
+				default:
+					return nil, fmt.Errorf("invalid sort order for %s: %v", field, order)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("malformed sort: %v", s)
+		}
+	}
+	return keys, nil
+}
+
+// mockSortValue returns the value a hit sorts by: for a multi-valued field,
+// the lowest value when ascending and the highest when descending.
+func mockSortValue(hit *mockHit, key *mockSortKey) (interface{}, bool) {
+	var values []interface{}
+	if key.field == "_uid" {
+		values = []interface{}{hit.uid()}
+	} else {
+		values = mockLookup(hit.doc, key.field)
+	}
+	if len(values) == 0 {
+		return nil, false
+	}
+
+	best := values[0]
+	for _, v := range values[1:] {
+		cmp := mockCompare(v, best)
+		if (key.asc && cmp < 0) || (!key.asc && cmp > 0) {
+			best = v
+		}
+	}
+	return best, true
+}
+
+// mockSortHits orders the hits by the sort keys. Hits missing a key sort
+// after those that have it, in either order; remaining ties are broken by id.
+func mockSortHits(hits []*mockHit, keys []*mockSortKey) {
+	sort.SliceStable(hits, func(i, j int) bool {
+		for _, key := range keys {
+			// every hit scores alike, and index order is id order
+			if key.field == "_score" || key.field == "_doc" {
+				continue
+			}
+			a, aok := mockSortValue(hits[i], key)
+			b, bok := mockSortValue(hits[j], key)
+			switch {
+			case aok && !bok:
+				return true
+			case !aok && bok:
+				return false
+			case !aok && !bok:
+				continue
+			}
+			cmp := mockCompare(a, b)
+			if cmp != 0 {
+				return (cmp < 0) == key.asc
+			}
+		}
+		if hits[i].id != hits[j].id {
+			return hits[i].id < hits[j].id
+		}
+		return hits[i].uid() < hits[j].uid()
+	})
+}
+
+// execute runs the request against the documents of the type (or of all
+// types, if typeName is empty).
+func (esi *MockIndex) execute(typeName string, req *mockSearchRequest) (*SearchResult, error) {
+	hits, err := esi.search(typeName, req.query)
+	if err != nil {
+		return nil, err
+	}
+	mockSortHits(hits, req.sort)
+
+	resp := &SearchResult{
+		totalHits: int64(len(hits)),
+		hits:      make([]*SearchResultHit, 0),
+		Found:     true,
+	}
+
+	if req.aggs != nil {
+		docs := make([]map[string]interface{}, len(hits))
+		for i, hit := range hits {
+			docs[i] = hit.doc
+		}
+		resp.Aggregations, err = mockAggregationResults(req.aggs, docs)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	from, size := req.from, req.size
+	if from > len(hits) {
+		from = len(hits)
+	}
+	if from+size < len(hits) {
+		hits = hits[from : from+size]
+	} else {
+		hits = hits[from:]
+	}
+	for _, hit := range hits {
+		r := hit.result(req.sort)
+		if req.highlight != nil {
+			r.Highlight, err = mockHighlight(req.highlight, req.query, hit.doc)
+			if err != nil {
+				return nil, err
+			}
+		}
+		resp.hits = append(resp.hits, r)
+	}
+
+	return resp, nil
+}
+
+// mockHighlight approximates the highlighter: the terms the query looks for
+// in a field are marked wherever they occur in it, and each value of the
+// field with a mark is a fragment of its own.
+func mockHighlight(highlight map[string]interface{}, query map[string]interface{}, doc map[string]interface{}) (map[string][]string, error) {
+	fields := map[string]interface{}{}
+	switch f := highlight["fields"].(type) {
+	case map[string]interface{}:
+		fields = f
+	case []interface{}:
+		for _, e := range f {
+			m, ok := e.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("malformed highlight fields: %v", f)
+			}
+			for name, opts := range m {
+				fields[name] = opts
+			}
+		}
+	}
+
+	terms := map[string]map[string]bool{}
+	mockQueryTerms(query, terms)
+
+	result := map[string][]string{}
+	for name, opts := range fields {
+		pre, post := mockHighlightTags(highlight, "<em>", "</em>")
+		if m, ok := opts.(map[string]interface{}); ok {
+			pre, post = mockHighlightTags(m, pre, post)
+		}
+		if len(terms[name]) == 0 {
+			continue
+		}
+		for _, v := range mockLookup(doc, name) {
+			text, ok := v.(string)
+			if !ok {
+				continue
+			}
+			if fragment, marked := mockMark(text, terms[name], pre, post); marked {
+				result[name] = append(result[name], fragment)
+			}
+		}
+	}
+	if len(result) == 0 {
+		return nil, nil
+	}
+	return result, nil
+}
+
+// mockHighlightTags returns the first of the pre and post tags given in
+// opts, or the defaults.
+func mockHighlightTags(opts map[string]interface{}, pre, post string) (string, string) {
+	if tags, ok := opts["pre_tags"].([]interface{}); ok && len(tags) > 0 {
+		pre = fmt.Sprintf("%v", tags[0])
+	}
+	if tags, ok := opts["post_tags"].([]interface{}); ok && len(tags) > 0 {
+		post = fmt.Sprintf("%v", tags[0])
+	}
+	return pre, post
+}
+
+// mockQueryTerms collects, by field, the lowercased terms the query looks
+// for. Clauses which exclude documents contribute nothing.
+func mockQueryTerms(query map[string]interface{}, terms map[string]map[string]bool) {
+	add := func(field string, values ...interface{}) {
+		if terms[field] == nil {
+			terms[field] = map[string]bool{}
+		}
+		for _, v := range values {
+			for _, token := range mockTokens(fmt.Sprintf("%v", v)) {
+				terms[field][token] = true
+			}
+		}
+	}
+
+	for kind, body := range query {
+		switch kind {
+		case "match", "term":
+			field, value, err := mockFieldClause(body)
+			if err != nil {
+				continue
+			}
+			if m, ok := value.(map[string]interface{}); ok {
+				value = m["query"]
+				if kind == "term" {
+					value = m["value"]
+				}
+			}
+			add(field, value)
+		case "terms":
+			field, value, err := mockFieldClause(body)
+			if err != nil {
+				continue
+			}
+			add(field, mockFlatten(value)...)
+		case "bool":
+			m, ok := body.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for _, occur := range []string{"must", "filter", "should"} {
+				for _, clause := range mockClauses(m[occur]) {
+					mockQueryTerms(clause, terms)
+				}
+			}
+		}
+	}
+}
+
+// mockMark wraps the tokens of text found in terms in the tags, and reports
+// whether there were any.
+func mockMark(text string, terms map[string]bool, pre, post string) (string, bool) {
+	var b bytes.Buffer
+	marked := false
+	last := 0
+	for _, span := range mockTokenSpans(text) {
+		token := text[span[0]:span[1]]
+		if !terms[strings.ToLower(token)] {
+			continue
+		}
+		b.WriteString(text[last:span[0]])
+		b.WriteString(pre)
+		b.WriteString(token)
+		b.WriteString(post)
+		last = span[1]
+		marked = true
+	}
+	b.WriteString(text[last:])
+	return b.String(), marked
+}
@@ -0,0 +1,238 @@
+// Copyright 2016, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elasticsearch
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// The mock suggesters work, like the mock queries, on the JSON form of the
+// suggester. Completion and term suggesters are approximated; the phrase
+// suggester's language model isn't.
+
+// mockDefaultSuggestSize is the number of options a suggester returns when
+// the request doesn't say.
+const mockDefaultSuggestSize = 5
+
+func mockSuggest(body map[string]interface{}, hits []*mockHit) ([]*Suggestion, error) {
+	for kind, v := range body {
+		opts, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		switch kind {
+		case "completion":
+			return mockComplete(body, opts, hits)
+		case "term":
+			return mockSuggestTerms(body, opts, hits)
+		case "phrase":
+			return nil, fmt.Errorf("phrase suggester not supported under mocking")
+		}
+	}
+	return nil, fmt.Errorf("malformed suggester: %v", body)
+}
+
+func mockSuggestSize(opts map[string]interface{}) int {
+	if n, ok := mockNumber(opts["size"]); ok {
+		return int(n)
+	}
+	return mockDefaultSuggestSize
+}
+
+// mockComplete finds the documents with an input of the completion field
+// starting with the prefix, case aside, best weighted first. A document is
+// suggested once, for the first of its inputs that matches.
+func mockComplete(body map[string]interface{}, opts map[string]interface{}, hits []*mockHit) ([]*Suggestion, error) {
+	for _, k := range []string{"regex", "fuzzy", "contexts"} {
+		if body[k] != nil || opts[k] != nil {
+			return nil, fmt.Errorf("completion suggester option %s not supported under mocking", k)
+		}
+	}
+	field, _ := opts["field"].(string)
+	prefix, _ := body["prefix"].(string)
+	lower := strings.ToLower(prefix)
+
+	options := []*SuggestionOption{}
+	for _, hit := range hits {
+	values:
+		for _, v := range mockLookup(hit.doc, field) {
+			inputs := []interface{}{v}
+			weight := 1.0
+			if m, ok := v.(map[string]interface{}); ok {
+				inputs = mockFlatten(m["input"])
+				if w, ok := mockNumber(m["weight"]); ok {
+					weight = w
+				}
+			}
+			for _, input := range inputs {
+				text, ok := input.(string)
+				if ok && strings.HasPrefix(strings.ToLower(text), lower) {
+					options = append(options, &SuggestionOption{
+						Text:   text,
+						Score:  weight,
+						ID:     hit.id,
+						Type:   hit.typ,
+						Source: hit.source,
+					})
+					break values
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(options, func(i, j int) bool {
+		if options[i].Score != options[j].Score {
+			return options[i].Score > options[j].Score
+		}
+		return options[i].Text < options[j].Text
+	})
+	if size := mockSuggestSize(opts); len(options) > size {
+		options = options[:size]
+	}
+
+	suggestion := &Suggestion{Text: prefix, Length: utf8.RuneCountInString(prefix), Options: options}
+	return []*Suggestion{suggestion}, nil
+}
+
+// mockSuggestTerms suggests, for each token of the text, the tokens of the
+// field within the edit distance, scored as Lucene's spell checker does.
+func mockSuggestTerms(body map[string]interface{}, opts map[string]interface{}, hits []*mockHit) ([]*Suggestion, error) {
+	text, ok := body["text"].(string)
+	if !ok {
+		return nil, fmt.Errorf("term suggester requires text")
+	}
+	field, _ := opts["field"].(string)
+	mode := "missing"
+	if m, ok := opts["suggest_mode"].(string); ok {
+		mode = m
+	}
+	byFrequency := opts["sort"] == "frequency"
+	setting := func(name string, def int) int {
+		if n, ok := mockNumber(opts[name]); ok {
+			return int(n)
+		}
+		return def
+	}
+	maxEdits := setting("max_edits", 2)
+	prefixLength := setting("prefix_length", 1)
+	minWordLength := setting("min_word_length", 4)
+
+	// the number of documents each token of the field occurs in
+	freq := map[string]int{}
+	for _, hit := range hits {
+		seen := map[string]bool{}
+		for _, v := range mockLookup(hit.doc, field) {
+			for _, token := range mockTokens(fmt.Sprintf("%v", v)) {
+				if !seen[token] {
+					seen[token] = true
+					freq[token]++
+				}
+			}
+		}
+	}
+
+	suggestions := []*Suggestion{}
+	for _, span := range mockTokenSpans(text) {
+		original := text[span[0]:span[1]]
+		token := strings.ToLower(original)
+		s := &Suggestion{
+			Text:    original,
+			Offset:  utf8.RuneCountInString(text[:span[0]]),
+			Length:  utf8.RuneCountInString(original),
+			Options: []*SuggestionOption{},
+		}
+		suggestions = append(suggestions, s)
+
+		if utf8.RuneCountInString(token) < minWordLength || (mode == "missing" && freq[token] > 0) {
+			continue
+		}
+		for term, n := range freq {
+			if term == token || (mode == "popular" && n <= freq[token]) || !mockSharePrefix(term, token, prefixLength) {
+				continue
+			}
+			edits := mockEditDistance(token, term)
+			if edits > maxEdits {
+				continue
+			}
+			shorter := utf8.RuneCountInString(token)
+			if l := utf8.RuneCountInString(term); l < shorter {
+				shorter = l
+			}
+			score := 1 - float64(edits)/float64(shorter)
+			s.Options = append(s.Options, &SuggestionOption{Text: term, Score: score, Freq: n})
+		}
+
+		sort.Slice(s.Options, func(i, j int) bool {
+			a, b := s.Options[i], s.Options[j]
+			if byFrequency && a.Freq != b.Freq {
+				return a.Freq > b.Freq
+			}
+			if a.Score != b.Score {
+				return a.Score > b.Score
+			}
+			if a.Freq != b.Freq {
+				return a.Freq > b.Freq
+			}
+			return a.Text < b.Text
+		})
+		if size := mockSuggestSize(opts); len(s.Options) > size {
+			s.Options = s.Options[:size]
+		}
+	}
+	return suggestions, nil
+}
+
+func mockSharePrefix(a, b string, n int) bool {
+	ra, rb := []rune(a), []rune(b)
+	if len(ra) < n || len(rb) < n {
+		return false
+	}
+	return string(ra[:n]) == string(rb[:n])
+}
+
+// mockEditDistance is the Levenshtein distance between a and b.
+func mockEditDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur := make([]int, len(rb)+1)
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = mockMin(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(rb)]
+}
+
+func mockMin(values ...int) int {
+	min := values[0]
+	for _, v := range values[1:] {
+		if v < min {
+			min = v
+		}
+	}
+	return min
+}
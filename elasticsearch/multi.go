@@ -0,0 +1,63 @@
+// Copyright 2016, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elasticsearch
+
+import (
+	"github.com/venicegeo/pz-gocommon/elasticsearch/elastic-5-api"
+	"github.com/venicegeo/pz-gocommon/gocommon"
+)
+
+// SearchRequest is one of the searches run together by IIndex.MultiSearch.
+type SearchRequest struct {
+	// Type is the type searched, or "" for every type.
+	Type string
+
+	// Query selects the documents; nil matches them all.
+	Query elastic.Query
+
+	// Format pages and sorts the hits; nil leaves Elasticsearch's defaults.
+	Format *piazza.JsonPagination
+}
+
+func (r *SearchRequest) query() elastic.Query {
+	if r.Query == nil {
+		return elastic.NewMatchAllQuery()
+	}
+	return r.Query
+}
+
+func (r *SearchRequest) request() *elastic.SearchRequest {
+	src := elastic.NewSearchSource().Query(searchQuery(r.Type, r.Query)).Version(true)
+	if r.Format != nil {
+		format := NewQueryFormat(r.Format)
+		src = src.From(format.From).Size(format.Size)
+		if format.Key != "" {
+			src = src.Sort(format.Key, format.Order)
+		}
+	}
+
+	req := elastic.NewSearchRequest().SearchSource(src)
+	if r.Type != "" {
+		req = req.Type(r.Type)
+	}
+	return req
+}
+
+// MultiSearchItem is the outcome of one of the searches of a MultiSearch.
+// Exactly one of Result and Error is set.
+type MultiSearchItem struct {
+	Result *SearchResult
+	Error  string
+}
@@ -0,0 +1,76 @@
+// Copyright 2016, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elasticsearch
+
+import "time"
+
+// IndexOptions configures an Index beyond its connection parameters.
+type IndexOptions struct {
+	// ReadTimeout bounds each existence check, get and search.
+	ReadTimeout time.Duration
+
+	// WriteTimeout bounds each document write and delete, including bulk requests.
+	WriteTimeout time.Duration
+
+	// AdminTimeout bounds each index and mapping operation.
+	AdminTimeout time.Duration
+
+	// Logger, if set, receives the Elasticsearch client's messages. Trace
+	// adds every request and response in full.
+	Logger Logger
+	Trace  bool
+
+	// SlowThreshold, if positive, is the time beyond which a request is
+	// logged as slow, with its index, type, duration and body.
+	SlowThreshold time.Duration
+}
+
+// The timeouts used where IndexOptions leaves them zero. A negative
+// timeout means no limit beyond the caller's context.
+const (
+	DefaultReadTimeout  = 30 * time.Second
+	DefaultWriteTimeout = 30 * time.Second
+	DefaultAdminTimeout = 60 * time.Second
+)
+
+// NewIndexOptions returns the default options.
+func NewIndexOptions() *IndexOptions {
+	return &IndexOptions{
+		ReadTimeout:  DefaultReadTimeout,
+		WriteTimeout: DefaultWriteTimeout,
+		AdminTimeout: DefaultAdminTimeout,
+	}
+}
+
+// withDefaults returns a copy of the options with zero values filled in.
+func (o *IndexOptions) withDefaults() *IndexOptions {
+	opts := NewIndexOptions()
+	if o == nil {
+		return opts
+	}
+	if o.ReadTimeout != 0 {
+		opts.ReadTimeout = o.ReadTimeout
+	}
+	if o.WriteTimeout != 0 {
+		opts.WriteTimeout = o.WriteTimeout
+	}
+	if o.AdminTimeout != 0 {
+		opts.AdminTimeout = o.AdminTimeout
+	}
+	opts.Logger = o.Logger
+	opts.Trace = o.Trace
+	opts.SlowThreshold = o.SlowThreshold
+	return opts
+}
@@ -16,19 +16,76 @@ package elasticsearch
 
 import (
 	"encoding/json"
+	"fmt"
 
 	"github.com/venicegeo/pz-gocommon/elasticsearch/elastic-5-api"
 )
 
+// SearchResultHit is a document found by a search, with what Elasticsearch
+// had to say about it.
 type SearchResultHit struct {
 	ID     string
 	Source *json.RawMessage
+
+	Index string
+	Type  string
+
+	// Score is how well the document matched the query. It is zero if the
+	// hits were sorted on something else.
+	Score float64
+
+	// Version is the document's version. The searches of IIndex ask for
+	// it, but SearchByJSON only gets it if the query does.
+	Version int
+
+	// Sort holds the document's value for each of the sort keys.
+	Sort []interface{}
+
+	// Highlight holds, for each field highlighted, the fragments that
+	// matched.
+	Highlight map[string][]string
+}
+
+func newSearchResultHit(hit *elastic.SearchHit) *SearchResultHit {
+	resp := &SearchResultHit{
+		ID:        hit.Id,
+		Source:    hit.Source,
+		Index:     hit.Index,
+		Type:      hit.Type,
+		Sort:      hit.Sort,
+		Highlight: hit.Highlight,
+	}
+	if hit.Score != nil {
+		resp.Score = *hit.Score
+	}
+	if hit.Version != nil {
+		resp.Version = int(*hit.Version)
+	}
+	return resp
+}
+
+// Unmarshal decodes the document into v.
+func (h *SearchResultHit) Unmarshal(v interface{}) error {
+	return unmarshalSource(h.ID, h.Source, v)
+}
+
+// unmarshalSource decodes a document, which Elasticsearch leaves out if
+// asked to or if the type's mapping doesn't keep it.
+func unmarshalSource(id string, src *json.RawMessage, v interface{}) error {
+	if src == nil {
+		return fmt.Errorf("document %s has no source", id)
+	}
+	return json.Unmarshal(*src, v)
 }
 
 type SearchResult struct {
 	totalHits int64 // total number of hits overall
 	hits      []*SearchResultHit
 	Found     bool
+
+	// Aggregations holds the results of any aggregations requested with the
+	// search; read them with e.g. Aggregations.Terms(name).
+	Aggregations elastic.Aggregations
 }
 
 func NewSearchResult(searchResult *elastic.SearchResult) *SearchResult {
@@ -39,14 +96,12 @@ func NewSearchResult(searchResult *elastic.SearchResult) *SearchResult {
 		totalHits: totalHits,
 		hits:      make([]*SearchResultHit, numHits),
 		Found:     true,
+
+		Aggregations: searchResult.Aggregations,
 	}
 
 	for i, hit := range searchResult.Hits.Hits {
-		tmp := &SearchResultHit{
-			ID:     hit.Id,
-			Source: hit.Source,
-		}
-		resp.hits[i] = tmp
+		resp.hits[i] = newSearchResultHit(hit)
 	}
 
 	return resp
@@ -69,6 +124,26 @@ func (r *SearchResult) GetHit(i int) *SearchResultHit {
 	return (*arr)[i]
 }
 
+// DecodeInto decodes the documents found, in order, into v, which must
+// point to a slice:
+//
+//	var objs []Obj
+//	err := result.DecodeInto(&objs)
+func (r *SearchResult) DecodeInto(v interface{}) error {
+	sources := make([]*json.RawMessage, len(r.hits))
+	for i, hit := range r.hits {
+		if hit.Source == nil {
+			return fmt.Errorf("document %s has no source", hit.ID)
+		}
+		sources[i] = hit.Source
+	}
+	byts, err := json.Marshal(sources)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(byts, v)
+}
+
 type IndexResponse struct {
 	Created bool
 	ID      string
@@ -88,6 +163,19 @@ func NewIndexResponse(indexResponse *elastic.IndexResponse) *IndexResponse {
 	return resp
 }
 
+// NewUpdateResponse converts the result of a partial update, which reports
+// the same things as indexing does.
+func NewUpdateResponse(updateResponse *elastic.UpdateResponse) *IndexResponse {
+	resp := &IndexResponse{
+		Created: updateResponse.Created,
+		ID:      updateResponse.Id,
+		Index:   updateResponse.Index,
+		Type:    updateResponse.Type,
+		Version: updateResponse.Version,
+	}
+	return resp
+}
+
 // DeleteResponse is the response when a deletion of a document or type occurs
 type DeleteResponse struct {
 	Found bool
@@ -104,16 +192,108 @@ func NewDeleteResponse(deleteResponse *elastic.DeleteResponse) *DeleteResponse {
 }
 
 type GetResult struct {
-	ID     string
-	Source *json.RawMessage
-	Found  bool
+	ID      string
+	Index   string
+	Type    string
+	Source  *json.RawMessage
+	Found   bool
+	Version int
+
+	// Error is set, by GetByIDs, when the document couldn't be looked up.
+	Error string
 }
 
 func NewGetResult(getResult *elastic.GetResult) *GetResult {
 	resp := &GetResult{
 		ID:     getResult.Id,
+		Index:  getResult.Index,
+		Type:   getResult.Type,
 		Source: getResult.Source,
 		Found:  getResult.Found,
 	}
+	if getResult.Version != nil {
+		resp.Version = int(*getResult.Version)
+	}
+	if getResult.Error != nil {
+		resp.Error = getResult.Error.Reason
+	}
+	return resp
+}
+
+// Unmarshal decodes the document into v.
+func (r *GetResult) Unmarshal(v interface{}) error {
+	return unmarshalSource(r.ID, r.Source, v)
+}
+
+// BulkResponseItem is the result of a single operation within a bulk request.
+type BulkResponseItem struct {
+	Action  string
+	ID      string
+	Type    string
+	Version int
+	Status  int
+	Found   bool
+	Error   string
+}
+
+// Succeeded returns true if the operation completed with a 2xx status.
+func (item *BulkResponseItem) Succeeded() bool {
+	return item.Status >= 200 && item.Status <= 299
+}
+
+// BulkResponse holds the per-item results of a bulk request, in the order
+// the operations were submitted.
+type BulkResponse struct {
+	Errors bool
+	Items  []*BulkResponseItem
+}
+
+// NewBulkResponse is the initializing constructor for BulkResponse
+func NewBulkResponse(bulkResponse *elastic.BulkResponse) *BulkResponse {
+	resp := &BulkResponse{
+		Errors: bulkResponse.Errors,
+		Items:  make([]*BulkResponseItem, 0, len(bulkResponse.Items)),
+	}
+	for _, item := range bulkResponse.Items {
+		for action, result := range item {
+			tmp := &BulkResponseItem{
+				Action:  action,
+				ID:      result.Id,
+				Type:    result.Type,
+				Version: int(result.Version),
+				Status:  result.Status,
+				Found:   result.Found,
+			}
+			if result.Error != nil {
+				tmp.Error = result.Error.Reason
+			}
+			resp.Items = append(resp.Items, tmp)
+		}
+	}
 	return resp
 }
+
+// Failed returns the items of the bulk request that did not succeed.
+func (r *BulkResponse) Failed() []*BulkResponseItem {
+	failed := []*BulkResponseItem{}
+	for _, item := range r.Items {
+		if !item.Succeeded() {
+			failed = append(failed, item)
+		}
+	}
+	return failed
+}
+
+// PercolateResponseMatch identifies a registered percolation query which
+// matched a document.
+type PercolateResponseMatch struct {
+	Id    string
+	Index string
+}
+
+// PercolateResponse is the result of matching a document against the
+// registered percolation queries.
+type PercolateResponse struct {
+	Total   int64
+	Matches []*PercolateResponseMatch
+}
@@ -0,0 +1,386 @@
+// Copyright 2016, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elasticsearch
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A rolling index spreads documents that never stop coming, such as log
+// messages, over a series of generations, each an index of its own, so that
+// old ones can be dropped whole:
+//
+//	rolling, err := NewRollingIndex("pzlogger",
+//		&RollingSettings{Daily: true, RetentionDays: 30},
+//		NewIndexOpener(url, user, pass))
+//	writer, err := rolling.Current()
+//	rolled, err := rolling.Roll()     // from time to time, e.g. per batch
+//	deleted, err := rolling.Expire()  // from time to time, e.g. daily
+//	reader, err := rolling.Reader()
+//
+// Generations are named for the day they were started: pzlogger-2017.03.01,
+// or, when they are also limited in size, pzlogger-2017.03.01-000001. The
+// write alias, pzlogger-write, points at the newest generation only; the
+// read alias, pzlogger, points at them all. Searching Pattern() instead
+// finds generations whatever their aliases.
+
+const rollingDateLayout = "2006.01.02"
+
+// IndexOpener returns the named index, or alias, without creating it.
+type IndexOpener func(name string) (IIndex, error)
+
+// NewIndexOpener returns an IndexOpener for the Elasticsearch at url. At
+// most one IndexOptions may be given, as for NewIndex2.
+func NewIndexOpener(url, user, pass string, options ...*IndexOptions) IndexOpener {
+	return func(name string) (IIndex, error) {
+		return openIndex(url, user, pass, name, options...)
+	}
+}
+
+// RollingSettings says when a RollingIndex starts a new generation and how
+// long old ones are kept.
+type RollingSettings struct {
+	// Daily starts a new generation on each day, UTC.
+	Daily bool
+	// MaxDocs, if positive, starts a new generation once the current one
+	// holds that many documents.
+	MaxDocs int64
+	// RetentionDays, if positive, is the number of days a generation is
+	// kept once the next one has started.
+	RetentionDays int
+	// Settings, if not nil, are those each generation is created with,
+	// mappings included.
+	Settings *IndexSettings
+}
+
+// Validate checks that the settings roll the index one way or another.
+func (s *RollingSettings) Validate() error {
+	if !s.Daily && s.MaxDocs <= 0 {
+		return fmt.Errorf("rolling index must be daily, limited by MaxDocs, or both")
+	}
+	if s.MaxDocs < 0 {
+		return fmt.Errorf("MaxDocs must not be negative: %d", s.MaxDocs)
+	}
+	if s.RetentionDays < 0 {
+		return fmt.Errorf("RetentionDays must not be negative: %d", s.RetentionDays)
+	}
+	if s.Settings != nil {
+		return s.Settings.Validate()
+	}
+	return nil
+}
+
+// RollingIndex is a series of indices written through one alias and read
+// through another. It is safe for concurrent use.
+type RollingIndex struct {
+	name     string
+	settings RollingSettings
+	open     IndexOpener
+	now      func() time.Time
+
+	mu      sync.Mutex
+	current IIndex
+}
+
+type generation struct {
+	name string
+	date time.Time
+	seq  int
+}
+
+// NewRollingIndex returns the rolling index called name, whose generations
+// are opened with open. Nothing is created until it is first used.
+func NewRollingIndex(name string, settings *RollingSettings, open IndexOpener) (*RollingIndex, error) {
+	if name == "" {
+		return nil, fmt.Errorf("rolling index must have a name")
+	}
+	if err := settings.Validate(); err != nil {
+		return nil, err
+	}
+	return &RollingIndex{name: name, settings: *settings, open: open, now: time.Now}, nil
+}
+
+// ReadAlias returns the alias pointing at every generation.
+func (r *RollingIndex) ReadAlias() string {
+	return r.name
+}
+
+// WriteAlias returns the alias pointing at the current generation.
+func (r *RollingIndex) WriteAlias() string {
+	return r.name + "-write"
+}
+
+// Pattern returns the wildcard matching the names of the generations.
+func (r *RollingIndex) Pattern() string {
+	return r.name + "-*"
+}
+
+// Current returns the generation being written, starting the first one if
+// need be. It doesn't check whether the generation is due to be rolled.
+func (r *RollingIndex) Current() (IIndex, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.writer()
+}
+
+// Reader returns the read alias. Elasticsearch resolves an alias on every
+// request, but a MockIndex does so when opened, so under mocking a reader
+// should be opened afresh after a roll.
+func (r *RollingIndex) Reader() (IIndex, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.writer(); err != nil {
+		return nil, err
+	}
+	return r.open(r.ReadAlias())
+}
+
+// Roll starts a new generation if the current one is from an earlier day
+// or is full, and reports whether it did. Calls are serialized within the
+// process, but nothing coordinates them across processes, which could each
+// start a generation: only one writer process may call Roll.
+func (r *RollingIndex) Roll() (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	current, err := r.writer()
+	if err != nil {
+		return false, err
+	}
+	g, _ := r.parseGeneration(current.IndexName())
+
+	due := r.settings.Daily && !g.date.Equal(r.today())
+	if !due && r.settings.MaxDocs > 0 {
+		// a search of every type leaves out the percolation queries, under
+		// mocking as against Elasticsearch, so they aren't counted
+		resp, err := current.SearchByJSON("", `{"size":0}`)
+		if err != nil {
+			return false, err
+		}
+		due = resp.TotalHits() >= r.settings.MaxDocs
+	}
+	if !due {
+		return false, nil
+	}
+
+	next, err := r.start(r.next(g))
+	if err != nil {
+		return false, err
+	}
+	r.current = next
+	return true, nil
+}
+
+// Expire deletes the generations superseded more than RetentionDays ago
+// and returns their names. The current generation is never deleted, and
+// nothing is if RetentionDays is zero.
+func (r *RollingIndex) Expire() ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deleted := []string{}
+	if r.settings.RetentionDays == 0 {
+		return deleted, nil
+	}
+	gens, err := r.generations()
+	if err != nil {
+		return deleted, err
+	}
+
+	cutoff := r.today().AddDate(0, 0, -r.settings.RetentionDays)
+	for i := 0; i+1 < len(gens) && gens[i+1].date.Before(cutoff); i++ {
+		if r.current != nil && gens[i].name == r.current.IndexName() {
+			continue
+		}
+		esi, err := r.open(gens[i].name)
+		if err != nil {
+			return deleted, err
+		}
+		if err = esi.Delete(); err != nil {
+			return deleted, err
+		}
+		deleted = append(deleted, gens[i].name)
+	}
+	return deleted, nil
+}
+
+// Generations returns the names of the generations, oldest first.
+func (r *RollingIndex) Generations() ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	gens, err := r.generations()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(gens))
+	for i, g := range gens {
+		names[i] = g.name
+	}
+	return names, nil
+}
+
+// writer returns the current generation: the one the write alias points
+// at or, failing that, a new one. r.mu must be held.
+func (r *RollingIndex) writer() (IIndex, error) {
+	if r.current != nil {
+		return r.current, nil
+	}
+
+	admin, err := r.open(r.ReadAlias())
+	if err != nil {
+		return nil, err
+	}
+	holders, err := admin.AliasIndices(r.WriteAlias())
+	if err != nil {
+		return nil, err
+	}
+	if len(holders) == 1 {
+		if _, ok := r.parseGeneration(holders[0]); ok {
+			r.current, err = r.open(holders[0])
+			return r.current, err
+		}
+	}
+
+	g := generation{date: r.today()}
+	gens, err := r.generations()
+	if err != nil {
+		return nil, err
+	}
+	if len(gens) > 0 {
+		g = r.next(gens[len(gens)-1])
+	} else if r.settings.MaxDocs > 0 {
+		g.seq = 1
+	}
+	r.current, err = r.start(g)
+	return r.current, err
+}
+
+// start creates the generation, unless it exists already, and points both
+// aliases at it.
+func (r *RollingIndex) start(g generation) (IIndex, error) {
+	name := r.generationName(g)
+	esi, err := r.open(name)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := esi.IndexExists()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		settings := ""
+		if r.settings.Settings != nil {
+			settings, err = r.settings.Settings.JSON()
+			if err != nil {
+				return nil, err
+			}
+		}
+		if err = esi.Create(settings); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = esi.AddAlias(r.ReadAlias()); err != nil {
+		return nil, err
+	}
+	if err = esi.SwapAlias(r.WriteAlias()); err != nil {
+		return nil, err
+	}
+	return esi, nil
+}
+
+// generations returns the generations the read alias points at, oldest
+// first. r.mu must be held.
+func (r *RollingIndex) generations() ([]generation, error) {
+	admin, err := r.open(r.ReadAlias())
+	if err != nil {
+		return nil, err
+	}
+	names, err := admin.AliasIndices(r.ReadAlias())
+	if err != nil {
+		return nil, err
+	}
+
+	gens := []generation{}
+	for _, name := range names {
+		if g, ok := r.parseGeneration(name); ok {
+			gens = append(gens, g)
+		}
+	}
+	sort.Slice(gens, func(i, j int) bool {
+		if !gens[i].date.Equal(gens[j].date) {
+			return gens[i].date.Before(gens[j].date)
+		}
+		return gens[i].seq < gens[j].seq
+	})
+	return gens, nil
+}
+
+// next returns the generation that follows g, started today. Numbering
+// begins again each day for daily indices and carries on otherwise.
+func (r *RollingIndex) next(g generation) generation {
+	today := r.today()
+	switch {
+	case r.settings.MaxDocs <= 0:
+		return generation{date: today}
+	case r.settings.Daily && !g.date.Equal(today):
+		return generation{date: today, seq: 1}
+	}
+	return generation{date: today, seq: g.seq + 1}
+}
+
+func (r *RollingIndex) generationName(g generation) string {
+	name := r.name + "-" + g.date.Format(rollingDateLayout)
+	if g.seq > 0 {
+		name += fmt.Sprintf("-%06d", g.seq)
+	}
+	return name
+}
+
+// parseGeneration accepts names with or without a sequence number, so that
+// the settings can change between runs.
+func (r *RollingIndex) parseGeneration(name string) (generation, bool) {
+	rest := strings.TrimPrefix(name, r.name+"-")
+	if rest == name || len(rest) < len(rollingDateLayout) {
+		return generation{}, false
+	}
+	date, err := time.Parse(rollingDateLayout, rest[:len(rollingDateLayout)])
+	if err != nil {
+		return generation{}, false
+	}
+
+	g := generation{name: name, date: date}
+	if seq := rest[len(rollingDateLayout):]; seq != "" {
+		if !strings.HasPrefix(seq, "-") {
+			return generation{}, false
+		}
+		g.seq, err = strconv.Atoi(seq[1:])
+		if err != nil || g.seq <= 0 {
+			return generation{}, false
+		}
+	}
+	return g, true
+}
+
+func (r *RollingIndex) today() time.Time {
+	y, m, d := r.now().UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,106 @@
+// Copyright 2016, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elasticsearch
+
+import (
+	"io"
+)
+
+// DefaultScanPageSize is the number of documents a ScanIterator fetches per request.
+const DefaultScanPageSize = 500
+
+// scanPageFunc returns the page of hits which follows the cursor "after"
+// (nil for the first page), along with the cursor of the page's last hit.
+type scanPageFunc func(after []interface{}, size int) ([]*SearchResultHit, []interface{}, error)
+
+// ScanIterator walks every document matching a query, fetching a page at a
+// time with search_after, so that result sets of any size can be read
+// without guessing page counts. Next returns io.EOF when there are no more
+// documents.
+type ScanIterator struct {
+	fetch    scanPageFunc
+	pageSize int
+	after    []interface{}
+	page     []*SearchResultHit
+	pos      int
+	done     bool
+	err      error
+}
+
+func newScanIterator(fetch scanPageFunc) *ScanIterator {
+	return &ScanIterator{
+		fetch:    fetch,
+		pageSize: DefaultScanPageSize,
+	}
+}
+
+// PageSize sets the number of documents fetched per request.
+func (it *ScanIterator) PageSize(size int) *ScanIterator {
+	if size > 0 {
+		it.pageSize = size
+	}
+	return it
+}
+
+// Next returns the next hit, or io.EOF once the result set is exhausted.
+func (it *ScanIterator) Next() (*SearchResultHit, error) {
+	if it.pos >= len(it.page) {
+		if it.done {
+			return nil, io.EOF
+		}
+
+		hits, after, err := it.fetch(it.after, it.pageSize)
+		it.err = err
+		if err != nil {
+			return nil, err
+		}
+
+		it.page = hits
+		it.pos = 0
+		it.after = after
+		if len(hits) < it.pageSize {
+			it.done = true
+		}
+		if len(hits) == 0 {
+			return nil, io.EOF
+		}
+	}
+
+	hit := it.page[it.pos]
+	it.pos++
+	return hit, nil
+}
+
+// Err returns the error with which the last page failed to be fetched, if
+// the next one hasn't been since. A failed page is fetched again by the
+// next call of Next.
+func (it *ScanIterator) Err() error {
+	return it.err
+}
+
+// All drains the iterator and returns every remaining hit.
+func (it *ScanIterator) All() ([]*SearchResultHit, error) {
+	hits := []*SearchResultHit{}
+	for {
+		hit, err := it.Next()
+		if err == io.EOF {
+			return hits, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		hits = append(hits, hit)
+	}
+}
@@ -0,0 +1,507 @@
+// Copyright 2016, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elasticsearch
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/venicegeo/pz-gocommon/gocommon"
+)
+
+// IndexSettings are the settings, and optionally the mappings, an index is
+// created with. Its JSON is what Create and NewIndex2 take:
+//
+//	settings := NewIndexSettings().
+//		Shards(1).
+//		Replicas(0).
+//		RefreshInterval("5s").
+//		Analyzer("folded", "standard", "lowercase", "asciifolding").
+//		Mapping(NewMapping("LogData").Property(...))
+//	jsn, err := settings.JSON()
+//
+// See https://www.elastic.co/guide/en/elasticsearch/reference/5.5/index-modules.html.
+type IndexSettings struct {
+	shards          int
+	replicas        *int
+	refreshInterval string
+	analysis        map[string]map[string]interface{}
+	mappings        map[string]*Mapping
+	params          map[string]interface{}
+}
+
+// Sections of the analysis settings.
+const (
+	AnalysisAnalyzer   = "analyzer"
+	AnalysisTokenizer  = "tokenizer"
+	AnalysisFilter     = "filter"
+	AnalysisCharFilter = "char_filter"
+)
+
+// readOnlySettings are reported by Elasticsearch but can't be set.
+var readOnlySettings = map[string]bool{
+	"creation_date": true,
+	"provided_name": true,
+	"uuid":          true,
+	"version":       true,
+}
+
+// NewIndexSettings returns settings that leave everything at Elasticsearch's
+// defaults.
+func NewIndexSettings() *IndexSettings {
+	return &IndexSettings{}
+}
+
+// Shards sets the number of primary shards, which is fixed when the index
+// is created.
+func (s *IndexSettings) Shards(shards int) *IndexSettings {
+	s.shards = shards
+	return s
+}
+
+// Replicas sets the number of copies kept of each primary shard.
+func (s *IndexSettings) Replicas(replicas int) *IndexSettings {
+	s.replicas = &replicas
+	return s
+}
+
+// RefreshInterval sets how often changes are made visible to search, e.g.
+// "1s", or "-1" to refresh only when asked.
+func (s *IndexSettings) RefreshInterval(interval string) *IndexSettings {
+	s.refreshInterval = interval
+	return s
+}
+
+// Analyzer defines a custom analyzer from a tokenizer and token filters.
+func (s *IndexSettings) Analyzer(name string, tokenizer string, filters ...string) *IndexSettings {
+	def := map[string]interface{}{"type": "custom", "tokenizer": tokenizer}
+	if len(filters) > 0 {
+		def["filter"] = filters
+	}
+	return s.Analysis(AnalysisAnalyzer, name, def)
+}
+
+// Analysis defines a component of the given section of the analysis
+// settings: an analyzer, tokenizer, filter or char_filter.
+func (s *IndexSettings) Analysis(section string, name string, definition interface{}) *IndexSettings {
+	if s.analysis == nil {
+		s.analysis = make(map[string]map[string]interface{})
+	}
+	if s.analysis[section] == nil {
+		s.analysis[section] = make(map[string]interface{})
+	}
+	s.analysis[section][name] = definition
+	return s
+}
+
+// Mapping adds the mapping of a type, to be created with the index.
+func (s *IndexSettings) Mapping(mapping *Mapping) *IndexSettings {
+	if s.mappings == nil {
+		s.mappings = make(map[string]*Mapping)
+	}
+	s.mappings[mapping.Name()] = mapping
+	return s
+}
+
+// Param sets an index setting IndexSettings doesn't model, named without
+// the "index." prefix, e.g. "max_result_window".
+func (s *IndexSettings) Param(name string, value interface{}) *IndexSettings {
+	if s.params == nil {
+		s.params = make(map[string]interface{})
+	}
+	s.params[name] = value
+	return s
+}
+
+// GetShards returns the number of primary shards; 0 if not set.
+func (s *IndexSettings) GetShards() int {
+	return s.shards
+}
+
+// GetReplicas returns the number of replicas and whether it was set.
+func (s *IndexSettings) GetReplicas() (int, bool) {
+	if s.replicas == nil {
+		return 0, false
+	}
+	return *s.replicas, true
+}
+
+// GetRefreshInterval returns the refresh interval; "" if not set.
+func (s *IndexSettings) GetRefreshInterval() string {
+	return s.refreshInterval
+}
+
+// GetAnalysis returns the definition of a component of the analysis settings.
+func (s *IndexSettings) GetAnalysis(section string, name string) (interface{}, bool) {
+	def, ok := s.analysis[section][name]
+	return def, ok
+}
+
+// GetParam returns a setting IndexSettings doesn't model.
+func (s *IndexSettings) GetParam(name string) (interface{}, bool) {
+	value, ok := s.params[name]
+	return value, ok
+}
+
+// GetMappings returns the mappings, sorted by type name.
+func (s *IndexSettings) GetMappings() []*Mapping {
+	names := make([]string, 0, len(s.mappings))
+	for name := range s.mappings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	mappings := make([]*Mapping, len(names))
+	for i, name := range names {
+		mappings[i] = s.mappings[name]
+	}
+	return mappings
+}
+
+// Validate checks the settings for values Elasticsearch would reject.
+func (s *IndexSettings) Validate() error {
+	if s.shards < 0 {
+		return fmt.Errorf("settings: number of shards may not be negative")
+	}
+	if s.replicas != nil && *s.replicas < 0 {
+		return fmt.Errorf("settings: number of replicas may not be negative")
+	}
+	for section, defs := range s.analysis {
+		switch section {
+		case AnalysisAnalyzer, AnalysisTokenizer, AnalysisFilter, AnalysisCharFilter:
+		default:
+			return fmt.Errorf("settings: unknown analysis section %s", section)
+		}
+		for name := range defs {
+			if name == "" {
+				return fmt.Errorf("settings: %s name may not be empty", section)
+			}
+		}
+	}
+	for name := range s.params {
+		if strings.HasPrefix(name, "index.") {
+			return fmt.Errorf("settings: %s should be named without the index. prefix", name)
+		}
+	}
+	for _, m := range s.GetMappings() {
+		if err := m.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexSource returns the index-level settings, without the mappings.
+func (s *IndexSettings) indexSource() map[string]interface{} {
+	src := make(map[string]interface{})
+	for k, v := range s.params {
+		src[k] = v
+	}
+	if s.shards > 0 {
+		src["number_of_shards"] = s.shards
+	}
+	if s.replicas != nil {
+		src["number_of_replicas"] = *s.replicas
+	}
+	if s.refreshInterval != "" {
+		src["refresh_interval"] = s.refreshInterval
+	}
+	if len(s.analysis) > 0 {
+		analysis := make(map[string]interface{})
+		for section, defs := range s.analysis {
+			analysis[section] = defs
+		}
+		src["analysis"] = analysis
+	}
+	return src
+}
+
+// mappingsSource returns the mappings, keyed by type name.
+func (s *IndexSettings) mappingsSource() (map[string]interface{}, error) {
+	src := make(map[string]interface{})
+	for name, m := range s.mappings {
+		body, err := m.Source()
+		if err != nil {
+			return nil, err
+		}
+		src[name] = body.(map[string]interface{})[name]
+	}
+	return src, nil
+}
+
+// Source returns the body of a create index request.
+func (s *IndexSettings) Source() (interface{}, error) {
+	err := s.Validate()
+	if err != nil {
+		return nil, err
+	}
+	src := map[string]interface{}{"settings": s.indexSource()}
+	if len(s.mappings) > 0 {
+		src["mappings"], err = s.mappingsSource()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return src, nil
+}
+
+// JSON returns the settings as Create takes them.
+func (s *IndexSettings) JSON() (string, error) {
+	src, err := s.Source()
+	if err != nil {
+		return "", err
+	}
+	byts, err := json.Marshal(src)
+	if err != nil {
+		return "", err
+	}
+	return string(byts), nil
+}
+
+// updateSource returns the body of an update settings request. Only some
+// settings can be changed once the index exists.
+func (s *IndexSettings) updateSource() (interface{}, error) {
+	err := s.Validate()
+	if err != nil {
+		return nil, err
+	}
+	if s.shards != 0 {
+		return nil, fmt.Errorf("settings: the number of shards can't be changed")
+	}
+	if len(s.mappings) > 0 {
+		return nil, fmt.Errorf("settings: mappings are changed with SetMapping, not UpdateSettings")
+	}
+	return map[string]interface{}{"index": s.indexSource()}, nil
+}
+
+// merge overlays the index-level settings of o on those of s, as
+// Elasticsearch does when several templates, and then the create request,
+// apply to an index. Mappings are left to the caller, since they are merged
+// field by field.
+func (s *IndexSettings) merge(o *IndexSettings) {
+	if o.shards != 0 {
+		s.shards = o.shards
+	}
+	if o.replicas != nil {
+		s.Replicas(*o.replicas)
+	}
+	if o.refreshInterval != "" {
+		s.refreshInterval = o.refreshInterval
+	}
+	for section, defs := range o.analysis {
+		for name, def := range defs {
+			s.Analysis(section, name, def)
+		}
+	}
+	for name, value := range o.params {
+		s.Param(name, value)
+	}
+}
+
+// ParseIndexSettings reads the settings Elasticsearch reports for an index,
+// in nested or flat form, with or without the "settings" and "index"
+// wrappers. Read-only settings, such as the creation date, are dropped.
+func ParseIndexSettings(settings interface{}) (*IndexSettings, error) {
+	byts, err := json.Marshal(settings)
+	if err != nil {
+		return nil, err
+	}
+	obj := map[string]interface{}{}
+	err = json.Unmarshal(byts, &obj)
+	if err != nil {
+		return nil, err
+	}
+	if inner, ok := obj["settings"].(map[string]interface{}); ok && len(obj) == 1 {
+		obj = inner
+	}
+	obj = expandSettings(obj)
+	if inner, ok := obj["index"].(map[string]interface{}); ok && len(obj) == 1 {
+		obj = inner
+	}
+
+	s := NewIndexSettings()
+	for k, v := range obj {
+		switch k {
+		case "number_of_shards", "number_of_replicas":
+			n, err := settingsInt(v)
+			if err != nil {
+				return nil, fmt.Errorf("settings: %s: %s", k, err.Error())
+			}
+			if k == "number_of_shards" {
+				s.Shards(n)
+			} else {
+				s.Replicas(n)
+			}
+		case "refresh_interval":
+			s.RefreshInterval(fmt.Sprintf("%v", v))
+		case "analysis":
+			sections, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("settings: malformed analysis: %v", v)
+			}
+			for section, defs := range sections {
+				m, ok := defs.(map[string]interface{})
+				if !ok {
+					return nil, fmt.Errorf("settings: malformed analysis %s: %v", section, defs)
+				}
+				for name, def := range m {
+					s.Analysis(section, name, def)
+				}
+			}
+		default:
+			if !readOnlySettings[k] {
+				s.Param(k, v)
+			}
+		}
+	}
+	return s, nil
+}
+
+// expandSettings turns flat settings, such as "index.number_of_shards",
+// into nested ones.
+func expandSettings(obj map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{})
+	expandSettingsInto(out, obj)
+	return out
+}
+
+func expandSettingsInto(dst map[string]interface{}, obj map[string]interface{}) {
+	child := func(m map[string]interface{}, name string) map[string]interface{} {
+		next, ok := m[name].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			m[name] = next
+		}
+		return next
+	}
+
+	for k, v := range obj {
+		parts := strings.Split(k, ".")
+		d := dst
+		for _, part := range parts[:len(parts)-1] {
+			d = child(d, part)
+		}
+		last := parts[len(parts)-1]
+		if m, ok := v.(map[string]interface{}); ok {
+			expandSettingsInto(child(d, last), m)
+		} else {
+			d[last] = v
+		}
+	}
+}
+
+// settingsInt reads a number, which Elasticsearch reports as a string.
+func settingsInt(v interface{}) (int, error) {
+	switch v := v.(type) {
+	case float64:
+		if v == float64(int(v)) {
+			return int(v), nil
+		}
+	case string:
+		return strconv.Atoi(v)
+	}
+	return 0, fmt.Errorf("not an integer: %v", v)
+}
+
+//---------------------------------------------------------------------------
+
+// IndexTemplate holds settings, mappings and aliases which are applied to
+// every index created with a name matching its pattern, such as the daily
+// indices of a log. When several templates match, those of higher order
+// override those of lower.
+type IndexTemplate struct {
+	pattern  string
+	order    int
+	settings *IndexSettings
+	aliases  []string
+}
+
+// NewIndexTemplate returns a template applying settings to the indices
+// whose names match pattern, in which '*' matches any run of characters.
+func NewIndexTemplate(pattern string, settings *IndexSettings) *IndexTemplate {
+	if settings == nil {
+		settings = NewIndexSettings()
+	}
+	return &IndexTemplate{pattern: pattern, settings: settings}
+}
+
+// Order sets the precedence of the template.
+func (t *IndexTemplate) Order(order int) *IndexTemplate {
+	t.order = order
+	return t
+}
+
+// Alias adds aliases the indices are given.
+func (t *IndexTemplate) Alias(aliases ...string) *IndexTemplate {
+	t.aliases = append(t.aliases, aliases...)
+	return t
+}
+
+// Matches reports whether the template applies to the named index.
+func (t *IndexTemplate) Matches(index string) bool {
+	parts := strings.Split(t.pattern, "*")
+	if len(parts) == 1 {
+		return index == t.pattern
+	}
+	if !strings.HasPrefix(index, parts[0]) {
+		return false
+	}
+	index = index[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(index, part)
+		if i < 0 {
+			return false
+		}
+		index = index[i+len(part):]
+	}
+	return strings.HasSuffix(index, parts[len(parts)-1])
+}
+
+// Source returns the body of a put template request.
+func (t *IndexTemplate) Source() (interface{}, error) {
+	if t.pattern == "" {
+		return nil, fmt.Errorf("template: pattern may not be empty")
+	}
+	body, err := t.settings.Source()
+	if err != nil {
+		return nil, err
+	}
+	src := body.(map[string]interface{})
+	src["template"] = t.pattern
+	src["order"] = t.order
+	if len(t.aliases) > 0 {
+		aliases := make(map[string]interface{})
+		for _, alias := range t.aliases {
+			aliases[alias] = map[string]interface{}{}
+		}
+		src["aliases"] = aliases
+	}
+	return src, nil
+}
+
+// JSON returns the template as PutTemplate sends it.
+func (t *IndexTemplate) JSON() (piazza.JsonString, error) {
+	src, err := t.Source()
+	if err != nil {
+		return "", err
+	}
+	byts, err := json.Marshal(src)
+	if err != nil {
+		return "", err
+	}
+	return piazza.JsonString(byts), nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2016, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elasticsearch
+
+import (
+	"encoding/json"
+
+	"github.com/venicegeo/pz-gocommon/elasticsearch/elastic-5-api"
+)
+
+// Suggestion is what a suggester, as given to IIndex.Suggest, makes of one
+// term of its text, for term suggesters, or of the text as a whole, for
+// phrase and completion suggesters. A search box autocompletes with e.g.
+//
+//	elastic.NewCompletionSuggester("names").Field("suggest").Prefix(typed)
+//
+// over a field mapped as MappingElementTypeCompletion.
+type Suggestion struct {
+	// Text is the part of the text the suggestion is for, found at Offset
+	// and Length characters long.
+	Text   string
+	Offset int
+	Length int
+
+	// Options are the suggestions made, best first. There are none if the
+	// suggester found nothing better than Text.
+	Options []*SuggestionOption
+}
+
+// SuggestionOption is one suggested term, phrase or completion.
+type SuggestionOption struct {
+	// Text is the term or phrase suggested, or the input completed.
+	Text string
+	// Highlighted is Text with its corrections marked, if a phrase
+	// suggester was asked to.
+	Highlighted string
+	Score       float64
+	// Freq is the number of documents a suggested term occurs in.
+	Freq int
+
+	// ID, Type and Source are those of the document a completion came
+	// from.
+	ID     string
+	Type   string
+	Source *json.RawMessage
+}
+
+// Unmarshal decodes the document a completion came from into v.
+func (o *SuggestionOption) Unmarshal(v interface{}) error {
+	return unmarshalSource(o.ID, o.Source, v)
+}
+
+func newSuggestions(entries []elastic.SearchSuggestion) []*Suggestion {
+	suggestions := make([]*Suggestion, len(entries))
+	for i, entry := range entries {
+		s := &Suggestion{
+			Text:    entry.Text,
+			Offset:  entry.Offset,
+			Length:  entry.Length,
+			Options: make([]*SuggestionOption, len(entry.Options)),
+		}
+		for j, option := range entry.Options {
+			s.Options[j] = &SuggestionOption{
+				Text:        option.Text,
+				Highlighted: option.Highlighted,
+				Score:       option.Score,
+				Freq:        option.Freq,
+				ID:          option.Id,
+				Type:        option.Type,
+				Source:      option.Source,
+			}
+		}
+		suggestions[i] = s
+	}
+	return suggestions
+}
@@ -17,15 +17,18 @@
 package elasticsearch
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
 	"sort"
 	"strings"
 	"testing"
 	"time"
 
-	"gopkg.in/olivere/elastic.v5"
+	"github.com/venicegeo/pz-gocommon/elasticsearch/elastic-5-api"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
@@ -101,7 +104,7 @@ func (suite *EsTester) SetUpIndex() elasticsearch.IIndex {
 	var ok bool
 
 	{
-		esi, err = elasticsearch.NewIndex2(suite.url, "estest$"+uniq(), "")
+		esi, err = elasticsearch.NewIndex2(suite.url, "", "", "estest$"+uniq(), "")
 		assert.NoError(err)
 
 		_ = esi.Delete()
@@ -170,7 +173,7 @@ func deleteOldIndexes(es *elastic.Client) {
 	log.Printf("%d indexes", len(s))
 
 	del := func(nam string) {
-		ret, err := es.DeleteIndex(nam).Do()
+		ret, err := es.DeleteIndex(nam).Do(context.Background())
 		if err != nil {
 			log.Printf("%s: %s", nam, err.Error())
 		} else {
@@ -193,7 +196,7 @@ func (suite *EsTester) Test01Client() {
 	t := suite.T()
 	assert := assert.New(t)
 
-	esi, err := elasticsearch.NewIndex2(suite.url, "estest01$", "")
+	esi, err := elasticsearch.NewIndex2(suite.url, "", "", "estest01$", "")
 	assert.NoError(err)
 
 	version := esi.GetVersion()
@@ -578,7 +581,7 @@ func (suite *EsTester) Test08Percolation() {
 	defer closer(t, esi)
 
 	items := make(map[string]elasticsearch.MappingElementTypeName)
-	items["tag"] = elasticsearch.MappingElementTypeString
+	items["tag"] = elasticsearch.MappingElementTypeText
 	jsonstr, err := elasticsearch.ConstructMappingSchema("Event", items)
 	assert.NoError(err)
 	assert.NotEmpty(jsonstr)
@@ -660,7 +663,7 @@ func (suite *EsTester) Test09FullPercolation() {
 	{
 		var err error
 
-		esi, err = elasticsearch.NewIndex2(suite.url, "estest09$"+uniq(), "")
+		esi, err = elasticsearch.NewIndex2(suite.url, "", "", "estest09$"+uniq(), "")
 		assert.NoError(err)
 
 		defer closer(t, esi)
@@ -732,12 +735,12 @@ func (suite *EsTester) Test09FullPercolation() {
 
 	maps := map[string](map[string]elasticsearch.MappingElementTypeName){
 		"EventType1": map[string]elasticsearch.MappingElementTypeName{
-			"id":  elasticsearch.MappingElementTypeString,
-			"str": elasticsearch.MappingElementTypeString,
+			"id":  elasticsearch.MappingElementTypeText,
+			"str": elasticsearch.MappingElementTypeText,
 			"num": elasticsearch.MappingElementTypeInteger,
 		},
 		"EventType2": map[string]elasticsearch.MappingElementTypeName{
-			"id":  elasticsearch.MappingElementTypeString,
+			"id":  elasticsearch.MappingElementTypeText,
 			"boo": elasticsearch.MappingElementTypeBool,
 			"num": elasticsearch.MappingElementTypeInteger,
 		},
@@ -785,8 +788,8 @@ func (suite *EsTester) Test09FullPercolation() {
 	q5 :=
 		`{
 			"query" : {
-				"filtered": {
-					"query": {
+				"bool": {
+					"must": {
 						"match": {
 							"num": 17
 						}
@@ -849,13 +852,32 @@ func (suite *EsTester) Test09FullPercolation() {
 	}
 
 	addEvents(tests)
+
+	// a search of every type finds neither the percolated documents nor
+	// the queries, which only a search of their type does
+	err := esi.Refresh()
+	assert.NoError(err)
+	all, err := esi.FilterByMatchAll("", nil)
+	assert.NoError(err)
+	assert.EqualValues(0, all.TotalHits())
+	all, err = esi.SearchByJSON("", `{"query": {"match_all": {}}}`)
+	assert.NoError(err)
+	assert.EqualValues(0, all.TotalHits())
+	it, err := esi.Scan("", nil)
+	assert.NoError(err)
+	hits, err := it.All()
+	assert.NoError(err)
+	assert.Len(hits, 0)
+	all, err = esi.FilterByMatchAll("percolate", nil)
+	assert.NoError(err)
+	assert.EqualValues(len(queries), all.TotalHits())
 }
 
 func (suite *EsTester) Test10GetAll() {
 	t := suite.T()
 	assert := assert.New(t)
 
-	esi, err := elasticsearch.NewIndex2(suite.url, "getall$", "")
+	esi, err := elasticsearch.NewIndex2(suite.url, "", "", "getall$", "")
 	assert.NoError(err)
 	defer closer(t, esi)
 
@@ -1095,3 +1117,240 @@ func (suite *EsTester) Test11Pagination() {
 		assert.Equal("id5_"+p, getResult.GetHit(2).ID)
 	}
 }
+
+func (suite *EsTester) Test12Scan() {
+	t := suite.T()
+	assert := assert.New(t)
+
+	esi := suite.SetUpIndex()
+	assert.NotNil(esi)
+	defer closer(t, esi)
+
+	ids := func(it *elasticsearch.ScanIterator) []string {
+		hits, err := it.All()
+		assert.NoError(err)
+		assert.NoError(it.Err())
+		ids := []string{}
+		for _, hit := range hits {
+			ids = append(ids, hit.ID)
+		}
+		sort.Strings(ids)
+		return ids
+	}
+
+	// a page size that doesn't divide the hits, one that does, and one
+	// bigger than them all
+	for _, size := range []int{2, 1, 10} {
+		it, err := esi.Scan(objType, nil)
+		assert.NoError(err)
+		assert.Equal([]string{"id0", "id1", "id2"}, ids(it.PageSize(size)))
+	}
+
+	it, err := esi.Scan(objType, elastic.NewTermQuery("tags", "foo"))
+	assert.NoError(err)
+	assert.Equal([]string{"id0", "id2"}, ids(it.PageSize(1)))
+
+	it, err = esi.Scan("", nil)
+	assert.NoError(err)
+	assert.Equal([]string{"id0", "id1", "id2"}, ids(it))
+
+	it, err = esi.Scan(objType, elastic.NewTermQuery("tags", "quux"))
+	assert.NoError(err)
+	hit, err := it.Next()
+	assert.Equal(io.EOF, err)
+	assert.Nil(hit)
+}
+
+func (suite *EsTester) Test13TypedErrors() {
+	t := suite.T()
+	assert := assert.New(t)
+
+	esi := suite.SetUpIndex()
+	assert.NotNil(esi)
+	defer closer(t, esi)
+
+	_, err := esi.GetByID(objType, "nosuch")
+	assert.True(elasticsearch.IsDocumentNotFound(err))
+	assert.Equal(http.StatusNotFound, elasticsearch.StatusCode(err))
+	_, err = esi.DeleteByID(objType, "nosuch")
+	assert.True(elasticsearch.IsDocumentNotFound(err))
+	_, err = esi.GetMapping("NoSuchType")
+	assert.True(elasticsearch.IsTypeNotFound(err))
+
+	_, err = esi.CreateData(objType, "id0", objs[0])
+	assert.True(elasticsearch.IsConflict(err))
+	assert.Equal(http.StatusConflict, elasticsearch.StatusCode(err))
+
+	got, err := esi.GetByID(objType, "id0")
+	assert.NoError(err)
+	_, err = esi.PutDataIfVersion(objType, "id0", objs[0], got.Version+1)
+	assert.True(elasticsearch.IsConflict(err))
+	_, err = esi.PutDataIfVersion(objType, "id0", objs[0], got.Version)
+	assert.NoError(err)
+
+	// a string field can't become a number in place
+	err = esi.SetMapping(objType, `{"Obj":{"properties":{"id":{"type":"integer"}}}}`)
+	assert.True(elasticsearch.IsInvalidMapping(err))
+	assert.Equal(http.StatusBadRequest, elasticsearch.StatusCode(err))
+
+	gone, err := elasticsearch.NewIndex2(suite.url, "", "", "estest$"+uniq(), "")
+	assert.NoError(err)
+	err = gone.Delete()
+	assert.NoError(err)
+	_, err = gone.GetByID(objType, "id0")
+	assert.True(elasticsearch.IsIndexNotFound(err))
+	assert.Equal(http.StatusNotFound, elasticsearch.StatusCode(err))
+}
+
+func (suite *EsTester) Test14Aliases() {
+	t := suite.T()
+	assert := assert.New(t)
+
+	alias := "estest.alias" + uniq()
+
+	src, err := elasticsearch.NewIndex2(suite.url, "", "", elasticsearch.BackingIndexName(alias, 1), "")
+	assert.NoError(err)
+	defer closer(t, src)
+	err = src.SetMapping(objType, objMapping)
+	assert.NoError(err)
+	for _, o := range objs {
+		_, err = src.PostData(objType, o.ID, o)
+		assert.NoError(err)
+	}
+	err = src.Refresh()
+	assert.NoError(err)
+
+	err = src.AddAlias(alias)
+	assert.NoError(err)
+	aliases, err := src.GetAliases()
+	assert.NoError(err)
+	assert.Equal([]string{alias}, aliases)
+	indices, err := src.AliasIndices(alias)
+	assert.NoError(err)
+	assert.Equal([]string{src.IndexName()}, indices)
+
+	// documents can be read through the alias
+	view, err := elasticsearch.NewIndex2(suite.url, "", "", alias, "")
+	assert.NoError(err)
+	got, err := view.GetByID(objType, "id1")
+	assert.NoError(err)
+	assert.True(got.Found)
+	assert.Equal(src.IndexName(), got.Index)
+
+	name, err := elasticsearch.NextBackingIndexName(src, alias)
+	assert.NoError(err)
+	assert.Equal(elasticsearch.BackingIndexName(alias, 2), name)
+	dst, err := elasticsearch.NewIndex2(suite.url, "", "", name, "")
+	assert.NoError(err)
+	defer closer(t, dst)
+
+	n, err := elasticsearch.Reindex(alias, src, dst)
+	assert.NoError(err)
+	assert.Equal(len(objs), n)
+	indices, err = src.AliasIndices(alias)
+	assert.NoError(err)
+	assert.Equal([]string{name}, indices)
+	aliases, err = src.GetAliases()
+	assert.NoError(err)
+	assert.Len(aliases, 0)
+
+	got, err = view.GetByID(objType, "id1")
+	assert.NoError(err)
+	assert.True(got.Found)
+	assert.Equal(name, got.Index)
+	ok, err := dst.TypeExists(objType)
+	assert.NoError(err)
+	assert.True(ok)
+
+	err = dst.RemoveAlias(alias)
+	assert.NoError(err)
+	indices, err = dst.AliasIndices(alias)
+	assert.NoError(err)
+	assert.Len(indices, 0)
+}
+
+func (suite *EsTester) Test15ByQuery() {
+	t := suite.T()
+	assert := assert.New(t)
+
+	esi := suite.SetUpIndex()
+	assert.NotNil(esi)
+	defer closer(t, esi)
+
+	task, err := esi.DeleteByQuery(objType, elastic.NewTermQuery("tags", "foo"))
+	assert.NoError(err)
+	assert.NotEmpty(task.ID)
+	status, err := task.Wait()
+	assert.NoError(err)
+	assert.True(status.Completed)
+	assert.EqualValues(2, status.Total)
+	assert.EqualValues(2, status.Deleted)
+	assert.Len(status.Failures, 0)
+
+	err = esi.Refresh()
+	assert.NoError(err)
+	result, err := esi.FilterByMatchAll(objType, nil)
+	assert.NoError(err)
+	assert.EqualValues(1, result.TotalHits())
+	assert.Equal("id1", result.GetHit(0).ID)
+
+	script := elastic.NewScript("ctx._source.data = 'updated'")
+	task, err = esi.UpdateByQuery(objType, nil, script)
+	assert.NoError(err)
+	status, err = task.Wait()
+	assert.NoError(err)
+	assert.True(status.Completed)
+	assert.EqualValues(1, status.Updated)
+	status, err = task.Status()
+	assert.NoError(err)
+	assert.True(status.Completed)
+
+	err = esi.Refresh()
+	assert.NoError(err)
+	result, err = esi.FilterByTermQuery(objType, "data", "updated", nil)
+	assert.NoError(err)
+	assert.EqualValues(1, result.TotalHits())
+
+	// without a script, documents are reindexed as they are
+	task, err = esi.UpdateByQuery("", nil, nil)
+	assert.NoError(err)
+	status, err = task.Wait()
+	assert.NoError(err)
+	assert.EqualValues(1, status.Updated)
+}
+
+func (suite *EsTester) Test16MultiGetAndSearch() {
+	t := suite.T()
+	assert := assert.New(t)
+
+	esi := suite.SetUpIndex()
+	assert.NotNil(esi)
+	defer closer(t, esi)
+
+	results, err := esi.GetByIDs(objType, []string{"id2", "nosuch", "id0"})
+	assert.NoError(err)
+	assert.Len(results, 3)
+	assert.True(results[0].Found)
+	assert.Equal("id2", results[0].ID)
+	assert.False(results[1].Found)
+	assert.True(results[2].Found)
+	assert.Equal("id0", results[2].ID)
+	var obj Obj
+	err = results[2].Unmarshal(&obj)
+	assert.NoError(err)
+	assert.Equal(objs[0], obj)
+
+	items, err := esi.MultiSearch([]*elasticsearch.SearchRequest{
+		{Type: objType, Query: elastic.NewTermQuery("tags", "foo")},
+		{Type: objType},
+		{Type: objType, Query: elastic.NewTermQuery("tags", "quux")},
+	})
+	assert.NoError(err)
+	assert.Len(items, 3)
+	assert.Empty(items[0].Error)
+	assert.EqualValues(2, items[0].Result.TotalHits())
+	assert.Empty(items[1].Error)
+	assert.EqualValues(len(objs), items[1].Result.TotalHits())
+	assert.Empty(items[2].Error)
+	assert.EqualValues(0, items[2].Result.TotalHits())
+}
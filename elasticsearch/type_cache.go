@@ -0,0 +1,62 @@
+// Copyright 2016, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elasticsearch
+
+import "sync"
+
+// typeCache remembers the types an Index has seen to exist, so that writes
+// and searches needn't ask Elasticsearch each time. Elasticsearch 5 can't
+// delete a type short of deleting its index, so an entry only goes stale
+// along with the index; the cache is cleared when the Index deletes it or
+// Elasticsearch reports it missing, as it does to writes only if it isn't
+// allowed to create indices automatically; see Index.
+//
+// The cache is shared by the views WithContext returns. A nil typeCache, as
+// in an Index built by hand, remembers nothing.
+type typeCache struct {
+	sync.Mutex
+	types map[string]bool
+}
+
+func newTypeCache() *typeCache {
+	return &typeCache{types: map[string]bool{}}
+}
+
+func (c *typeCache) has(typ string) bool {
+	if c == nil {
+		return false
+	}
+	c.Lock()
+	defer c.Unlock()
+	return c.types[typ]
+}
+
+func (c *typeCache) add(typ string) {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.types[typ] = true
+}
+
+func (c *typeCache) clear() {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.types = map[string]bool{}
+}
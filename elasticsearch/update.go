@@ -0,0 +1,62 @@
+// Copyright 2016, RadiantBlue Technologies, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package elasticsearch
+
+import (
+	"fmt"
+
+	"github.com/venicegeo/pz-gocommon/elasticsearch/elastic-5-api"
+)
+
+// Update describes a partial update of a stored document; see IIndex.UpdateData.
+// Exactly one of Doc and Script must be given.
+type Update struct {
+	// Doc is merged into the stored document.
+	Doc interface{}
+
+	// Script modifies the stored document, which it sees as ctx._source.
+	Script *elastic.Script
+
+	// Upsert is stored as the document if it does not exist yet.
+	Upsert interface{}
+
+	// DocAsUpsert stores Doc as the document if it does not exist yet.
+	DocAsUpsert bool
+
+	// Version, if not zero, is the version the stored document must have
+	// for the update to succeed.
+	Version int
+
+	// RetryOnConflict is how many times Elasticsearch retries the update if
+	// the document changes while it is being applied. It can't be
+	// combined with Version.
+	RetryOnConflict int
+}
+
+func (u *Update) validate() error {
+	if u == nil {
+		return fmt.Errorf("UpdateData: update may not be nil")
+	}
+	if (u.Doc == nil) == (u.Script == nil) {
+		return fmt.Errorf("UpdateData: exactly one of Doc and Script must be given")
+	}
+	if u.DocAsUpsert && u.Doc == nil {
+		return fmt.Errorf("UpdateData: DocAsUpsert requires Doc")
+	}
+	if u.Version != 0 && u.RetryOnConflict != 0 {
+		return fmt.Errorf("UpdateData: Version and RetryOnConflict can't be combined")
+	}
+	return nil
+}
@@ -633,3 +633,67 @@ func Test13MultiWriter(t *testing.T) {
 	err = mw.Close()
 	assert.NoError(err)
 }
+
+func Test14ElasticsearchWriterCreateType(t *testing.T) {
+	assert := assert.New(t)
+	var err error
+
+	esi := elasticsearch.NewMockIndex("test14")
+	err = esi.Create("")
+	assert.NoError(err)
+	ew := NewElasticWriter(esi, "Baz")
+
+	v1 := `{"properties": {"message": {"type": "text"}}}`
+	exists, err := ew.CreateType(v1)
+	assert.NoError(err)
+	assert.False(exists)
+	exists, err = ew.CreateType(v1)
+	assert.NoError(err)
+	assert.True(exists)
+
+	// adding a field updates the mapping in place
+	v2 := `{"properties": {"message": {"type": "text"}, "severity": {"type": "integer"}}}`
+	_, err = ew.CreateType(v2)
+	assert.NoError(err)
+	mapping, err := esi.GetMapping("Baz")
+	assert.NoError(err)
+	m, err := elasticsearch.ParseMapping("Baz", mapping)
+	assert.NoError(err)
+	assert.Len(m.Properties(), 2)
+
+	// changing a field's type is refused
+	_, err = ew.CreateType(`{"properties": {"message": {"type": "keyword"}}}`)
+	assert.Error(err)
+}
+
+// a Logger can take the logs and metrics of an elasticsearch index
+var _ elasticsearch.Logger = (*Logger)(nil)
+var _ elasticsearch.MetricLogger = (*Logger)(nil)
+
+func Test15InstrumentedIndexMetrics(t *testing.T) {
+	assert := assert.New(t)
+	var err error
+
+	logWriter := &LocalReaderWriter{}
+	logger := NewLogger(logWriter, &LocalReaderWriter{}, "testapp", "123456")
+
+	esi := elasticsearch.NewInstrumentedIndex(elasticsearch.NewMockIndex("test15"), logger)
+	err = esi.Create("")
+	assert.NoError(err)
+	_, err = esi.GetByID("Baz", "nope")
+	assert.Error(err)
+
+	err = esi.LogMetrics()
+	assert.NoError(err)
+	mssgs, err := logWriter.Read(100)
+	assert.NoError(err)
+	assert.Len(mssgs, 14)
+	for _, m := range mssgs {
+		assert.NotNil(m.MetricData)
+		assert.EqualValues("test15", m.MetricData.Object)
+	}
+	assert.EqualValues("elasticsearch.Create.calls", mssgs[0].MetricData.Name)
+	assert.EqualValues(1, mssgs[0].MetricData.Value)
+	assert.EqualValues("elasticsearch.GetByID.errors", mssgs[8].MetricData.Name)
+	assert.EqualValues(1, mssgs[8].MetricData.Value)
+}
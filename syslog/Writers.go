@@ -20,7 +20,7 @@ import (
 	"io"
 	"log"
 	"os"
-	"reflect"
+	"strings"
 
 	"github.com/venicegeo/pz-gocommon/elasticsearch"
 	piazza "github.com/venicegeo/pz-gocommon/gocommon"
@@ -427,6 +427,9 @@ func (w *ElasticWriter) CreateIndex() (bool, error) {
 	return exists, nil
 }
 
+// CreateType creates the writer's type with the given mapping or, if the
+// type exists, brings its mapping up to date. Only changes Elasticsearch can
+// make in place are allowed; anything else needs the index rebuilt.
 func (w *ElasticWriter) CreateType(mapping string) (bool, error) {
 	if w == nil || w.Esi == nil || w.typ == "" {
 		return false, fmt.Errorf("writer not set not set")
@@ -435,19 +438,23 @@ func (w *ElasticWriter) CreateType(mapping string) (bool, error) {
 	if err != nil {
 		return exists, err
 	}
-	if exists {
-		var currentMapping, newMapi interface{}
-		if currentMapping, err = w.Esi.GetMapping(w.typ); err != nil {
-			return exists, err
-		}
-		if newMapi, err = piazza.StructStringToInterface(mapping); err != nil {
-			return exists, err
-		}
-		newMap := map[string]interface{}{w.typ: newMapi}
-		if !reflect.DeepEqual(currentMapping, newMap) {
-			return exists, errors.New("Elasticsearch contains an invalid mapping for type: " + w.typ)
-		}
-	} else {
+	if !exists {
+		return exists, w.Esi.SetMapping(w.typ, piazza.JsonString(mapping))
+	}
+
+	wanted, err := elasticsearch.ParseMapping(w.typ, piazza.JsonString(mapping))
+	if err != nil {
+		return exists, err
+	}
+	diff, err := elasticsearch.DiffMapping(w.Esi, wanted)
+	if err != nil {
+		return exists, err
+	}
+	switch diff.Change {
+	case elasticsearch.MappingIncompatible:
+		return exists, errors.New("Elasticsearch contains an incompatible mapping for type " + w.typ +
+			": " + strings.Join(diff.Conflicts, "; "))
+	case elasticsearch.MappingAdditive:
 		return exists, w.Esi.SetMapping(w.typ, piazza.JsonString(mapping))
 	}
 	return exists, nil